@@ -0,0 +1,178 @@
+// Команда shortener-migrate переносит ссылки, пользователей и пометки удаления
+// из одного хранилища сервиса в другое.
+//
+// Пример:
+//
+//	shortener-migrate -from file -from-location storage.json -to sqlite -to-location shortener.db -verify
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/repository"
+)
+
+// Значения по умолчанию
+const (
+	defaultCheckpointPath = "shortener-migrate.checkpoint"
+	defaultBatchSize      = 1000
+)
+
+// Ошибки
+var (
+	// ErrUsage ошибка некорректных аргументов команды
+	ErrUsage = errors.New("usage: shortener-migrate -from <type> -from-location <location> -to <type> -to-location <location> [-checkpoint <path>] [-batch-size <n>] [-verify]")
+	// ErrSameStorage ошибка совпадения хранилища-источника и хранилища-назначения
+	ErrSameStorage = errors.New("source and destination are the same storage")
+	// ErrUnsupportedStorage ошибка хранилища, не поддерживающего перенос данных
+	ErrUnsupportedStorage = errors.New("storage does not support migration")
+)
+
+// storageArgs тип и расположение хранилища из аргументов командной строки
+type storageArgs struct {
+	storageType config.StorageType
+	location    string
+}
+
+// migrateArgs аргументы командной строки
+type migrateArgs struct {
+	from           storageArgs
+	to             storageArgs
+	checkpointPath string
+	batchSize      int
+	verify         bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[0], os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run разбирает аргументы, открывает хранилища и переносит данные
+func run(ctx context.Context, programName string, args []string, out io.Writer) error {
+	parsed, err := parseArgs(programName, args, out)
+	if err != nil {
+		return err
+	}
+
+	source, err := openStorage(ctx, parsed.from)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer source.Close()
+
+	target, err := openStorage(ctx, parsed.to)
+	if err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+	defer target.Close()
+
+	m := &migrator{
+		source:            source,
+		target:            target,
+		sourceFingerprint: fingerprint(parsed.from.storageType.String(), parsed.from.location),
+		targetFingerprint: fingerprint(parsed.to.storageType.String(), parsed.to.location),
+		fs:                afero.NewOsFs(),
+		checkpointPath:    parsed.checkpointPath,
+		batchSize:         parsed.batchSize,
+		verify:            parsed.verify,
+		out:               out,
+	}
+
+	return m.run(ctx)
+}
+
+// parseArgs разбирает и проверяет аргументы командной строки
+func parseArgs(programName string, args []string, out io.Writer) (*migrateArgs, error) {
+	flags := flag.NewFlagSet(programName, flag.ContinueOnError)
+	flags.SetOutput(out)
+
+	var fromType, toType string
+	parsed := &migrateArgs{}
+	flags.StringVar(&fromType, "from", "", "source storage type: file, database, sqlite, bolt or redis")
+	flags.StringVar(&parsed.from.location, "from-location", "", "source file path, DSN or address")
+	flags.StringVar(&toType, "to", "", "destination storage type: file, database, sqlite, bolt or redis")
+	flags.StringVar(&parsed.to.location, "to-location", "", "destination file path, DSN or address")
+	flags.StringVar(&parsed.checkpointPath, "checkpoint", defaultCheckpointPath, "checkpoint file path used to resume an interrupted migration")
+	flags.IntVar(&parsed.batchSize, "batch-size", defaultBatchSize, "number of entries imported per batch")
+	flags.BoolVar(&parsed.verify, "verify", false, "verify destination entries and counts after copying")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() > 0 || parsed.from.location == "" || parsed.to.location == "" ||
+		parsed.checkpointPath == "" || parsed.batchSize <= 0 {
+		return nil, ErrUsage
+	}
+
+	var err error
+	if parsed.from.storageType, err = parseStorageType(fromType); err != nil {
+		return nil, err
+	}
+
+	if parsed.to.storageType, err = parseStorageType(toType); err != nil {
+		return nil, err
+	}
+
+	if parsed.from == parsed.to {
+		return nil, ErrSameStorage
+	}
+
+	return parsed, nil
+}
+
+// parseStorageType возвращает тип постоянного хранилища по имени
+func parseStorageType(name string) (config.StorageType, error) {
+	storageType, err := config.ParseStorageType(name)
+	if err != nil {
+		return config.None, err
+	}
+
+	if storageType == config.None {
+		return config.None, ErrUnsupportedStorage
+	}
+
+	return storageType, nil
+}
+
+// migratableRepository репозиторий, поддерживающий перенос данных
+type migratableRepository interface {
+	repository.Repository
+	repository.EntryExporter
+	repository.EntryImporter
+}
+
+// openStorage открывает хранилище и проверяет, что оно поддерживает перенос данных
+func openStorage(ctx context.Context, args storageArgs) (migratableRepository, error) {
+	appConfig := config.NewConfig(config.WithStorageLocation(args.storageType, args.location))
+
+	repo, err := repository.NewAppRepository(ctx, appConfig, zap.NewNop())
+	if err != nil {
+		return nil, err
+	}
+
+	migratable, ok := repo.(migratableRepository)
+	if !ok {
+		repo.Close()
+		return nil, ErrUnsupportedStorage
+	}
+
+	return migratable, nil
+}
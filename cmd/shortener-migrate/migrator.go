@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/afero"
+
+	"github.com/rovany706/url-shortener/internal/repository"
+)
+
+// checkpointTmpSuffix суффикс временного файла при сохранении контрольной точки
+const checkpointTmpSuffix = ".tmp"
+
+// Ошибки
+var (
+	// ErrCheckpointMismatch ошибка контрольной точки, сохраненной для другой пары хранилищ
+	ErrCheckpointMismatch = errors.New("checkpoint belongs to another source or destination")
+	// ErrVerificationFailed ошибка расхождения данных хранилищ после переноса
+	ErrVerificationFailed = errors.New("destination does not match source")
+)
+
+// migrationSource хранилище, из которого переносятся данные
+type migrationSource interface {
+	repository.EntryExporter
+}
+
+// migrationTarget хранилище, в которое переносятся данные
+type migrationTarget interface {
+	repository.EntryExporter
+	repository.EntryImporter
+	// GetFullURL ищет в хранилище ссылку по короткому ID
+	GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *repository.ShortenedURLInfo, ok bool)
+}
+
+// checkpoint состояние переноса, позволяющее продолжить его после прерывания
+type checkpoint struct {
+	// Source отпечаток хранилища-источника
+	Source string `json:"source"`
+	// Target отпечаток хранилища-назначения
+	Target string `json:"target"`
+	// LastShortID наибольший перенесенный shortID
+	LastShortID string `json:"last_short_id"`
+	// Copied количество перенесенных ссылок
+	Copied int `json:"copied"`
+}
+
+// migrationStats количество ссылок в хранилище
+type migrationStats struct {
+	entries int
+	deleted int
+}
+
+// migrationUsers пользователи хранилища
+type migrationUsers struct {
+	lastUserID int
+	deleted    []int
+}
+
+// migrator переносит ссылки, пользователей и пометки удаления ссылок и пользователей между хранилищами.
+// Ссылки переносятся пакетами в порядке возрастания shortID, после каждого пакета
+// сохраняется контрольная точка, поэтому прерванный перенос продолжается с места остановки.
+// Повторная загрузка уже перенесенных ссылок не изменяет хранилище-назначение.
+type migrator struct {
+	source            migrationSource
+	target            migrationTarget
+	sourceFingerprint string
+	targetFingerprint string
+	fs                afero.Fs
+	checkpointPath    string
+	batchSize         int
+	verify            bool
+	out               io.Writer
+}
+
+// run выполняет перенос и, если задано, проверку хранилища-назначения
+func (m *migrator) run(ctx context.Context) error {
+	state, err := m.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	if state.Copied > 0 {
+		fmt.Fprintf(m.out, "resuming after %q, %d entries already copied\n", state.LastShortID, state.Copied)
+	}
+
+	batch := make([]repository.ShortenedURLInfo, 0, m.batchSize)
+	maxUserID := 0
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := m.target.ImportEntries(ctx, batch); err != nil {
+			return err
+		}
+
+		state.LastShortID = batch[len(batch)-1].ShortID
+		state.Copied += len(batch)
+		batch = batch[:0]
+
+		if err := m.saveCheckpoint(state); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(m.out, "copied %d entries\n", state.Copied)
+
+		return nil
+	}
+
+	err = m.source.ForEachEntry(ctx, state.LastShortID, func(info repository.ShortenedURLInfo) error {
+		batch = append(batch, info)
+		maxUserID = max(maxUserID, info.UserID)
+
+		if len(batch) < m.batchSize {
+			return nil
+		}
+
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = flush(); err != nil {
+		return err
+	}
+
+	lastUserID, err := m.source.LastUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = m.target.ReserveUserIDs(ctx, max(lastUserID, maxUserID)); err != nil {
+		return err
+	}
+
+	if err = m.copyDeletedUsers(ctx); err != nil {
+		return err
+	}
+
+	if m.verify {
+		if err = m.verifyTarget(ctx); err != nil {
+			return err
+		}
+	}
+
+	return m.removeCheckpoint()
+}
+
+// copyDeletedUsers переносит пометки удаления пользователей, чтобы их токены
+// оставались отозванными после переключения на хранилище-назначение
+func (m *migrator) copyDeletedUsers(ctx context.Context) error {
+	userIDs, err := m.source.DeletedUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	if importer, ok := m.target.(repository.UserImporter); ok {
+		if err = importer.ImportUsers(ctx, userIDs); err != nil {
+			return err
+		}
+	}
+
+	for _, userID := range userIDs {
+		if err = m.target.DeleteUser(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(m.out, "copied %d deleted users\n", len(userIDs))
+
+	return nil
+}
+
+// verifyTarget проверяет, что каждая ссылка источника сохранена в хранилище-назначении
+// с тем же владельцем и пометкой удаления, что удаленные пользователи источника
+// удалены в хранилище-назначении, и сравнивает количество ссылок и пользователей
+func (m *migrator) verifyTarget(ctx context.Context) error {
+	var sourceStats migrationStats
+	mismatches := 0
+
+	err := m.source.ForEachEntry(ctx, "", func(info repository.ShortenedURLInfo) error {
+		sourceStats.add(info)
+
+		saved, ok := m.target.GetFullURL(ctx, info.ShortID)
+		if !ok || *saved != info {
+			mismatches++
+			fmt.Fprintf(m.out, "mismatch: %s\n", info.ShortID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var targetStats migrationStats
+	err = m.target.ForEachEntry(ctx, "", func(info repository.ShortenedURLInfo) error {
+		targetStats.add(info)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sourceUsers, err := loadMigrationUsers(ctx, m.source)
+	if err != nil {
+		return err
+	}
+
+	targetUsers, err := loadMigrationUsers(ctx, m.target)
+	if err != nil {
+		return err
+	}
+
+	targetDeleted := make(map[int]struct{}, len(targetUsers.deleted))
+	for _, userID := range targetUsers.deleted {
+		targetDeleted[userID] = struct{}{}
+	}
+
+	for _, userID := range sourceUsers.deleted {
+		if _, ok := targetDeleted[userID]; !ok {
+			mismatches++
+			fmt.Fprintf(m.out, "mismatch: deleted user %d\n", userID)
+		}
+	}
+
+	fmt.Fprintf(m.out, "source: %d entries (%d deleted), last user ID %d, %d deleted users\n",
+		sourceStats.entries, sourceStats.deleted, sourceUsers.lastUserID, len(sourceUsers.deleted))
+	fmt.Fprintf(m.out, "destination: %d entries (%d deleted), last user ID %d, %d deleted users\n",
+		targetStats.entries, targetStats.deleted, targetUsers.lastUserID, len(targetUsers.deleted))
+
+	if mismatches > 0 || targetStats.entries < sourceStats.entries || targetUsers.lastUserID < sourceUsers.lastUserID {
+		return fmt.Errorf("%w: %d mismatches", ErrVerificationFailed, mismatches)
+	}
+
+	fmt.Fprintln(m.out, "verification passed")
+
+	return nil
+}
+
+// loadMigrationUsers читает наибольший ID и удаленных пользователей хранилища
+func loadMigrationUsers(ctx context.Context, exporter repository.EntryExporter) (users migrationUsers, err error) {
+	if users.lastUserID, err = exporter.LastUserID(ctx); err != nil {
+		return migrationUsers{}, err
+	}
+
+	if users.deleted, err = exporter.DeletedUsers(ctx); err != nil {
+		return migrationUsers{}, err
+	}
+
+	return users, nil
+}
+
+func (s *migrationStats) add(info repository.ShortenedURLInfo) {
+	s.entries++
+	if info.IsDeleted {
+		s.deleted++
+	}
+}
+
+// loadCheckpoint читает контрольную точку или возвращает начальное состояние, если ее нет
+func (m *migrator) loadCheckpoint() (checkpoint, error) {
+	state := checkpoint{Source: m.sourceFingerprint, Target: m.targetFingerprint}
+
+	data, err := afero.ReadFile(m.fs, m.checkpointPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return checkpoint{}, err
+	}
+
+	var saved checkpoint
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return checkpoint{}, err
+	}
+
+	if saved.Source != state.Source || saved.Target != state.Target {
+		return checkpoint{}, ErrCheckpointMismatch
+	}
+
+	return saved, nil
+}
+
+// saveCheckpoint атомарно сохраняет контрольную точку
+func (m *migrator) saveCheckpoint(state checkpoint) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := m.checkpointPath + checkpointTmpSuffix
+	if err = afero.WriteFile(m.fs, tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	return m.fs.Rename(tmpPath, m.checkpointPath)
+}
+
+// removeCheckpoint удаляет контрольную точку завершенного переноса
+func (m *migrator) removeCheckpoint() error {
+	err := m.fs.Remove(m.checkpointPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// fingerprint возвращает отпечаток хранилища для контрольной точки,
+// не раскрывающий строку подключения
+func fingerprint(storageType string, location string) string {
+	sum := sha256.Sum256([]byte(storageType + ":" + location))
+
+	return hex.EncodeToString(sum[:])
+}
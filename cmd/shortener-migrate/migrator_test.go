@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/repository"
+)
+
+const (
+	testSourcePath     = "/data/storage.json"
+	testCheckpointPath = "/data/migrate.checkpoint"
+)
+
+var errImportFailed = errors.New("import failed")
+
+// failingTarget хранилище-назначение в памяти, возвращающее ошибку
+// после failAfter успешных вызовов ImportEntries
+type failingTarget struct {
+	*repository.MemoryRepository
+	failAfter int
+	imports   int
+}
+
+func (t *failingTarget) ImportEntries(ctx context.Context, entries []repository.ShortenedURLInfo) error {
+	if t.failAfter >= 0 && t.imports >= t.failAfter {
+		return errImportFailed
+	}
+	t.imports++
+
+	return t.MemoryRepository.ImportEntries(ctx, entries)
+}
+
+// newTestSource создает файловое хранилище с 10 ссылками трех пользователей,
+// две из которых помечены удаленными, и удаленным пользователем без ссылок
+func newTestSource(t *testing.T, fs afero.Fs) *repository.FileRepository {
+	ctx := context.Background()
+
+	source, err := repository.NewFileRepository(fs, testSourcePath)
+	require.NoError(t, err)
+	t.Cleanup(func() { source.Close() })
+
+	for i := 0; i < 10; i++ {
+		userID := i%3 + 1
+		if i < 4 {
+			_, err = source.GetNewUserID(ctx)
+			require.NoError(t, err)
+		}
+
+		shortID := fmt.Sprintf("id%02d", i)
+		require.NoError(t, source.SaveEntry(ctx, userID, shortID, "https://example.com/"+shortID))
+	}
+
+	err = source.DeleteUserURLs(ctx, []models.UserDeleteRequest{
+		{UserID: 1, ShortIDToDelete: "id00"},
+		{UserID: 2, ShortIDToDelete: "id04"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, source.DeleteUser(ctx, 4))
+
+	return source
+}
+
+func newTestMigrator(fs afero.Fs, source migrationSource, target migrationTarget, out *bytes.Buffer) *migrator {
+	return &migrator{
+		source:            source,
+		target:            target,
+		sourceFingerprint: fingerprint("file", testSourcePath),
+		targetFingerprint: fingerprint("memory", ""),
+		fs:                fs,
+		checkpointPath:    testCheckpointPath,
+		batchSize:         3,
+		verify:            true,
+		out:               out,
+	}
+}
+
+func collectEntries(t *testing.T, exporter repository.EntryExporter) []repository.ShortenedURLInfo {
+	var entries []repository.ShortenedURLInfo
+	err := exporter.ForEachEntry(context.Background(), "", func(info repository.ShortenedURLInfo) error {
+		entries = append(entries, info)
+		return nil
+	})
+	require.NoError(t, err)
+
+	return entries
+}
+
+func TestMigratorRun(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	source := newTestSource(t, fs)
+	target := &failingTarget{MemoryRepository: repository.NewMemoryRepository(), failAfter: -1}
+
+	var out bytes.Buffer
+	require.NoError(t, newTestMigrator(fs, source, target, &out).run(ctx))
+
+	assert.Equal(t, collectEntries(t, source), collectEntries(t, target))
+	assert.Contains(t, out.String(), "source: 10 entries (2 deleted), last user ID 4, 1 deleted users")
+	assert.Contains(t, out.String(), "verification passed")
+
+	deletedUsers, err := target.DeletedUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, deletedUsers)
+
+	userID, err := target.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, userID)
+
+	exists, err := afero.Exists(fs, testCheckpointPath)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// повторный перенос не изменяет хранилище-назначение
+	require.NoError(t, newTestMigrator(fs, source, target, &out).run(ctx))
+	assert.Equal(t, collectEntries(t, source), collectEntries(t, target))
+}
+
+func TestMigratorResume(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	source := newTestSource(t, fs)
+	target := &failingTarget{MemoryRepository: repository.NewMemoryRepository(), failAfter: 2}
+
+	var out bytes.Buffer
+	err := newTestMigrator(fs, source, target, &out).run(ctx)
+	require.ErrorIs(t, err, errImportFailed)
+	assert.Len(t, collectEntries(t, target), 6)
+
+	data, err := afero.ReadFile(fs, testCheckpointPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"last_short_id":"id05"`)
+	assert.Contains(t, string(data), `"copied":6`)
+
+	target.failAfter = -1
+	out.Reset()
+	require.NoError(t, newTestMigrator(fs, source, target, &out).run(ctx))
+
+	assert.Equal(t, 4, target.imports, "only the remaining batches are imported")
+	assert.Contains(t, out.String(), `resuming after "id05", 6 entries already copied`)
+	assert.Equal(t, collectEntries(t, source), collectEntries(t, target))
+}
+
+func TestMigratorDeletedUsersToDatabase(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	source := newTestSource(t, fs)
+	// удаленный пользователь 4 без ссылок не последний выданный
+	_, err := source.GetNewUserID(ctx)
+	require.NoError(t, err)
+
+	// в БД пользователь должен быть сохранен до пометки удаления
+	storage, err := repository.NewSQLiteRepository(ctx, filepath.Join(t.TempDir(), "storage.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+	target := storage.(*repository.SQLiteRepository)
+
+	var out bytes.Buffer
+	require.NoError(t, newTestMigrator(fs, source, target, &out).run(ctx))
+	assert.Contains(t, out.String(), "copied 1 deleted users")
+	assert.Contains(t, out.String(), "destination: 10 entries (2 deleted), last user ID 5, 1 deleted users")
+
+	deletedUsers, err := target.DeletedUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, deletedUsers)
+}
+
+func TestMigratorCheckpointMismatch(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	source := newTestSource(t, fs)
+	target := &failingTarget{MemoryRepository: repository.NewMemoryRepository(), failAfter: -1}
+
+	require.NoError(t, afero.WriteFile(fs, testCheckpointPath, []byte(`{"source":"other","target":"other","last_short_id":"id05","copied":6}`), 0644))
+
+	var out bytes.Buffer
+	err := newTestMigrator(fs, source, target, &out).run(context.Background())
+	assert.ErrorIs(t, err, ErrCheckpointMismatch)
+	assert.Empty(t, collectEntries(t, target))
+}
+
+func TestMigratorVerificationFailed(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	source := newTestSource(t, fs)
+	target := &failingTarget{MemoryRepository: repository.NewMemoryRepository(), failAfter: -1}
+
+	// полная ссылка уже сокращена в хранилище-назначении под другим shortID
+	require.NoError(t, target.SaveEntry(ctx, 7, "taken", "https://example.com/id03"))
+
+	var out bytes.Buffer
+	err := newTestMigrator(fs, source, target, &out).run(ctx)
+	assert.ErrorIs(t, err, ErrVerificationFailed)
+	assert.Contains(t, out.String(), "mismatch: id03")
+
+	exists, err := afero.Exists(fs, testCheckpointPath)
+	require.NoError(t, err)
+	assert.True(t, exists, "checkpoint is kept after a failed verification")
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    *migrateArgs
+		wantErr error
+	}{
+		{
+			"file to sqlite",
+			[]string{"-from", "file", "-from-location", "storage.json", "-to", "sqlite", "-to-location", "shortener.db", "-verify"},
+			&migrateArgs{
+				from:           storageArgs{config.File, "storage.json"},
+				to:             storageArgs{config.SQLite, "shortener.db"},
+				checkpointPath: defaultCheckpointPath,
+				batchSize:      defaultBatchSize,
+				verify:         true,
+			},
+			nil,
+		},
+		{
+			"custom checkpoint and batch size",
+			[]string{"-from", "bolt", "-from-location", "a.bolt", "-to", "redis", "-to-location", "localhost:6379", "-checkpoint", "c.json", "-batch-size", "10"},
+			&migrateArgs{
+				from:           storageArgs{config.Bolt, "a.bolt"},
+				to:             storageArgs{config.Redis, "localhost:6379"},
+				checkpointPath: "c.json",
+				batchSize:      10,
+			},
+			nil,
+		},
+		{
+			"missing location",
+			[]string{"-from", "file", "-to", "sqlite", "-to-location", "shortener.db"},
+			nil,
+			ErrUsage,
+		},
+		{
+			"invalid batch size",
+			[]string{"-from", "file", "-from-location", "storage.json", "-to", "sqlite", "-to-location", "shortener.db", "-batch-size", "0"},
+			nil,
+			ErrUsage,
+		},
+		{
+			"unknown storage type",
+			[]string{"-from", "mongodb", "-from-location", "mongodb://localhost", "-to", "sqlite", "-to-location", "shortener.db"},
+			nil,
+			config.ErrInvalidStorageType,
+		},
+		{
+			"memory storage",
+			[]string{"-from", "memory", "-from-location", "-", "-to", "sqlite", "-to-location", "shortener.db"},
+			nil,
+			ErrUnsupportedStorage,
+		},
+		{
+			"same storage",
+			[]string{"-from", "file", "-from-location", "storage.json", "-to", "file", "-to-location", "storage.json"},
+			nil,
+			ErrSameStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := parseArgs("shortener-migrate", tt.args, &out)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
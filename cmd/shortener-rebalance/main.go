@@ -0,0 +1,89 @@
+// Команда shortener-rebalance переносит ссылки между шардами БД после добавления шардов.
+//
+// Новые шарды добавляются в конец списка. Перенос выполняется до перезапуска сервиса
+// с новым списком шардов и повторяется после перезапуска, чтобы перенести ссылки,
+// сохраненные на прежние шарды в промежутке; повторный запуск безопасен.
+//
+// Пример:
+//
+//	shortener-rebalance -shards postgresql://shard1/db,postgresql://shard2/db,postgresql://shard3/db
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/repository"
+)
+
+const defaultBatchSize = 1000
+
+// Ошибки
+var (
+	// ErrUsage ошибка некорректных аргументов команды
+	ErrUsage = errors.New("usage: shortener-rebalance -shards <dsn>,<dsn>[,...] [-batch-size <n>]")
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[0], os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run разбирает аргументы, подключается к шардам и переносит ссылки
+func run(ctx context.Context, programName string, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(programName, flag.ContinueOnError)
+	flags.SetOutput(out)
+
+	var shards string
+	var batchSize int
+	flags.StringVar(&shards, "shards", os.Getenv("DATABASE_SHARD_DSNS"), "comma-separated database shard DSNs in the order used by the service")
+	flags.IntVar(&batchSize, "batch-size", defaultBatchSize, "number of entries moved per batch")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if shards == "" || batchSize <= 0 || flags.NArg() > 0 {
+		return ErrUsage
+	}
+
+	appConfig := config.NewConfig(
+		config.WithDatabaseShards(strings.Split(shards, ",")),
+		config.WithStorageType(config.Database),
+	)
+
+	repo, err := repository.NewAppRepository(ctx, appConfig, zap.NewNop())
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	sharded, ok := repo.(*repository.ShardedRepository)
+	if !ok {
+		return ErrUsage
+	}
+
+	stats, err := sharded.Rebalance(ctx, batchSize)
+	fmt.Fprintf(out, "moved %d entries, purged %d from previous shards\n", stats.Moved, stats.Purged)
+	if len(stats.Conflicts) > 0 {
+		fmt.Fprintf(out, "kept %d entries on previous shards because their shards already store another entry: %s\n",
+			len(stats.Conflicts), strings.Join(stats.Conflicts, ", "))
+	}
+
+	return err
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"go.uber.org/zap"
 
@@ -13,6 +17,14 @@ import (
 var appConfig *config.AppConfig
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == migrateCommand {
+		if err := runMigrate(context.Background(), os.Args[0], os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var err error
 	appConfig, err = config.ParseArgs(os.Args[0], os.Args[1:])
 
@@ -39,5 +51,8 @@ func main() {
 func run(server *server.Server) error {
 	defer server.StopServer()
 
-	return server.RunServer()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return server.RunServer(ctx)
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/rovany706/url-shortener/internal/database"
+)
+
+const migrateCommand = "migrate"
+
+// Ошибки
+var (
+	// ErrMigrateUsage ошибка некорректных аргументов команды migrate
+	ErrMigrateUsage = errors.New("usage: migrate [-d DSN] up | down | to <version> | version")
+)
+
+// runMigrate выполняет команду migrate: применяет, откатывает миграции схемы БД
+// или переводит схему к указанной версии
+func runMigrate(ctx context.Context, programName string, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(programName+" "+migrateCommand, flag.ContinueOnError)
+	flags.SetOutput(out)
+
+	var dsn string
+	flags.StringVar(&dsn, "d", os.Getenv("DATABASE_DSN"), "database DSN")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if dsn == "" || flags.NArg() == 0 {
+		return ErrMigrateUsage
+	}
+
+	db, err := database.InitConnection(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.DBConnection.Close()
+
+	migrator, err := database.NewMigrator(db.DBConnection)
+	if err != nil {
+		return err
+	}
+
+	switch command := flags.Arg(0); {
+	case command == "up" && flags.NArg() == 1:
+		err = migrator.Up(ctx)
+	case command == "down" && flags.NArg() == 1:
+		err = migrator.Down(ctx)
+	case command == "to" && flags.NArg() == 2:
+		target, parseErr := strconv.ParseInt(flags.Arg(1), 10, 64)
+		if parseErr != nil {
+			return ErrMigrateUsage
+		}
+		err = migrator.To(ctx, target)
+	case command == "version" && flags.NArg() == 1:
+	default:
+		return ErrMigrateUsage
+	}
+
+	if err != nil {
+		return err
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "schema version: %d (latest: %d)\n", version, migrator.LatestVersion())
+
+	return nil
+}
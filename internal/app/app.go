@@ -6,17 +6,32 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 
 	"github.com/rovany706/url-shortener/internal/repository"
 )
 
 const shortHashByteCount = 4
 
+// maxShortIDAttempts количество вариантов короткого ID ссылки, перебираемых при коллизиях хешей
+const maxShortIDAttempts = 8
+
+// ErrShortIDCollision ошибка: все варианты короткого ID ссылки заняты другими ссылками
+var ErrShortIDCollision = errors.New("short id collision")
+
 // URLShortener интерфейс сокращателя ссылок
 type URLShortener interface {
 	GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *repository.ShortenedURLInfo, ok bool)
-	GetShortID(ctx context.Context, userID int, fullURL string) (shortID string, err error)
-	GetShortIDBatch(ctx context.Context, userID int, fullURLs []string) (shortIDs []string, err error)
+	GetShortID(ctx context.Context, userID int, domain string, fullURL string) (shortID string, err error)
+	GetShortIDBatch(ctx context.Context, userID int, domain string, fullURLs []string) (results []ShortIDResult, err error)
+}
+
+// ShortIDResult результат сокращения ссылки из набора
+type ShortIDResult struct {
+	// ShortID ключ ссылки (см. DomainShortID)
+	ShortID string
+	// Conflict ссылка уже была сокращена ранее
+	Conflict bool
 }
 
 // URLShortenerApp реализует интерфейс URLShortener
@@ -33,64 +48,122 @@ func NewURLShortenerApp(repository repository.Repository) *URLShortenerApp {
 	return &app
 }
 
-// GetFullURL возвращает полную ссылку по короткому id и флаг успеха операции.
+// GetFullURL возвращает полную ссылку по ключу ссылки (см. DomainShortID) и флаг успеха операции.
 func (app *URLShortenerApp) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *repository.ShortenedURLInfo, ok bool) {
 	return app.repository.GetFullURL(ctx, shortID)
 }
 
-// GetShortID возвращает первые 4 байта sha1-хеша ссылки в виде строки.
-func (app *URLShortenerApp) GetShortID(ctx context.Context, userID int, fullURL string) (shortID string, err error) {
+// GetShortID сокращает ссылку в пространстве имен домена domain (пустой - основной домен)
+// и возвращает ключ ссылки, короткий ID в котором - первые 4 байта sha1-хеша ссылки в виде строки.
+// Если короткий ID занят другой ссылкой, перебираются следующие варианты (см. shortIDCandidate).
+func (app *URLShortenerApp) GetShortID(ctx context.Context, userID int, domain string, fullURL string) (shortID string, err error) {
 	if _, err = url.ParseRequestURI(fullURL); err != nil {
 		return "", err
 	}
 
-	shortID = getShortSHA1Hash(fullURL, shortHashByteCount)
+	for attempt := 0; attempt < maxShortIDAttempts; attempt++ {
+		shortID = DomainShortID(domain, shortIDCandidate(fullURL, attempt))
 
-	if err := app.repository.SaveEntry(ctx, userID, shortID, fullURL); err != nil {
-		switch {
-		case errors.Is(err, repository.ErrConflict):
-			shortID, err = app.repository.GetShortID(ctx, fullURL)
-
-			if err != nil {
-				return "", err
-			}
+		err = app.repository.SaveEntry(ctx, userID, shortID, fullURL)
+		if err == nil {
+			return shortID, nil
+		}
+		if !errors.Is(err, repository.ErrConflict) {
+			return "", err
+		}
 
-			return shortID, repository.ErrConflict
-		default:
+		existingShortID, err := app.repository.GetShortID(ctx, domain, fullURL)
+		if err == nil {
+			return existingShortID, repository.ErrConflict
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
 			return "", err
 		}
+		// короткий ID занят другой ссылкой
 	}
 
-	return shortID, nil
+	return "", ErrShortIDCollision
 }
 
-// GetShortIDBatch возвращает короткие ID слайса ссылок.
-func (app *URLShortenerApp) GetShortIDBatch(ctx context.Context, userID int, fullURLs []string) (shortIDs []string, err error) {
-	shortIDs = make([]string, len(fullURLs))
-	for i, fullURL := range fullURLs {
+// GetShortIDBatch сокращает слайс ссылок в пространстве имен домена domain
+// и возвращает ключи ссылок в порядке ссылок.
+// Для уже сокращенных ранее ссылок возвращается существующий ключ с флагом Conflict,
+// ссылки, короткий ID которых занят другой ссылкой, сохраняются под следующим вариантом ID.
+func (app *URLShortenerApp) GetShortIDBatch(ctx context.Context, userID int, domain string, fullURLs []string) (results []ShortIDResult, err error) {
+	for _, fullURL := range fullURLs {
 		if _, err = url.ParseRequestURI(fullURL); err != nil {
 			return nil, err
 		}
+	}
 
-		shortID := getShortSHA1Hash(fullURL, shortHashByteCount)
-		shortIDs[i] = shortID
+	results = make([]ShortIDResult, len(fullURLs))
+	pending := make([]int, len(fullURLs))
+	for i := range fullURLs {
+		pending[i] = i
 	}
 
-	if err = app.saveBatch(ctx, userID, shortIDs, fullURLs); err != nil {
-		return nil, err
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt == maxShortIDAttempts {
+			return nil, ErrShortIDCollision
+		}
+
+		if pending, err = app.saveBatch(ctx, userID, domain, fullURLs, pending, attempt, results); err != nil {
+			return nil, err
+		}
 	}
 
-	return shortIDs, nil
+	return results, nil
 }
 
-func (app *URLShortenerApp) saveBatch(ctx context.Context, userID int, shortIDs []string, fullURLs []string) error {
-	shortURLMap := make(map[string]string, len(shortIDs))
+// saveBatch сохраняет ссылки fullURLs с индексами pending под вариантом attempt короткого ID
+// и заполняет их результаты. Возвращает индексы ссылок, вариант ID которых занят другой ссылкой.
+func (app *URLShortenerApp) saveBatch(ctx context.Context, userID int, domain string, fullURLs []string, pending []int, attempt int, results []ShortIDResult) (collisions []int, err error) {
+	batch := make(repository.URLMapping, len(pending))
+	// owners индексы ссылок набора по короткому ID
+	owners := make(map[string][]int, len(pending))
+	for _, i := range pending {
+		shortID := DomainShortID(domain, shortIDCandidate(fullURLs[i], attempt))
+		if fullURL, ok := batch[shortID]; ok && fullURL != fullURLs[i] {
+			collisions = append(collisions, i)
+			continue
+		}
+
+		batch[shortID] = fullURLs[i]
+		owners[shortID] = append(owners[shortID], i)
+		results[i] = ShortIDResult{ShortID: shortID}
+	}
+
+	conflicts, err := app.repository.SaveEntries(ctx, userID, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	for shortID, fullURL := range conflicts {
+		existingShortID, err := app.repository.GetShortID(ctx, domain, fullURL)
+		if errors.Is(err, repository.ErrNotFound) {
+			collisions = append(collisions, owners[shortID]...)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range owners[shortID] {
+			results[i] = ShortIDResult{ShortID: existingShortID, Conflict: true}
+		}
+	}
+
+	return collisions, nil
+}
 
-	for i := 0; i < len(shortIDs); i++ {
-		shortURLMap[shortIDs[i]] = fullURLs[i]
+// shortIDCandidate возвращает вариант attempt короткого ID ссылки: нулевой - первые 4 байта
+// sha1-хеша ссылки, следующие - первые 4 байта хеша ссылки с номером варианта
+func shortIDCandidate(fullURL string, attempt int) string {
+	if attempt == 0 {
+		return getShortSHA1Hash(fullURL, shortHashByteCount)
 	}
 
-	return app.repository.SaveEntries(ctx, userID, shortURLMap)
+	return getShortSHA1Hash(strconv.Itoa(attempt)+"\x00"+fullURL, shortHashByteCount)
 }
 
 func getShortSHA1Hash(value string, byteCount int) string {
@@ -72,6 +72,7 @@ func TestGetFullURL(t *testing.T) {
 func TestGetShortID(t *testing.T) {
 	tests := []struct {
 		name        string
+		domain      string
 		fullURL     string
 		wantShortID string
 		wantErr     bool
@@ -82,6 +83,13 @@ func TestGetShortID(t *testing.T) {
 			wantShortID: "488575e6",
 			wantErr:     false,
 		},
+		{
+			name:        "branded domain",
+			domain:      "go.brand.com",
+			fullURL:     "http://example.com/123",
+			wantShortID: "go.brand.com/488575e6",
+			wantErr:     false,
+		},
 		{
 			name:        "invalid url",
 			fullURL:     "http,,:example.com",
@@ -106,7 +114,7 @@ func TestGetShortID(t *testing.T) {
 			repository.EXPECT().SaveEntry(gomock.Any(), gomock.Any(), gomock.Any(), tt.fullURL).Return(nil).AnyTimes()
 
 			app := NewURLShortenerApp(repository)
-			shortID, err := app.GetShortID(ctx, 1, tt.fullURL)
+			shortID, err := app.GetShortID(ctx, 1, tt.domain, tt.fullURL)
 
 			if !tt.wantErr {
 				require.NoError(t, err)
@@ -119,6 +127,103 @@ func TestGetShortID(t *testing.T) {
 	}
 }
 
+func TestGetShortIDBatch(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock.NewMockRepository(ctrl)
+	repo.EXPECT().SaveEntries(gomock.Any(), 1, repository.URLMapping{
+		"488575e6": "http://example.com/123",
+		"74704cb5": "https://ya.ru",
+	}).Return(repository.URLMapping{"74704cb5": "https://ya.ru"}, nil)
+	repo.EXPECT().GetShortID(gomock.Any(), "", "https://ya.ru").Return("existing", nil)
+
+	app := NewURLShortenerApp(repo)
+	results, err := app.GetShortIDBatch(ctx, 1, "", []string{"http://example.com/123", "https://ya.ru"})
+	require.NoError(t, err)
+	assert.Equal(t, []ShortIDResult{
+		{ShortID: "488575e6"},
+		{ShortID: "existing", Conflict: true},
+	}, results)
+
+	_, err = app.GetShortIDBatch(ctx, 1, "", []string{"http,,:example.com"})
+	assert.Error(t, err)
+}
+
+// collidingURLs ссылки, первые 4 байта sha1-хешей которых совпадают (f31bfe85)
+var collidingURLs = []string{"http://example.com/48880", "http://example.com/78510"}
+
+func TestGetShortIDCollision(t *testing.T) {
+	ctx := context.Background()
+	app := NewURLShortenerApp(repository.NewMemoryRepository())
+
+	shortID, err := app.GetShortID(ctx, 1, "", collidingURLs[0])
+	require.NoError(t, err)
+	assert.Equal(t, "f31bfe85", shortID)
+
+	// короткий ID занят другой ссылкой: ссылка сохраняется под следующим вариантом
+	collidingShortID, err := app.GetShortID(ctx, 1, "", collidingURLs[1])
+	require.NoError(t, err)
+	assert.NotEqual(t, shortID, collidingShortID)
+
+	info, ok := app.GetFullURL(ctx, collidingShortID)
+	require.True(t, ok)
+	assert.Equal(t, collidingURLs[1], info.FullURL)
+
+	existingShortID, err := app.GetShortID(ctx, 2, "", collidingURLs[1])
+	assert.ErrorIs(t, err, repository.ErrConflict)
+	assert.Equal(t, collidingShortID, existingShortID)
+}
+
+func TestGetShortIDCollisionExhausted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock.NewMockRepository(ctrl)
+	repo.EXPECT().SaveEntry(gomock.Any(), 1, gomock.Any(), "https://ya.ru").Return(repository.ErrConflict).Times(maxShortIDAttempts)
+	repo.EXPECT().GetShortID(gomock.Any(), "", "https://ya.ru").Return("", repository.ErrNotFound).Times(maxShortIDAttempts)
+
+	_, err := NewURLShortenerApp(repo).GetShortID(context.Background(), 1, "", "https://ya.ru")
+	assert.ErrorIs(t, err, ErrShortIDCollision)
+}
+
+func TestGetShortIDBatchCollision(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("within batch", func(t *testing.T) {
+		app := NewURLShortenerApp(repository.NewMemoryRepository())
+
+		results, err := app.GetShortIDBatch(ctx, 1, "go.brand.com", collidingURLs)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.NotEqual(t, results[0].ShortID, results[1].ShortID)
+
+		for i, result := range results {
+			assert.False(t, result.Conflict)
+			info, ok := app.GetFullURL(ctx, result.ShortID)
+			require.True(t, ok)
+			assert.Equal(t, collidingURLs[i], info.FullURL)
+		}
+	})
+
+	t.Run("with saved link", func(t *testing.T) {
+		app := NewURLShortenerApp(repository.NewMemoryRepository())
+		shortID, err := app.GetShortID(ctx, 1, "", collidingURLs[0])
+		require.NoError(t, err)
+
+		results, err := app.GetShortIDBatch(ctx, 2, "", []string{collidingURLs[1], collidingURLs[0]})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+
+		assert.False(t, results[0].Conflict)
+		assert.NotEqual(t, shortID, results[0].ShortID)
+		info, ok := app.GetFullURL(ctx, results[0].ShortID)
+		require.True(t, ok)
+		assert.Equal(t, collidingURLs[1], info.FullURL)
+
+		assert.Equal(t, ShortIDResult{ShortID: shortID, Conflict: true}, results[1])
+	})
+}
+
 func BenchmarkGetShortID(b *testing.B) {
 	fullURL := "http://example.com"
 	ctx := context.Background()
@@ -130,6 +235,6 @@ func BenchmarkGetShortID(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		app.GetShortID(ctx, 1, fullURL)
+		app.GetShortID(ctx, 1, "", fullURL)
 	}
 }
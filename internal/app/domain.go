@@ -0,0 +1,28 @@
+package app
+
+import "strings"
+
+// domainSeparator разделяет домен и короткий ID в ключе ссылки
+const domainSeparator = "/"
+
+// DomainShortID возвращает ключ, под которым хранится ссылка с коротким ID shortID
+// в пространстве имен домена domain. Для основного домена (пустой domain) ключ совпадает
+// с коротким ID, поэтому ранее созданные ссылки остаются доступны.
+func DomainShortID(domain string, shortID string) string {
+	if domain == "" {
+		return shortID
+	}
+
+	return domain + domainSeparator + shortID
+}
+
+// SplitDomainShortID разбирает ключ ссылки на домен и короткий ID.
+// Для ссылок основного домена возвращается пустой domain.
+func SplitDomainShortID(key string) (domain string, shortID string) {
+	domain, shortID, ok := strings.Cut(key, domainSeparator)
+	if !ok {
+		return "", key
+	}
+
+	return domain, shortID
+}
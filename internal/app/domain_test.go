@@ -0,0 +1,31 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainShortID(t *testing.T) {
+	tests := []struct {
+		name    string
+		domain  string
+		shortID string
+		wantKey string
+	}{
+		{"default domain", "", "488575e6", "488575e6"},
+		{"branded domain", "go.brand.com", "488575e6", "go.brand.com/488575e6"},
+		{"domain with port", "links.example.org:8443", "488575e6", "links.example.org:8443/488575e6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := DomainShortID(tt.domain, tt.shortID)
+			assert.Equal(t, tt.wantKey, key)
+
+			domain, shortID := SplitDomainShortID(key)
+			assert.Equal(t, tt.domain, domain)
+			assert.Equal(t, tt.shortID, shortID)
+		})
+	}
+}
@@ -25,8 +25,8 @@ func (shortener *MockURLShortener) GetFullURL(ctx context.Context, shortID strin
 }
 
 // GetShortID возвращает первые 4 байта sha1-хеша ссылки в виде строки.
-func (shortener *MockURLShortener) GetShortID(ctx context.Context, userID int, fullURL string) (shortID string, err error) {
-	shortID = strconv.Itoa(shortener.counter)
+func (shortener *MockURLShortener) GetShortID(ctx context.Context, userID int, domain string, fullURL string) (shortID string, err error) {
+	shortID = DomainShortID(domain, strconv.Itoa(shortener.counter))
 	shortener.shortURLMap[shortID] = fullURL
 	shortener.counter++
 
@@ -34,14 +34,14 @@ func (shortener *MockURLShortener) GetShortID(ctx context.Context, userID int, f
 }
 
 // GetShortIDBatch возвращает короткие ID слайса ссылок.
-func (shortener *MockURLShortener) GetShortIDBatch(ctx context.Context, userID int, fullURLs []string) (shortIDs []string, err error) {
-	shortIDs = make([]string, 0)
+func (shortener *MockURLShortener) GetShortIDBatch(ctx context.Context, userID int, domain string, fullURLs []string) (results []ShortIDResult, err error) {
+	results = make([]ShortIDResult, 0)
 	for _, fullURL := range fullURLs {
-		shortID, _ := shortener.GetShortID(ctx, userID, fullURL)
-		shortIDs = append(shortIDs, shortID)
+		shortID, _ := shortener.GetShortID(ctx, userID, domain, fullURL)
+		results = append(results, ShortIDResult{ShortID: shortID})
 	}
 
-	return shortIDs, nil
+	return results, nil
 }
 
 // NewMockURLShortener создает mock-сокращатель для тестов
@@ -60,11 +60,11 @@ func (shortener *ErrMockURLShortener) GetFullURL(ctx context.Context, shortID st
 }
 
 // GetShortID возвращает первые 4 байта sha1-хеша ссылки в виде строки.
-func (shortener *ErrMockURLShortener) GetShortID(ctx context.Context, userID int, fullURL string) (shortID string, err error) {
+func (shortener *ErrMockURLShortener) GetShortID(ctx context.Context, userID int, domain string, fullURL string) (shortID string, err error) {
 	return "", errors.New("test error")
 }
 
 // GetShortIDBatch возвращает короткие ID слайса ссылок.
-func (shortener *ErrMockURLShortener) GetShortIDBatch(ctx context.Context, userID int, fullURLs []string) (shortIDs []string, err error) {
+func (shortener *ErrMockURLShortener) GetShortIDBatch(ctx context.Context, userID int, domain string, fullURLs []string) (results []ShortIDResult, err error) {
 	return nil, errors.New("test error")
 }
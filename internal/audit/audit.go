@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/afero"
+
+	"github.com/rovany706/url-shortener/internal/config"
+)
+
+// Action тип изменения в журнале аудита
+type Action string
+
+// Перечисление изменений
+const (
+	// ActionLinkCreated ссылка сокращена
+	ActionLinkCreated Action = "link.created"
+	// ActionLinkDeleted ссылка помечена удаленной
+	ActionLinkDeleted Action = "link.deleted"
+	// ActionUserCreated выдан ID нового пользователя
+	ActionUserCreated Action = "user.created"
+	// ActionUserDeleted пользователь удалил свой аккаунт
+	ActionUserDeleted Action = "user.deleted"
+)
+
+// DefaultUserEventsLimit количество событий пользователя, возвращаемых по умолчанию
+const DefaultUserEventsLimit = 100
+
+// Ошибки
+var (
+	// ErrInvalidLimit ошибка неположительного количества запрашиваемых событий
+	ErrInvalidLimit = errors.New("invalid audit events limit")
+	// ErrNoDatabase ошибка журнала в БД при хранении ссылок не в БД Postgres
+	ErrNoDatabase = errors.New("audit database sink requires a Postgres storage")
+)
+
+// LinkState состояние ссылки до или после изменения
+type LinkState struct {
+	FullURL   string `json:"full_url"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// Event запись журнала аудита: кто, когда и что изменил
+type Event struct {
+	// Time время изменения (UTC)
+	Time time.Time `json:"time"`
+	// Action тип изменения
+	Action Action `json:"action"`
+	// UserID ID пользователя, выполнившего изменение
+	UserID int `json:"user_id"`
+	// ClientIP адрес клиента
+	ClientIP string `json:"client_ip,omitempty"`
+	// RequestID ID HTTP-запроса, вызвавшего изменение
+	RequestID string `json:"request_id,omitempty"`
+	// ShortID ключ измененной ссылки
+	ShortID string `json:"short_id,omitempty"`
+	// Before состояние ссылки до изменения (nil - ссылки не было)
+	Before *LinkState `json:"before,omitempty"`
+	// After состояние ссылки после изменения
+	After *LinkState `json:"after,omitempty"`
+}
+
+// Log интерфейс журнала аудита. Записи журнала только добавляются.
+type Log interface {
+	// Append добавляет события в журнал
+	Append(ctx context.Context, events ...Event) error
+	// UserEvents возвращает не более limit последних событий пользователя, начиная с новых
+	UserEvents(ctx context.Context, userID int, limit int) ([]Event, error)
+	// Close завершает работу с журналом
+	Close() error
+}
+
+// NopLog журнал аудита, который ничего не сохраняет
+type NopLog struct{}
+
+// Append отбрасывает события
+func (NopLog) Append(ctx context.Context, events ...Event) error {
+	return nil
+}
+
+// UserEvents возвращает пустой список событий
+func (NopLog) UserEvents(ctx context.Context, userID int, limit int) ([]Event, error) {
+	if limit < 1 {
+		return nil, ErrInvalidLimit
+	}
+
+	return []Event{}, nil
+}
+
+// Close ничего не делает
+func (NopLog) Close() error {
+	return nil
+}
+
+// stampEvents проставляет время изменения событиям без него
+func stampEvents(events []Event) {
+	now := time.Now().UTC()
+	for i := range events {
+		if events[i].Time.IsZero() {
+			events[i].Time = now
+		} else {
+			events[i].Time = events[i].Time.UTC()
+		}
+	}
+}
+
+// NewAppLog создает журнал аудита по настройкам из конфига.
+// Журнал в БД использует пул pool хранилища ссылок (nil, если ссылки хранятся не в Postgres).
+func NewAppLog(appConfig *config.AppConfig, pool *pgxpool.Pool) (Log, error) {
+	switch appConfig.AuditSink {
+	case config.AuditSinkFile:
+		return NewFileLog(afero.NewOsFs(), appConfig.AuditFilePath)
+	case config.AuditSinkDatabase:
+		if pool == nil {
+			return nil, ErrNoDatabase
+		}
+		return NewDatabaseLog(pool), nil
+	default:
+		return NopLog{}, nil
+	}
+}
@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/rovany706/url-shortener/internal/database"
+)
+
+var (
+	insertAuditEventsSQL = fmt.Sprintf(
+		`INSERT INTO %s (created_at, action, user_id, client_ip, request_id, short_id, before, after)
+			SELECT batch.created_at, batch.action, batch.user_id, batch.client_ip, batch.request_id, batch.short_id,
+				batch.before::jsonb, batch.after::jsonb
+			FROM unnest($1::timestamptz[], $2::text[], $3::int[], $4::text[], $5::text[], $6::text[], $7::text[], $8::text[])
+				WITH ORDINALITY AS batch(created_at, action, user_id, client_ip, request_id, short_id, before, after, n)
+			ORDER BY batch.n`, database.AuditEventsTableName)
+	selectUserAuditEventsSQL = fmt.Sprintf(
+		`SELECT created_at, action, user_id, client_ip, request_id, short_id, before::text, after::text FROM %s
+		WHERE user_id = $1
+		ORDER BY id DESC
+		LIMIT $2`, database.AuditEventsTableName)
+)
+
+// DatabaseLog журнал аудита в таблице БД.
+// Изменение и удаление записей таблицы запрещены триггером.
+type DatabaseLog struct {
+	pool *pgxpool.Pool
+}
+
+// NewDatabaseLog создает журнал в БД пула pool с примененными миграциями схемы.
+// Пул принадлежит вызывающему и не закрывается журналом.
+func NewDatabaseLog(pool *pgxpool.Pool) *DatabaseLog {
+	return &DatabaseLog{pool: pool}
+}
+
+// Append добавляет события одним запросом
+func (l *DatabaseLog) Append(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	stampEvents(events)
+
+	times := make([]time.Time, len(events))
+	actions := make([]string, len(events))
+	userIDs := make([]int, len(events))
+	clientIPs := make([]string, len(events))
+	requestIDs := make([]string, len(events))
+	shortIDs := make([]string, len(events))
+	befores := make([]*string, len(events))
+	afters := make([]*string, len(events))
+
+	for i, event := range events {
+		times[i] = event.Time
+		actions[i] = string(event.Action)
+		userIDs[i] = event.UserID
+		clientIPs[i] = event.ClientIP
+		requestIDs[i] = event.RequestID
+		shortIDs[i] = event.ShortID
+
+		var err error
+		if befores[i], err = marshalLinkState(event.Before); err != nil {
+			return err
+		}
+		if afters[i], err = marshalLinkState(event.After); err != nil {
+			return err
+		}
+	}
+
+	_, err := l.pool.Exec(ctx, insertAuditEventsSQL, times, actions, userIDs, clientIPs, requestIDs, shortIDs, befores, afters)
+
+	return err
+}
+
+// UserEvents возвращает не более limit последних событий пользователя
+func (l *DatabaseLog) UserEvents(ctx context.Context, userID int, limit int) ([]Event, error) {
+	if limit < 1 {
+		return nil, ErrInvalidLimit
+	}
+
+	rows, err := l.pool.Query(ctx, selectUserAuditEventsSQL, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0)
+	for rows.Next() {
+		var event Event
+		var before, after *string
+		if err = rows.Scan(&event.Time, &event.Action, &event.UserID, &event.ClientIP, &event.RequestID, &event.ShortID, &before, &after); err != nil {
+			return nil, err
+		}
+
+		if event.Before, err = unmarshalLinkState(before); err != nil {
+			return nil, err
+		}
+		if event.After, err = unmarshalLinkState(after); err != nil {
+			return nil, err
+		}
+		event.Time = event.Time.UTC()
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// Close ничего не делает: пул закрывает его владелец
+func (l *DatabaseLog) Close() error {
+	return nil
+}
+
+// marshalLinkState кодирует состояние ссылки в JSON (nil - NULL)
+func marshalLinkState(state *LinkState) (*string, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	value := string(data)
+
+	return &value, nil
+}
+
+// unmarshalLinkState декодирует состояние ссылки из JSON (NULL - nil)
+func unmarshalLinkState(value *string) (*LinkState, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	var state LinkState
+	if err := json.Unmarshal([]byte(*value), &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
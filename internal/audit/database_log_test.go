@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/database"
+)
+
+// testDatabaseDSNEnv переменная окружения со строкой подключения к тестовой БД Postgres
+const testDatabaseDSNEnv = "TEST_DATABASE_DSN"
+
+func TestDatabaseLog(t *testing.T) {
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseDSNEnv)
+	}
+
+	ctx := context.Background()
+	pool, err := database.InitPool(ctx, dsn, database.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+	require.NoError(t, database.MigratePool(ctx, pool))
+
+	log := NewDatabaseLog(pool)
+
+	// уникальный пользователь, чтобы не зависеть от событий предыдущих запусков
+	userID := int(time.Now().UnixNano() % 1_000_000_000)
+
+	require.NoError(t, log.Append(ctx,
+		Event{Action: ActionLinkCreated, UserID: userID, ClientIP: "192.0.2.1", RequestID: "request-1",
+			ShortID: "a", After: &LinkState{FullURL: "http://example.com/a"}},
+		Event{Action: ActionLinkDeleted, UserID: userID, ShortID: "a",
+			Before: &LinkState{FullURL: "http://example.com/a"}, After: &LinkState{FullURL: "http://example.com/a", IsDeleted: true}},
+	))
+
+	events, err := log.UserEvents(ctx, userID, DefaultUserEventsLimit)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	assert.Equal(t, ActionLinkDeleted, events[0].Action)
+	assert.Equal(t, &LinkState{FullURL: "http://example.com/a", IsDeleted: true}, events[0].After)
+	assert.Equal(t, ActionLinkCreated, events[1].Action)
+	assert.Nil(t, events[1].Before)
+	assert.Equal(t, "192.0.2.1", events[1].ClientIP)
+	assert.Equal(t, "request-1", events[1].RequestID)
+	assert.WithinDuration(t, time.Now(), events[1].Time, time.Minute)
+
+	t.Run("append only", func(t *testing.T) {
+		_, err := log.pool.Exec(ctx, fmt.Sprintf("UPDATE %s SET action = 'x' WHERE user_id = $1", database.AuditEventsTableName), userID)
+		assert.Error(t, err)
+
+		_, err = log.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", database.AuditEventsTableName), userID)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewAppLogWithoutDatabase(t *testing.T) {
+	// журнал в БД требует пула хранилища ссылок в Postgres
+	_, err := NewAppLog(config.NewConfig(config.WithAudit(config.AuditSinkDatabase, "")), nil)
+	assert.ErrorIs(t, err, ErrNoDatabase)
+}
@@ -0,0 +1,228 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// tornLineChunkSize размер блока, которым файл читается с конца в поисках последней целой строки
+const tornLineChunkSize = 4096
+
+// eventPosition положение строки события в файле журнала
+type eventPosition struct {
+	offset int64
+	size   int64
+}
+
+// FileLog журнал аудита в файле: по одному событию в формате JSON на строку.
+// Каждая запись сбрасывается на диск до возврата из Append.
+//
+// Положения строк событий каждого пользователя хранятся в памяти: индекс строится
+// при открытии файла и пополняется при записи, поэтому UserEvents читает
+// только строки запрошенных событий.
+type FileLog struct {
+	mutex sync.Mutex
+	file  afero.File
+	// size размер файла без недописанной строки
+	size int64
+	// userEvents положения строк событий пользователя в порядке записи
+	userEvents map[int][]eventPosition
+}
+
+// NewFileLog открывает или создает файл журнала аудита.
+// Строка, недописанная при аварийном завершении, отбрасывается.
+func NewFileLog(fs afero.Fs, path string) (*FileLog, error) {
+	file, err := fs.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = truncateTornLine(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	l := &FileLog{
+		file:       file,
+		userEvents: make(map[int][]eventPosition),
+	}
+
+	if err = l.buildIndex(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// buildIndex читает файл и запоминает положения строк событий каждого пользователя
+func (l *FileLog) buildIndex() error {
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(l.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// недописанная строка обрезана при открытии
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		position := eventPosition{offset: l.size, size: int64(len(line))}
+		l.size += position.size
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var event struct {
+			UserID int `json:"user_id"`
+		}
+		if err = json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		l.userEvents[event.UserID] = append(l.userEvents[event.UserID], position)
+	}
+
+	_, err := l.file.Seek(l.size, io.SeekStart)
+
+	return err
+}
+
+// Append дописывает события в конец файла
+func (l *FileLog) Append(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	stampEvents(events)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	positions := make([]eventPosition, len(events))
+	for i, event := range events {
+		offset := int64(buf.Len())
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+		positions[i] = eventPosition{offset: offset, size: int64(buf.Len()) - offset}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	written, err := l.file.Write(buf.Bytes())
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		l.discard(int64(written))
+		return err
+	}
+
+	for i, event := range events {
+		positions[i].offset += l.size
+		l.userEvents[event.UserID] = append(l.userEvents[event.UserID], positions[i])
+	}
+	l.size += int64(buf.Len())
+
+	return nil
+}
+
+// discard убирает из файла written байт неудавшейся записи, чтобы они не оказались
+// перед следующими записями. Если обрезать файл не удалось, байты учитываются в размере,
+// чтобы положения следующих событий оставались верными. Вызывается под мьютексом.
+func (l *FileLog) discard(written int64) {
+	if err := truncateAt(l.file, l.size+written, l.size); err != nil {
+		l.size += written
+	}
+}
+
+// UserEvents читает из файла не более limit последних событий пользователя
+func (l *FileLog) UserEvents(ctx context.Context, userID int, limit int) ([]Event, error) {
+	if limit < 1 {
+		return nil, ErrInvalidLimit
+	}
+
+	l.mutex.Lock()
+	positions := l.userEvents[userID]
+	positions = positions[max(len(positions)-limit, 0):]
+	l.mutex.Unlock()
+
+	// записанные строки не изменяются, поэтому читаются без блокировки
+	events := make([]Event, 0, len(positions))
+	for i := len(positions) - 1; i >= 0; i-- {
+		line := make([]byte, positions[i].size)
+		if _, err := l.file.ReadAt(line, positions[i].offset); err != nil {
+			return nil, err
+		}
+
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Close закрывает файл журнала
+func (l *FileLog) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.file.Close()
+}
+
+// truncateTornLine отбрасывает строку, недописанную при аварийном завершении:
+// обрезает файл после последнего перевода строки
+func truncateTornLine(file afero.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	chunk := make([]byte, tornLineChunkSize)
+	for end := info.Size(); end > 0; {
+		start := max(end-tornLineChunkSize, 0)
+		n, err := file.ReadAt(chunk[:end-start], start)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if i := bytes.LastIndexByte(chunk[:n], '\n'); i >= 0 {
+			return truncateAt(file, info.Size(), start+int64(i)+1)
+		}
+		end = start
+	}
+
+	return truncateAt(file, info.Size(), 0)
+}
+
+// truncateAt обрезает файл размера size до newSize и переносит позицию записи в новый конец файла
+func truncateAt(file afero.File, size int64, newSize int64) error {
+	if newSize == size {
+		return nil
+	}
+
+	if err := file.Truncate(newSize); err != nil {
+		return err
+	}
+
+	_, err := file.Seek(newSize, io.SeekStart)
+
+	return err
+}
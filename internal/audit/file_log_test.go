@@ -0,0 +1,228 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAuditFilePath = "audit.log"
+
+func TestFileLogUserEvents(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+
+	log, err := NewFileLog(fs, testAuditFilePath)
+	require.NoError(t, err)
+	defer log.Close()
+
+	require.NoError(t, log.Append(ctx,
+		Event{Action: ActionUserCreated, UserID: 1, ClientIP: "192.0.2.1", RequestID: "request-1"},
+		Event{Action: ActionLinkCreated, UserID: 1, ShortID: "a", After: &LinkState{FullURL: "http://example.com/a"}},
+		Event{Action: ActionLinkCreated, UserID: 2, ShortID: "b", After: &LinkState{FullURL: "http://example.com/b"}},
+	))
+	require.NoError(t, log.Append(ctx, Event{
+		Action:  ActionLinkDeleted,
+		UserID:  1,
+		ShortID: "a",
+		Before:  &LinkState{FullURL: "http://example.com/a"},
+		After:   &LinkState{FullURL: "http://example.com/a", IsDeleted: true},
+	}))
+
+	events, err := log.UserEvents(ctx, 1, DefaultUserEventsLimit)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	actions := make([]Action, 0, len(events))
+	for _, event := range events {
+		assert.Equal(t, 1, event.UserID)
+		assert.WithinDuration(t, time.Now(), event.Time, time.Minute)
+		assert.Equal(t, time.UTC, event.Time.Location())
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []Action{ActionLinkDeleted, ActionLinkCreated, ActionUserCreated}, actions)
+
+	assert.Equal(t, &LinkState{FullURL: "http://example.com/a"}, events[0].Before)
+	assert.Equal(t, &LinkState{FullURL: "http://example.com/a", IsDeleted: true}, events[0].After)
+	assert.Nil(t, events[1].Before)
+	assert.Equal(t, "192.0.2.1", events[2].ClientIP)
+	assert.Equal(t, "request-1", events[2].RequestID)
+
+	t.Run("limit", func(t *testing.T) {
+		events, err := log.UserEvents(ctx, 1, 2)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, ActionLinkDeleted, events[0].Action)
+		assert.Equal(t, ActionLinkCreated, events[1].Action)
+
+		_, err = log.UserEvents(ctx, 1, 0)
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+	})
+
+	t.Run("no events", func(t *testing.T) {
+		events, err := log.UserEvents(ctx, 3, DefaultUserEventsLimit)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+}
+
+func TestFileLogReopen(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+
+	log, err := NewFileLog(fs, testAuditFilePath)
+	require.NoError(t, err)
+	require.NoError(t, log.Append(ctx, Event{Action: ActionUserCreated, UserID: 1}))
+	require.NoError(t, log.Close())
+
+	// запись, недописанная при аварийном завершении
+	file, err := fs.OpenFile(testAuditFilePath, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"time":"2024-01-01T00:00:00Z","action":"link.cre`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	log, err = NewFileLog(fs, testAuditFilePath)
+	require.NoError(t, err)
+	defer log.Close()
+
+	require.NoError(t, log.Append(ctx, Event{Action: ActionUserDeleted, UserID: 1}))
+
+	events, err := log.UserEvents(ctx, 1, DefaultUserEventsLimit)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, ActionUserDeleted, events[0].Action)
+	assert.Equal(t, ActionUserCreated, events[1].Action)
+}
+
+func TestFileLogIndex(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+
+	log, err := NewFileLog(fs, testAuditFilePath)
+	require.NoError(t, err)
+	for i := range 10 {
+		require.NoError(t, log.Append(ctx,
+			Event{Action: ActionLinkCreated, UserID: 1, ShortID: fmt.Sprint("a", i)},
+			Event{Action: ActionLinkCreated, UserID: 2, ShortID: fmt.Sprint("b", i)},
+		))
+	}
+	require.NoError(t, log.Close())
+
+	// индекс событий пользователей восстанавливается при открытии файла
+	log, err = NewFileLog(fs, testAuditFilePath)
+	require.NoError(t, err)
+	defer log.Close()
+
+	require.NoError(t, log.Append(ctx, Event{Action: ActionLinkDeleted, UserID: 2, ShortID: "b9"}))
+
+	tests := []struct {
+		name     string
+		userID   int
+		limit    int
+		shortIDs []string
+	}{
+		{"first user", 1, 3, []string{"a9", "a8", "a7"}},
+		{"appended after reopen", 2, 2, []string{"b9", "b9"}},
+		{"limit above event count", 2, 100, []string{"b9", "b9", "b8", "b7", "b6", "b5", "b4", "b3", "b2", "b1", "b0"}},
+		{"unknown user", 3, 10, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := log.UserEvents(ctx, tt.userID, tt.limit)
+			require.NoError(t, err)
+
+			shortIDs := make([]string, len(events))
+			for i, event := range events {
+				assert.Equal(t, tt.userID, event.UserID)
+				shortIDs[i] = event.ShortID
+			}
+			assert.Equal(t, tt.shortIDs, shortIDs)
+		})
+	}
+}
+
+var errFileFailed = errors.New("file operation failed")
+
+// failingSyncFs файловая система в памяти, файлы которой не сбрасываются на диск при failSync
+// и не обрезаются при failTruncate
+type failingSyncFs struct {
+	afero.Fs
+	failSync     bool
+	failTruncate bool
+}
+
+func (fs *failingSyncFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	file, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failingSyncFile{File: file, fs: fs}, nil
+}
+
+type failingSyncFile struct {
+	afero.File
+	fs *failingSyncFs
+}
+
+func (f *failingSyncFile) Sync() error {
+	if f.fs.failSync {
+		return errFileFailed
+	}
+
+	return f.File.Sync()
+}
+
+func (f *failingSyncFile) Truncate(size int64) error {
+	if f.fs.failTruncate {
+		return errFileFailed
+	}
+
+	return f.File.Truncate(size)
+}
+
+func TestFileLogSyncFailure(t *testing.T) {
+	tests := []struct {
+		name         string
+		failTruncate bool
+	}{
+		{"failed write is truncated", false},
+		{"failed write is skipped when truncate fails", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fs := &failingSyncFs{Fs: afero.NewMemMapFs()}
+
+			log, err := NewFileLog(fs, testAuditFilePath)
+			require.NoError(t, err)
+			defer log.Close()
+
+			require.NoError(t, log.Append(ctx, Event{Action: ActionLinkCreated, UserID: 1, ShortID: "a"}))
+
+			fs.failSync, fs.failTruncate = true, tt.failTruncate
+			err = log.Append(ctx, Event{Action: ActionLinkCreated, UserID: 1, ShortID: "lost"})
+			require.ErrorIs(t, err, errFileFailed)
+
+			// положения следующих событий не сдвигаются на байты неудавшейся записи
+			fs.failSync, fs.failTruncate = false, false
+			require.NoError(t, log.Append(ctx, Event{Action: ActionLinkCreated, UserID: 1, ShortID: "b"}))
+
+			events, err := log.UserEvents(ctx, 1, DefaultUserEventsLimit)
+			require.NoError(t, err)
+			require.Len(t, events, 2)
+			assert.Equal(t, "b", events[0].ShortID)
+			assert.Equal(t, "a", events[1].ShortID)
+		})
+	}
+}
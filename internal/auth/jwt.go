@@ -2,7 +2,9 @@ package auth
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,15 +16,20 @@ const TokenExpiryTime = time.Hour * 24
 // AuthCookieName cookie-ключ токена
 const AuthCookieName = "token"
 
+// ErrTokenRevoked ошибка отозванного токена
+var ErrTokenRevoked = errors.New("token is revoked")
+
 // TokenManager интерфейс менеджера токенов аутентификации
 type TokenManager interface {
 	GetClaimsFromToken(tokenString string) (*Claims, error)
 	CreateToken(userID int) (string, error)
+	RevokeUserTokens(userID int) error
 }
 
 // JWTTokenManager реализует TokenManager и использует для работы JWT-токены
 type JWTTokenManager struct {
-	secretKey []byte
+	secretKey    []byte
+	revokedUsers sync.Map
 }
 
 // NewJWTTokenManager создает экземпляр JWTTokenManager.
@@ -91,5 +98,17 @@ func (auth *JWTTokenManager) GetClaimsFromToken(tokenString string) (*Claims, er
 		return nil, fmt.Errorf("token is not valid")
 	}
 
+	if _, revoked := auth.revokedUsers.Load(claims.UserID); revoked {
+		return nil, ErrTokenRevoked
+	}
+
 	return claims, nil
 }
+
+// RevokeUserTokens отзывает все токены пользователя с userID.
+// Последующие проверки токенов пользователя завершаются ошибкой ErrTokenRevoked.
+func (auth *JWTTokenManager) RevokeUserTokens(userID int) error {
+	auth.revokedUsers.Store(userID, struct{}{})
+
+	return nil
+}
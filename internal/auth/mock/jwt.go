@@ -70,3 +70,17 @@ func (mr *MockTokenManagerMockRecorder) GetClaimsFromToken(tokenString any) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClaimsFromToken", reflect.TypeOf((*MockTokenManager)(nil).GetClaimsFromToken), tokenString)
 }
+
+// RevokeUserTokens mocks base method.
+func (m *MockTokenManager) RevokeUserTokens(userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserTokens", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserTokens indicates an expected call of RevokeUserTokens.
+func (mr *MockTokenManagerMockRecorder) RevokeUserTokens(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserTokens", reflect.TypeOf((*MockTokenManager)(nil).RevokeUserTokens), userID)
+}
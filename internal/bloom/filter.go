@@ -0,0 +1,137 @@
+// Package bloom реализует фильтр Блума для проверки принадлежности строк множеству
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"math"
+	"sync/atomic"
+)
+
+// filterMagic сигнатура сериализованного фильтра
+const filterMagic = "BLM1"
+
+// Ограничения параметров сериализованного фильтра
+const (
+	maxBitCount  = 1 << 40
+	maxHashCount = 64
+)
+
+// Ошибки
+var (
+	// ErrInvalidParameters ошибка параметров фильтра
+	ErrInvalidParameters = errors.New("invalid bloom filter parameters")
+	// ErrInvalidFormat ошибка формата сериализованного фильтра
+	ErrInvalidFormat = errors.New("invalid bloom filter format")
+)
+
+// Filter потокобезопасный фильтр Блума.
+// Test возвращает false, только если строка точно не добавлялась в фильтр.
+type Filter struct {
+	bits      []atomic.Uint64
+	bitCount  uint64
+	hashCount uint64
+}
+
+// New создает фильтр, рассчитанный на capacity строк с вероятностью
+// ложноположительного срабатывания falsePositiveRate
+func New(capacity int, falsePositiveRate float64) (*Filter, error) {
+	if capacity <= 0 || falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, ErrInvalidParameters
+	}
+
+	bitCount := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashCount := math.Max(1, math.Round(bitCount/float64(capacity)*math.Ln2))
+
+	return newFilter(uint64(bitCount), uint64(hashCount)), nil
+}
+
+func newFilter(bitCount uint64, hashCount uint64) *Filter {
+	wordCount := (bitCount + 63) / 64
+
+	return &Filter{
+		bits:      make([]atomic.Uint64, wordCount),
+		bitCount:  wordCount * 64,
+		hashCount: hashCount,
+	}
+}
+
+// Add добавляет строку в фильтр
+func (f *Filter) Add(value string) {
+	h1, h2 := hashes(value)
+	for i := uint64(0); i < f.hashCount; i++ {
+		bit := (h1 + i*h2) % f.bitCount
+		f.bits[bit/64].Or(1 << (bit % 64))
+	}
+}
+
+// Test проверяет, могла ли строка быть добавлена в фильтр
+func (f *Filter) Test(value string) bool {
+	h1, h2 := hashes(value)
+	for i := uint64(0); i < f.hashCount; i++ {
+		bit := (h1 + i*h2) % f.bitCount
+		if f.bits[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WriteTo сериализует фильтр в w
+func (f *Filter) WriteTo(w io.Writer) (n int64, err error) {
+	buf := make([]byte, 0, len(filterMagic)+16+len(f.bits)*8)
+	buf = append(buf, filterMagic...)
+	buf = binary.BigEndian.AppendUint64(buf, f.bitCount)
+	buf = binary.BigEndian.AppendUint64(buf, f.hashCount)
+	for i := range f.bits {
+		buf = binary.BigEndian.AppendUint64(buf, f.bits[i].Load())
+	}
+
+	written, err := w.Write(buf)
+
+	return int64(written), err
+}
+
+// Read восстанавливает фильтр, сериализованный методом WriteTo
+func Read(r io.Reader) (*Filter, error) {
+	header := make([]byte, len(filterMagic)+16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalidFormat
+	}
+
+	if string(header[:len(filterMagic)]) != filterMagic {
+		return nil, ErrInvalidFormat
+	}
+
+	bitCount := binary.BigEndian.Uint64(header[len(filterMagic):])
+	hashCount := binary.BigEndian.Uint64(header[len(filterMagic)+8:])
+	if bitCount == 0 || bitCount%64 != 0 || bitCount > maxBitCount || hashCount == 0 || hashCount > maxHashCount {
+		return nil, ErrInvalidFormat
+	}
+
+	filter := newFilter(bitCount, hashCount)
+	word := make([]byte, 8)
+	for i := range filter.bits {
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, ErrInvalidFormat
+		}
+		filter.bits[i].Store(binary.BigEndian.Uint64(word))
+	}
+
+	return filter, nil
+}
+
+// hashes возвращает две независимые хеш-функции строки для схемы двойного хеширования
+func hashes(value string) (h1 uint64, h2 uint64) {
+	hash := fnv.New128a()
+	hash.Write([]byte(value))
+	sum := hash.Sum(nil)
+
+	h1 = binary.BigEndian.Uint64(sum[:8])
+	h2 = binary.BigEndian.Uint64(sum[8:]) | 1
+
+	return h1, h2
+}
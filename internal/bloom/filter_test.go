@@ -0,0 +1,79 @@
+package bloom
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name              string
+		capacity          int
+		falsePositiveRate float64
+		wantErr           bool
+	}{
+		{"valid", 1000, 0.01, false},
+		{"zero capacity", 0, 0.01, true},
+		{"zero false positive rate", 1000, 0, true},
+		{"false positive rate above one", 1000, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.capacity, tt.falsePositiveRate)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidParameters)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	const count = 10000
+	filter, err := New(count, 0.01)
+	require.NoError(t, err)
+
+	for i := 0; i < count; i++ {
+		filter.Add(fmt.Sprintf("added-%d", i))
+	}
+
+	for i := 0; i < count; i++ {
+		require.True(t, filter.Test(fmt.Sprintf("added-%d", i)))
+	}
+
+	falsePositives := 0
+	for i := 0; i < count; i++ {
+		if filter.Test(fmt.Sprintf("missing-%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, count*3/100)
+}
+
+func TestFilterSerialization(t *testing.T) {
+	filter, err := New(100, 0.01)
+	require.NoError(t, err)
+	filter.Add("1")
+
+	var buf bytes.Buffer
+	_, err = filter.WriteTo(&buf)
+	require.NoError(t, err)
+
+	restored, err := Read(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.True(t, restored.Test("1"))
+	assert.False(t, restored.Test("2"))
+
+	_, err = Read(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+
+	_, err = Read(bytes.NewReader([]byte("invalid filter data")))
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
@@ -5,21 +5,55 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/url"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"go.uber.org/zap"
+
+	"github.com/rovany706/url-shortener/internal/storage"
 )
 
 // Ошибки
 var (
 	// ErrInvalidBaseURL ошибка валидации базового URL
 	ErrInvalidBaseURL = errors.New("invalid base URL")
+	// ErrInvalidDomains ошибка валидации дополнительных доменов коротких ссылок
+	ErrInvalidDomains = errors.New("invalid short link domains")
 	// ErrInvalidAppRunAddress ошибка валидации адреса для запуска сервера
 	ErrInvalidAppRunAddress = errors.New("invalid address and port to run server")
 	// ErrInvalidLogLevel ошибка валидации уровня логгирования
 	ErrInvalidLogLevel = errors.New("invalid log level")
+	// ErrInvalidDatabasePool ошибка валидации настроек пула подключений к БД
+	ErrInvalidDatabasePool = errors.New("invalid database pool settings")
+	// ErrInvalidDatabaseReplicas ошибка валидации настроек реплик БД
+	ErrInvalidDatabaseReplicas = errors.New("invalid database replica settings")
+	// ErrInvalidDatabaseShards ошибка валидации настроек шардов БД
+	ErrInvalidDatabaseShards = errors.New("invalid database shard settings")
+	// ErrInvalidFileSyncPolicy ошибка валидации политики сброса файлового хранилища на диск
+	ErrInvalidFileSyncPolicy = errors.New("invalid file storage sync policy")
+	// ErrInvalidFileCompression ошибка валидации алгоритма сжатия файлового хранилища
+	ErrInvalidFileCompression = errors.New("invalid file storage compression")
+	// ErrInvalidCache ошибка валидации настроек кэша ссылок
+	ErrInvalidCache = errors.New("invalid cache settings")
+	// ErrInvalidBloomFilter ошибка валидации настроек фильтра Блума
+	ErrInvalidBloomFilter = errors.New("invalid bloom filter settings")
+	// ErrInvalidStorageType ошибка неизвестного имени типа хранилища
+	ErrInvalidStorageType = errors.New("invalid storage type")
+	// ErrInvalidShadowStorage ошибка валидации настроек теневого хранилища
+	ErrInvalidShadowStorage = errors.New("invalid shadow storage settings")
+	// ErrInvalidAuditSink ошибка валидации настроек журнала аудита
+	ErrInvalidAuditSink = errors.New("invalid audit log settings")
+	// ErrInvalidWebhooks ошибка валидации настроек доставки вебхуков
+	ErrInvalidWebhooks = errors.New("invalid webhook settings")
+	// ErrInvalidDeleteQueue ошибка валидации настроек очереди запросов на удаление
+	ErrInvalidDeleteQueue = errors.New("invalid delete queue settings")
+	// ErrInvalidStatsLogInterval ошибка валидации периода логирования статистики хранилища
+	ErrInvalidStatsLogInterval = errors.New("invalid storage stats log interval")
 )
 
 const (
@@ -28,7 +62,64 @@ const (
 	defaultLogLevel        = "info"
 	defaultFileStoragePath = ""
 	defaultDatabaseDSN     = ""
+	defaultSQLitePath      = ""
+	defaultBoltPath        = ""
+	defaultRedisAddr       = ""
 	defaultProfiling       = false
+
+	defaultDatabaseMaxConns        = 0
+	defaultDatabaseMinConns        = 0
+	defaultDatabaseMaxConnLifetime = time.Duration(0)
+	defaultDatabaseMaxConnIdleTime = time.Duration(0)
+	defaultDatabaseAcquireTimeout  = time.Duration(0)
+
+	defaultDatabaseReplicaHealthCheckInterval = time.Second * 5
+	defaultDatabaseReadYourWritesWindow       = time.Second * 5
+
+	defaultFileCompactionInterval = time.Duration(0)
+	defaultFileSyncPolicy         = string(storage.SyncNever)
+	defaultFileSyncInterval       = time.Millisecond * 100
+	defaultFileCompression        = string(storage.CompressionNone)
+	defaultFileFormatUpgrade      = false
+
+	defaultCacheSize        = 0
+	defaultCacheTTL         = time.Minute
+	defaultCacheNegativeTTL = time.Second * 10
+
+	defaultBloomFilterCapacity          = 0
+	defaultBloomFilterFalsePositiveRate = 0.01
+	defaultBloomFilterRebuildInterval   = time.Hour
+	defaultBloomFilterPath              = ""
+
+	defaultShadowStorage         = ""
+	defaultShadowStorageLocation = ""
+	defaultShadowReadSampleRate  = 0.01
+	defaultShadowPromote         = false
+
+	defaultAuditSink     = AuditSinkNone
+	defaultAuditFilePath = ""
+
+	defaultWebhookClickSampleRate = 0.01
+	defaultWebhookMaxAttempts     = 5
+	defaultWebhookRetryDelay      = time.Second
+	defaultWebhookMaxRetryDelay   = time.Hour
+	defaultWebhookTimeout         = time.Second * 5
+	defaultWebhookPrivateNetworks = false
+	defaultWebhookRetention       = time.Hour * 24 * 7
+
+	defaultDeleteQueuePath = ""
+
+	defaultStatsLogInterval = time.Minute
+)
+
+// Хранилища журнала аудита
+const (
+	// AuditSinkNone журнал аудита отключен
+	AuditSinkNone = "none"
+	// AuditSinkFile журнал аудита в файле
+	AuditSinkFile = "file"
+	// AuditSinkDatabase журнал аудита в БД Postgres (DatabaseDSN)
+	AuditSinkDatabase = "database"
 )
 
 // StorageType тип хранилища данных сервиса
@@ -42,12 +133,53 @@ const (
 	File
 	// Database хранение в базе данных
 	Database
+	// SQLite хранение во встроенной базе данных SQLite
+	SQLite
+	// Bolt хранение во встроенной key-value базе данных bbolt
+	Bolt
+	// Redis хранение в Redis или совместимом хранилище
+	Redis
 )
 
+// storageTypeNames имена типов хранилища
+var storageTypeNames = map[StorageType]string{
+	None:     "memory",
+	File:     "file",
+	Database: "database",
+	SQLite:   "sqlite",
+	Bolt:     "bolt",
+	Redis:    "redis",
+}
+
+// String возвращает имя типа хранилища
+func (storageType StorageType) String() string {
+	if name, ok := storageTypeNames[storageType]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// ParseStorageType возвращает тип хранилища по его имени
+func ParseStorageType(name string) (StorageType, error) {
+	for storageType, storageTypeName := range storageTypeNames {
+		if storageTypeName == name {
+			return storageType, nil
+		}
+	}
+
+	return None, ErrInvalidStorageType
+}
+
+// SQLiteDSNScheme схема строки подключения к БД, выбирающая хранилище SQLite
+const SQLiteDSNScheme = "sqlite://"
+
 // AppConfig содержит конфигурацию сервиса
 type AppConfig struct {
 	// BaseURL базовый URL для сокращенных ссылок
 	BaseURL string `env:"BASE_URL"`
+	// Domains базовые URL дополнительных доменов коротких ссылок; у каждого домена свое пространство коротких ID
+	Domains []string `env:"DOMAINS" envSeparator:","`
 	// AppRunAddress адрес для запуска сервера
 	AppRunAddress string `env:"SERVER_ADDRESS"`
 	// LogLevel уровень логгирования
@@ -56,8 +188,88 @@ type AppConfig struct {
 	FileStoragePath string `env:"FILE_STORAGE_PATH"`
 	// DatabaseDSN строка подключения к БД
 	DatabaseDSN string `env:"DATABASE_DSN"`
+	// DatabaseMaxConns максимальное количество подключений к БД (0 - по умолчанию pgxpool)
+	DatabaseMaxConns int `env:"DATABASE_MAX_CONNS"`
+	// DatabaseMinConns минимальное количество поддерживаемых подключений к БД
+	DatabaseMinConns int `env:"DATABASE_MIN_CONNS"`
+	// DatabaseMaxConnLifetime время жизни подключения к БД (0 - по умолчанию pgxpool)
+	DatabaseMaxConnLifetime time.Duration `env:"DATABASE_MAX_CONN_LIFETIME"`
+	// DatabaseMaxConnIdleTime время простоя, после которого подключение к БД закрывается (0 - по умолчанию pgxpool)
+	DatabaseMaxConnIdleTime time.Duration `env:"DATABASE_MAX_CONN_IDLE_TIME"`
+	// DatabaseAcquireTimeout время ожидания свободного подключения к БД (0 - без ограничения)
+	DatabaseAcquireTimeout time.Duration `env:"DATABASE_ACQUIRE_TIMEOUT"`
+	// DatabaseReplicaDSNs строки подключения к репликам БД для запросов чтения
+	DatabaseReplicaDSNs []string `env:"DATABASE_REPLICA_DSNS" envSeparator:","`
+	// DatabaseReplicaHealthCheckInterval период проверки доступности реплик БД
+	DatabaseReplicaHealthCheckInterval time.Duration `env:"DATABASE_REPLICA_HEALTH_CHECK_INTERVAL"`
+	// DatabaseReadYourWritesWindow окно после записи, в течение которого чтение выполняется на основной БД (0 - отключено)
+	DatabaseReadYourWritesWindow time.Duration `env:"DATABASE_READ_YOUR_WRITES_WINDOW"`
+	// DatabaseShardDSNs строки подключения к шардам БД; порядок определяет распределение ссылок
+	DatabaseShardDSNs []string `env:"DATABASE_SHARD_DSNS" envSeparator:","`
+	// SQLitePath путь файла базы данных SQLite
+	SQLitePath string `env:"SQLITE_PATH"`
+	// BoltPath путь файла базы данных bbolt
+	BoltPath string `env:"BOLT_PATH"`
+	// RedisAddr адрес Redis (host:port или redis:// URL)
+	RedisAddr string `env:"REDIS_ADDR"`
 	// EnableProfiling флаг включения режима профилирования
 	EnableProfiling bool `env:"PPROF"`
+	// FileCompactionInterval период сжатия журнала файлового хранилища (0 - не сжимать)
+	FileCompactionInterval time.Duration `env:"FILE_STORAGE_COMPACTION_INTERVAL"`
+	// FileSyncPolicy политика сброса файлового хранилища на диск: always, interval или never
+	FileSyncPolicy string `env:"FILE_STORAGE_SYNC"`
+	// FileSyncInterval период сброса файлового хранилища на диск для политики interval
+	FileSyncInterval time.Duration `env:"FILE_STORAGE_SYNC_INTERVAL"`
+	// FileCompression сжатие новых файлов хранилища: none, gzip или zstd
+	FileCompression string `env:"FILE_STORAGE_COMPRESSION"`
+	// FileFormatUpgrade флаг перевода файлов хранилища в текущую версию формата при запуске
+	FileFormatUpgrade bool `env:"FILE_STORAGE_UPGRADE"`
+	// CacheSize количество ссылок в кэше перенаправлений (0 - кэш отключен)
+	CacheSize int `env:"CACHE_SIZE"`
+	// CacheTTL время жизни ссылки в кэше (0 - до вытеснения)
+	CacheTTL time.Duration `env:"CACHE_TTL"`
+	// CacheNegativeTTL время жизни в кэше отсутствующей ссылки (0 - не кэшировать)
+	CacheNegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL"`
+	// BloomFilterCapacity ожидаемое количество ссылок в фильтре Блума (0 - фильтр отключен).
+	// Фильтр не используется с хранилищами, общими для нескольких экземпляров сервиса.
+	BloomFilterCapacity int `env:"BLOOM_FILTER_CAPACITY"`
+	// BloomFilterFalsePositiveRate вероятность ложноположительного срабатывания фильтра Блума
+	BloomFilterFalsePositiveRate float64 `env:"BLOOM_FILTER_FP_RATE"`
+	// BloomFilterRebuildInterval период перестроения фильтра Блума (0 - не перестраивать)
+	BloomFilterRebuildInterval time.Duration `env:"BLOOM_FILTER_REBUILD_INTERVAL"`
+	// BloomFilterPath путь файла для сохранения фильтра Блума между штатными перезапусками
+	BloomFilterPath string `env:"BLOOM_FILTER_PATH"`
+	// ShadowStorage тип теневого хранилища, в которое дублируется запись (пусто - отключено)
+	ShadowStorage string `env:"SHADOW_STORAGE"`
+	// ShadowStorageLocation путь, строка подключения или адрес теневого хранилища
+	ShadowStorageLocation string `env:"SHADOW_STORAGE_LOCATION"`
+	// ShadowReadSampleRate доля чтений, сверяемых с теневым хранилищем
+	ShadowReadSampleRate float64 `env:"SHADOW_READ_SAMPLE_RATE"`
+	// ShadowPromote флаг, меняющий местами основное и теневое хранилища
+	ShadowPromote bool `env:"SHADOW_PROMOTE"`
+	// AuditSink хранилище журнала аудита: none, file или database
+	AuditSink string `env:"AUDIT_SINK"`
+	// AuditFilePath путь файла журнала аудита
+	AuditFilePath string `env:"AUDIT_FILE_PATH"`
+	// WebhookClickSampleRate доля переходов, для которых отправляется событие link.clicked
+	WebhookClickSampleRate float64 `env:"WEBHOOK_CLICK_SAMPLE_RATE"`
+	// WebhookMaxAttempts количество попыток доставки события, после которого доставка прекращается
+	WebhookMaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS"`
+	// WebhookRetryDelay задержка перед второй попыткой доставки; удваивается с каждой следующей
+	WebhookRetryDelay time.Duration `env:"WEBHOOK_RETRY_DELAY"`
+	// WebhookMaxRetryDelay наибольшая задержка между попытками доставки
+	WebhookMaxRetryDelay time.Duration `env:"WEBHOOK_MAX_RETRY_DELAY"`
+	// WebhookTimeout время ожидания ответа получателя вебхука
+	WebhookTimeout time.Duration `env:"WEBHOOK_TIMEOUT"`
+	// WebhookPrivateNetworks разрешает доставку вебхуков на адреса частных и локальных сетей (для разработки)
+	WebhookPrivateNetworks bool `env:"WEBHOOK_PRIVATE_NETWORKS"`
+	// WebhookRetention время хранения завершенных доставок вебхуков
+	WebhookRetention time.Duration `env:"WEBHOOK_RETENTION"`
+	// DeleteQueuePath путь файла очереди запросов на удаление (пусто - рядом с файлом хранилища)
+	DeleteQueuePath string `env:"DELETE_QUEUE_PATH"`
+	// StatsLogInterval период логирования статистики кэша, фильтра Блума и теневого хранилища
+	// (0 - только при остановке)
+	StatsLogInterval time.Duration `env:"STATS_LOG_INTERVAL"`
 	// StorageType тип хранилища
 	StorageType StorageType
 }
@@ -74,6 +286,13 @@ func WithBaseURL(url string) Option {
 	}
 }
 
+// WithDomains задает базовые URL дополнительных доменов коротких ссылок
+func WithDomains(domains []string) Option {
+	return func(c *AppConfig) {
+		c.Domains = domains
+	}
+}
+
 // WithAppRunAddress задает адрес для запуска сервера
 func WithAppRunAddress(appRunAddress string) Option {
 	return func(c *AppConfig) {
@@ -110,6 +329,196 @@ func WithDatabseDSN(databaseDSN string) Option {
 	}
 }
 
+// WithDatabasePool задает настройки пула подключений к БД
+func WithDatabasePool(maxConns int, minConns int, maxConnLifetime time.Duration, maxConnIdleTime time.Duration, acquireTimeout time.Duration) Option {
+	return func(c *AppConfig) {
+		c.DatabaseMaxConns = maxConns
+		c.DatabaseMinConns = minConns
+		c.DatabaseMaxConnLifetime = maxConnLifetime
+		c.DatabaseMaxConnIdleTime = maxConnIdleTime
+		c.DatabaseAcquireTimeout = acquireTimeout
+	}
+}
+
+// WithDatabaseReplicas задает реплики БД для запросов чтения, период проверки их доступности
+// и окно read-your-writes
+func WithDatabaseReplicas(dsns []string, healthCheckInterval time.Duration, readYourWritesWindow time.Duration) Option {
+	return func(c *AppConfig) {
+		c.DatabaseReplicaDSNs = dsns
+		c.DatabaseReplicaHealthCheckInterval = healthCheckInterval
+		c.DatabaseReadYourWritesWindow = readYourWritesWindow
+	}
+}
+
+// WithDatabaseShards задает строки подключения к шардам БД
+func WithDatabaseShards(dsns []string) Option {
+	return func(c *AppConfig) {
+		c.DatabaseShardDSNs = dsns
+	}
+}
+
+// WithSQLitePath задает путь файла базы данных SQLite
+func WithSQLitePath(sqlitePath string) Option {
+	return func(c *AppConfig) {
+		if sqlitePath != "" {
+			c.SQLitePath = sqlitePath
+		}
+	}
+}
+
+// WithBoltPath задает путь файла базы данных bbolt
+func WithBoltPath(boltPath string) Option {
+	return func(c *AppConfig) {
+		if boltPath != "" {
+			c.BoltPath = boltPath
+		}
+	}
+}
+
+// WithRedisAddr задает адрес Redis
+func WithRedisAddr(redisAddr string) Option {
+	return func(c *AppConfig) {
+		if redisAddr != "" {
+			c.RedisAddr = redisAddr
+		}
+	}
+}
+
+// WithFileCompactionInterval задает период сжатия журнала файлового хранилища
+func WithFileCompactionInterval(interval time.Duration) Option {
+	return func(c *AppConfig) {
+		c.FileCompactionInterval = interval
+	}
+}
+
+// WithFileSync задает политику и период сброса файлового хранилища на диск
+func WithFileSync(policy string, interval time.Duration) Option {
+	return func(c *AppConfig) {
+		if policy != "" {
+			c.FileSyncPolicy = policy
+		}
+		if interval > 0 {
+			c.FileSyncInterval = interval
+		}
+	}
+}
+
+// WithFileCompression задает сжатие новых файлов хранилища
+func WithFileCompression(compression string) Option {
+	return func(c *AppConfig) {
+		if compression != "" {
+			c.FileCompression = compression
+		}
+	}
+}
+
+// WithFileFormatUpgrade включает перевод файлов хранилища в текущую версию формата
+func WithFileFormatUpgrade() Option {
+	return func(c *AppConfig) {
+		c.FileFormatUpgrade = true
+	}
+}
+
+// WithCache задает размер кэша ссылок и время жизни найденных и отсутствующих ссылок
+func WithCache(size int, ttl time.Duration, negativeTTL time.Duration) Option {
+	return func(c *AppConfig) {
+		c.CacheSize = size
+		c.CacheTTL = ttl
+		c.CacheNegativeTTL = negativeTTL
+	}
+}
+
+// WithBloomFilter задает параметры фильтра Блума несуществующих ссылок
+func WithBloomFilter(capacity int, falsePositiveRate float64, rebuildInterval time.Duration, path string) Option {
+	return func(c *AppConfig) {
+		c.BloomFilterCapacity = capacity
+		c.BloomFilterFalsePositiveRate = falsePositiveRate
+		c.BloomFilterRebuildInterval = rebuildInterval
+		c.BloomFilterPath = path
+	}
+}
+
+// WithShadowStorage задает теневое хранилище, в которое дублируется запись.
+// При promote теневое хранилище становится основным, а основное - теневым.
+func WithShadowStorage(storage string, location string, readSampleRate float64, promote bool) Option {
+	return func(c *AppConfig) {
+		c.ShadowStorage = storage
+		c.ShadowStorageLocation = location
+		c.ShadowReadSampleRate = readSampleRate
+		c.ShadowPromote = promote
+	}
+}
+
+// WithAudit задает хранилище журнала аудита и путь его файла
+func WithAudit(sink string, filePath string) Option {
+	return func(c *AppConfig) {
+		if sink != "" {
+			c.AuditSink = sink
+		}
+		c.AuditFilePath = filePath
+	}
+}
+
+// WithWebhooks задает долю переходов, публикуемых в вебхуки, количество попыток доставки,
+// задержки между ними и время ожидания ответа получателя
+func WithWebhooks(clickSampleRate float64, maxAttempts int, retryDelay time.Duration, maxRetryDelay time.Duration, timeout time.Duration) Option {
+	return func(c *AppConfig) {
+		c.WebhookClickSampleRate = clickSampleRate
+		c.WebhookMaxAttempts = maxAttempts
+		c.WebhookRetryDelay = retryDelay
+		c.WebhookMaxRetryDelay = maxRetryDelay
+		c.WebhookTimeout = timeout
+	}
+}
+
+// WithWebhookRetention задает время хранения завершенных доставок вебхуков
+func WithWebhookRetention(retention time.Duration) Option {
+	return func(c *AppConfig) {
+		c.WebhookRetention = retention
+	}
+}
+
+// WithWebhookPrivateNetworks разрешает доставку вебхуков на адреса частных и локальных сетей
+func WithWebhookPrivateNetworks() Option {
+	return func(c *AppConfig) {
+		c.WebhookPrivateNetworks = true
+	}
+}
+
+// WithDeleteQueuePath задает путь файла очереди запросов на удаление
+func WithDeleteQueuePath(path string) Option {
+	return func(c *AppConfig) {
+		c.DeleteQueuePath = path
+	}
+}
+
+// WithStatsLogInterval задает период логирования статистики хранилища
+func WithStatsLogInterval(interval time.Duration) Option {
+	return func(c *AppConfig) {
+		c.StatsLogInterval = interval
+	}
+}
+
+// WithStorageLocation задает тип хранилища и его путь, строку подключения или адрес
+func WithStorageLocation(storageType StorageType, location string) Option {
+	return func(c *AppConfig) {
+		c.StorageType = storageType
+
+		switch storageType {
+		case Database:
+			c.DatabaseDSN = location
+		case SQLite:
+			c.SQLitePath = location
+		case Bolt:
+			c.BoltPath = location
+		case Redis:
+			c.RedisAddr = location
+		case File:
+			c.FileStoragePath = location
+		}
+	}
+}
+
 // WithStorageType задает тип хранилища
 func WithStorageType(storageType StorageType) Option {
 	return func(c *AppConfig) {
@@ -133,6 +542,36 @@ func NewConfig(opts ...Option) *AppConfig {
 		LogLevel:        defaultLogLevel,
 		FileStoragePath: defaultFileStoragePath,
 		DatabaseDSN:     defaultDatabaseDSN,
+		SQLitePath:      defaultSQLitePath,
+		BoltPath:        defaultBoltPath,
+		RedisAddr:       defaultRedisAddr,
+
+		DatabaseReplicaHealthCheckInterval: defaultDatabaseReplicaHealthCheckInterval,
+		DatabaseReadYourWritesWindow:       defaultDatabaseReadYourWritesWindow,
+
+		FileSyncPolicy:   defaultFileSyncPolicy,
+		FileSyncInterval: defaultFileSyncInterval,
+		FileCompression:  defaultFileCompression,
+
+		CacheSize:        defaultCacheSize,
+		CacheTTL:         defaultCacheTTL,
+		CacheNegativeTTL: defaultCacheNegativeTTL,
+
+		BloomFilterFalsePositiveRate: defaultBloomFilterFalsePositiveRate,
+		BloomFilterRebuildInterval:   defaultBloomFilterRebuildInterval,
+
+		ShadowReadSampleRate: defaultShadowReadSampleRate,
+
+		AuditSink: defaultAuditSink,
+
+		WebhookClickSampleRate: defaultWebhookClickSampleRate,
+		WebhookMaxAttempts:     defaultWebhookMaxAttempts,
+		WebhookRetryDelay:      defaultWebhookRetryDelay,
+		WebhookMaxRetryDelay:   defaultWebhookMaxRetryDelay,
+		WebhookTimeout:         defaultWebhookTimeout,
+		WebhookRetention:       defaultWebhookRetention,
+
+		StatsLogInterval: defaultStatsLogInterval,
 	}
 
 	for _, opt := range opts {
@@ -149,10 +588,59 @@ func ParseArgs(programName string, args []string) (appConfig *AppConfig, err err
 
 	flags.StringVar(&appConfig.AppRunAddress, "a", defaultAppRunAddress, fmt.Sprintf("address and port to run server (default: %s)", defaultAppRunAddress))
 	flags.StringVar(&appConfig.BaseURL, "b", defaultBaseURL, fmt.Sprintf("base URL for short links (default: %s)", defaultBaseURL))
+	flags.Func("domains", "comma-separated base URLs of additional short link domains", func(value string) error {
+		appConfig.Domains = strings.Split(value, ",")
+		return nil
+	})
 	flags.StringVar(&appConfig.LogLevel, "l", defaultLogLevel, fmt.Sprintf("log level (default: %s)", defaultLogLevel))
 	flags.StringVar(&appConfig.FileStoragePath, "f", defaultFileStoragePath, "file storage path")
-	flags.StringVar(&appConfig.DatabaseDSN, "d", defaultDatabaseDSN, "database DSN")
+	flags.StringVar(&appConfig.DatabaseDSN, "d", defaultDatabaseDSN, fmt.Sprintf("database DSN (%s<path> selects SQLite)", SQLiteDSNScheme))
+	flags.IntVar(&appConfig.DatabaseMaxConns, "db-max-conns", defaultDatabaseMaxConns, "maximum number of database connections (0 uses the pool default)")
+	flags.IntVar(&appConfig.DatabaseMinConns, "db-min-conns", defaultDatabaseMinConns, "minimum number of idle database connections")
+	flags.DurationVar(&appConfig.DatabaseMaxConnLifetime, "db-max-conn-lifetime", defaultDatabaseMaxConnLifetime, "maximum database connection lifetime (0 uses the pool default)")
+	flags.DurationVar(&appConfig.DatabaseMaxConnIdleTime, "db-max-conn-idle-time", defaultDatabaseMaxConnIdleTime, "maximum database connection idle time (0 uses the pool default)")
+	flags.DurationVar(&appConfig.DatabaseAcquireTimeout, "db-acquire-timeout", defaultDatabaseAcquireTimeout, "database connection acquire timeout (0 waits indefinitely)")
+	flags.Func("db-replicas", "comma-separated database replica DSNs for read queries", func(value string) error {
+		appConfig.DatabaseReplicaDSNs = strings.Split(value, ",")
+		return nil
+	})
+	flags.DurationVar(&appConfig.DatabaseReplicaHealthCheckInterval, "db-replica-health-check-interval", defaultDatabaseReplicaHealthCheckInterval, "database replica health check interval")
+	flags.Func("db-shards", "comma-separated database shard DSNs; new shards are only appended", func(value string) error {
+		appConfig.DatabaseShardDSNs = strings.Split(value, ",")
+		return nil
+	})
+	flags.DurationVar(&appConfig.DatabaseReadYourWritesWindow, "db-read-your-writes-window", defaultDatabaseReadYourWritesWindow, "time after a write during which reads of the written data go to the primary database (0 disables)")
+	flags.StringVar(&appConfig.SQLitePath, "sqlite", defaultSQLitePath, "SQLite database file path")
+	flags.StringVar(&appConfig.BoltPath, "bolt", defaultBoltPath, "bbolt database file path")
+	flags.StringVar(&appConfig.RedisAddr, "redis", defaultRedisAddr, "Redis address (host:port or redis:// URL)")
 	flags.BoolVar(&appConfig.EnableProfiling, "p", defaultProfiling, "enable pprof server at /debug")
+	flags.StringVar(&appConfig.FileSyncPolicy, "file-sync", defaultFileSyncPolicy, "file storage fsync policy: always, interval or never")
+	flags.DurationVar(&appConfig.FileSyncInterval, "file-sync-interval", defaultFileSyncInterval, "file storage fsync interval for the interval policy")
+	flags.StringVar(&appConfig.FileCompression, "file-compression", defaultFileCompression, "file storage compression for new files: none, gzip or zstd")
+	flags.BoolVar(&appConfig.FileFormatUpgrade, "file-upgrade", defaultFileFormatUpgrade, "upgrade file storage to the current format on startup")
+	flags.DurationVar(&appConfig.FileCompactionInterval, "file-compaction-interval", defaultFileCompactionInterval, "file storage compaction interval (0 disables compaction)")
+	flags.IntVar(&appConfig.CacheSize, "cache-size", defaultCacheSize, "number of links in the redirect cache (0 disables the cache)")
+	flags.DurationVar(&appConfig.CacheTTL, "cache-ttl", defaultCacheTTL, "redirect cache entry lifetime (0 keeps entries until eviction)")
+	flags.IntVar(&appConfig.BloomFilterCapacity, "bloom-capacity", defaultBloomFilterCapacity, "expected number of links in the bloom filter of existing short IDs (0 disables the filter; not supported with database, shards or redis)")
+	flags.Float64Var(&appConfig.BloomFilterFalsePositiveRate, "bloom-fp-rate", defaultBloomFilterFalsePositiveRate, "bloom filter false positive rate")
+	flags.DurationVar(&appConfig.BloomFilterRebuildInterval, "bloom-rebuild-interval", defaultBloomFilterRebuildInterval, "bloom filter rebuild interval (0 disables rebuilds)")
+	flags.StringVar(&appConfig.BloomFilterPath, "bloom-path", defaultBloomFilterPath, "file to persist the bloom filter between restarts")
+	flags.StringVar(&appConfig.ShadowStorage, "shadow-storage", defaultShadowStorage, "shadow storage type that receives duplicated writes: file, database, sqlite, bolt or redis")
+	flags.StringVar(&appConfig.ShadowStorageLocation, "shadow-location", defaultShadowStorageLocation, "shadow storage file path, DSN or address")
+	flags.Float64Var(&appConfig.ShadowReadSampleRate, "shadow-sample-rate", defaultShadowReadSampleRate, "fraction of reads compared with the shadow storage")
+	flags.BoolVar(&appConfig.ShadowPromote, "shadow-promote", defaultShadowPromote, "serve reads from the shadow storage and keep duplicating writes to the main one")
+	flags.StringVar(&appConfig.AuditSink, "audit-sink", defaultAuditSink, "audit log sink: none, file or database")
+	flags.StringVar(&appConfig.AuditFilePath, "audit-file", defaultAuditFilePath, "audit log file path for the file sink")
+	flags.Float64Var(&appConfig.WebhookClickSampleRate, "webhook-click-sample-rate", defaultWebhookClickSampleRate, "fraction of redirects published as link.clicked webhook events")
+	flags.IntVar(&appConfig.WebhookMaxAttempts, "webhook-max-attempts", defaultWebhookMaxAttempts, "webhook delivery attempts before a delivery is dead")
+	flags.DurationVar(&appConfig.WebhookRetryDelay, "webhook-retry-delay", defaultWebhookRetryDelay, "delay before the second webhook delivery attempt, doubled for each next one")
+	flags.DurationVar(&appConfig.WebhookMaxRetryDelay, "webhook-max-retry-delay", defaultWebhookMaxRetryDelay, "maximum delay between webhook delivery attempts")
+	flags.DurationVar(&appConfig.WebhookTimeout, "webhook-timeout", defaultWebhookTimeout, "webhook receiver response timeout")
+	flags.BoolVar(&appConfig.WebhookPrivateNetworks, "webhook-private-networks", defaultWebhookPrivateNetworks, "allow webhook delivery to private, loopback and link-local addresses (development only)")
+	flags.DurationVar(&appConfig.WebhookRetention, "webhook-retention", defaultWebhookRetention, "how long delivered and dead webhook deliveries are kept")
+	flags.StringVar(&appConfig.DeleteQueuePath, "delete-queue-file", defaultDeleteQueuePath, "file of accepted delete requests that survive restarts (default: next to the storage file, in Redis with Redis storage; unused with a database DSN or shards)")
+	flags.DurationVar(&appConfig.StatsLogInterval, "stats-log-interval", defaultStatsLogInterval, "period of logging redirect cache, bloom filter and shadow storage stats (0 logs them only at shutdown)")
+	flags.DurationVar(&appConfig.CacheNegativeTTL, "cache-negative-ttl", defaultCacheNegativeTTL, "redirect cache lifetime of missing links (0 disables negative caching)")
 
 	err = flags.Parse(args)
 
@@ -170,6 +658,11 @@ func ParseArgs(programName string, args []string) (appConfig *AppConfig, err err
 		return nil, err
 	}
 
+	if sqlitePath, ok := strings.CutPrefix(appConfig.DatabaseDSN, SQLiteDSNScheme); ok {
+		appConfig.SQLitePath = sqlitePath
+		appConfig.DatabaseDSN = ""
+	}
+
 	appConfig.StorageType = getStorageType(appConfig)
 
 	log.Printf("Parsed app config: %+v\n", appConfig)
@@ -182,6 +675,10 @@ func validateParsedArgs(appConfig *AppConfig) error {
 		return ErrInvalidBaseURL
 	}
 
+	if err := validateDomains(appConfig); err != nil {
+		return err
+	}
+
 	if _, err := net.ResolveTCPAddr("tcp", appConfig.AppRunAddress); err != nil {
 		return ErrInvalidAppRunAddress
 	}
@@ -190,6 +687,155 @@ func validateParsedArgs(appConfig *AppConfig) error {
 		return ErrInvalidLogLevel
 	}
 
+	if appConfig.DatabaseMaxConns < 0 || appConfig.DatabaseMaxConns > math.MaxInt32 ||
+		appConfig.DatabaseMinConns < 0 || appConfig.DatabaseMinConns > math.MaxInt32 ||
+		appConfig.DatabaseMaxConnLifetime < 0 || appConfig.DatabaseMaxConnIdleTime < 0 || appConfig.DatabaseAcquireTimeout < 0 {
+		return ErrInvalidDatabasePool
+	}
+
+	if appConfig.DatabaseMaxConns > 0 && appConfig.DatabaseMinConns > appConfig.DatabaseMaxConns {
+		return ErrInvalidDatabasePool
+	}
+
+	if appConfig.DatabaseReplicaHealthCheckInterval <= 0 || appConfig.DatabaseReadYourWritesWindow < 0 {
+		return ErrInvalidDatabaseReplicas
+	}
+
+	if len(appConfig.DatabaseShardDSNs) > 0 && (appConfig.DatabaseDSN != "" || len(appConfig.DatabaseReplicaDSNs) > 0 ||
+		slices.Contains(appConfig.DatabaseShardDSNs, "")) {
+		return ErrInvalidDatabaseShards
+	}
+
+	if _, err := storage.ParseSyncPolicy(appConfig.FileSyncPolicy); err != nil {
+		return ErrInvalidFileSyncPolicy
+	}
+
+	if appConfig.FileSyncInterval <= 0 {
+		return ErrInvalidFileSyncPolicy
+	}
+
+	if _, err := storage.ParseCompression(appConfig.FileCompression); err != nil {
+		return ErrInvalidFileCompression
+	}
+
+	if appConfig.CacheSize < 0 || appConfig.CacheTTL < 0 || appConfig.CacheNegativeTTL < 0 {
+		return ErrInvalidCache
+	}
+
+	if err := validateBloomFilter(appConfig); err != nil {
+		return err
+	}
+
+	if err := validateShadowStorage(appConfig); err != nil {
+		return err
+	}
+
+	if err := validateAuditSink(appConfig); err != nil {
+		return err
+	}
+
+	if err := validateWebhooks(appConfig); err != nil {
+		return err
+	}
+
+	// с БД Postgres (и шардами БД) очередь хранится в таблице
+	if appConfig.DeleteQueuePath != "" && isPostgres(appConfig) {
+		return ErrInvalidDeleteQueue
+	}
+
+	if appConfig.StatsLogInterval < 0 {
+		return ErrInvalidStatsLogInterval
+	}
+
+	return nil
+}
+
+// validateBloomFilter проверяет настройки фильтра Блума. Фильтр не используется с хранилищами,
+// общими для нескольких экземпляров сервиса (Postgres, шарды БД, Redis): ссылки, сохраненные
+// другим экземпляром, до перестроения фильтра отвечали бы 404.
+func validateBloomFilter(appConfig *AppConfig) error {
+	if appConfig.BloomFilterCapacity < 0 || appConfig.BloomFilterRebuildInterval < 0 ||
+		appConfig.BloomFilterFalsePositiveRate <= 0 || appConfig.BloomFilterFalsePositiveRate >= 1 {
+		return ErrInvalidBloomFilter
+	}
+
+	if appConfig.BloomFilterCapacity > 0 && (isPostgres(appConfig) || appConfig.RedisAddr != "") {
+		return ErrInvalidBloomFilter
+	}
+
+	return nil
+}
+
+// isPostgres проверяет, что ссылки хранятся в БД Postgres или ее шардах
+func isPostgres(appConfig *AppConfig) bool {
+	return (appConfig.DatabaseDSN != "" && !strings.HasPrefix(appConfig.DatabaseDSN, SQLiteDSNScheme)) ||
+		len(appConfig.DatabaseShardDSNs) > 0
+}
+
+func validateDomains(appConfig *AppConfig) error {
+	hosts := map[string]bool{urlHost(appConfig.BaseURL): true}
+	for _, domain := range appConfig.Domains {
+		if !isURL(domain) || hosts[urlHost(domain)] {
+			return ErrInvalidDomains
+		}
+		hosts[urlHost(domain)] = true
+	}
+
+	return nil
+}
+
+func validateShadowStorage(appConfig *AppConfig) error {
+	if appConfig.ShadowReadSampleRate < 0 || appConfig.ShadowReadSampleRate > 1 {
+		return ErrInvalidShadowStorage
+	}
+
+	if appConfig.ShadowStorage == "" {
+		if appConfig.ShadowStorageLocation != "" || appConfig.ShadowPromote {
+			return ErrInvalidShadowStorage
+		}
+		return nil
+	}
+
+	storageType, err := ParseStorageType(appConfig.ShadowStorage)
+	if err != nil || storageType == None || appConfig.ShadowStorageLocation == "" {
+		return ErrInvalidShadowStorage
+	}
+
+	return nil
+}
+
+func validateAuditSink(appConfig *AppConfig) error {
+	switch appConfig.AuditSink {
+	case AuditSinkNone:
+		if appConfig.AuditFilePath != "" {
+			return ErrInvalidAuditSink
+		}
+	case AuditSinkFile:
+		if appConfig.AuditFilePath == "" {
+			return ErrInvalidAuditSink
+		}
+	case AuditSinkDatabase:
+		if !isPostgres(appConfig) || appConfig.AuditFilePath != "" {
+			return ErrInvalidAuditSink
+		}
+	default:
+		return ErrInvalidAuditSink
+	}
+
+	return nil
+}
+
+func validateWebhooks(appConfig *AppConfig) error {
+	if appConfig.WebhookClickSampleRate < 0 || appConfig.WebhookClickSampleRate > 1 {
+		return ErrInvalidWebhooks
+	}
+
+	if appConfig.WebhookMaxAttempts < 1 || appConfig.WebhookTimeout <= 0 ||
+		appConfig.WebhookRetryDelay <= 0 || appConfig.WebhookMaxRetryDelay < appConfig.WebhookRetryDelay ||
+		appConfig.WebhookRetention <= 0 {
+		return ErrInvalidWebhooks
+	}
+
 	return nil
 }
 
@@ -198,9 +844,25 @@ func isURL(str string) bool {
 	return err == nil && u.Scheme != "" && u.Host != ""
 }
 
+// urlHost возвращает хост URL в нижнем регистре
+func urlHost(str string) string {
+	u, err := url.Parse(str)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Host)
+}
+
 func getStorageType(appConfig *AppConfig) StorageType {
-	if appConfig.DatabaseDSN != "" {
+	if appConfig.DatabaseDSN != "" || len(appConfig.DatabaseShardDSNs) > 0 {
 		return Database
+	} else if appConfig.RedisAddr != "" {
+		return Redis
+	} else if appConfig.SQLitePath != "" {
+		return SQLite
+	} else if appConfig.BoltPath != "" {
+		return Bolt
 	} else if appConfig.FileStoragePath != "" {
 		return File
 	}
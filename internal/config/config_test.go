@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -45,6 +46,120 @@ func TestParseFlags(t *testing.T) {
 			[]string{programName, "-d", "postgresql://user@localhost/db"},
 			*NewConfig(WithDatabseDSN("postgresql://user@localhost/db"), WithStorageType(Database)),
 		},
+		{
+			"only SQLite path",
+			[]string{programName, "-sqlite", "shortener.db"},
+			*NewConfig(WithSQLitePath("shortener.db"), WithStorageType(SQLite)),
+		},
+		{
+			"SQLite DSN",
+			[]string{programName, "-d", "sqlite:///var/lib/shortener.db", "-f", "storage.json"},
+			*NewConfig(WithSQLitePath("/var/lib/shortener.db"), WithFileStoragePath("storage.json"), WithStorageType(SQLite)),
+		},
+		{
+			"only bbolt path",
+			[]string{programName, "-bolt", "shortener.bolt"},
+			*NewConfig(WithBoltPath("shortener.bolt"), WithStorageType(Bolt)),
+		},
+		{
+			"only Redis address",
+			[]string{programName, "-redis", "localhost:6379"},
+			*NewConfig(WithRedisAddr("localhost:6379"), WithStorageType(Redis)),
+		},
+		{
+			"database pool settings",
+			[]string{programName, "-d", "postgresql://user@localhost/db", "-db-max-conns", "20", "-db-min-conns", "2",
+				"-db-max-conn-lifetime", "1h", "-db-max-conn-idle-time", "5m", "-db-acquire-timeout", "500ms"},
+			*NewConfig(WithDatabseDSN("postgresql://user@localhost/db"), WithStorageType(Database),
+				WithDatabasePool(20, 2, time.Hour, 5*time.Minute, 500*time.Millisecond)),
+		},
+		{
+			"database replicas",
+			[]string{programName, "-d", "postgresql://user@primary/db", "-db-replicas", "postgresql://user@replica1/db,postgresql://user@replica2/db",
+				"-db-replica-health-check-interval", "10s", "-db-read-your-writes-window", "2s"},
+			*NewConfig(WithDatabseDSN("postgresql://user@primary/db"), WithStorageType(Database),
+				WithDatabaseReplicas([]string{"postgresql://user@replica1/db", "postgresql://user@replica2/db"}, 10*time.Second, 2*time.Second)),
+		},
+		{
+			"branded domains",
+			[]string{programName, "-domains", "https://go.brand.com,https://links.example.org:8443"},
+			*NewConfig(WithDomains([]string{"https://go.brand.com", "https://links.example.org:8443"})),
+		},
+		{
+			"database shards",
+			[]string{programName, "-db-shards", "postgresql://user@shard1/db,postgresql://user@shard2/db"},
+			*NewConfig(WithStorageType(Database),
+				WithDatabaseShards([]string{"postgresql://user@shard1/db", "postgresql://user@shard2/db"})),
+		},
+		{
+			"file sync policy",
+			[]string{programName, "-f", "storage.json", "-file-sync", "interval", "-file-sync-interval", "50ms"},
+			*NewConfig(WithFileStoragePath("storage.json"), WithStorageType(File), WithFileSync("interval", 50*time.Millisecond)),
+		},
+		{
+			"file compression and upgrade",
+			[]string{programName, "-f", "storage.json", "-file-compression", "zstd", "-file-upgrade"},
+			*NewConfig(WithFileStoragePath("storage.json"), WithStorageType(File), WithFileCompression("zstd"), WithFileFormatUpgrade()),
+		},
+		{
+			"redirect cache",
+			[]string{programName, "-cache-size", "10000", "-cache-ttl", "5m", "-cache-negative-ttl", "0"},
+			*NewConfig(WithCache(10000, 5*time.Minute, 0)),
+		},
+		{
+			"bloom filter",
+			[]string{programName, "-bloom-capacity", "100000", "-bloom-fp-rate", "0.001", "-bloom-rebuild-interval", "30m", "-bloom-path", "bloom.bin"},
+			*NewConfig(WithBloomFilter(100000, 0.001, 30*time.Minute, "bloom.bin")),
+		},
+		{
+			"shadow storage",
+			[]string{programName, "-f", "storage.json", "-shadow-storage", "database", "-shadow-location", "postgresql://user@localhost/db",
+				"-shadow-sample-rate", "0.1", "-shadow-promote"},
+			*NewConfig(WithFileStoragePath("storage.json"), WithStorageType(File),
+				WithShadowStorage("database", "postgresql://user@localhost/db", 0.1, true)),
+		},
+		{
+			"file audit log",
+			[]string{programName, "-audit-sink", "file", "-audit-file", "audit.log"},
+			*NewConfig(WithAudit(AuditSinkFile, "audit.log")),
+		},
+		{
+			"database audit log",
+			[]string{programName, "-d", "postgresql://user@localhost/db", "-audit-sink", "database"},
+			*NewConfig(WithDatabseDSN("postgresql://user@localhost/db"), WithStorageType(Database), WithAudit(AuditSinkDatabase, "")),
+		},
+		{
+			"database audit log with shards",
+			[]string{programName, "-db-shards", "postgresql://user@shard1/db,postgresql://user@shard2/db", "-audit-sink", "database"},
+			*NewConfig(WithStorageType(Database), WithDatabaseShards([]string{"postgresql://user@shard1/db", "postgresql://user@shard2/db"}),
+				WithAudit(AuditSinkDatabase, "")),
+		},
+		{
+			"webhook settings",
+			[]string{programName, "-webhook-click-sample-rate", "0.5", "-webhook-max-attempts", "3", "-webhook-retry-delay", "2s",
+				"-webhook-max-retry-delay", "1m", "-webhook-timeout", "10s"},
+			*NewConfig(WithWebhooks(0.5, 3, time.Second*2, time.Minute, time.Second*10)),
+		},
+		{
+			"webhook private networks",
+			[]string{programName, "-webhook-private-networks"},
+			*NewConfig(WithWebhookPrivateNetworks()),
+		},
+		{
+			"webhook retention",
+			[]string{programName, "-webhook-retention", "24h"},
+			*NewConfig(WithWebhookRetention(time.Hour * 24)),
+		},
+		{
+			"stats log interval",
+			[]string{programName, "-stats-log-interval", "0"},
+			*NewConfig(WithStatsLogInterval(0)),
+		},
+		{
+			"delete queue file",
+			[]string{programName, "-f", "storage.json", "-delete-queue-file", "deletes.log"},
+			*NewConfig(WithStorageLocation(File, "storage.json"), WithDeleteQueuePath("deletes.log")),
+		},
 		{
 			"full args",
 			[]string{programName, "-a", ":8888", "-b", "http://test.com/", "-l", "debug"},
@@ -83,6 +198,166 @@ func TestParseArgsErr(t *testing.T) {
 			[]string{programName, "-l", "debug123"},
 			ErrInvalidLogLevel,
 		},
+		{
+			"min database connections above max",
+			[]string{programName, "-db-max-conns", "2", "-db-min-conns", "5"},
+			ErrInvalidDatabasePool,
+		},
+		{
+			"negative database acquire timeout",
+			[]string{programName, "-db-acquire-timeout", "-1s"},
+			ErrInvalidDatabasePool,
+		},
+		{
+			"negative read-your-writes window",
+			[]string{programName, "-db-read-your-writes-window", "-1s"},
+			ErrInvalidDatabaseReplicas,
+		},
+		{
+			"invalid domain",
+			[]string{programName, "-domains", "go.brand.com"},
+			ErrInvalidDomains,
+		},
+		{
+			"duplicate domain",
+			[]string{programName, "-domains", "https://go.brand.com,http://GO.brand.com"},
+			ErrInvalidDomains,
+		},
+		{
+			"domain of base URL",
+			[]string{programName, "-b", "http://localhost:8080", "-domains", "https://localhost:8080"},
+			ErrInvalidDomains,
+		},
+		{
+			"database shards with database DSN",
+			[]string{programName, "-d", "postgresql://user@localhost/db", "-db-shards", "postgresql://user@shard1/db"},
+			ErrInvalidDatabaseShards,
+		},
+		{
+			"empty database shard DSN",
+			[]string{programName, "-db-shards", "postgresql://user@shard1/db,"},
+			ErrInvalidDatabaseShards,
+		},
+		{
+			"invalid file sync policy",
+			[]string{programName, "-file-sync", "sometimes"},
+			ErrInvalidFileSyncPolicy,
+		},
+		{
+			"invalid file compression",
+			[]string{programName, "-file-compression", "lz4"},
+			ErrInvalidFileCompression,
+		},
+		{
+			"negative stats log interval",
+			[]string{programName, "-stats-log-interval", "-1s"},
+			ErrInvalidStatsLogInterval,
+		},
+		{
+			"negative cache size",
+			[]string{programName, "-cache-size", "-1"},
+			ErrInvalidCache,
+		},
+		{
+			"invalid bloom filter false positive rate",
+			[]string{programName, "-bloom-capacity", "1000", "-bloom-fp-rate", "1.5"},
+			ErrInvalidBloomFilter,
+		},
+		{
+			"bloom filter with database",
+			[]string{programName, "-d", "postgresql://user@localhost/db", "-bloom-capacity", "1000"},
+			ErrInvalidBloomFilter,
+		},
+		{
+			"bloom filter with shards",
+			[]string{programName, "-db-shards", "postgresql://user@shard1/db,postgresql://user@shard2/db", "-bloom-capacity", "1000"},
+			ErrInvalidBloomFilter,
+		},
+		{
+			"bloom filter with redis",
+			[]string{programName, "-redis", "localhost:6379", "-bloom-capacity", "1000"},
+			ErrInvalidBloomFilter,
+		},
+		{
+			"unknown shadow storage type",
+			[]string{programName, "-shadow-storage", "mongodb", "-shadow-location", "mongodb://localhost"},
+			ErrInvalidShadowStorage,
+		},
+		{
+			"shadow storage without location",
+			[]string{programName, "-shadow-storage", "sqlite"},
+			ErrInvalidShadowStorage,
+		},
+		{
+			"promote without shadow storage",
+			[]string{programName, "-shadow-promote"},
+			ErrInvalidShadowStorage,
+		},
+		{
+			"invalid shadow sample rate",
+			[]string{programName, "-shadow-storage", "sqlite", "-shadow-location", "shortener.db", "-shadow-sample-rate", "2"},
+			ErrInvalidShadowStorage,
+		},
+		{
+			"unknown audit sink",
+			[]string{programName, "-audit-sink", "syslog"},
+			ErrInvalidAuditSink,
+		},
+		{
+			"file audit log without path",
+			[]string{programName, "-audit-sink", "file"},
+			ErrInvalidAuditSink,
+		},
+		{
+			"audit file without file sink",
+			[]string{programName, "-audit-file", "audit.log"},
+			ErrInvalidAuditSink,
+		},
+		{
+			"database audit log without database",
+			[]string{programName, "-audit-sink", "database"},
+			ErrInvalidAuditSink,
+		},
+		{
+			"database audit log with SQLite",
+			[]string{programName, "-d", "sqlite://shortener.db", "-audit-sink", "database"},
+			ErrInvalidAuditSink,
+		},
+		{
+			"webhook click sample rate above one",
+			[]string{programName, "-webhook-click-sample-rate", "1.5"},
+			ErrInvalidWebhooks,
+		},
+		{
+			"zero webhook attempts",
+			[]string{programName, "-webhook-max-attempts", "0"},
+			ErrInvalidWebhooks,
+		},
+		{
+			"webhook max retry delay below retry delay",
+			[]string{programName, "-webhook-retry-delay", "1m", "-webhook-max-retry-delay", "1s"},
+			ErrInvalidWebhooks,
+		},
+		{
+			"zero webhook timeout",
+			[]string{programName, "-webhook-timeout", "0s"},
+			ErrInvalidWebhooks,
+		},
+		{
+			"zero webhook retention",
+			[]string{programName, "-webhook-retention", "0s"},
+			ErrInvalidWebhooks,
+		},
+		{
+			"delete queue file with database",
+			[]string{programName, "-d", "postgresql://user@localhost/db", "-delete-queue-file", "deletes.log"},
+			ErrInvalidDeleteQueue,
+		},
+		{
+			"delete queue file with database shards",
+			[]string{programName, "-db-shards", "postgresql://user@shard0/db,postgresql://user@shard1/db", "-delete-queue-file", "deletes.log"},
+			ErrInvalidDeleteQueue,
+		},
 	}
 
 	for _, tt := range tests {
@@ -92,3 +367,14 @@ func TestParseArgsErr(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStorageType(t *testing.T) {
+	for storageType := None; storageType <= Redis; storageType++ {
+		parsed, err := ParseStorageType(storageType.String())
+		require.NoError(t, err)
+		assert.Equal(t, storageType, parsed)
+	}
+
+	_, err := ParseStorageType("mongodb")
+	assert.ErrorIs(t, err, ErrInvalidStorageType)
+}
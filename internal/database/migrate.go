@@ -0,0 +1,298 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// SchemaMigrationsTableName имя таблицы примененных миграций
+const SchemaMigrationsTableName = "schema_migrations"
+
+// migrationsLockID ключ advisory lock, под которым выполняются миграции
+const migrationsLockID int64 = 0x73686f7274656e // "shorten"
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var createSchemaMigrationsSQL = fmt.Sprintf(
+	`CREATE TABLE IF NOT EXISTS %s (
+		version BIGINT PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`, SchemaMigrationsTableName)
+
+// Ошибки
+var (
+	// ErrInvalidMigration ошибка некорректного набора файлов миграций
+	ErrInvalidMigration = errors.New("invalid migration")
+	// ErrUnknownMigrationVersion ошибка перехода к несуществующей версии схемы
+	ErrUnknownMigrationVersion = errors.New("unknown migration version")
+)
+
+// Migration миграция схемы БД
+type Migration struct {
+	// Version номер версии схемы после применения миграции
+	Version int64
+	// Name название миграции
+	Name string
+	// Up SQL применения миграции
+	Up string
+	// Down SQL отката миграции
+	Down string
+}
+
+// migrationStep шаг перехода между версиями схемы
+type migrationStep struct {
+	migration Migration
+	up        bool
+}
+
+// LoadMigrations читает миграции из файлов <version>_<name>.up.sql и <version>_<name>.down.sql
+// в корне fsys. Для каждой версии должны присутствовать оба файла.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, filename := range files {
+		match := migrationFileRegexp.FindStringSubmatch(path.Base(filename))
+		if match == nil {
+			return nil, fmt.Errorf("%w: unexpected file %q", ErrInvalidMigration, filename)
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: invalid version in %q", ErrInvalidMigration, filename)
+		}
+
+		content, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("%w: version %d has different names", ErrInvalidMigration, version)
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("%w: version %d must have up and down files", ErrInvalidMigration, migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator применяет миграции схемы к БД Postgres.
+// Миграции выполняются под advisory lock, поэтому несколько экземпляров сервиса,
+// запущенных одновременно, применяют каждую миграцию ровно один раз.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator создает Migrator со встроенными миграциями сервиса
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrationsFS, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := LoadMigrations(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// LatestVersion возвращает версию схемы после применения всех миграций
+func (m *Migrator) LatestVersion() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version возвращает текущую версию схемы БД
+func (m *Migrator) Version(ctx context.Context) (version int64, err error) {
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		version, err = currentVersion(ctx, conn)
+		return err
+	})
+
+	return version, err
+}
+
+// Up применяет все непримененные миграции
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.LatestVersion())
+}
+
+// Down откатывает последнюю примененную миграцию
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil || current == 0 {
+			return err
+		}
+
+		var target int64
+		for _, migration := range m.migrations {
+			if migration.Version < current {
+				target = migration.Version
+			}
+		}
+
+		return m.migrate(ctx, conn, current, target)
+	})
+}
+
+// To применяет или откатывает миграции до версии target (0 - откатить все)
+func (m *Migrator) To(ctx context.Context, target int64) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		return m.migrate(ctx, conn, current, target)
+	})
+}
+
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, current int64, target int64) error {
+	steps, err := planMigrations(m.migrations, current, target)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if err := applyMigrationStep(ctx, conn, step); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", step.migration.Version, step.migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// withLock выполняет fn на отдельном подключении под advisory lock миграций
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationsLockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationsLockID)
+
+	if _, err = conn.ExecContext(ctx, createSchemaMigrationsSQL); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// planMigrations возвращает шаги перехода от версии current к версии target
+func planMigrations(migrations []Migration, current int64, target int64) ([]migrationStep, error) {
+	if target != 0 && findMigration(migrations, target) < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMigrationVersion, target)
+	}
+
+	steps := make([]migrationStep, 0)
+	if target >= current {
+		for _, migration := range migrations {
+			if migration.Version > current && migration.Version <= target {
+				steps = append(steps, migrationStep{migration: migration, up: true})
+			}
+		}
+		return steps, nil
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if migrations[i].Version <= current && migrations[i].Version > target {
+			steps = append(steps, migrationStep{migration: migrations[i], up: false})
+		}
+	}
+
+	return steps, nil
+}
+
+func findMigration(migrations []Migration, version int64) int {
+	for i, migration := range migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (version int64, err error) {
+	row := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", SchemaMigrationsTableName))
+	err = row.Scan(&version)
+
+	return version, err
+}
+
+func applyMigrationStep(ctx context.Context, conn *sql.Conn, step migrationStep) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if step.up {
+		if _, err = tx.ExecContext(ctx, step.migration.Up); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("INSERT INTO %s (version, name) VALUES ($1, $2)", SchemaMigrationsTableName),
+			step.migration.Version, step.migration.Name)
+	} else {
+		if _, err = tx.ExecContext(ctx, step.migration.Down); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("DELETE FROM %s WHERE version = $1", SchemaMigrationsTableName),
+			step.migration.Version)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMigratorEmbeddedMigrations(t *testing.T) {
+	migrator, err := NewMigrator(nil)
+	require.NoError(t, err)
+
+	require.NotEmpty(t, migrator.migrations)
+	assert.Equal(t, int64(1), migrator.migrations[0].Version)
+	assert.Equal(t, migrator.migrations[len(migrator.migrations)-1].Version, migrator.LatestVersion())
+
+	for _, migration := range migrator.migrations {
+		assert.NotContains(t, migration.Up, "DROP TABLE", "up migration %d must not drop tables", migration.Version)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name         string
+		fsys         fstest.MapFS
+		wantVersions []int64
+		wantErr      error
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"10_second.up.sql":   {Data: []byte("up 10")},
+				"10_second.down.sql": {Data: []byte("down 10")},
+				"2_first.up.sql":     {Data: []byte("up 2")},
+				"2_first.down.sql":   {Data: []byte("down 2")},
+			},
+			wantVersions: []int64{2, 10},
+		},
+		{
+			name: "missing down",
+			fsys: fstest.MapFS{
+				"1_init.up.sql": {Data: []byte("up")},
+			},
+			wantErr: ErrInvalidMigration,
+		},
+		{
+			name: "different names",
+			fsys: fstest.MapFS{
+				"1_init.up.sql":    {Data: []byte("up")},
+				"1_other.down.sql": {Data: []byte("down")},
+			},
+			wantErr: ErrInvalidMigration,
+		},
+		{
+			name: "unexpected file name",
+			fsys: fstest.MapFS{
+				"init.sql": {Data: []byte("up")},
+			},
+			wantErr: ErrInvalidMigration,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := LoadMigrations(tt.fsys)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			versions := make([]int64, 0, len(migrations))
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+			}
+			assert.Equal(t, tt.wantVersions, versions)
+		})
+	}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+
+	tests := []struct {
+		name      string
+		current   int64
+		target    int64
+		wantSteps []migrationStep
+		wantErr   error
+	}{
+		{
+			name:    "up from empty",
+			current: 0,
+			target:  3,
+			wantSteps: []migrationStep{
+				{migration: migrations[0], up: true},
+				{migration: migrations[1], up: true},
+				{migration: migrations[2], up: true},
+			},
+		},
+		{
+			name:    "up to intermediate version",
+			current: 1,
+			target:  2,
+			wantSteps: []migrationStep{
+				{migration: migrations[1], up: true},
+			},
+		},
+		{
+			name:      "already at target",
+			current:   2,
+			target:    2,
+			wantSteps: []migrationStep{},
+		},
+		{
+			name:    "down to zero",
+			current: 3,
+			target:  0,
+			wantSteps: []migrationStep{
+				{migration: migrations[2], up: false},
+				{migration: migrations[1], up: false},
+				{migration: migrations[0], up: false},
+			},
+		},
+		{
+			name:    "unknown target",
+			current: 1,
+			target:  5,
+			wantErr: ErrUnknownMigrationVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := planMigrations(migrations, tt.current, tt.target)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSteps, steps)
+		})
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS short_links;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users (
+	id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY
+);
+
+CREATE TABLE IF NOT EXISTS short_links (
+	id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+	short_id varchar(8) NOT NULL,
+	full_url text UNIQUE NOT NULL,
+	is_deleted boolean NOT NULL,
+	user_id INT REFERENCES users(id)
+);
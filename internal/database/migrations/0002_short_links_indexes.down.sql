@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS short_links_user_id_idx;
+DROP INDEX IF EXISTS short_links_short_id_idx;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS short_links_short_id_idx ON short_links (short_id);
+CREATE INDEX IF NOT EXISTS short_links_user_id_idx ON short_links (user_id);
@@ -0,0 +1,2 @@
+-- откат возможен только после удаления ссылок дополнительных доменов
+ALTER TABLE short_links ALTER COLUMN short_id TYPE varchar(8);
@@ -0,0 +1,2 @@
+-- ключ ссылки дополнительного домена содержит хост домена перед коротким ID
+ALTER TABLE short_links ALTER COLUMN short_id TYPE text;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS short_links_short_id_key;
+CREATE INDEX IF NOT EXISTS short_links_short_id_idx ON short_links (short_id);
@@ -0,0 +1,11 @@
+-- до уникального индекса разные ссылки могли получить один короткий ID:
+-- ключ остается у самой ранней ссылки, остальные получают ключ с номером строки
+UPDATE short_links SET short_id = short_id || '-' || id
+WHERE id IN (
+	SELECT id FROM (
+		SELECT id, row_number() OVER (PARTITION BY short_id ORDER BY id) AS n FROM short_links
+	) AS duplicates
+	WHERE n > 1
+);
+DROP INDEX IF EXISTS short_links_short_id_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS short_links_short_id_key ON short_links (short_id);
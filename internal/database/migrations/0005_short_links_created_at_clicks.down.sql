@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS short_links_user_id_created_at_idx;
+ALTER TABLE short_links DROP COLUMN IF EXISTS clicks;
+ALTER TABLE short_links DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,4 @@
+-- время создания ссылки и счетчик переходов для сортировки ссылок пользователя
+ALTER TABLE short_links ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE short_links ADD COLUMN IF NOT EXISTS clicks bigint NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS short_links_user_id_created_at_idx ON short_links (user_id, created_at);
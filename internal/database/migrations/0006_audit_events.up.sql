@@ -0,0 +1,26 @@
+-- журнал изменений ссылок и пользователей; записи только добавляются
+CREATE TABLE IF NOT EXISTS audit_events (
+	id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	action text NOT NULL,
+	user_id int NOT NULL,
+	client_ip text NOT NULL DEFAULT '',
+	request_id text NOT NULL DEFAULT '',
+	short_id text NOT NULL DEFAULT '',
+	before jsonb,
+	after jsonb
+);
+CREATE INDEX IF NOT EXISTS audit_events_user_id_id_idx ON audit_events (user_id, id);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,26 @@
+-- вебхуки пользователей и доставки событий на них
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+	id text PRIMARY KEY,
+	user_id int NOT NULL,
+	url text NOT NULL,
+	secret text NOT NULL,
+	events text[] NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS webhook_endpoints_user_id_idx ON webhook_endpoints (user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id text PRIMARY KEY,
+	endpoint_id text NOT NULL,
+	user_id int NOT NULL,
+	event jsonb NOT NULL,
+	status text NOT NULL,
+	attempts int NOT NULL DEFAULT 0,
+	next_attempt_at timestamptz NOT NULL,
+	last_status_code int NOT NULL DEFAULT 0,
+	last_error text NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL DEFAULT now(),
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_user_id_created_at_idx ON webhook_deliveries (user_id, created_at);
@@ -0,0 +1,11 @@
+-- принятые запросы на удаление ссылок, которые еще не выполнены
+CREATE TABLE IF NOT EXISTS delete_queue (
+	id bigserial PRIMARY KEY,
+	user_id int NOT NULL,
+	short_id text NOT NULL,
+	client_ip text NOT NULL DEFAULT '',
+	request_id text NOT NULL DEFAULT '',
+	-- время, до которого запрос забран обработчиком
+	claimed_until timestamptz NOT NULL DEFAULT '-infinity',
+	created_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,4 @@
+-- откат невозможен, если одна полная ссылка сохранена в нескольких доменах
+DROP INDEX IF EXISTS short_links_domain_full_url_key;
+ALTER TABLE short_links ADD CONSTRAINT short_links_full_url_key UNIQUE (full_url);
+ALTER TABLE short_links DROP COLUMN IF EXISTS domain;
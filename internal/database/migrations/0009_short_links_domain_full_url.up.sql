@@ -0,0 +1,6 @@
+-- полная ссылка уникальна в пространстве имен домена: домен выделяется из ключа ссылки (host/id),
+-- для основного домена ключ не содержит разделителя и домен пустой
+ALTER TABLE short_links ADD COLUMN IF NOT EXISTS domain text NOT NULL
+	GENERATED ALWAYS AS (CASE WHEN strpos(short_id, '/') > 0 THEN split_part(short_id, '/', 1) ELSE '' END) STORED;
+ALTER TABLE short_links DROP CONSTRAINT IF EXISTS short_links_full_url_key;
+CREATE UNIQUE INDEX IF NOT EXISTS short_links_domain_full_url_key ON short_links (domain, full_url);
@@ -0,0 +1,2 @@
+-- время удаления учетной записи пользователя: токены удаленного пользователя отклоняются после перезапуска
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
@@ -0,0 +1,2 @@
+ALTER TABLE delete_queue DROP COLUMN IF EXISTS event;
+ALTER TABLE delete_queue DROP COLUMN IF EXISTS prepared;
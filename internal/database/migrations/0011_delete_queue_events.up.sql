@@ -0,0 +1,3 @@
+-- события удаления, вычисленные до выполнения запроса: после сбоя они публикуются при повторе запроса
+ALTER TABLE delete_queue ADD COLUMN IF NOT EXISTS prepared boolean NOT NULL DEFAULT false;
+ALTER TABLE delete_queue ADD COLUMN IF NOT EXISTS event jsonb;
@@ -0,0 +1,2 @@
+-- удаление завершенных доставок по истечении времени хранения
+CREATE INDEX IF NOT EXISTS webhook_deliveries_finished_updated_at_idx ON webhook_deliveries (updated_at) WHERE status <> 'pending';
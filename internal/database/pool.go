@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// PoolConfig настройки пула подключений к БД.
+// Нулевые значения оставляют настройки pgxpool по умолчанию.
+type PoolConfig struct {
+	// MaxConns максимальное количество подключений
+	MaxConns int32
+	// MinConns минимальное количество поддерживаемых подключений
+	MinConns int32
+	// MaxConnLifetime время жизни подключения
+	MaxConnLifetime time.Duration
+	// MaxConnIdleTime время простоя, после которого подключение закрывается
+	MaxConnIdleTime time.Duration
+	// AcquireTimeout время ожидания свободного подключения
+	AcquireTimeout time.Duration
+}
+
+// InitPool создает пул подключений к БД.
+// Запросы выполняются через кеш подготовленных выражений pgx,
+// поэтому каждое выражение подготавливается на подключении один раз.
+func InitPool(ctx context.Context, connString string, poolConfig PoolConfig) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+
+	if poolConfig.MaxConns > 0 {
+		config.MaxConns = poolConfig.MaxConns
+	}
+	if poolConfig.MinConns > 0 {
+		config.MinConns = poolConfig.MinConns
+	}
+	if poolConfig.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = poolConfig.MaxConnLifetime
+	}
+	if poolConfig.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = poolConfig.MaxConnIdleTime
+	}
+
+	return pgxpool.NewWithConfig(ctx, config)
+}
+
+// MigratePool применяет к БД пула все непримененные миграции схемы
+func MigratePool(ctx context.Context, pool *pgxpool.Pool) error {
+	db := stdlib.OpenDBFromPool(pool)
+	defer db.Close()
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	return migrator.Up(ctx)
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDriverName имя драйвера SQLite (без cgo)
+const SQLiteDriverName = "sqlite"
+
+// sqliteShortLinksTableSQL возвращает определение таблицы ссылок с именем table.
+// Полная ссылка уникальна в пространстве имен домена, который выделяется из ключа ссылки (host/id).
+func sqliteShortLinksTableSQL(table string) string {
+	return fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			short_id text NOT NULL,
+			full_url text NOT NULL,
+			is_deleted boolean NOT NULL,
+			user_id INTEGER REFERENCES %s(id),
+			created_at INTEGER NOT NULL DEFAULT 0,
+			clicks INTEGER NOT NULL DEFAULT 0,
+			domain text GENERATED ALWAYS AS (
+				CASE WHEN instr(short_id, '/') > 0 THEN substr(short_id, 1, instr(short_id, '/') - 1) ELSE '' END
+			) VIRTUAL
+		)`, table, UsersTableName)
+}
+
+var createSQLiteTablesSQL = fmt.Sprintf(
+	`CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		deleted_at INTEGER
+	);
+
+	%s;`,
+	UsersTableName, sqliteShortLinksTableSQL(ShortLinksTableName))
+
+var createSQLiteIndexesSQL = fmt.Sprintf(
+	`DROP INDEX IF EXISTS %[1]s_short_id_idx;
+	CREATE UNIQUE INDEX IF NOT EXISTS %[1]s_short_id_key ON %[1]s (short_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS %[1]s_domain_full_url_key ON %[1]s (domain, full_url);
+	CREATE INDEX IF NOT EXISTS %[1]s_user_id_idx ON %[1]s (user_id);`,
+	ShortLinksTableName)
+
+// sqliteRebuildTableName имя временной таблицы при пересоздании таблицы ссылок
+var sqliteRebuildTableName = ShortLinksTableName + "_rebuild"
+
+// rebuildSQLiteShortLinksSQL пересоздает таблицу ссылок, созданную с уникальной полной ссылкой
+// без учета домена: ограничение UNIQUE столбца нельзя удалить из существующей таблицы SQLite
+var rebuildSQLiteShortLinksSQL = fmt.Sprintf(
+	`%[1]s;
+	INSERT INTO %[2]s (id, short_id, full_url, is_deleted, user_id, created_at, clicks)
+		SELECT id, short_id, full_url, is_deleted, user_id, created_at, clicks FROM %[3]s;
+	DROP TABLE %[3]s;
+	ALTER TABLE %[2]s RENAME TO %[3]s;`,
+	sqliteShortLinksTableSQL(sqliteRebuildTableName), sqliteRebuildTableName, ShortLinksTableName)
+
+// sqliteShortIDKeyIndexName имя уникального индекса коротких ID
+var sqliteShortIDKeyIndexName = ShortLinksTableName + "_short_id_key"
+
+// rekeySQLiteDuplicateShortIDsSQL оставляет короткий ID самой ранней из ссылок с одинаковым
+// коротким ID, а остальным присваивает ключ с номером строки (см. миграцию 0004 для Postgres)
+var rekeySQLiteDuplicateShortIDsSQL = fmt.Sprintf(
+	`UPDATE %[1]s SET short_id = short_id || '-' || id
+	WHERE id IN (
+		SELECT id FROM (
+			SELECT id, row_number() OVER (PARTITION BY short_id ORDER BY id) AS n FROM %[1]s
+		)
+		WHERE n > 1
+	)`, ShortLinksTableName)
+
+// sqliteColumn столбец, добавленный в таблицу после ее создания,
+// с определением для ALTER TABLE в базах данных, созданных ранее
+type sqliteColumn struct {
+	name       string
+	definition string
+}
+
+// sqliteShortLinksColumns столбцы таблицы ссылок, добавленные после ее создания
+var sqliteShortLinksColumns = []sqliteColumn{
+	{name: "created_at", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{name: "clicks", definition: "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// sqliteUsersColumns столбцы таблицы пользователей, добавленные после ее создания
+var sqliteUsersColumns = []sqliteColumn{
+	{name: "deleted_at", definition: "INTEGER"},
+}
+
+// InitSQLiteConnection открывает файл базы данных SQLite по пути path.
+// Включаются проверка внешних ключей, журнал WAL и ожидание блокировки,
+// транзакции сразу захватывают блокировку записи.
+func InitSQLiteConnection(ctx context.Context, path string) (*Database, error) {
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Set("_txlock", "immediate")
+
+	dbConnection, err := sql.Open(SQLiteDriverName, "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	db := Database{
+		DBConnection: dbConnection,
+	}
+	return &db, nil
+}
+
+// EnsureCreatedSQLite создает необходимые для работы таблицы в базе данных SQLite
+func (db *Database) EnsureCreatedSQLite(ctx context.Context) error {
+	if _, err := db.DBConnection.ExecContext(ctx, createSQLiteTablesSQL); err != nil {
+		return err
+	}
+
+	if err := db.ensureSQLiteColumns(ctx, UsersTableName, sqliteUsersColumns); err != nil {
+		return err
+	}
+
+	if err := db.ensureSQLiteColumns(ctx, ShortLinksTableName, sqliteShortLinksColumns); err != nil {
+		return err
+	}
+
+	if err := db.rebuildSQLiteShortLinks(ctx); err != nil {
+		return err
+	}
+
+	if err := db.rekeySQLiteDuplicateShortIDs(ctx); err != nil {
+		return err
+	}
+
+	_, err := db.DBConnection.ExecContext(ctx, createSQLiteIndexesSQL)
+
+	return err
+}
+
+// rebuildSQLiteShortLinks пересоздает таблицу ссылок, если в ней осталось ограничение
+// UNIQUE полной ссылки из прежнего определения таблицы
+func (db *Database) rebuildSQLiteShortLinks(ctx context.Context) error {
+	var constraints int
+	row := db.DBConnection.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_index_list(?) WHERE origin = 'u'`, ShortLinksTableName)
+	if err := row.Scan(&constraints); err != nil {
+		return err
+	}
+
+	if constraints == 0 {
+		return nil
+	}
+
+	tx, err := db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, rebuildSQLiteShortLinksSQL); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rekeySQLiteDuplicateShortIDs устраняет повторяющиеся короткие ID в базах данных,
+// созданных до появления уникального индекса, чтобы индекс можно было построить
+func (db *Database) rekeySQLiteDuplicateShortIDs(ctx context.Context) error {
+	var indexes int
+	row := db.DBConnection.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`, sqliteShortIDKeyIndexName)
+	if err := row.Scan(&indexes); err != nil {
+		return err
+	}
+
+	if indexes > 0 {
+		return nil
+	}
+
+	_, err := db.DBConnection.ExecContext(ctx, rekeySQLiteDuplicateShortIDsSQL)
+
+	return err
+}
+
+// ensureSQLiteColumns добавляет в таблицу table отсутствующие столбцы
+func (db *Database) ensureSQLiteColumns(ctx context.Context, table string, tableColumns []sqliteColumn) error {
+	columns, err := db.sqliteColumns(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range tableColumns {
+		if _, ok := columns[column.name]; ok {
+			continue
+		}
+
+		alterSQL := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column.name, column.definition)
+		if _, err = db.DBConnection.ExecContext(ctx, alterSQL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sqliteColumns возвращает множество имен столбцов таблицы
+func (db *Database) sqliteColumns(ctx context.Context, table string) (map[string]struct{}, error) {
+	rows, err := db.DBConnection.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	columns := make(map[string]struct{})
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		columns[name] = struct{}{}
+	}
+
+	return columns, rows.Err()
+}
@@ -0,0 +1,155 @@
+package deletequeue
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/database"
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+var (
+	insertRequestsSQL = fmt.Sprintf(
+		`INSERT INTO %s (user_id, short_id, client_ip, request_id)
+			SELECT * FROM unnest($1::int[], $2::text[], $3::text[], $4::text[])`, database.DeleteQueueTableName)
+	// claimRequestsSQL откладывает невыполненные запросы, не забранные другим обработчиком;
+	// SKIP LOCKED не дает нескольким экземплярам сервиса взять один запрос
+	claimRequestsSQL = fmt.Sprintf(
+		`UPDATE %[1]s SET claimed_until = $2
+		WHERE id IN (
+			SELECT id FROM %[1]s
+			WHERE claimed_until <= $1
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, short_id, client_ip, request_id, prepared, event`, database.DeleteQueueTableName)
+	prepareRequestsSQL = fmt.Sprintf(
+		`UPDATE %[1]s SET prepared = true, event = batch.event::jsonb
+		FROM unnest($1::bigint[], $2::text[]) AS batch(id, event)
+		WHERE %[1]s.id = batch.id`, database.DeleteQueueTableName)
+	deleteRequestsSQL = fmt.Sprintf(
+		`DELETE FROM %s WHERE id = ANY($1)`, database.DeleteQueueTableName)
+)
+
+// DatabaseQueue очередь запросов на удаление в БД Postgres
+type DatabaseQueue struct {
+	pool *pgxpool.Pool
+}
+
+// NewDatabaseQueue создает очередь в БД пула pool с примененными миграциями схемы.
+// Пул принадлежит вызывающему и не закрывается очередью.
+func NewDatabaseQueue(pool *pgxpool.Pool) *DatabaseQueue {
+	return &DatabaseQueue{pool: pool}
+}
+
+// Enqueue сохраняет запросы одним запросом к БД
+func (q *DatabaseQueue) Enqueue(ctx context.Context, requests ...models.UserDeleteRequest) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	userIDs := make([]int, len(requests))
+	shortIDs := make([]string, len(requests))
+	clientIPs := make([]string, len(requests))
+	requestIDs := make([]string, len(requests))
+	for i, request := range requests {
+		userIDs[i] = request.UserID
+		shortIDs[i] = request.ShortIDToDelete
+		clientIPs[i] = request.ClientIP
+		requestIDs[i] = request.RequestID
+	}
+
+	_, err := q.pool.Exec(ctx, insertRequestsSQL, userIDs, shortIDs, clientIPs, requestIDs)
+
+	return err
+}
+
+// Claim возвращает невыполненные запросы, не забранные до now
+func (q *DatabaseQueue) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Entry, error) {
+	rows, err := q.pool.Query(ctx, claimRequestsSQL, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		var entry Entry
+		var event []byte
+		err = rows.Scan(&entry.ID, &entry.Request.UserID, &entry.Request.ShortIDToDelete, &entry.Request.ClientIP, &entry.Request.RequestID,
+			&entry.Prepared, &event)
+		if err != nil {
+			return nil, err
+		}
+
+		if event != nil {
+			entry.Event = new(audit.Event)
+			if err = json.Unmarshal(event, entry.Event); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING не сохраняет порядок подзапроса
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return entries, nil
+}
+
+// Prepare сохраняет события удаления запросов одним запросом к БД
+func (q *DatabaseQueue) Prepare(ctx context.Context, entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(entries))
+	events := make([]*string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+		if entry.Event == nil {
+			continue
+		}
+
+		data, err := json.Marshal(entry.Event)
+		if err != nil {
+			return err
+		}
+		event := string(data)
+		events[i] = &event
+	}
+
+	_, err := q.pool.Exec(ctx, prepareRequestsSQL, ids, events)
+
+	return err
+}
+
+// Done удаляет выполненные запросы из таблицы
+func (q *DatabaseQueue) Done(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := q.pool.Exec(ctx, deleteRequestsSQL, ids)
+
+	return err
+}
+
+// Close ничего не делает: пул закрывает его владелец
+func (q *DatabaseQueue) Close() error {
+	return nil
+}
@@ -0,0 +1,81 @@
+package deletequeue
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/database"
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// testDatabaseDSNEnv переменная окружения со строкой подключения к тестовой БД Postgres
+const testDatabaseDSNEnv = "TEST_DATABASE_DSN"
+
+func TestDatabaseQueue(t *testing.T) {
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseDSNEnv)
+	}
+
+	ctx := context.Background()
+	pool, err := database.InitPool(ctx, dsn, database.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+	require.NoError(t, database.MigratePool(ctx, pool))
+
+	queue := NewDatabaseQueue(pool)
+
+	// уникальный пользователь, чтобы не зависеть от данных предыдущих запусков
+	userID := int(time.Now().UnixNano() % 1_000_000_000)
+	requests := []models.UserDeleteRequest{
+		{UserID: userID, ShortIDToDelete: "a", ClientIP: "192.0.2.1", RequestID: "request-1"},
+		{UserID: userID, ShortIDToDelete: "b"},
+	}
+	require.NoError(t, queue.Enqueue(ctx, requests...))
+
+	now := time.Now().UTC()
+	entries := userEntries(t, queue, now, userID)
+	require.Len(t, entries, 2)
+	assert.Equal(t, requests[0], entries[0].Request)
+	assert.Equal(t, requests[1], entries[1].Request)
+
+	// забранные запросы скрыты до истечения аренды
+	assert.Empty(t, userEntries(t, queue, now, userID))
+
+	require.NoError(t, queue.Done(ctx, entries[0].ID))
+
+	entries = userEntries(t, queue, now.Add(time.Hour), userID)
+	require.Len(t, entries, 1)
+	assert.Equal(t, requests[1], entries[0].Request)
+	assert.False(t, entries[0].Prepared)
+
+	// сохраненное событие удаления возвращается при повторе запроса
+	entries[0].Prepared = true
+	entries[0].Event = &audit.Event{Action: audit.ActionLinkDeleted, UserID: userID, ShortID: "b"}
+	require.NoError(t, queue.Prepare(ctx, entries...))
+
+	prepared := userEntries(t, queue, now.Add(time.Hour*2), userID)
+	assert.Equal(t, entries, prepared)
+	require.NoError(t, queue.Done(ctx, entries[0].ID))
+}
+
+// userEntries забирает из очереди запросы пользователя userID
+func userEntries(t *testing.T, queue *DatabaseQueue, now time.Time, userID int) []Entry {
+	claimed, err := queue.Claim(context.Background(), now, time.Minute, 1000)
+	require.NoError(t, err)
+
+	entries := make([]Entry, 0, len(claimed))
+	for _, entry := range claimed {
+		if entry.Request.UserID == userID {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
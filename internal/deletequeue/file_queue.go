@@ -0,0 +1,225 @@
+package deletequeue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// journalRecord строка файла очереди: добавленный запрос, события удаления запросов
+// или номера выполненных запросов
+type journalRecord struct {
+	Put     *Entry  `json:"put,omitempty"`
+	Prepare []Entry `json:"prepare,omitempty"`
+	Done    []int64 `json:"done,omitempty"`
+}
+
+// FileQueue очередь запросов на удаление в файле-журнале.
+// Каждое изменение дописывается строкой JSON и сбрасывается на диск до возврата;
+// при открытии журнал воспроизводится, а когда очередь пустеет, файл обрезается.
+type FileQueue struct {
+	mutex   sync.Mutex
+	file    afero.File
+	pending *MemoryQueue
+}
+
+// NewFileQueue открывает или создает файл очереди и восстанавливает невыполненные запросы.
+// Строка, недописанная при аварийном завершении, отбрасывается.
+func NewFileQueue(fs afero.Fs, path string) (*FileQueue, error) {
+	file, err := fs.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &FileQueue{
+		file:    file,
+		pending: NewMemoryQueue(),
+	}
+
+	if err = q.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Enqueue дописывает запросы в журнал
+func (q *FileQueue) Enqueue(ctx context.Context, requests ...models.UserDeleteRequest) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	entries := q.pending.newEntries(requests)
+
+	records := make([]journalRecord, len(entries))
+	for i := range entries {
+		records[i] = journalRecord{Put: &entries[i]}
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if err := q.write(records...); err != nil {
+		return err
+	}
+	q.pending.put(entries...)
+
+	return nil
+}
+
+// Claim возвращает невыполненные запросы, не забранные до now
+func (q *FileQueue) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Entry, error) {
+	return q.pending.Claim(ctx, now, lease, limit)
+}
+
+// Prepare дописывает в журнал события удаления запросов
+func (q *FileQueue) Prepare(ctx context.Context, entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if err := q.write(journalRecord{Prepare: entries}); err != nil {
+		return err
+	}
+
+	return q.pending.Prepare(ctx, entries...)
+}
+
+// Done дописывает в журнал номера выполненных запросов
+func (q *FileQueue) Done(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if err := q.write(journalRecord{Done: ids}); err != nil {
+		return err
+	}
+
+	if err := q.pending.Done(ctx, ids...); err != nil {
+		return err
+	}
+
+	// в пустой очереди журнал больше не нужен
+	if q.pending.Len() == 0 {
+		return q.truncate(0)
+	}
+
+	return nil
+}
+
+// Close закрывает файл очереди
+func (q *FileQueue) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.file.Close()
+}
+
+// write дописывает записи в журнал и сбрасывает его на диск
+func (q *FileQueue) write(records ...journalRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	if _, err := q.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	return q.file.Sync()
+}
+
+// replay восстанавливает невыполненные запросы из журнала
+// и обрезает его после последней целой строки
+func (q *FileQueue) replay() error {
+	if _, err := q.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	entries := make(map[int64]Entry)
+	var nextID, offset int64
+
+	reader := bufio.NewReader(q.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// недописанная строка не применяется
+			break
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+
+		var record journalRecord
+		if err = json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+
+		if record.Put != nil {
+			entries[record.Put.ID] = *record.Put
+			nextID = max(nextID, record.Put.ID)
+		}
+		for _, prepared := range record.Prepare {
+			if entry, ok := entries[prepared.ID]; ok {
+				entry.Prepared, entry.Event = prepared.Prepared, prepared.Event
+				entries[prepared.ID] = entry
+			}
+		}
+		for _, id := range record.Done {
+			delete(entries, id)
+		}
+	}
+
+	if len(entries) == 0 {
+		// все запросы выполнены: журнал начинается заново
+		return q.truncate(0)
+	}
+
+	pending := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		pending = append(pending, entry)
+	}
+	q.pending.put(pending...)
+	q.pending.nextID = nextID + 1
+
+	return q.truncate(offset)
+}
+
+// truncate обрезает журнал до size байт и переносит позицию записи в его конец
+func (q *FileQueue) truncate(size int64) error {
+	info, err := q.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() != size {
+		if err = q.file.Truncate(size); err != nil {
+			return err
+		}
+	}
+
+	_, err = q.file.Seek(size, io.SeekStart)
+
+	return err
+}
@@ -0,0 +1,138 @@
+package deletequeue
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+const testJournalPath = "deletes.log"
+
+func TestFileQueueReplay(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+
+	queue, err := NewFileQueue(fs, testJournalPath)
+	require.NoError(t, err)
+
+	require.NoError(t, queue.Enqueue(ctx,
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a", ClientIP: "192.0.2.1", RequestID: "request-1"},
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "b"},
+	))
+	entries, err := queue.Claim(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.NoError(t, queue.Done(ctx, entries[0].ID))
+	require.NoError(t, queue.Close())
+
+	// после перезапуска забранный, но не выполненный запрос снова доступен
+	queue, err = NewFileQueue(fs, testJournalPath)
+	require.NoError(t, err)
+	defer queue.Close()
+
+	replayed, err := queue.Claim(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, entries[1:], replayed)
+
+	// номера новых запросов продолжают номера журнала
+	require.NoError(t, queue.Enqueue(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "c"}))
+	added, err := queue.Claim(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, added, 1)
+	assert.Greater(t, added[0].ID, entries[1].ID)
+}
+
+func TestFileQueueReplayPrepared(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+
+	queue, err := NewFileQueue(fs, testJournalPath)
+	require.NoError(t, err)
+
+	require.NoError(t, queue.Enqueue(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a"}))
+	entries, err := queue.Claim(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	entries[0].Prepared = true
+	entries[0].Event = &audit.Event{Action: audit.ActionLinkDeleted, UserID: 1, ShortID: "a"}
+	require.NoError(t, queue.Prepare(ctx, entries...))
+	require.NoError(t, queue.Close())
+
+	// событие, сохраненное до удаления, восстанавливается вместе с запросом
+	queue, err = NewFileQueue(fs, testJournalPath)
+	require.NoError(t, err)
+	defer queue.Close()
+
+	replayed, err := queue.Claim(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, entries, replayed)
+}
+
+func TestFileQueueTruncatesDrainedJournal(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+
+	queue, err := NewFileQueue(fs, testJournalPath)
+	require.NoError(t, err)
+	defer queue.Close()
+
+	require.NoError(t, queue.Enqueue(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a"}))
+	entries, err := queue.Claim(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	require.NoError(t, queue.Done(ctx, entries[0].ID))
+
+	info, err := fs.Stat(testJournalPath)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	// запись после обрезки начинается с начала файла
+	require.NoError(t, queue.Enqueue(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "b"}))
+	data, err := afero.ReadFile(fs, testJournalPath)
+	require.NoError(t, err)
+	assert.Equal(t, byte('{'), data[0])
+}
+
+func TestFileQueueTornLine(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+
+	queue, err := NewFileQueue(fs, testJournalPath)
+	require.NoError(t, err)
+	require.NoError(t, queue.Enqueue(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a"}))
+	require.NoError(t, queue.Close())
+
+	// запись, прерванная аварийным завершением
+	file, err := fs.OpenFile(testJournalPath, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"put":{"id":2,"requ`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	queue, err = NewFileQueue(fs, testJournalPath)
+	require.NoError(t, err)
+
+	entries, err := queue.Claim(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, shortIDs(entries))
+
+	require.NoError(t, queue.Enqueue(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "b"}))
+	require.NoError(t, queue.Close())
+
+	// новая запись не склеивается с отброшенной строкой
+	queue, err = NewFileQueue(fs, testJournalPath)
+	require.NoError(t, err)
+	defer queue.Close()
+
+	entries, err = queue.Claim(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, shortIDs(entries))
+}
@@ -0,0 +1,214 @@
+// Package deletequeue хранит принятые запросы на удаление ссылок до их выполнения,
+// чтобы запросы, на которые клиент уже получил 202 Accepted, не терялись при перезапуске сервиса.
+package deletequeue
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/afero"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// journalSuffix суффикс файла очереди, создаваемого рядом с файлом хранилища
+const journalSuffix = ".delete-queue"
+
+// Entry запрос на удаление в очереди
+type Entry struct {
+	// ID порядковый номер запроса в очереди
+	ID      int64                    `json:"id"`
+	Request models.UserDeleteRequest `json:"request"`
+	// Prepared событие удаления уже вычислено и сохранено в очереди (см. Queue.Prepare)
+	Prepared bool `json:"prepared,omitempty"`
+	// Event событие аудита удаления ссылки; nil, если запрос не изменяет ссылку
+	Event *audit.Event `json:"event,omitempty"`
+}
+
+// Queue интерфейс очереди запросов на удаление
+type Queue interface {
+	// Enqueue сохраняет запросы; после успешного возврата они переживают перезапуск сервиса
+	Enqueue(ctx context.Context, requests ...models.UserDeleteRequest) error
+	// Claim возвращает не более limit невыполненных запросов в порядке добавления
+	// и скрывает их от следующих вызовов Claim до now+lease
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Entry, error)
+	// Prepare сохраняет события удаления забранных запросов (Prepared и Event) до выполнения удаления:
+	// запрос, повторенный после сбоя, возвращается Claim с сохраненным событием, даже если ссылка уже удалена.
+	// Выполненные запросы пропускаются.
+	Prepare(ctx context.Context, entries ...Entry) error
+	// Done удаляет выполненные запросы из очереди
+	Done(ctx context.Context, ids ...int64) error
+	// Close завершает работу с очередью
+	Close() error
+}
+
+// NewAppQueue создает очередь запросов на удаление: в БД пула pool хранилища ссылок в Postgres
+// (для шардов БД - первого шарда), иначе в файле DeleteQueuePath, в Redis для хранилища в Redis
+// или в файле рядом с файлом хранилища. Для хранилища в памяти без DeleteQueuePath очередь хранится
+// в памяти: запросы теряются при перезапуске вместе со ссылками.
+func NewAppQueue(ctx context.Context, appConfig *config.AppConfig, pool *pgxpool.Pool) (Queue, error) {
+	if pool != nil {
+		return NewDatabaseQueue(pool), nil
+	}
+
+	if appConfig.DeleteQueuePath == "" && appConfig.StorageType == config.Redis {
+		return NewRedisQueue(ctx, appConfig.RedisAddr)
+	}
+
+	if path := journalPath(appConfig); path != "" {
+		return NewFileQueue(afero.NewOsFs(), path)
+	}
+
+	return NewMemoryQueue(), nil
+}
+
+// journalPath возвращает путь файла очереди или пустую строку, если его негде разместить
+func journalPath(appConfig *config.AppConfig) string {
+	if appConfig.DeleteQueuePath != "" {
+		return appConfig.DeleteQueuePath
+	}
+
+	switch appConfig.StorageType {
+	case config.File:
+		return appConfig.FileStoragePath + journalSuffix
+	case config.SQLite:
+		return appConfig.SQLitePath + journalSuffix
+	case config.Bolt:
+		return appConfig.BoltPath + journalSuffix
+	default:
+		return ""
+	}
+}
+
+// MemoryQueue очередь запросов на удаление в памяти.
+// Используется также как индекс невыполненных запросов FileQueue.
+type MemoryQueue struct {
+	mutex  sync.Mutex
+	nextID int64
+	// entries невыполненные запросы в порядке добавления
+	entries []Entry
+	// claimedUntil время, до которого запрос скрыт от Claim
+	claimedUntil map[int64]time.Time
+}
+
+// NewMemoryQueue создает MemoryQueue
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		nextID:       1,
+		claimedUntil: make(map[int64]time.Time),
+	}
+}
+
+// Enqueue добавляет запросы в очередь
+func (q *MemoryQueue) Enqueue(ctx context.Context, requests ...models.UserDeleteRequest) error {
+	q.put(q.newEntries(requests)...)
+
+	return nil
+}
+
+// Claim возвращает невыполненные запросы, не забранные до now
+func (q *MemoryQueue) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Entry, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	claimed := make([]Entry, 0, min(limit, len(q.entries)))
+	for _, entry := range q.entries {
+		if len(claimed) == limit {
+			break
+		}
+		if q.claimedUntil[entry.ID].After(now) {
+			continue
+		}
+
+		q.claimedUntil[entry.ID] = now.Add(lease)
+		claimed = append(claimed, entry)
+	}
+
+	return claimed, nil
+}
+
+// Prepare сохраняет события удаления невыполненных запросов
+func (q *MemoryQueue) Prepare(ctx context.Context, entries ...Entry) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	prepared := make(map[int64]Entry, len(entries))
+	for _, entry := range entries {
+		prepared[entry.ID] = entry
+	}
+
+	for i, entry := range q.entries {
+		if update, ok := prepared[entry.ID]; ok {
+			q.entries[i].Prepared, q.entries[i].Event = update.Prepared, update.Event
+		}
+	}
+
+	return nil
+}
+
+// Done удаляет выполненные запросы из очереди
+func (q *MemoryQueue) Done(ctx context.Context, ids ...int64) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	done := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		done[id] = struct{}{}
+		delete(q.claimedUntil, id)
+	}
+
+	q.entries = slices.DeleteFunc(q.entries, func(entry Entry) bool {
+		_, ok := done[entry.ID]
+		return ok
+	})
+
+	return nil
+}
+
+// Close ничего не делает
+func (q *MemoryQueue) Close() error {
+	return nil
+}
+
+// Len возвращает количество невыполненных запросов
+func (q *MemoryQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.entries)
+}
+
+// newEntries присваивает запросам следующие порядковые номера
+func (q *MemoryQueue) newEntries(requests []models.UserDeleteRequest) []Entry {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	entries := make([]Entry, len(requests))
+	for i, request := range requests {
+		entries[i] = Entry{ID: q.nextID, Request: request}
+		q.nextID++
+	}
+
+	return entries
+}
+
+// put добавляет запросы с присвоенными номерами, сохраняя порядок добавления
+func (q *MemoryQueue) put(entries ...Entry) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, entry := range entries {
+		q.nextID = max(q.nextID, entry.ID+1)
+	}
+
+	q.entries = append(q.entries, entries...)
+	slices.SortFunc(q.entries, func(a, b Entry) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
@@ -0,0 +1,90 @@
+package deletequeue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	ctx := context.Background()
+	queue := NewMemoryQueue()
+	now := time.Now().UTC()
+
+	require.NoError(t, queue.Enqueue(ctx,
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a"},
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "b"},
+		models.UserDeleteRequest{UserID: 2, ShortIDToDelete: "c"},
+	))
+
+	entries, err := queue.Claim(ctx, now, time.Minute, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, shortIDs(entries))
+
+	// забранные запросы скрыты до истечения аренды
+	claimed, err := queue.Claim(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, shortIDs(claimed))
+
+	require.NoError(t, queue.Done(ctx, entries[0].ID))
+	assert.Equal(t, 2, queue.Len())
+
+	claimed, err = queue.Claim(ctx, now.Add(time.Minute), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, shortIDs(claimed))
+}
+
+func TestJournalPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		appConfig *config.AppConfig
+		want      string
+	}{
+		{
+			"explicit path",
+			config.NewConfig(config.WithStorageLocation(config.File, "storage.json"), config.WithDeleteQueuePath("deletes.log")),
+			"deletes.log",
+		},
+		{
+			"next to file storage",
+			config.NewConfig(config.WithStorageLocation(config.File, "storage.json")),
+			"storage.json" + journalSuffix,
+		},
+		{
+			"next to bolt database",
+			config.NewConfig(config.WithStorageLocation(config.Bolt, "shortener.bolt")),
+			"shortener.bolt" + journalSuffix,
+		},
+		{
+			"memory storage",
+			config.NewConfig(),
+			"",
+		},
+		{
+			"redis storage",
+			config.NewConfig(config.WithStorageLocation(config.Redis, "localhost:6379")),
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, journalPath(tt.appConfig))
+		})
+	}
+}
+
+func shortIDs(entries []Entry) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.Request.ShortIDToDelete
+	}
+
+	return ids
+}
@@ -0,0 +1,192 @@
+package deletequeue
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+const (
+	// redisQueueKeyPrefix префикс ключей очереди в Redis
+	redisQueueKeyPrefix = "shortener:delete_queue:"
+	// redisQueueNextIDKey счетчик порядковых номеров запросов
+	redisQueueNextIDKey = redisQueueKeyPrefix + "next_id"
+	// redisQueueIDsKey упорядоченное множество номеров невыполненных запросов (score - номер)
+	redisQueueIDsKey = redisQueueKeyPrefix + "ids"
+	// redisQueueEntriesKey хеш номер запроса -> запрос в JSON
+	redisQueueEntriesKey = redisQueueKeyPrefix + "entries"
+	// redisQueueClaimsKey хеш номер запроса -> время окончания аренды в миллисекундах Unix
+	redisQueueClaimsKey = redisQueueKeyPrefix + "claims"
+)
+
+// redisClaimScript забирает не более ARGV[3] невыполненных запросов в порядке номеров,
+// аренда которых истекла к ARGV[1], и продлевает их аренду до ARGV[2].
+// Скрипт выполняется атомарно, поэтому несколько экземпляров сервиса не забирают один запрос.
+var redisClaimScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local limit = tonumber(ARGV[3])
+local claimed = {}
+local offset = 0
+while #claimed < limit do
+	local ids = redis.call('ZRANGE', KEYS[1], offset, offset + 99)
+	if #ids == 0 then
+		break
+	end
+	for _, id in ipairs(ids) do
+		local claimedUntil = tonumber(redis.call('HGET', KEYS[3], id) or '0')
+		if claimedUntil <= now then
+			local entry = redis.call('HGET', KEYS[2], id)
+			if entry then
+				redis.call('HSET', KEYS[3], id, ARGV[2])
+				table.insert(claimed, entry)
+				if #claimed == limit then
+					break
+				end
+			end
+		end
+	end
+	offset = offset + #ids
+end
+return claimed
+`)
+
+// redisPrepareScript заменяет запросы ARGV[2i-1] их JSON ARGV[2i], если они еще не выполнены
+var redisPrepareScript = redis.NewScript(`
+for i = 1, #ARGV, 2 do
+	if redis.call('HEXISTS', KEYS[1], ARGV[i]) == 1 then
+		redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	end
+end
+return 0
+`)
+
+// RedisQueue очередь запросов на удаление в Redis.
+// Используется с хранилищем в Redis, чтобы очередь была общей для экземпляров сервиса
+// и переживала их перезапуск вместе с хранилищем.
+type RedisQueue struct {
+	client *redis.Client
+}
+
+// NewRedisQueue подключается к Redis по адресу addr (host:port или redis:// URL)
+func NewRedisQueue(ctx context.Context, addr string) (*RedisQueue, error) {
+	options := &redis.Options{Addr: addr}
+	if strings.Contains(addr, "://") {
+		var err error
+		if options, err = redis.ParseURL(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisQueue{client: client}, nil
+}
+
+// Enqueue присваивает запросам номера и сохраняет их в одной транзакции
+func (q *RedisQueue) Enqueue(ctx context.Context, requests ...models.UserDeleteRequest) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	lastID, err := q.client.IncrBy(ctx, redisQueueNextIDKey, int64(len(requests))).Result()
+	if err != nil {
+		return err
+	}
+
+	ids := make([]redis.Z, len(requests))
+	entries := make(map[string]any, len(requests))
+	for i, request := range requests {
+		entry := Entry{ID: lastID - int64(len(requests)-i-1), Request: request}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		member := strconv.FormatInt(entry.ID, 10)
+		ids[i] = redis.Z{Score: float64(entry.ID), Member: member}
+		entries[member] = data
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisQueueEntriesKey, entries)
+		pipe.ZAdd(ctx, redisQueueIDsKey, ids...)
+
+		return nil
+	})
+
+	return err
+}
+
+// Claim возвращает невыполненные запросы, не забранные до now
+func (q *RedisQueue) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Entry, error) {
+	keys := []string{redisQueueIDsKey, redisQueueEntriesKey, redisQueueClaimsKey}
+	values, err := redisClaimScript.Run(ctx, q.client, keys, now.UnixMilli(), now.Add(lease).UnixMilli(), limit).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, len(values))
+	for i, value := range values {
+		if err = json.Unmarshal([]byte(value), &entries[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// Prepare сохраняет события удаления невыполненных запросов
+func (q *RedisQueue) Prepare(ctx context.Context, entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(entries)*2)
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		args = append(args, strconv.FormatInt(entry.ID, 10), data)
+	}
+
+	return redisPrepareScript.Run(ctx, q.client, []string{redisQueueEntriesKey}, args...).Err()
+}
+
+// Done удаляет выполненные запросы из очереди
+func (q *RedisQueue) Done(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	members := make([]string, len(ids))
+	for i, id := range ids {
+		members[i] = strconv.FormatInt(id, 10)
+	}
+
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, redisQueueIDsKey, members)
+		pipe.HDel(ctx, redisQueueEntriesKey, members...)
+		pipe.HDel(ctx, redisQueueClaimsKey, members...)
+
+		return nil
+	})
+
+	return err
+}
+
+// Close закрывает подключение к Redis
+func (q *RedisQueue) Close() error {
+	return q.client.Close()
+}
@@ -0,0 +1,102 @@
+package deletequeue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+func TestRedisQueue(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	queue, err := NewRedisQueue(ctx, server.Addr())
+	require.NoError(t, err)
+
+	requests := make([]models.UserDeleteRequest, 0, 12)
+	for i := range 12 {
+		requests = append(requests, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: fmt.Sprintf("id%02d", i)})
+	}
+	requests[0].ClientIP, requests[0].RequestID = "192.0.2.1", "request-1"
+	require.NoError(t, queue.Enqueue(ctx, requests...))
+
+	now := time.Now().UTC()
+	entries, err := queue.Claim(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 10)
+	// запросы забираются в порядке добавления, в том числе после номера 9
+	assert.Equal(t, requests[0], entries[0].Request)
+	assert.Equal(t, []string{"id00", "id01", "id02", "id03", "id04", "id05", "id06", "id07", "id08", "id09"}, shortIDs(entries))
+
+	// забранные запросы скрыты до истечения аренды
+	claimed, err := queue.Claim(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id10", "id11"}, shortIDs(claimed))
+
+	require.NoError(t, queue.Done(ctx, entries[0].ID))
+	require.NoError(t, queue.Close())
+
+	// очередь общая для экземпляров сервиса и переживает их перезапуск
+	queue, err = NewRedisQueue(ctx, server.Addr())
+	require.NoError(t, err)
+	defer queue.Close()
+
+	claimed, err = queue.Claim(ctx, now.Add(time.Minute), time.Minute, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id01", "id02"}, shortIDs(claimed))
+
+	require.NoError(t, queue.Enqueue(ctx, models.UserDeleteRequest{UserID: 2, ShortIDToDelete: "next"}))
+	claimed, err = queue.Claim(ctx, now.Add(time.Minute), time.Minute, 20)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id03", "id04", "id05", "id06", "id07", "id08", "id09", "id10", "id11", "next"}, shortIDs(claimed))
+	assert.Greater(t, claimed[len(claimed)-1].ID, entries[len(entries)-1].ID)
+}
+
+func TestRedisQueuePrepare(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	queue, err := NewRedisQueue(ctx, server.Addr())
+	require.NoError(t, err)
+	defer queue.Close()
+
+	require.NoError(t, queue.Enqueue(ctx,
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a"},
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "b"},
+	))
+
+	now := time.Now().UTC()
+	entries, err := queue.Claim(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	entries[0].Prepared = true
+	entries[0].Event = &audit.Event{Action: audit.ActionLinkDeleted, UserID: 1, ShortID: "a"}
+	entries[1].Prepared = true
+	require.NoError(t, queue.Done(ctx, entries[1].ID))
+	// выполненный запрос не восстанавливается
+	require.NoError(t, queue.Prepare(ctx, entries...))
+
+	claimed, err := queue.Claim(ctx, now.Add(time.Minute), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, entries[:1], claimed)
+}
+
+func TestNewAppQueueRedisStorage(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	queue, err := NewAppQueue(context.Background(), config.NewConfig(config.WithStorageLocation(config.Redis, server.Addr())), nil)
+	require.NoError(t, err)
+	defer queue.Close()
+
+	assert.IsType(t, &RedisQueue{}, queue)
+}
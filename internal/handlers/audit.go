@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+)
+
+// maxAuditEventsLimit максимальное количество событий журнала аудита в ответе
+const maxAuditEventsLimit = 1000
+
+// auditRecorder записывает в журнал аудита изменения, выполненные обработчиками
+type auditRecorder struct {
+	log    audit.Log
+	logger *zap.Logger
+}
+
+// event возвращает событие аудита с адресом клиента и ID запроса r
+func (a auditRecorder) event(r *http.Request, action audit.Action, userID int) audit.Event {
+	return audit.Event{
+		Action:    action,
+		UserID:    userID,
+		ClientIP:  clientIP(r),
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// record добавляет события в журнал аудита.
+// Запись не прерывается отменой запроса, ошибка журнала логируется и не влияет на ответ.
+func (a auditRecorder) record(r *http.Request, events ...audit.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	if err := a.log.Append(context.WithoutCancel(r.Context()), events...); err != nil {
+		a.logger.Error("error writing audit events", zap.Error(err))
+	}
+}
+
+// clientIP возвращает адрес клиента без порта
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// GetUserAuditHandler возвращает последние события журнала аудита пользователя, начиная с новых.
+// Параметр limit задает количество событий (по умолчанию audit.DefaultUserEventsLimit).
+func (h *UserHandlers) GetUserAuditHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := getAuthorizedUserID(h.tokenManager, r)
+
+		if err != nil {
+			h.logger.Info("unauthorized audit request", zap.Error(err))
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		limit := audit.DefaultUserEventsLimit
+		if value := r.URL.Query().Get(limitQueryParam); value != "" {
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxAuditEventsLimit {
+				http.Error(w, ErrInvalidLimit.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		events, err := h.audit.log.UserEvents(r.Context(), userID, limit)
+
+		if err != nil {
+			h.logger.Info("error getting audit events", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		if len(events) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		if err := encoder.Encode(events); err != nil {
+			h.logger.Info("error encoding response", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+	}
+}
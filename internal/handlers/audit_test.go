@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/auth"
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/deletequeue"
+	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/service"
+	"github.com/rovany706/url-shortener/internal/webhook"
+)
+
+func TestGetUserAuditHandler(t *testing.T) {
+	appConfig := config.NewConfig()
+	logger := zaptest.NewLogger(t)
+
+	tokenManager, err := auth.NewJWTTokenManager(nil)
+	require.NoError(t, err)
+
+	auditLog, err := audit.NewFileLog(afero.NewMemMapFs(), "audit.log")
+	require.NoError(t, err)
+	defer auditLog.Close()
+
+	memoryRepository := repository.NewMemoryRepository()
+	shortenHandlers := NewShortenURLHandlers(app.NewURLShortenerApp(memoryRepository), tokenManager, memoryRepository, auditLog, webhook.NopPublisher{}, appConfig, logger)
+	userHandlers := NewUserHandlers(service.NewDeleteService(memoryRepository, deletequeue.NewMemoryQueue(), auditLog, webhook.NopPublisher{}), tokenManager, memoryRepository, auditLog, appConfig, logger)
+
+	shorten := func(cookie *http.Cookie) *http.Response {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("http://example.com/"))
+		request = request.WithContext(context.WithValue(request.Context(), middleware.RequestIDKey, "request-1"))
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+
+		shortenHandlers.MakeShortURLHandler()(w, request)
+
+		response := w.Result()
+		response.Body.Close()
+
+		return response
+	}
+
+	// новый пользователь сокращает ссылку, повторное сокращение ничего не меняет
+	response := shorten(nil)
+	require.Equal(t, http.StatusCreated, response.StatusCode)
+	var cookie *http.Cookie
+	for _, c := range response.Cookies() {
+		if c.Name == auth.AuthCookieName {
+			cookie = c
+		}
+	}
+	require.NotNil(t, cookie)
+	require.Equal(t, http.StatusConflict, shorten(cookie).StatusCode)
+
+	getAudit := func(query string, cookie *http.Cookie) (*http.Response, []audit.Event) {
+		request := httptest.NewRequest(http.MethodGet, "/api/user/audit"+query, nil)
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+
+		userHandlers.GetUserAuditHandler()(w, request)
+
+		response := w.Result()
+		defer response.Body.Close()
+
+		var events []audit.Event
+		if response.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&events))
+		}
+
+		return response, events
+	}
+
+	t.Run("user events", func(t *testing.T) {
+		response, events := getAudit("", cookie)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+		require.Len(t, events, 2)
+
+		assert.Equal(t, audit.ActionLinkCreated, events[0].Action)
+		assert.NotEmpty(t, events[0].ShortID)
+		assert.Nil(t, events[0].Before)
+		assert.Equal(t, &audit.LinkState{FullURL: "http://example.com/"}, events[0].After)
+
+		assert.Equal(t, audit.ActionUserCreated, events[1].Action)
+		for _, event := range events {
+			assert.Equal(t, events[1].UserID, event.UserID)
+			assert.Equal(t, "192.0.2.1", event.ClientIP)
+			assert.Equal(t, "request-1", event.RequestID)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		response, events := getAudit("?limit=1", cookie)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Len(t, events, 1)
+		assert.Equal(t, audit.ActionLinkCreated, events[0].Action)
+
+		for _, query := range []string{"?limit=0", "?limit=1001", "?limit=abc"} {
+			response, _ := getAudit(query, cookie)
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode, query)
+		}
+	})
+
+	t.Run("no events", func(t *testing.T) {
+		token, err := tokenManager.CreateToken(100)
+		require.NoError(t, err)
+
+		response, _ := getAudit("", &http.Cookie{Name: auth.AuthCookieName, Value: token})
+		assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		response, _ := getAudit("", nil)
+		assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	})
+}
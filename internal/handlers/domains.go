@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/config"
+)
+
+// domainQueryParam параметр запроса сокращения, задающий домен ссылки
+const domainQueryParam = "domain"
+
+var (
+	// ErrUnknownDomain ошибка запроса сокращения на домене, отсутствующем в конфигурации
+	ErrUnknownDomain = errors.New("unknown short link domain")
+	// ErrInvalidShortURL ошибка разбора короткой ссылки, не относящейся к базовому URL домена
+	ErrInvalidShortURL = errors.New("invalid short url")
+)
+
+// lookupDomain возвращает пространство имен домена name (хост дополнительного домена из конфигурации).
+// Пустое имя и хост BaseURL соответствуют основному домену, для которого возвращается пустая строка.
+func lookupDomain(appConfig *config.AppConfig, name string) (domain string, err error) {
+	name = strings.ToLower(name)
+	if name == "" || name == domainHost(appConfig.BaseURL) {
+		return "", nil
+	}
+
+	for _, baseURL := range appConfig.Domains {
+		if domainHost(baseURL) == name {
+			return name, nil
+		}
+	}
+
+	return "", ErrUnknownDomain
+}
+
+// requestDomain возвращает пространство имен домена, на который пришел запрос.
+// Запросы на хосты, отсутствующие в конфигурации, относятся к основному домену.
+func requestDomain(r *http.Request, appConfig *config.AppConfig) string {
+	domain, err := lookupDomain(appConfig, r.Host)
+	if err != nil {
+		return ""
+	}
+
+	return domain
+}
+
+// requestShortIDKey возвращает ключ ссылки (см. app.DomainShortID) по ссылке из запроса клиента.
+// Клиент передает короткую ссылку целиком, ключ ссылки host/id или короткий ID;
+// короткий ID относится к домену domain (пространство имен из lookupDomain).
+func requestShortIDKey(appConfig *config.AppConfig, domain string, link string) (string, error) {
+	u, err := url.Parse(link)
+	if err == nil && u.Scheme != "" && u.Host != "" {
+		return shortURLKey(appConfig, u)
+	}
+
+	if host, shortID, ok := strings.Cut(link, "/"); ok {
+		domain, err = lookupDomain(appConfig, host)
+		if err != nil {
+			return "", err
+		}
+
+		link = shortID
+	}
+
+	if link == "" || strings.Contains(link, "/") {
+		return "", ErrInvalidShortURL
+	}
+
+	return app.DomainShortID(domain, link), nil
+}
+
+// shortURLKey возвращает ключ ссылки по короткой ссылке u на одном из доменов конфигурации
+func shortURLKey(appConfig *config.AppConfig, u *url.URL) (string, error) {
+	domain, err := lookupDomain(appConfig, u.Host)
+	if err != nil {
+		return "", err
+	}
+
+	base, err := url.Parse(domainBaseURL(appConfig, domain))
+	if err != nil {
+		return "", err
+	}
+
+	shortID, ok := strings.CutPrefix(u.Path, strings.TrimSuffix(base.Path, "/")+"/")
+	if !ok || shortID == "" || strings.Contains(shortID, "/") {
+		return "", ErrInvalidShortURL
+	}
+
+	return app.DomainShortID(domain, shortID), nil
+}
+
+// getShortURL возвращает короткую ссылку по ключу ссылки с базовым URL ее домена
+func getShortURL(key string, appConfig *config.AppConfig) string {
+	domain, shortID := app.SplitDomainShortID(key)
+
+	return domainBaseURL(appConfig, domain) + "/" + shortID
+}
+
+// shortURLDomain возвращает хост домена короткой ссылки по ключу ссылки
+func shortURLDomain(key string, appConfig *config.AppConfig) string {
+	domain, _ := app.SplitDomainShortID(key)
+
+	return domainHost(domainBaseURL(appConfig, domain))
+}
+
+// domainBaseURL возвращает базовый URL домена; для основного и неизвестных доменов - BaseURL
+func domainBaseURL(appConfig *config.AppConfig, domain string) string {
+	if domain != "" {
+		for _, baseURL := range appConfig.Domains {
+			if domainHost(baseURL) == domain {
+				return baseURL
+			}
+		}
+	}
+
+	return appConfig.BaseURL
+}
+
+// domainHost возвращает хост базового URL в нижнем регистре
+func domainHost(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Host)
+}
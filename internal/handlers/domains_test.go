@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/auth"
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/service"
+	"github.com/rovany706/url-shortener/internal/webhook"
+)
+
+func TestBrandedDomains(t *testing.T) {
+	appConfig := config.NewConfig(
+		config.WithBaseURL("http://localhost:8080"),
+		config.WithDomains([]string{"https://go.brand.com", "https://Links.Example.org:8443"}),
+	)
+
+	tokenManager, err := auth.NewJWTTokenManager(nil)
+	require.NoError(t, err)
+
+	memoryRepository := repository.NewMemoryRepository()
+	shortener := app.NewURLShortenerApp(memoryRepository)
+	shortenHandlers := NewShortenURLHandlers(shortener, tokenManager, memoryRepository, audit.NopLog{}, webhook.NopPublisher{}, appConfig, zaptest.NewLogger(t))
+	redirectHandlers := NewRedirectHandlers(shortener, service.NewClickService(memoryRepository), webhook.NopPublisher{}, appConfig, zaptest.NewLogger(t))
+
+	router := chi.NewRouter()
+	router.Post("/", shortenHandlers.MakeShortURLHandler())
+	router.Get("/{id}", redirectHandlers.RedirectHandler())
+
+	shorten := func(query string, fullURL string) (statusCode int, shortURL string) {
+		request := httptest.NewRequest(http.MethodPost, "/"+query, strings.NewReader(fullURL))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		response := w.Result()
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		return response.StatusCode, string(body)
+	}
+
+	redirect := func(host string, shortID string) (statusCode int, location string) {
+		request := httptest.NewRequest(http.MethodGet, "/"+shortID, nil)
+		request.Host = host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		response := w.Result()
+		defer response.Body.Close()
+
+		return response.StatusCode, response.Header.Get("Location")
+	}
+
+	statusCode, shortURL := shorten("?domain=go.brand.com", "https://ya.ru")
+	require.Equal(t, http.StatusCreated, statusCode)
+	assert.Equal(t, "https://go.brand.com/74704cb5", shortURL)
+
+	statusCode, shortURL = shorten("?domain=links.example.org:8443", "http://example.com/123")
+	require.Equal(t, http.StatusCreated, statusCode)
+	assert.Equal(t, "https://Links.Example.org:8443/488575e6", shortURL)
+
+	statusCode, shortURL = shorten("", "http://example.com/456")
+	require.Equal(t, http.StatusCreated, statusCode)
+	assert.True(t, strings.HasPrefix(shortURL, "http://localhost:8080/"))
+
+	// ссылка, сокращенная на другом домене, сокращается заново в пространстве имен запрошенного домена
+	statusCode, shortURL = shorten("", "https://ya.ru")
+	assert.Equal(t, http.StatusCreated, statusCode)
+	assert.Equal(t, "http://localhost:8080/74704cb5", shortURL)
+
+	statusCode, shortURL = shorten("?domain=go.brand.com", "https://ya.ru")
+	assert.Equal(t, http.StatusConflict, statusCode)
+	assert.Equal(t, "https://go.brand.com/74704cb5", shortURL)
+
+	statusCode, _ = shorten("?domain=unknown.com", "http://example.com/789")
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	statusCode, location := redirect("go.brand.com", "74704cb5")
+	assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+	assert.Equal(t, "https://ya.ru", location)
+
+	statusCode, location = redirect("LINKS.example.org:8443", "488575e6")
+	assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+	assert.Equal(t, "http://example.com/123", location)
+
+	// короткие ID доменов не пересекаются
+	statusCode, _ = redirect("localhost:8080", "488575e6")
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	statusCode, _ = redirect("links.example.org:8443", "74704cb5")
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+}
+
+func TestGetShortURL(t *testing.T) {
+	appConfig := config.NewConfig(
+		config.WithBaseURL("http://localhost:8080"),
+		config.WithDomains([]string{"https://go.brand.com"}),
+	)
+
+	tests := []struct {
+		name       string
+		key        string
+		wantURL    string
+		wantDomain string
+	}{
+		{"default domain", "74704cb5", "http://localhost:8080/74704cb5", "localhost:8080"},
+		{"branded domain", "go.brand.com/74704cb5", "https://go.brand.com/74704cb5", "go.brand.com"},
+		{"removed domain", "old.brand.com/74704cb5", "http://localhost:8080/74704cb5", "localhost:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantURL, getShortURL(tt.key, appConfig))
+			assert.Equal(t, tt.wantDomain, shortURLDomain(tt.key, appConfig))
+		})
+	}
+}
+
+func TestRequestShortIDKey(t *testing.T) {
+	appConfig := config.NewConfig(
+		config.WithBaseURL("http://localhost:8080/s"),
+		config.WithDomains([]string{"https://go.brand.com"}),
+	)
+
+	tests := []struct {
+		name    string
+		domain  string
+		link    string
+		wantKey string
+		wantErr error
+	}{
+		{"default short id", "", "74704cb5", "74704cb5", nil},
+		{"short id on requested domain", "go.brand.com", "74704cb5", "go.brand.com/74704cb5", nil},
+		{"branded key", "", "go.brand.com/74704cb5", "go.brand.com/74704cb5", nil},
+		{"default short url", "go.brand.com", "http://localhost:8080/s/74704cb5", "74704cb5", nil},
+		{"branded short url", "", "https://GO.brand.com/74704cb5", "go.brand.com/74704cb5", nil},
+		{"unknown domain key", "", "old.brand.com/74704cb5", "", ErrUnknownDomain},
+		{"unknown domain short url", "", "https://old.brand.com/74704cb5", "", ErrUnknownDomain},
+		{"short url outside base path", "", "http://localhost:8080/74704cb5", "", ErrInvalidShortURL},
+		{"empty", "", "", "", ErrInvalidShortURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := requestShortIDKey(appConfig, tt.domain, tt.link)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantKey, key)
+		})
+	}
+}
@@ -10,10 +10,12 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/audit"
 	"github.com/rovany706/url-shortener/internal/auth"
 	"github.com/rovany706/url-shortener/internal/config"
 	"github.com/rovany706/url-shortener/internal/repository"
 	"github.com/rovany706/url-shortener/internal/service"
+	"github.com/rovany706/url-shortener/internal/webhook"
 )
 
 type exampleRepository struct {
@@ -32,6 +34,10 @@ type exampleDeleteService struct {
 	service.DeleteService
 }
 
+type exampleClickService struct {
+	service.ClickService
+}
+
 func ExamplePingHandler() {
 	repository := new(exampleRepository)
 	logger := zap.NewNop()
@@ -51,7 +57,8 @@ func ExamplePingHandler() {
 
 func ExampleRedirectHandlers_RedirectHandler() {
 	app := new(exampleURLShortener)
-	redirectHandlers := NewRedirectHandlers(app)
+	clickService := new(exampleClickService)
+	redirectHandlers := NewRedirectHandlers(app, clickService, webhook.NopPublisher{}, config.NewConfig(), zap.NewNop())
 	handler := redirectHandlers.RedirectHandler()
 
 	// Example of registering handler:
@@ -73,7 +80,7 @@ func ExampleShortenURLHandlers_MakeShortURLHandler() {
 	logger := zap.NewNop()
 	appConfig := config.NewConfig()
 
-	shortenHandlers := NewShortenURLHandlers(app, tokenManager, repository, appConfig, logger)
+	shortenHandlers := NewShortenURLHandlers(app, tokenManager, repository, audit.NopLog{}, webhook.NopPublisher{}, appConfig, logger)
 	handler := shortenHandlers.MakeShortURLHandler()
 
 	// Example of registering handler:
@@ -96,7 +103,7 @@ func ExampleShortenURLHandlers_MakeShortURLHandlerJSON() {
 	logger := zap.NewNop()
 	appConfig := config.NewConfig()
 
-	shortenHandlers := NewShortenURLHandlers(app, tokenManager, repository, appConfig, logger)
+	shortenHandlers := NewShortenURLHandlers(app, tokenManager, repository, audit.NopLog{}, webhook.NopPublisher{}, appConfig, logger)
 	handler := shortenHandlers.MakeShortURLHandlerJSON()
 
 	// Example of registering handler:
@@ -119,7 +126,7 @@ func ExampleShortenURLHandlers_MakeShortURLBatchHandler() {
 	logger := zap.NewNop()
 	appConfig := config.NewConfig()
 
-	shortenHandlers := NewShortenURLHandlers(app, tokenManager, repository, appConfig, logger)
+	shortenHandlers := NewShortenURLHandlers(app, tokenManager, repository, audit.NopLog{}, webhook.NopPublisher{}, appConfig, logger)
 	handler := shortenHandlers.MakeShortURLBatchHandler()
 
 	// Example of registering handler:
@@ -157,7 +164,7 @@ func ExampleUserHandlers_GetUserURLsHandler() {
 	logger := zap.NewNop()
 	appConfig := config.NewConfig()
 
-	shortenHandlers := NewUserHandlers(deleteService, tokenManager, repository, appConfig, logger)
+	shortenHandlers := NewUserHandlers(deleteService, tokenManager, repository, audit.NopLog{}, appConfig, logger)
 	handler := shortenHandlers.GetUserURLsHandler()
 
 	// Example of registering handler:
@@ -178,7 +185,8 @@ func ExampleUserHandlers_GetUserURLsHandler() {
 		Jar: jar,
 	}
 
-	resp, err := client.Get("http://service:8080/api/user/urls")
+	// The Link header of the response contains the next page URL, if any
+	resp, err := client.Get("http://service:8080/api/user/urls?limit=100&sort=clicks&order=desc")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -193,7 +201,7 @@ func ExampleUserHandlers_DeleteUserURLsHandler() {
 	logger := zap.NewNop()
 	appConfig := config.NewConfig()
 
-	shortenHandlers := NewUserHandlers(deleteService, tokenManager, repository, appConfig, logger)
+	shortenHandlers := NewUserHandlers(deleteService, tokenManager, repository, audit.NopLog{}, appConfig, logger)
 	handler := shortenHandlers.DeleteUserURLsHandler()
 
 	// Example of registering handler:
@@ -227,3 +235,76 @@ func ExampleUserHandlers_DeleteUserURLsHandler() {
 
 	resp.Body.Close()
 }
+
+func ExampleUserHandlers_ExportUserDataHandler() {
+	deleteService := new(exampleDeleteService)
+	repository := new(exampleRepository)
+	tokenManager := new(exampleTokenManager)
+	logger := zap.NewNop()
+	appConfig := config.NewConfig()
+
+	userHandlers := NewUserHandlers(deleteService, tokenManager, repository, audit.NopLog{}, appConfig, logger)
+	handler := userHandlers.ExportUserDataHandler()
+
+	// Example of registering handler:
+	http.HandleFunc("/api/user/export", handler)
+
+	// Example of sending request:
+	jar, _ := cookiejar.New(nil)
+	cookie := &http.Cookie{
+		Name:   "token",
+		Value:  "<jwt-token>",
+		Path:   "/",
+		Domain: "service:8080",
+	}
+
+	u, _ := url.Parse("http://service:8080")
+	jar.SetCookies(u, []*http.Cookie{cookie})
+	client := &http.Client{
+		Jar: jar,
+	}
+
+	resp, err := client.Get("http://service:8080/api/user/export?format=zip")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp.Body.Close()
+}
+
+func ExampleUserHandlers_DeleteUserHandler() {
+	deleteService := new(exampleDeleteService)
+	repository := new(exampleRepository)
+	tokenManager := new(exampleTokenManager)
+	logger := zap.NewNop()
+	appConfig := config.NewConfig()
+
+	userHandlers := NewUserHandlers(deleteService, tokenManager, repository, audit.NopLog{}, appConfig, logger)
+	handler := userHandlers.DeleteUserHandler()
+
+	// Example of registering handler:
+	http.HandleFunc("/api/user", handler)
+
+	// Example of sending request:
+	jar, _ := cookiejar.New(nil)
+	cookie := &http.Cookie{
+		Name:   "token",
+		Value:  "<jwt-token>",
+		Path:   "/",
+		Domain: "service:8080",
+	}
+
+	u, _ := url.Parse("http://service:8080")
+	jar.SetCookies(u, []*http.Cookie{cookie})
+	client := &http.Client{
+		Jar: jar,
+	}
+
+	request, _ := http.NewRequest(http.MethodDelete, "http://service:8080/api/user", nil)
+	resp, err := client.Do(request)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp.Body.Close()
+}
@@ -4,32 +4,51 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
 
 	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/service"
+	"github.com/rovany706/url-shortener/internal/webhook"
 )
 
 // RedirectHandlers обработчики методов перенаправления
 type RedirectHandlers struct {
-	app app.URLShortener
+	app          app.URLShortener
+	clickService service.ClickService
+	webhooks     webhookPublisher
+	appConfig    *config.AppConfig
 }
 
 // NewRedirectHandlers создает RedirectHandlers
-func NewRedirectHandlers(app app.URLShortener) RedirectHandlers {
+func NewRedirectHandlers(app app.URLShortener, clickService service.ClickService, webhooks webhook.Publisher, appConfig *config.AppConfig, logger *zap.Logger) RedirectHandlers {
 	return RedirectHandlers{
-		app: app,
+		app:          app,
+		clickService: clickService,
+		webhooks:     webhookPublisher{publisher: webhooks, logger: logger},
+		appConfig:    appConfig,
 	}
 }
 
-// RedirectHandler хэндлер перенаправления сокращенной ссылки
+// RedirectHandler хэндлер перенаправления сокращенной ссылки.
+// Ссылка ищется в пространстве имен домена из заголовка Host, переход учитывается в счетчике ссылки
+// и публикуется на вебхуки владельца ссылки.
 func (h *RedirectHandlers) RedirectHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		shortID := chi.URLParam(r, "id")
+		shortID := app.DomainShortID(requestDomain(r, h.appConfig), chi.URLParam(r, "id"))
 		shortenedURLInfo, ok := h.app.GetFullURL(r.Context(), shortID)
 		if ok {
 			if shortenedURLInfo.IsDeleted {
 				w.WriteHeader(http.StatusGone)
 				return
 			}
+			h.clickService.Add(shortID)
+			h.webhooks.publish(r, webhook.Event{
+				Type:        webhook.EventLinkClicked,
+				UserID:      shortenedURLInfo.UserID,
+				ShortID:     shortID,
+				OriginalURL: shortenedURLInfo.FullURL,
+			})
 			http.Redirect(w, r, shortenedURLInfo.FullURL, http.StatusTemporaryRedirect)
 		} else {
 			http.Error(w, "400 Bad Request", http.StatusBadRequest)
@@ -13,9 +13,11 @@ import (
 	"go.uber.org/zap/zaptest"
 
 	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/audit"
 	"github.com/rovany706/url-shortener/internal/auth"
 	"github.com/rovany706/url-shortener/internal/config"
 	"github.com/rovany706/url-shortener/internal/repository/mock"
+	"github.com/rovany706/url-shortener/internal/webhook"
 )
 
 func TestMakeShortURLHandler(t *testing.T) {
@@ -75,7 +77,7 @@ func TestMakeShortURLHandler(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
 			w := httptest.NewRecorder()
 
-			shortenHandlers := NewShortenURLHandlers(shortener, tokenManager, repository, appConfig, testLogger)
+			shortenHandlers := NewShortenURLHandlers(shortener, tokenManager, repository, audit.NopLog{}, webhook.NopPublisher{}, appConfig, testLogger)
 			shortenHandlers.MakeShortURLHandler()(w, request)
 			response := w.Result()
 
@@ -176,7 +178,7 @@ func TestMakeShortURLHandlerJSON(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
 			w := httptest.NewRecorder()
 
-			shortenHandlers := NewShortenURLHandlers(shortener, tokenManager, repository, appConfig, testLogger)
+			shortenHandlers := NewShortenURLHandlers(shortener, tokenManager, repository, audit.NopLog{}, webhook.NopPublisher{}, appConfig, testLogger)
 			shortenHandlers.MakeShortURLHandlerJSON()(w, request)
 			response := w.Result()
 
@@ -10,10 +10,12 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/audit"
 	"github.com/rovany706/url-shortener/internal/auth"
 	"github.com/rovany706/url-shortener/internal/config"
 	"github.com/rovany706/url-shortener/internal/models"
 	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/webhook"
 )
 
 // ShortenURLHandlers обработчики методов сокращения
@@ -21,16 +23,20 @@ type ShortenURLHandlers struct {
 	app          app.URLShortener
 	tokenManager auth.TokenManager
 	repository   repository.Repository
+	audit        auditRecorder
+	webhooks     webhookPublisher
 	appConfig    *config.AppConfig
 	logger       *zap.Logger
 }
 
 // NewShortenURLHandlers создает ShortenURLHandlers
-func NewShortenURLHandlers(app app.URLShortener, tokenManager auth.TokenManager, repository repository.Repository, appConfig *config.AppConfig, logger *zap.Logger) ShortenURLHandlers {
+func NewShortenURLHandlers(app app.URLShortener, tokenManager auth.TokenManager, repository repository.Repository, auditLog audit.Log, webhooks webhook.Publisher, appConfig *config.AppConfig, logger *zap.Logger) ShortenURLHandlers {
 	return ShortenURLHandlers{
 		app:          app,
 		tokenManager: tokenManager,
 		repository:   repository,
+		audit:        auditRecorder{log: auditLog, logger: logger},
+		webhooks:     webhookPublisher{publisher: webhooks, logger: logger},
 		appConfig:    appConfig,
 		logger:       logger,
 	}
@@ -39,7 +45,7 @@ func NewShortenURLHandlers(app app.URLShortener, tokenManager auth.TokenManager,
 // MakeShortURLHandler хэндлер создания сокращенной ссылки
 func (h *ShortenURLHandlers) MakeShortURLHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, r)
+		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, h.audit, r)
 
 		if err != nil {
 			http.Error(w, "", http.StatusBadRequest)
@@ -53,7 +59,13 @@ func (h *ShortenURLHandlers) MakeShortURLHandler() http.HandlerFunc {
 			return
 		}
 
-		shortID, err := h.app.GetShortID(r.Context(), userID, string(body))
+		domain, err := lookupDomain(h.appConfig, r.URL.Query().Get(domainQueryParam))
+		if err != nil {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		shortID, err := h.app.GetShortID(r.Context(), userID, domain, string(body))
 
 		statusCode := http.StatusCreated
 		if err != nil {
@@ -63,6 +75,9 @@ func (h *ShortenURLHandlers) MakeShortURLHandler() http.HandlerFunc {
 				http.Error(w, "", http.StatusBadRequest)
 				return
 			}
+		} else {
+			h.audit.record(r, h.linkCreatedEvent(r, userID, shortID, string(body)))
+			h.webhooks.publish(r, linkCreatedWebhookEvent(userID, shortID, string(body)))
 		}
 
 		if err := auth.SetAuthCookie(h.tokenManager, w, userID, h.logger); err != nil {
@@ -79,7 +94,7 @@ func (h *ShortenURLHandlers) MakeShortURLHandler() http.HandlerFunc {
 // MakeShortURLHandlerJSON принимает запросы на сокращение ссылки в виде JSON
 func (h *ShortenURLHandlers) MakeShortURLHandlerJSON() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, r)
+		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, h.audit, r)
 
 		if err != nil {
 			http.Error(w, "", http.StatusBadRequest)
@@ -95,7 +110,18 @@ func (h *ShortenURLHandlers) MakeShortURLHandlerJSON() http.HandlerFunc {
 			return
 		}
 
-		shortID, err := h.app.GetShortID(r.Context(), userID, request.URL)
+		domainName := request.Domain
+		if domainName == "" {
+			domainName = r.URL.Query().Get(domainQueryParam)
+		}
+
+		domain, err := lookupDomain(h.appConfig, domainName)
+		if err != nil {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		shortID, err := h.app.GetShortID(r.Context(), userID, domain, request.URL)
 
 		statusCode := http.StatusCreated
 		if err != nil {
@@ -105,6 +131,9 @@ func (h *ShortenURLHandlers) MakeShortURLHandlerJSON() http.HandlerFunc {
 				http.Error(w, "", http.StatusBadRequest)
 				return
 			}
+		} else {
+			h.audit.record(r, h.linkCreatedEvent(r, userID, shortID, request.URL))
+			h.webhooks.publish(r, linkCreatedWebhookEvent(userID, shortID, request.URL))
 		}
 		response := models.ShortenResponse{
 			Result: getShortURL(shortID, h.appConfig),
@@ -130,7 +159,7 @@ func (h *ShortenURLHandlers) MakeShortURLHandlerJSON() http.HandlerFunc {
 // MakeShortURLBatchHandler принимает запросы на сокращение нескольких ссылок в виде JSON
 func (h *ShortenURLHandlers) MakeShortURLBatchHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, r)
+		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, h.audit, r)
 
 		if err != nil {
 			http.Error(w, "", http.StatusBadRequest)
@@ -151,7 +180,13 @@ func (h *ShortenURLHandlers) MakeShortURLBatchHandler() http.HandlerFunc {
 			fullURLs[i] = url.OriginalURL
 		}
 
-		shortIDs, err := h.app.GetShortIDBatch(r.Context(), userID, fullURLs)
+		domain, err := lookupDomain(h.appConfig, r.URL.Query().Get(domainQueryParam))
+		if err != nil {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		results, err := h.app.GetShortIDBatch(r.Context(), userID, domain, fullURLs)
 
 		if err != nil {
 			h.logger.Info("error creating short ids", zap.Error(err))
@@ -159,15 +194,25 @@ func (h *ShortenURLHandlers) MakeShortURLBatchHandler() http.HandlerFunc {
 			return
 		}
 
+		events := make([]audit.Event, 0, len(results))
+		webhookEvents := make([]webhook.Event, 0, len(results))
 		responseEntries := make([]models.BatchShortenResponseEntry, len(request))
-		for i, shortID := range shortIDs {
+		for i, result := range results {
+			if !result.Conflict {
+				events = append(events, h.linkCreatedEvent(r, userID, result.ShortID, fullURLs[i]))
+				webhookEvents = append(webhookEvents, linkCreatedWebhookEvent(userID, result.ShortID, fullURLs[i]))
+			}
+
 			entry := models.BatchShortenResponseEntry{
 				CorrelationID: request[i].CorrelationID,
-				ShortURL:      getShortURL(shortID, h.appConfig),
+				ShortURL:      getShortURL(result.ShortID, h.appConfig),
+				Conflict:      result.Conflict,
 			}
 
 			responseEntries[i] = entry
 		}
+		h.audit.record(r, events...)
+		h.webhooks.publish(r, webhookEvents...)
 
 		if err := auth.SetAuthCookie(h.tokenManager, w, userID, h.logger); err != nil {
 			http.Error(w, "", http.StatusBadRequest)
@@ -186,32 +231,49 @@ func (h *ShortenURLHandlers) MakeShortURLBatchHandler() http.HandlerFunc {
 	}
 }
 
-func getShortURL(shortID string, appConfig *config.AppConfig) string {
-	return appConfig.BaseURL + "/" + shortID
+// linkCreatedEvent возвращает событие аудита сокращения ссылки fullURL
+func (h *ShortenURLHandlers) linkCreatedEvent(r *http.Request, userID int, shortID string, fullURL string) audit.Event {
+	event := h.audit.event(r, audit.ActionLinkCreated, userID)
+	event.ShortID = shortID
+	event.After = &audit.LinkState{FullURL: fullURL}
+
+	return event
+}
+
+// linkCreatedWebhookEvent возвращает событие вебхука сокращения ссылки fullURL
+func linkCreatedWebhookEvent(userID int, shortID string, fullURL string) webhook.Event {
+	return webhook.Event{
+		Type:        webhook.EventLinkCreated,
+		UserID:      userID,
+		ShortID:     shortID,
+		OriginalURL: fullURL,
+	}
 }
 
-func getUserIDFromRequest(ctx context.Context, tokenManager auth.TokenManager, repository repository.Repository, r *http.Request) (int, error) {
+func getUserIDFromRequest(ctx context.Context, tokenManager auth.TokenManager, repository repository.Repository, auditor auditRecorder, r *http.Request) (int, error) {
 	authCookie, err := r.Cookie(auth.AuthCookieName)
 
 	if err != nil {
-		return getNewUserID(ctx, repository)
+		return getNewUserID(ctx, repository, auditor, r)
 	}
 
 	claims, err := tokenManager.GetClaimsFromToken(authCookie.Value)
 
 	if err != nil {
-		return getNewUserID(ctx, repository)
+		return getNewUserID(ctx, repository, auditor, r)
 	}
 
 	return claims.UserID, nil
 }
 
-func getNewUserID(ctx context.Context, repository repository.Repository) (int, error) {
+func getNewUserID(ctx context.Context, repository repository.Repository, auditor auditRecorder, r *http.Request) (int, error) {
 	newUserID, err := repository.GetNewUserID(ctx)
 
 	if err != nil {
 		return -1, err
 	}
 
+	auditor.record(r, auditor.event(r, audit.ActionUserCreated, newUserID))
+
 	return newUserID, nil
 }
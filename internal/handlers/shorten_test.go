@@ -2,14 +2,25 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
 
 	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/service"
+	serviceMock "github.com/rovany706/url-shortener/internal/service/mock"
+	"github.com/rovany706/url-shortener/internal/webhook"
 )
 
 func TestRedirectHandler(t *testing.T) {
@@ -52,7 +63,12 @@ func TestRedirectHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
 			shortener := app.NewMockURLShortener(tt.shortURLMap)
+			clickService := serviceMock.NewMockClickService(ctrl)
+			if tt.want.code == http.StatusTemporaryRedirect {
+				clickService.EXPECT().Add(tt.requestID)
+			}
 
 			request := httptest.NewRequest(http.MethodGet, tt.request, nil)
 			w := httptest.NewRecorder()
@@ -60,7 +76,7 @@ func TestRedirectHandler(t *testing.T) {
 			rctx := chi.NewRouteContext()
 			rctx.URLParams.Add("id", tt.requestID)
 			request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, rctx))
-			redirectHandlers := NewRedirectHandlers(shortener)
+			redirectHandlers := NewRedirectHandlers(shortener, clickService, webhook.NopPublisher{}, config.NewConfig(), zaptest.NewLogger(t))
 
 			redirectHandlers.RedirectHandler()(w, request)
 
@@ -72,3 +88,44 @@ func TestRedirectHandler(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkRedirectHandler(b *testing.B) {
+	const linkCount = 1000
+	ctx := context.Background()
+
+	storageRepository, err := repository.NewSQLiteRepository(ctx, filepath.Join(b.TempDir(), "shortener.db"))
+	require.NoError(b, err)
+	defer storageRepository.Close()
+
+	shortIDMap := make(repository.URLMapping, linkCount)
+	for i := 0; i < linkCount; i++ {
+		shortIDMap[fmt.Sprint(i)] = fmt.Sprintf("http://example.com/%d", i)
+	}
+	userID, err := storageRepository.GetNewUserID(ctx)
+	require.NoError(b, err)
+	_, err = storageRepository.SaveEntries(ctx, userID, shortIDMap)
+	require.NoError(b, err)
+
+	benchmarks := []struct {
+		name       string
+		repository repository.Repository
+	}{
+		{"Repository", storageRepository},
+		{"CachedRepository", repository.NewCachedRepository(storageRepository, linkCount)},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			router := chi.NewRouter()
+			redirectHandlers := NewRedirectHandlers(app.NewURLShortenerApp(bm.repository), service.NewClickService(bm.repository), webhook.NopPublisher{}, config.NewConfig(), zap.NewNop())
+			router.Get("/{id}", redirectHandlers.RedirectHandler())
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%d", i%linkCount), nil)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, request)
+			}
+		})
+	}
+}
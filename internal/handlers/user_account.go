@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/auth"
+	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/repository"
+)
+
+const (
+	exportFormatJSON = "json"
+	exportFormatZIP  = "zip"
+	exportFileName   = "export.json"
+)
+
+// ErrUnauthorized ошибка отсутствия валидного токена аутентификации
+var ErrUnauthorized = errors.New("user is not authorized")
+
+// ExportUserDataHandler выгружает все данные пользователя в виде JSON-документа
+// или ZIP-архива (параметр format=zip)
+func (h *UserHandlers) ExportUserDataHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := getAuthorizedUserID(h.tokenManager, r)
+
+		if err != nil {
+			h.logger.Info("unauthorized export request", zap.Error(err))
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = exportFormatJSON
+		}
+
+		if format != exportFormatJSON && format != exportFormatZIP {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		export, err := h.buildUserExport(r, userID)
+
+		if err != nil {
+			h.logger.Info("error building user export", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		if format == exportFormatZIP {
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", `attachment; filename="export.zip"`)
+			w.WriteHeader(http.StatusOK)
+
+			zw := zip.NewWriter(w)
+			fw, err := zw.Create(exportFileName)
+			if err != nil {
+				h.logger.Info("error creating export archive", zap.Error(err))
+				return
+			}
+
+			if err := json.NewEncoder(fw).Encode(export); err != nil {
+				h.logger.Info("error encoding export", zap.Error(err))
+				return
+			}
+
+			if err := zw.Close(); err != nil {
+				h.logger.Info("error closing export archive", zap.Error(err))
+			}
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+exportFileName+`"`)
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		if err := encoder.Encode(export); err != nil {
+			h.logger.Info("error encoding response", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// buildUserExport собирает выгрузку ссылок пользователя в порядке их создания
+func (h *UserHandlers) buildUserExport(r *http.Request, userID int) (*models.UserExport, error) {
+	entries, err := h.repository.ListUserEntries(r.Context(), userID, repository.UserEntriesQuery{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserExport{
+		UserID:     userID,
+		ExportedAt: time.Now().UTC(),
+		URLs:       make([]models.UserExportURL, 0, len(entries)),
+	}
+
+	for _, info := range entries {
+		export.URLs = append(export.URLs, models.UserExportURL{
+			ShortID:     info.ShortID,
+			ShortURL:    getShortURL(info.ShortID, h.appConfig),
+			OriginalURL: info.FullURL,
+			Domain:      shortURLDomain(info.ShortID, h.appConfig),
+			IsDeleted:   info.IsDeleted,
+			CreatedAt:   info.CreatedAt,
+			Clicks:      info.Clicks,
+		})
+	}
+
+	return export, nil
+}
+
+// DeleteUserHandler удаляет аккаунт пользователя: помечает удаленными все его ссылки
+// через сервис удаления, сохраняет удаление пользователя в репозитории и отзывает его токены.
+// Токены удаленных пользователей отзываются повторно при запуске сервера.
+func (h *UserHandlers) DeleteUserHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := getAuthorizedUserID(h.tokenManager, r)
+
+		if err != nil {
+			h.logger.Info("unauthorized user delete request", zap.Error(err))
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		shortIDMap, err := h.repository.GetUserEntries(r.Context(), userID)
+
+		if err != nil {
+			h.logger.Info("error getting user urls", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		if len(shortIDMap) > 0 {
+			remoteIP, requestID := clientIP(r), middleware.GetReqID(r.Context())
+			deleteRequests := make([]models.UserDeleteRequest, 0, len(shortIDMap))
+			for shortID := range shortIDMap {
+				deleteRequests = append(deleteRequests, models.UserDeleteRequest{
+					UserID:          userID,
+					ShortIDToDelete: shortID,
+					ClientIP:        remoteIP,
+					RequestID:       requestID,
+				})
+			}
+
+			if err := h.deleteService.Put(r.Context(), deleteRequests...); err != nil {
+				h.logger.Info("error saving delete requests", zap.Error(err))
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := h.repository.DeleteUser(r.Context(), userID); err != nil {
+			h.logger.Info("error deleting user", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		if err := h.tokenManager.RevokeUserTokens(userID); err != nil {
+			h.logger.Info("error revoking user tokens", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		h.audit.record(r, h.audit.event(r, audit.ActionUserDeleted, userID))
+
+		http.SetCookie(w, &http.Cookie{
+			Name:   auth.AuthCookieName,
+			Value:  "",
+			MaxAge: -1,
+		})
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func getAuthorizedUserID(tokenManager auth.TokenManager, r *http.Request) (int, error) {
+	authCookie, err := r.Cookie(auth.AuthCookieName)
+
+	if err != nil {
+		return -1, ErrUnauthorized
+	}
+
+	claims, err := tokenManager.GetClaimsFromToken(authCookie.Value)
+
+	if err != nil {
+		return -1, err
+	}
+
+	if claims.UserID < 1 {
+		return -1, ErrUnauthorized
+	}
+
+	return claims.UserID, nil
+}
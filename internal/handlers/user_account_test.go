@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/auth"
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/repository/mock"
+	serviceMock "github.com/rovany706/url-shortener/internal/service/mock"
+)
+
+func TestExportUserDataHandler(t *testing.T) {
+	appConfig := config.NewConfig(
+		config.WithBaseURL("http://localhost:8080"),
+		config.WithDomains([]string{"https://go.brand.com"}),
+	)
+	createdAt := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	userEntries := []repository.ShortenedURLInfo{
+		{UserID: 1, ShortID: "id1", FullURL: "http://example.com/1", CreatedAt: createdAt, Clicks: 3},
+		{UserID: 1, ShortID: "go.brand.com/id2", FullURL: "http://example.com/2", IsDeleted: true, CreatedAt: createdAt.Add(time.Hour)},
+	}
+	wantURLs := []models.UserExportURL{
+		{ShortID: "id1", ShortURL: "http://localhost:8080/id1", OriginalURL: "http://example.com/1", Domain: "localhost:8080", CreatedAt: createdAt, Clicks: 3},
+		{ShortID: "go.brand.com/id2", ShortURL: "https://go.brand.com/id2", OriginalURL: "http://example.com/2", Domain: "go.brand.com", IsDeleted: true, CreatedAt: createdAt.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		authorized     bool
+		wantStatusCode int
+		wantZIP        bool
+	}{
+		{
+			name:           "json export",
+			authorized:     true,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "zip export",
+			query:          "?format=zip",
+			authorized:     true,
+			wantStatusCode: http.StatusOK,
+			wantZIP:        true,
+		},
+		{
+			name:           "unknown format",
+			query:          "?format=xml",
+			authorized:     true,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "unauthorized",
+			authorized:     false,
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tokenManager, err := auth.NewJWTTokenManager(nil)
+			require.NoError(t, err)
+
+			repo := mock.NewMockRepository(ctrl)
+			repo.EXPECT().ListUserEntries(gomock.Any(), 1, repository.UserEntriesQuery{}).Return(userEntries, nil).AnyTimes()
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/export"+tt.query, nil)
+			if tt.authorized {
+				token, err := tokenManager.CreateToken(1)
+				require.NoError(t, err)
+				request.AddCookie(&http.Cookie{Name: auth.AuthCookieName, Value: token})
+			}
+			w := httptest.NewRecorder()
+
+			userHandlers := NewUserHandlers(serviceMock.NewMockDeleteService(ctrl), tokenManager, repo, audit.NopLog{}, appConfig, zaptest.NewLogger(t))
+			userHandlers.ExportUserDataHandler()(w, request)
+
+			response := w.Result()
+			defer response.Body.Close()
+
+			require.Equal(t, tt.wantStatusCode, response.StatusCode)
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			if tt.wantZIP {
+				assert.Equal(t, "application/zip", response.Header.Get("Content-Type"))
+				zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+				require.NoError(t, err)
+				require.Len(t, zr.File, 1)
+
+				f, err := zr.File[0].Open()
+				require.NoError(t, err)
+				defer f.Close()
+
+				body, err = io.ReadAll(f)
+				require.NoError(t, err)
+			}
+
+			var export models.UserExport
+			require.NoError(t, json.Unmarshal(body, &export))
+			assert.Equal(t, 1, export.UserID)
+			assert.Equal(t, wantURLs, export.URLs)
+		})
+	}
+}
+
+func TestDeleteUserHandler(t *testing.T) {
+	appConfig := config.NewConfig()
+
+	t.Run("deletes links and revokes tokens", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		tokenManager, err := auth.NewJWTTokenManager(nil)
+		require.NoError(t, err)
+		token, err := tokenManager.CreateToken(1)
+		require.NoError(t, err)
+
+		repo := mock.NewMockRepository(ctrl)
+		repo.EXPECT().GetUserEntries(gomock.Any(), 1).Return(repository.URLMapping{
+			"id1":              "http://example.com/1",
+			"go.brand.com/id2": "http://example.com/2",
+		}, nil)
+
+		var deleted []models.UserDeleteRequest
+		deleteService := serviceMock.NewMockDeleteService(ctrl)
+		deleteService.EXPECT().Put(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, requests ...models.UserDeleteRequest) error {
+			deleted = requests
+			return nil
+		})
+		repo.EXPECT().DeleteUser(gomock.Any(), 1).Return(nil)
+
+		auditLog, err := audit.NewFileLog(afero.NewMemMapFs(), "audit.log")
+		require.NoError(t, err)
+		defer auditLog.Close()
+
+		request := httptest.NewRequest(http.MethodDelete, "/api/user", nil)
+		request = request.WithContext(context.WithValue(request.Context(), middleware.RequestIDKey, "request-1"))
+		request.AddCookie(&http.Cookie{Name: auth.AuthCookieName, Value: token})
+		w := httptest.NewRecorder()
+
+		userHandlers := NewUserHandlers(deleteService, tokenManager, repo, auditLog, appConfig, zaptest.NewLogger(t))
+		userHandlers.DeleteUserHandler()(w, request)
+
+		response := w.Result()
+		defer response.Body.Close()
+
+		assert.Equal(t, http.StatusAccepted, response.StatusCode)
+
+		// ссылки дополнительных доменов удаляются по ключу ссылки host/id
+		assert.ElementsMatch(t, []models.UserDeleteRequest{
+			{UserID: 1, ShortIDToDelete: "id1", ClientIP: "192.0.2.1", RequestID: "request-1"},
+			{UserID: 1, ShortIDToDelete: "go.brand.com/id2", ClientIP: "192.0.2.1", RequestID: "request-1"},
+		}, deleted)
+
+		_, err = tokenManager.GetClaimsFromToken(token)
+		assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+
+		events, err := auditLog.UserEvents(context.Background(), 1, audit.DefaultUserEventsLimit)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, audit.ActionUserDeleted, events[0].Action)
+		assert.Equal(t, "192.0.2.1", events[0].ClientIP)
+		assert.Equal(t, "request-1", events[0].RequestID)
+	})
+
+	t.Run("delete requests not saved", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		tokenManager, err := auth.NewJWTTokenManager(nil)
+		require.NoError(t, err)
+		token, err := tokenManager.CreateToken(1)
+		require.NoError(t, err)
+
+		repo := mock.NewMockRepository(ctrl)
+		repo.EXPECT().GetUserEntries(gomock.Any(), 1).Return(repository.URLMapping{"id1": "http://example.com/1"}, nil)
+
+		deleteService := serviceMock.NewMockDeleteService(ctrl)
+		deleteService.EXPECT().Put(gomock.Any(), gomock.Any()).Return(errors.New("disk full"))
+
+		request := httptest.NewRequest(http.MethodDelete, "/api/user", nil)
+		request.AddCookie(&http.Cookie{Name: auth.AuthCookieName, Value: token})
+		w := httptest.NewRecorder()
+
+		userHandlers := NewUserHandlers(deleteService, tokenManager, repo, audit.NopLog{}, appConfig, zaptest.NewLogger(t))
+		userHandlers.DeleteUserHandler()(w, request)
+
+		response := w.Result()
+		defer response.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+
+		// без сохраненных запросов на удаление токены не отзываются
+		_, err = tokenManager.GetClaimsFromToken(token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("user deletion not saved", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		tokenManager, err := auth.NewJWTTokenManager(nil)
+		require.NoError(t, err)
+		token, err := tokenManager.CreateToken(1)
+		require.NoError(t, err)
+
+		repo := mock.NewMockRepository(ctrl)
+		repo.EXPECT().GetUserEntries(gomock.Any(), 1).Return(repository.URLMapping{}, nil)
+		repo.EXPECT().DeleteUser(gomock.Any(), 1).Return(errors.New("connection refused"))
+
+		request := httptest.NewRequest(http.MethodDelete, "/api/user", nil)
+		request.AddCookie(&http.Cookie{Name: auth.AuthCookieName, Value: token})
+		w := httptest.NewRecorder()
+
+		userHandlers := NewUserHandlers(serviceMock.NewMockDeleteService(ctrl), tokenManager, repo, audit.NopLog{}, appConfig, zaptest.NewLogger(t))
+		userHandlers.DeleteUserHandler()(w, request)
+
+		response := w.Result()
+		defer response.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+
+		_, err = tokenManager.GetClaimsFromToken(token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		tokenManager, err := auth.NewJWTTokenManager(nil)
+		require.NoError(t, err)
+
+		request := httptest.NewRequest(http.MethodDelete, "/api/user", nil)
+		w := httptest.NewRecorder()
+
+		userHandlers := NewUserHandlers(serviceMock.NewMockDeleteService(ctrl), tokenManager, mock.NewMockRepository(ctrl), audit.NopLog{}, appConfig, zaptest.NewLogger(t))
+		userHandlers.DeleteUserHandler()(w, request)
+
+		response := w.Result()
+		defer response.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	})
+}
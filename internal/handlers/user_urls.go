@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
 
+	"github.com/rovany706/url-shortener/internal/audit"
 	"github.com/rovany706/url-shortener/internal/auth"
 	"github.com/rovany706/url-shortener/internal/config"
 	"github.com/rovany706/url-shortener/internal/models"
@@ -20,23 +22,28 @@ type UserHandlers struct {
 	repository    repository.Repository
 	tokenManager  auth.TokenManager
 	deleteService service.DeleteService
+	audit         auditRecorder
 }
 
 // NewUserHandlers создает UserHandlers
-func NewUserHandlers(deleteService service.DeleteService, tokenManager auth.TokenManager, repository repository.Repository, appConfig *config.AppConfig, logger *zap.Logger) UserHandlers {
+func NewUserHandlers(deleteService service.DeleteService, tokenManager auth.TokenManager, repository repository.Repository, auditLog audit.Log, appConfig *config.AppConfig, logger *zap.Logger) UserHandlers {
 	return UserHandlers{
 		appConfig:     appConfig,
 		logger:        logger,
 		repository:    repository,
 		tokenManager:  tokenManager,
 		deleteService: deleteService,
+		audit:         auditRecorder{log: auditLog, logger: logger},
 	}
 }
 
-// GetUserURLsHandler возвращает пользователю список сокращенных им ссылок
+// GetUserURLsHandler возвращает пользователю список сокращенных им ссылок.
+// Параметры sort (created_at или clicks) и order (asc или desc) задают стабильный порядок ссылок,
+// limit - размер страницы. Если за страницей есть ссылки, заголовок Link (rel="next")
+// содержит адрес следующей страницы с курсором cursor.
 func (h *UserHandlers) GetUserURLsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, r)
+		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, h.audit, r)
 
 		if err != nil {
 			http.Error(w, "", http.StatusBadRequest)
@@ -61,7 +68,19 @@ func (h *UserHandlers) GetUserURLsHandler() http.HandlerFunc {
 			Value: token,
 		})
 
-		shortIDMap, err := h.repository.GetUserEntries(r.Context(), userID)
+		entriesQuery, order, err := parseUserURLsQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// лишняя ссылка показывает, что за страницей есть следующая
+		pageLimit := entriesQuery.Limit
+		if pageLimit > 0 {
+			entriesQuery.Limit++
+		}
+
+		entries, err := h.repository.ListUserEntries(r.Context(), userID, entriesQuery)
 
 		if err != nil {
 			h.logger.Info("error getting user urls", zap.Error(err))
@@ -69,17 +88,32 @@ func (h *UserHandlers) GetUserURLsHandler() http.HandlerFunc {
 			return
 		}
 
-		if len(shortIDMap) == 0 {
+		if pageLimit > 0 && len(entries) > pageLimit {
+			entries = entries[:pageLimit]
+
+			cursor, err := encodeUserURLsCursor(entriesQuery, order, entries[pageLimit-1])
+			if err != nil {
+				h.logger.Info("error encoding cursor", zap.Error(err))
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Link", nextPageLink(r, cursor))
+		}
+
+		if len(entries) == 0 {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
-		response := make(models.UserShortenedURLs, 0)
+		response := make(models.UserShortenedURLs, 0, len(entries))
 
-		for shortID, fullURL := range shortIDMap {
+		for _, info := range entries {
 			response = append(response, models.UserShortenedURL{
-				ShortURL:    getShortURL(shortID, h.appConfig),
-				OriginalURL: fullURL,
+				ShortURL:    getShortURL(info.ShortID, h.appConfig),
+				OriginalURL: info.FullURL,
+				Domain:      shortURLDomain(info.ShortID, h.appConfig),
+				CreatedAt:   info.CreatedAt,
+				Clicks:      info.Clicks,
 			})
 		}
 
@@ -94,10 +128,12 @@ func (h *UserHandlers) GetUserURLsHandler() http.HandlerFunc {
 	}
 }
 
-// DeleteUserURLsHandler принимает запросы на удаление сокращенных ссылкок
+// DeleteUserURLsHandler принимает запросы на удаление сокращенных ссылкок.
+// Ссылки передаются короткими ссылками целиком, ключами host/id или короткими ID;
+// короткие ID относятся к домену из параметра domain (по умолчанию - основному домену).
 func (h *UserHandlers) DeleteUserURLsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, r)
+		userID, err := getUserIDFromRequest(r.Context(), h.tokenManager, h.repository, h.audit, r)
 
 		if err != nil {
 			http.Error(w, "", http.StatusBadRequest)
@@ -113,20 +149,35 @@ func (h *UserHandlers) DeleteUserURLsHandler() http.HandlerFunc {
 			return
 		}
 
-		deleteChan := make(chan models.UserDeleteRequest)
-		go func() {
-			defer close(deleteChan)
-			for _, shortID := range request {
-				deleteRequest := models.UserDeleteRequest{
-					UserID:          userID,
-					ShortIDToDelete: shortID,
-				}
+		domain, err := lookupDomain(h.appConfig, r.URL.Query().Get(domainQueryParam))
+		if err != nil {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		remoteIP, requestID := clientIP(r), middleware.GetReqID(r.Context())
+		deleteRequests := make([]models.UserDeleteRequest, len(request))
+		for i, link := range request {
+			shortID, err := requestShortIDKey(h.appConfig, domain, link)
+			if err != nil {
+				h.logger.Info("invalid short url to delete", zap.String("link", link), zap.Error(err))
+				http.Error(w, "", http.StatusBadRequest)
+				return
+			}
 
-				deleteChan <- deleteRequest
+			deleteRequests[i] = models.UserDeleteRequest{
+				UserID:          userID,
+				ShortIDToDelete: shortID,
+				ClientIP:        remoteIP,
+				RequestID:       requestID,
 			}
-		}()
+		}
 
-		h.deleteService.Put(deleteChan)
+		if err = h.deleteService.Put(r.Context(), deleteRequests...); err != nil {
+			h.logger.Info("error saving delete requests", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
 
 		w.WriteHeader(http.StatusAccepted)
 	}
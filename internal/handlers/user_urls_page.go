@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rovany706/url-shortener/internal/repository"
+)
+
+// Параметры запроса списка ссылок пользователя
+const (
+	limitQueryParam  = "limit"
+	cursorQueryParam = "cursor"
+	sortQueryParam   = "sort"
+	orderQueryParam  = "order"
+)
+
+// Направления сортировки списка ссылок пользователя
+const (
+	orderAsc  = "asc"
+	orderDesc = "desc"
+)
+
+// maxUserURLsLimit максимальный размер страницы списка ссылок пользователя
+const maxUserURLsLimit = 1000
+
+var (
+	// ErrInvalidLimit ошибка размера страницы вне диапазона 1..maxUserURLsLimit
+	ErrInvalidLimit = errors.New("invalid limit")
+	// ErrInvalidSort ошибка неизвестного поля сортировки
+	ErrInvalidSort = errors.New("invalid sort")
+	// ErrInvalidOrder ошибка неизвестного направления сортировки
+	ErrInvalidOrder = errors.New("invalid order")
+	// ErrInvalidCursor ошибка курсора, который не был выдан для этой сортировки
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// userURLsCursor позиция последней ссылки страницы, передаваемая клиенту в непрозрачном виде
+type userURLsCursor struct {
+	Sort      repository.UserEntriesSort `json:"sort"`
+	Order     string                     `json:"order"`
+	CreatedAt time.Time                  `json:"created_at"`
+	Clicks    int64                      `json:"clicks"`
+	ShortID   string                     `json:"short_id"`
+}
+
+// parseUserURLsQuery разбирает параметры страницы списка ссылок пользователя.
+// По умолчанию ссылки сортируются по времени создания по убыванию и возвращаются без ограничения количества.
+// Без явных параметров sort и order используется сортировка курсора, с явными - она должна совпадать.
+func parseUserURLsQuery(query url.Values) (entriesQuery repository.UserEntriesQuery, order string, err error) {
+	var cursor *userURLsCursor
+	if value := query.Get(cursorQueryParam); value != "" {
+		if cursor, err = decodeUserURLsCursor(value); err != nil {
+			return repository.UserEntriesQuery{}, "", err
+		}
+	}
+
+	entriesQuery.Sort = repository.UserEntriesSort(query.Get(sortQueryParam))
+	switch {
+	case entriesQuery.Sort == "" && cursor != nil:
+		entriesQuery.Sort = cursor.Sort
+	case entriesQuery.Sort == "":
+		entriesQuery.Sort = repository.SortByCreatedAt
+	}
+	if entriesQuery.Sort != repository.SortByCreatedAt && entriesQuery.Sort != repository.SortByClicks {
+		return repository.UserEntriesQuery{}, "", ErrInvalidSort
+	}
+
+	order = query.Get(orderQueryParam)
+	switch {
+	case order == "" && cursor != nil:
+		order = cursor.Order
+	case order == "":
+		order = orderDesc
+	}
+	if order != orderAsc && order != orderDesc {
+		return repository.UserEntriesQuery{}, "", ErrInvalidOrder
+	}
+	entriesQuery.Descending = order == orderDesc
+
+	if cursor != nil {
+		if cursor.Sort != entriesQuery.Sort || cursor.Order != order {
+			return repository.UserEntriesQuery{}, "", ErrInvalidCursor
+		}
+
+		entriesQuery.After = &repository.UserEntriesCursor{
+			CreatedAt: cursor.CreatedAt,
+			Clicks:    cursor.Clicks,
+			ShortID:   cursor.ShortID,
+		}
+	}
+
+	if value := query.Get(limitQueryParam); value != "" {
+		entriesQuery.Limit, err = strconv.Atoi(value)
+		if err != nil || entriesQuery.Limit < 1 || entriesQuery.Limit > maxUserURLsLimit {
+			return repository.UserEntriesQuery{}, "", ErrInvalidLimit
+		}
+	}
+
+	return entriesQuery, order, nil
+}
+
+// encodeUserURLsCursor кодирует позицию ссылки info в курсор следующей страницы
+func encodeUserURLsCursor(entriesQuery repository.UserEntriesQuery, order string, info repository.ShortenedURLInfo) (string, error) {
+	data, err := json.Marshal(userURLsCursor{
+		Sort:      entriesQuery.Sort,
+		Order:     order,
+		CreatedAt: info.CreatedAt,
+		Clicks:    info.Clicks,
+		ShortID:   info.ShortID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeUserURLsCursor декодирует курсор страницы
+func decodeUserURLsCursor(value string) (*userURLsCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor userURLsCursor
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.ShortID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// nextPageLink возвращает значение заголовка Link со ссылкой на следующую страницу:
+// запрос r с курсором cursor
+func nextPageLink(r *http.Request, cursor string) string {
+	query := r.URL.Query()
+	query.Set(cursorQueryParam, cursor)
+
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+
+	return fmt.Sprintf(`<%s>; rel="next"`, next.String())
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/auth"
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/deletequeue"
+	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/service"
+	serviceMock "github.com/rovany706/url-shortener/internal/service/mock"
+	"github.com/rovany706/url-shortener/internal/webhook"
+)
+
+// nextLinkPattern разбирает заголовок Link со ссылкой на следующую страницу
+var nextLinkPattern = regexp.MustCompile(`^<(/api/user/urls\?[^>]+)>; rel="next"$`)
+
+func TestGetUserURLsHandler(t *testing.T) {
+	ctx := context.Background()
+	appConfig := config.NewConfig(config.WithBaseURL("http://localhost:8080"))
+
+	tokenManager, err := auth.NewJWTTokenManager(nil)
+	require.NoError(t, err)
+
+	memoryRepository := repository.NewMemoryRepository()
+	userID, err := memoryRepository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	token, err := tokenManager.CreateToken(userID)
+	require.NoError(t, err)
+
+	clicks := map[string]int64{"a": 3, "b": 1, "c": 3, "d": 0, "e": 5}
+	for _, shortID := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, memoryRepository.SaveEntry(ctx, userID, shortID, "http://example.com/"+shortID))
+	}
+	require.NoError(t, memoryRepository.AddClicks(ctx, clicks))
+
+	userHandlers := NewUserHandlers(service.NewDeleteService(memoryRepository, deletequeue.NewMemoryQueue(), audit.NopLog{}, webhook.NopPublisher{}), tokenManager, memoryRepository, audit.NopLog{}, appConfig, zaptest.NewLogger(t))
+
+	get := func(target string) (*http.Response, models.UserShortenedURLs) {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request.AddCookie(&http.Cookie{Name: auth.AuthCookieName, Value: token})
+		w := httptest.NewRecorder()
+
+		userHandlers.GetUserURLsHandler()(w, request)
+
+		response := w.Result()
+		defer response.Body.Close()
+
+		var urls models.UserShortenedURLs
+		if response.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&urls))
+		}
+
+		return response, urls
+	}
+
+	t.Run("all links", func(t *testing.T) {
+		response, urls := get("/api/user/urls")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Empty(t, response.Header.Get("Link"))
+		require.Len(t, urls, len(clicks))
+
+		// по умолчанию ссылки отсортированы по времени создания по убыванию
+		for i := 1; i < len(urls); i++ {
+			assert.False(t, urls[i].CreatedAt.After(urls[i-1].CreatedAt))
+		}
+		for _, u := range urls {
+			assert.Equal(t, clicks[u.ShortURL[len("http://localhost:8080/"):]], u.Clicks)
+		}
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		shortURLs := make([]string, 0, len(clicks))
+		target := "/api/user/urls?limit=2&sort=clicks&order=desc"
+		for pages := 0; target != ""; pages++ {
+			require.Less(t, pages, len(clicks), "pagination does not end")
+
+			response, urls := get(target)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			assert.LessOrEqual(t, len(urls), 2)
+			for _, u := range urls {
+				shortURLs = append(shortURLs, u.ShortURL)
+			}
+
+			target = ""
+			if link := response.Header.Get("Link"); link != "" {
+				match := nextLinkPattern.FindStringSubmatch(link)
+				require.NotNil(t, match, "unexpected Link header %q", link)
+				target = match[1]
+			}
+		}
+
+		want := make([]string, 0, len(clicks))
+		for _, shortID := range []string{"e", "c", "a", "b", "d"} {
+			want = append(want, fmt.Sprintf("http://localhost:8080/%s", shortID))
+		}
+		assert.Equal(t, want, shortURLs)
+	})
+
+	t.Run("last page without next link", func(t *testing.T) {
+		response, urls := get("/api/user/urls?limit=5")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Len(t, urls, 5)
+		assert.Empty(t, response.Header.Get("Link"))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		response, _ := get("/api/user/urls?limit=1&sort=clicks")
+		match := nextLinkPattern.FindStringSubmatch(response.Header.Get("Link"))
+		require.NotNil(t, match)
+		next, err := url.Parse(match[1])
+		require.NoError(t, err)
+		clicksCursor := "cursor=" + next.Query().Get("cursor")
+
+		for _, query := range []string{
+			"limit=0",
+			"limit=1001",
+			"limit=abc",
+			"sort=name",
+			"order=up",
+			"cursor=not-a-cursor",
+			// курсор выдан для другой сортировки
+			clicksCursor + "&sort=created_at",
+			clicksCursor + "&order=asc",
+		} {
+			response, _ := get("/api/user/urls?" + query)
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode, query)
+		}
+	})
+
+	t.Run("no links", func(t *testing.T) {
+		otherUserID, err := memoryRepository.GetNewUserID(ctx)
+		require.NoError(t, err)
+		otherToken, err := tokenManager.CreateToken(otherUserID)
+		require.NoError(t, err)
+
+		request := httptest.NewRequest(http.MethodGet, "/api/user/urls?limit=10", nil)
+		request.AddCookie(&http.Cookie{Name: auth.AuthCookieName, Value: otherToken})
+		w := httptest.NewRecorder()
+
+		userHandlers.GetUserURLsHandler()(w, request)
+
+		response := w.Result()
+		defer response.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+}
+
+func TestDeleteUserURLsHandler(t *testing.T) {
+	appConfig := config.NewConfig(
+		config.WithBaseURL("http://localhost:8080"),
+		config.WithDomains([]string{"https://go.brand.com"}),
+	)
+
+	tokenManager, err := auth.NewJWTTokenManager(nil)
+	require.NoError(t, err)
+	token, err := tokenManager.CreateToken(1)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		target         string
+		body           string
+		wantStatusCode int
+		wantShortIDs   []string
+	}{
+		{
+			name:           "default domain short ids",
+			target:         "/api/user/urls",
+			body:           `["id1", "id2"]`,
+			wantStatusCode: http.StatusAccepted,
+			wantShortIDs:   []string{"id1", "id2"},
+		},
+		{
+			name:           "short ids on requested domain",
+			target:         "/api/user/urls?domain=go.brand.com",
+			body:           `["id1"]`,
+			wantStatusCode: http.StatusAccepted,
+			wantShortIDs:   []string{"go.brand.com/id1"},
+		},
+		{
+			name:           "short urls",
+			target:         "/api/user/urls",
+			body:           `["https://go.brand.com/id1", "http://localhost:8080/id2"]`,
+			wantStatusCode: http.StatusAccepted,
+			wantShortIDs:   []string{"go.brand.com/id1", "id2"},
+		},
+		{
+			name:           "unknown domain",
+			target:         "/api/user/urls?domain=old.brand.com",
+			body:           `["id1"]`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "short url on unknown domain",
+			target:         "/api/user/urls",
+			body:           `["https://old.brand.com/id1"]`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var shortIDs []string
+			deleteService := serviceMock.NewMockDeleteService(ctrl)
+			deleteService.EXPECT().Put(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, requests ...models.UserDeleteRequest) error {
+				for _, request := range requests {
+					shortIDs = append(shortIDs, request.ShortIDToDelete)
+				}
+				return nil
+			}).MaxTimes(1)
+
+			request := httptest.NewRequest(http.MethodDelete, tt.target, strings.NewReader(tt.body))
+			request.AddCookie(&http.Cookie{Name: auth.AuthCookieName, Value: token})
+			w := httptest.NewRecorder()
+
+			userHandlers := NewUserHandlers(deleteService, tokenManager, repository.NewMemoryRepository(), audit.NopLog{}, appConfig, zaptest.NewLogger(t))
+			userHandlers.DeleteUserURLsHandler()(w, request)
+
+			response := w.Result()
+			defer response.Body.Close()
+
+			assert.Equal(t, tt.wantStatusCode, response.StatusCode)
+			assert.Equal(t, tt.wantShortIDs, shortIDs)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/rovany706/url-shortener/internal/auth"
+	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/webhook"
+)
+
+// maxWebhookDeliveriesLimit максимальное количество доставок вебхуков в ответе
+const maxWebhookDeliveriesLimit = 1000
+
+// webhookPublisher публикует на вебхуки события ссылок, измененных обработчиками
+type webhookPublisher struct {
+	publisher webhook.Publisher
+	logger    *zap.Logger
+}
+
+// publish публикует события.
+// Публикация не прерывается отменой запроса, ошибка логируется и не влияет на ответ.
+func (p webhookPublisher) publish(r *http.Request, events ...webhook.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	if err := p.publisher.Publish(context.WithoutCancel(r.Context()), events...); err != nil {
+		p.logger.Error("error publishing webhook events", zap.Error(err))
+	}
+}
+
+// WebhookHandlers обработчики методов управления вебхуками пользователя
+type WebhookHandlers struct {
+	store        webhook.Store
+	tokenManager auth.TokenManager
+	logger       *zap.Logger
+}
+
+// NewWebhookHandlers создает WebhookHandlers
+func NewWebhookHandlers(store webhook.Store, tokenManager auth.TokenManager, logger *zap.Logger) WebhookHandlers {
+	return WebhookHandlers{
+		store:        store,
+		tokenManager: tokenManager,
+		logger:       logger,
+	}
+}
+
+// CreateWebhookHandler регистрирует вебхук пользователя.
+// Ответ содержит секрет подписи запросов, который больше не возвращается.
+// Если у пользователя уже webhook.MaxUserEndpoints вебхуков, возвращается 409 Conflict.
+func (h *WebhookHandlers) CreateWebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := getAuthorizedUserID(h.tokenManager, r)
+
+		if err != nil {
+			h.logger.Info("unauthorized webhook request", zap.Error(err))
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		decoder := json.NewDecoder(r.Body)
+		var request models.CreateWebhookRequest
+
+		if err := decoder.Decode(&request); err != nil {
+			h.logger.Info("cannot decode request JSON body", zap.Error(err))
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		events := make([]webhook.EventType, len(request.Events))
+		for i, eventType := range request.Events {
+			events[i] = webhook.EventType(eventType)
+		}
+
+		endpoint, err := webhook.NewEndpoint(userID, request.URL, events)
+
+		if errors.Is(err, webhook.ErrInvalidEndpoint) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err == nil {
+			err = h.store.CreateEndpoint(r.Context(), endpoint)
+		}
+
+		if errors.Is(err, webhook.ErrTooManyEndpoints) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		if err != nil {
+			h.logger.Info("error creating webhook", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		encoder := json.NewEncoder(w)
+		if err := encoder.Encode(endpoint); err != nil {
+			h.logger.Info("error encoding response", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetWebhooksHandler возвращает вебхуки пользователя без секретов
+func (h *WebhookHandlers) GetWebhooksHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := getAuthorizedUserID(h.tokenManager, r)
+
+		if err != nil {
+			h.logger.Info("unauthorized webhook request", zap.Error(err))
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		endpoints, err := h.store.UserEndpoints(r.Context(), userID)
+
+		if err != nil {
+			h.logger.Info("error getting webhooks", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		if len(endpoints) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		for i := range endpoints {
+			endpoints[i].Secret = ""
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		if err := encoder.Encode(endpoints); err != nil {
+			h.logger.Info("error encoding response", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DeleteWebhookHandler удаляет вебхук пользователя.
+// Ожидающие доставки на удаленный вебхук прекращаются.
+func (h *WebhookHandlers) DeleteWebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := getAuthorizedUserID(h.tokenManager, r)
+
+		if err != nil {
+			h.logger.Info("unauthorized webhook request", zap.Error(err))
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		err = h.store.DeleteEndpoint(r.Context(), userID, chi.URLParam(r, "id"))
+
+		if errors.Is(err, webhook.ErrNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			h.logger.Info("error deleting webhook", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetWebhookDeliveriesHandler возвращает последние доставки событий на вебхуки пользователя, начиная с новых.
+// Параметр limit задает количество доставок (по умолчанию webhook.DefaultUserDeliveriesLimit).
+func (h *WebhookHandlers) GetWebhookDeliveriesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := getAuthorizedUserID(h.tokenManager, r)
+
+		if err != nil {
+			h.logger.Info("unauthorized webhook request", zap.Error(err))
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		limit := webhook.DefaultUserDeliveriesLimit
+		if value := r.URL.Query().Get(limitQueryParam); value != "" {
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxWebhookDeliveriesLimit {
+				http.Error(w, ErrInvalidLimit.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		deliveries, err := h.store.UserDeliveries(r.Context(), userID, limit)
+
+		if err != nil {
+			h.logger.Info("error getting webhook deliveries", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		if len(deliveries) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		if err := encoder.Encode(deliveries); err != nil {
+			h.logger.Info("error encoding response", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/auth"
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/service"
+	"github.com/rovany706/url-shortener/internal/webhook"
+)
+
+func TestWebhookHandlers(t *testing.T) {
+	appConfig := config.NewConfig()
+	logger := zaptest.NewLogger(t)
+
+	tokenManager, err := auth.NewJWTTokenManager(nil)
+	require.NoError(t, err)
+
+	memoryRepository := repository.NewMemoryRepository()
+	shortener := app.NewURLShortenerApp(memoryRepository)
+	store := webhook.NewMemoryStore()
+	dispatcher := webhook.NewDispatcher(store, webhook.WithClickSampleRate(1))
+
+	shortenHandlers := NewShortenURLHandlers(shortener, tokenManager, memoryRepository, audit.NopLog{}, dispatcher, appConfig, logger)
+	redirectHandlers := NewRedirectHandlers(shortener, service.NewClickService(memoryRepository), dispatcher, appConfig, logger)
+	webhookHandlers := NewWebhookHandlers(store, tokenManager, logger)
+
+	router := chi.NewRouter()
+	router.Post("/api/shorten", shortenHandlers.MakeShortURLHandlerJSON())
+	router.Get("/{id}", redirectHandlers.RedirectHandler())
+	router.Post("/api/user/webhooks", webhookHandlers.CreateWebhookHandler())
+	router.Get("/api/user/webhooks", webhookHandlers.GetWebhooksHandler())
+	router.Get("/api/user/webhooks/deliveries", webhookHandlers.GetWebhookDeliveriesHandler())
+	router.Delete("/api/user/webhooks/{id}", webhookHandlers.DeleteWebhookHandler())
+
+	userCookie := func(userID int) *http.Cookie {
+		token, err := tokenManager.CreateToken(userID)
+		require.NoError(t, err)
+
+		return &http.Cookie{Name: auth.AuthCookieName, Value: token}
+	}
+	cookie := userCookie(1)
+
+	serve := func(method string, target string, body string, cookie *http.Cookie) *http.Response {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, request)
+
+		return w.Result()
+	}
+
+	var endpoint webhook.Endpoint
+
+	t.Run("create", func(t *testing.T) {
+		response := serve(http.MethodPost, "/api/user/webhooks",
+			`{"url":"https://receiver.example/hook","events":["link.clicked","link.created"]}`, cookie)
+		defer response.Body.Close()
+
+		require.Equal(t, http.StatusCreated, response.StatusCode)
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&endpoint))
+		assert.NotEmpty(t, endpoint.ID)
+		assert.NotEmpty(t, endpoint.Secret)
+		assert.Equal(t, []webhook.EventType{webhook.EventLinkClicked, webhook.EventLinkCreated}, endpoint.Events)
+
+		for _, body := range []string{
+			`{"url":"https://receiver.example/hook","events":["link.renamed"]}`,
+			`{"url":"receiver.example","events":["link.created"]}`,
+			`{"url":`,
+		} {
+			response := serve(http.MethodPost, "/api/user/webhooks", body, cookie)
+			response.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode, body)
+		}
+	})
+
+	t.Run("endpoint limit", func(t *testing.T) {
+		otherCookie := userCookie(3)
+		body := `{"url":"https://receiver.example/hook","events":["link.created"]}`
+		for range webhook.MaxUserEndpoints {
+			response := serve(http.MethodPost, "/api/user/webhooks", body, otherCookie)
+			response.Body.Close()
+			require.Equal(t, http.StatusCreated, response.StatusCode)
+		}
+
+		response := serve(http.MethodPost, "/api/user/webhooks", body, otherCookie)
+		response.Body.Close()
+		assert.Equal(t, http.StatusConflict, response.StatusCode)
+	})
+
+	t.Run("list without secrets", func(t *testing.T) {
+		response := serve(http.MethodGet, "/api/user/webhooks", "", cookie)
+		defer response.Body.Close()
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var endpoints []webhook.Endpoint
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&endpoints))
+		require.Len(t, endpoints, 1)
+		assert.Equal(t, endpoint.ID, endpoints[0].ID)
+		assert.Empty(t, endpoints[0].Secret)
+
+		response = serve(http.MethodGet, "/api/user/webhooks", "", userCookie(2))
+		response.Body.Close()
+		assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("deliveries of shortened and clicked link", func(t *testing.T) {
+		response := serve(http.MethodPost, "/api/shorten", `{"url":"http://example.com/"}`, cookie)
+		var shortenResponse models.ShortenResponse
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&shortenResponse))
+		response.Body.Close()
+		require.Equal(t, http.StatusCreated, response.StatusCode)
+
+		shortURL, err := url.Parse(shortenResponse.Result)
+		require.NoError(t, err)
+		response = serve(http.MethodGet, shortURL.Path, "", nil)
+		response.Body.Close()
+		require.Equal(t, http.StatusTemporaryRedirect, response.StatusCode)
+
+		response = serve(http.MethodGet, "/api/user/webhooks/deliveries", "", cookie)
+		defer response.Body.Close()
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var deliveries []webhook.Delivery
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&deliveries))
+		require.Len(t, deliveries, 2)
+
+		assert.Equal(t, webhook.EventLinkClicked, deliveries[0].Event.Type)
+		assert.Equal(t, webhook.EventLinkCreated, deliveries[1].Event.Type)
+		for _, delivery := range deliveries {
+			assert.Equal(t, endpoint.ID, delivery.EndpointID)
+			assert.Equal(t, webhook.DeliveryPending, delivery.Status)
+			assert.Equal(t, strings.TrimPrefix(shortURL.Path, "/"), delivery.Event.ShortID)
+			assert.Equal(t, "http://example.com/", delivery.Event.OriginalURL)
+		}
+
+		for _, query := range []string{"?limit=0", "?limit=1001", "?limit=abc"} {
+			response := serve(http.MethodGet, "/api/user/webhooks/deliveries"+query, "", cookie)
+			response.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode, query)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		response := serve(http.MethodDelete, "/api/user/webhooks/"+endpoint.ID, "", userCookie(2))
+		response.Body.Close()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		response = serve(http.MethodDelete, "/api/user/webhooks/"+endpoint.ID, "", cookie)
+		response.Body.Close()
+		assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+		response = serve(http.MethodGet, "/api/user/webhooks", "", cookie)
+		response.Body.Close()
+		assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		for _, request := range []struct {
+			method string
+			target string
+		}{
+			{http.MethodPost, "/api/user/webhooks"},
+			{http.MethodGet, "/api/user/webhooks"},
+			{http.MethodGet, "/api/user/webhooks/deliveries"},
+			{http.MethodDelete, "/api/user/webhooks/" + endpoint.ID},
+		} {
+			response := serve(request.method, request.target, "", nil)
+			response.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, response.StatusCode, request.target)
+		}
+	})
+}
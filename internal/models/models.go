@@ -1,8 +1,12 @@
 package models
 
+import "time"
+
 // ShortenRequest содержит запрос на сокращение ссылки
 type ShortenRequest struct {
 	URL string `json:"url"`
+	// Domain хост домена короткой ссылки (пусто - основной домен)
+	Domain string `json:"domain,omitempty"`
 }
 
 // ShortenResponse содержит ответ на запрос сокращения ссылки
@@ -26,12 +30,17 @@ type BatchShortenResponse []BatchShortenResponseEntry
 type BatchShortenResponseEntry struct {
 	CorrelationID string `json:"correlation_id"`
 	ShortURL      string `json:"short_url"`
+	// Conflict ссылка уже была сокращена ранее, ShortURL указывает на существующую сокращенную ссылку
+	Conflict bool `json:"conflict,omitempty"`
 }
 
 // UserShortenedURL содержит информацию о сокращенной ссылке
 type UserShortenedURL struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Domain      string    `json:"domain"`
+	CreatedAt   time.Time `json:"created_at"`
+	Clicks      int64     `json:"clicks"`
 }
 
 // UserShortenedURLs содержит набор сокращенных пользователем ссылок
@@ -44,4 +53,33 @@ type DeleteURLsRequest []string
 type UserDeleteRequest struct {
 	UserID          int
 	ShortIDToDelete string
+	// ClientIP адрес клиента, запросившего удаление (для журнала аудита)
+	ClientIP string
+	// RequestID ID HTTP-запроса на удаление (для журнала аудита)
+	RequestID string
+}
+
+// CreateWebhookRequest содержит запрос на регистрацию вебхука
+type CreateWebhookRequest struct {
+	URL string `json:"url"`
+	// Events типы событий, на которые подписывается вебхук
+	Events []string `json:"events"`
+}
+
+// UserExport содержит выгрузку данных пользователя
+type UserExport struct {
+	UserID     int             `json:"user_id"`
+	ExportedAt time.Time       `json:"exported_at"`
+	URLs       []UserExportURL `json:"urls"`
+}
+
+// UserExportURL содержит информацию о сокращенной пользователем ссылке в выгрузке
+type UserExportURL struct {
+	ShortID     string    `json:"short_id"`
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Domain      string    `json:"domain"`
+	IsDeleted   bool      `json:"is_deleted"`
+	CreatedAt   time.Time `json:"created_at"`
+	Clicks      int64     `json:"clicks"`
 }
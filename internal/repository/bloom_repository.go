@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+
+	"github.com/rovany706/url-shortener/internal/bloom"
+)
+
+// bloomFilterTmpSuffix суффикс временного файла при сохранении фильтра
+const bloomFilterTmpSuffix = ".tmp"
+
+// BloomRepository декоратор репозитория, отвечающий на GetFullURL без обращения к хранилищу,
+// если фильтр Блума сохраненных shortID показывает, что ссылки точно нет.
+//
+// Фильтр заполняется из хранилища при запуске, пополняется при сохранении ссылок
+// через этот репозиторий и периодически перестраивается. Ссылки, сохраненные в то же
+// хранилище другими экземплярами сервиса, становятся видны только после перестроения,
+// поэтому конфигурация не допускает фильтр с хранилищами, общими для нескольких экземпляров.
+type BloomRepository struct {
+	Repository
+	capacity          int
+	falsePositiveRate float64
+	fs                afero.Fs
+	filterPath        string
+	rebuildInterval   time.Duration
+	logger            *zap.Logger
+
+	mutex sync.RWMutex
+	// filter текущий фильтр
+	filter *bloom.Filter
+	// nextFilter перестраиваемый фильтр, в который также добавляются новые shortID
+	nextFilter *bloom.Filter
+	// lastCount количество shortID при последнем перестроении
+	lastCount int
+
+	rebuildMutex sync.Mutex
+	rejected     atomic.Uint64
+	stopRebuild  context.CancelFunc
+	rebuildDone  sync.WaitGroup
+}
+
+// BloomRepositoryOption функциональная опция BloomRepository
+type BloomRepositoryOption func(*BloomRepository)
+
+// WithBloomFilterFile задает файл, в котором фильтр сохраняется при закрытии репозитория.
+// Сохраненный фильтр используется при запуске вместо заполнения из хранилища и сразу
+// удаляется, после чего в фоне перестраивается. Файл есть только после штатного закрытия,
+// поэтому после аварийного завершения фильтр заполняется из хранилища до начала работы.
+func WithBloomFilterFile(fs afero.Fs, path string) BloomRepositoryOption {
+	return func(r *BloomRepository) {
+		r.fs = fs
+		r.filterPath = path
+	}
+}
+
+// WithBloomRebuildInterval задает период перестроения фильтра.
+// Нулевое значение отключает периодическое перестроение.
+func WithBloomRebuildInterval(interval time.Duration) BloomRepositoryOption {
+	return func(r *BloomRepository) {
+		r.rebuildInterval = interval
+	}
+}
+
+// WithBloomLogger задает логгер ошибок фонового перестроения фильтра
+func WithBloomLogger(logger *zap.Logger) BloomRepositoryOption {
+	return func(r *BloomRepository) {
+		r.logger = logger
+	}
+}
+
+// NewBloomRepository создает декоратор repository с фильтром Блума, рассчитанным
+// на capacity ссылок с вероятностью ложноположительного срабатывания falsePositiveRate
+func NewBloomRepository(ctx context.Context, repository Repository, capacity int, falsePositiveRate float64, opts ...BloomRepositoryOption) (*BloomRepository, error) {
+	bloomRepository := &BloomRepository{
+		Repository:        repository,
+		capacity:          capacity,
+		falsePositiveRate: falsePositiveRate,
+		logger:            zap.NewNop(),
+	}
+
+	for _, opt := range opts {
+		opt(bloomRepository)
+	}
+
+	if _, err := bloom.New(capacity, falsePositiveRate); err != nil {
+		return nil, err
+	}
+
+	rebuildCtx, cancel := context.WithCancel(context.Background())
+	bloomRepository.stopRebuild = cancel
+
+	if filter, ok := bloomRepository.loadFilter(); ok {
+		bloomRepository.filter = filter
+		bloomRepository.startRebuild(rebuildCtx, 0)
+	} else if err := bloomRepository.Rebuild(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if bloomRepository.rebuildInterval > 0 {
+		bloomRepository.startRebuild(rebuildCtx, bloomRepository.rebuildInterval)
+	}
+
+	return bloomRepository, nil
+}
+
+// GetFullURL возвращает отсутствие ссылки без обращения к хранилищу, если shortID точно не сохранен
+func (r *BloomRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
+	r.mutex.RLock()
+	filter := r.filter
+	r.mutex.RUnlock()
+
+	if !filter.Test(shortID) {
+		r.rejected.Add(1)
+		return nil, false
+	}
+
+	return r.Repository.GetFullURL(ctx, shortID)
+}
+
+// SaveEntry сохраняет ссылку в хранилище и добавляет shortID в фильтр
+func (r *BloomRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
+	defer r.add(shortID)
+
+	return r.Repository.SaveEntry(ctx, userID, shortID, fullURL)
+}
+
+// SaveEntries сохраняет набор ссылок в хранилище и добавляет их shortID в фильтр
+func (r *BloomRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	defer func() {
+		for shortID := range shortIDMap {
+			r.add(shortID)
+		}
+	}()
+
+	return r.Repository.SaveEntries(ctx, userID, shortIDMap)
+}
+
+// Rebuild заполняет новый фильтр shortID из хранилища и заменяет им текущий,
+// что убирает из фильтра удаленные из хранилища ссылки
+func (r *BloomRepository) Rebuild(ctx context.Context) error {
+	r.rebuildMutex.Lock()
+	defer r.rebuildMutex.Unlock()
+
+	r.mutex.Lock()
+	filter, err := bloom.New(max(r.capacity, r.lastCount*2), r.falsePositiveRate)
+	if err != nil {
+		r.mutex.Unlock()
+		return err
+	}
+	r.nextFilter = filter
+	r.mutex.Unlock()
+
+	count := 0
+	err = r.Repository.ForEachShortID(ctx, func(shortID string) error {
+		filter.Add(shortID)
+		count++
+		return nil
+	})
+
+	r.mutex.Lock()
+	r.nextFilter = nil
+	if err == nil {
+		r.filter = filter
+		r.lastCount = count
+	}
+	r.mutex.Unlock()
+
+	return err
+}
+
+// Rejected возвращает количество запросов, отклоненных фильтром без обращения к хранилищу
+func (r *BloomRepository) Rejected() uint64 {
+	return r.rejected.Load()
+}
+
+// Close останавливает перестроение фильтра, сохраняет его в файл и закрывает хранилище
+func (r *BloomRepository) Close() error {
+	r.stopRebuild()
+	r.rebuildDone.Wait()
+
+	r.rebuildMutex.Lock()
+	defer r.rebuildMutex.Unlock()
+
+	return errors.Join(r.saveFilter(), r.Repository.Close())
+}
+
+func (r *BloomRepository) add(shortID string) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	r.filter.Add(shortID)
+	if r.nextFilter != nil {
+		r.nextFilter.Add(shortID)
+	}
+}
+
+// startRebuild перестраивает фильтр в фоне: однократно при нулевом interval, иначе периодически
+func (r *BloomRepository) startRebuild(ctx context.Context, interval time.Duration) {
+	r.rebuildDone.Add(1)
+
+	go func() {
+		defer r.rebuildDone.Done()
+
+		if interval == 0 {
+			r.rebuild(ctx)
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.rebuild(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// rebuild перестраивает фильтр и пишет ошибку в лог; прежний фильтр при ошибке остается в силе
+func (r *BloomRepository) rebuild(ctx context.Context) {
+	if err := r.Rebuild(ctx); err != nil && ctx.Err() == nil {
+		r.logger.Error("failed to rebuild bloom filter", zap.Error(err))
+	}
+}
+
+// loadFilter читает фильтр, сохраненный при штатном закрытии, и удаляет файл,
+// чтобы после аварийного завершения он не был использован повторно
+func (r *BloomRepository) loadFilter() (*bloom.Filter, bool) {
+	if r.filterPath == "" {
+		return nil, false
+	}
+
+	file, err := r.fs.Open(r.filterPath)
+	if err != nil {
+		return nil, false
+	}
+
+	filter, err := bloom.Read(file)
+	file.Close()
+	if err != nil {
+		return nil, false
+	}
+
+	if err = r.fs.Remove(r.filterPath); err != nil {
+		return nil, false
+	}
+
+	return filter, true
+}
+
+// saveFilter атомарно сохраняет текущий фильтр в файл, если он задан.
+// Вызывается при закрытии под rebuildMutex.
+func (r *BloomRepository) saveFilter() error {
+	if r.filterPath == "" {
+		return nil
+	}
+
+	r.mutex.RLock()
+	filter := r.filter
+	r.mutex.RUnlock()
+
+	tmpPath := r.filterPath + bloomFilterTmpSuffix
+	file, err := r.fs.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = filter.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return r.fs.Rename(tmpPath, r.filterPath)
+}
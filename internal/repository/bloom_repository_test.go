@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBloomRepositoryGetFullURL(t *testing.T) {
+	ctx := context.Background()
+	memoryRepository := NewMemoryRepository()
+	require.NoError(t, memoryRepository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+
+	repository, err := NewBloomRepository(ctx, memoryRepository, 100, 0.01)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	_, ok := repository.GetFullURL(ctx, "1")
+	assert.True(t, ok)
+
+	_, ok = repository.GetFullURL(ctx, "2")
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), repository.Rejected())
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "2", "http://example.com/2"))
+	_, err = repository.SaveEntries(ctx, 1, URLMapping{"3": "http://example.com/3"})
+	require.NoError(t, err)
+
+	_, ok = repository.GetFullURL(ctx, "2")
+	assert.True(t, ok)
+	_, ok = repository.GetFullURL(ctx, "3")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), repository.Rejected())
+}
+
+func TestBloomRepositoryRebuild(t *testing.T) {
+	ctx := context.Background()
+	memoryRepository := NewMemoryRepository()
+
+	repository, err := NewBloomRepository(ctx, memoryRepository, 100, 0.01)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	// ссылки, сохраненные в обход декоратора, появляются в фильтре после перестроения
+	require.NoError(t, memoryRepository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+	_, ok := repository.GetFullURL(ctx, "1")
+	assert.False(t, ok)
+
+	require.NoError(t, repository.Rebuild(ctx))
+	_, ok = repository.GetFullURL(ctx, "1")
+	assert.True(t, ok)
+}
+
+func TestBloomRepositoryPersistence(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	const filterPath = "bloom.bin"
+
+	memoryRepository := NewMemoryRepository()
+	require.NoError(t, memoryRepository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+
+	repository, err := NewBloomRepository(ctx, memoryRepository, 100, 0.01, WithBloomFilterFile(fs, filterPath))
+	require.NoError(t, err)
+	require.NoError(t, repository.SaveEntry(ctx, 1, "2", "http://example.com/2"))
+	require.NoError(t, repository.Close())
+
+	exists, err := afero.Exists(fs, filterPath)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// сохраненный фильтр используется без обхода хранилища,
+	// неудачное фоновое перестроение оставляет его в силе
+	core, logs := observer.New(zap.ErrorLevel)
+	storage := &unavailableScanRepository{MemoryRepository: memoryRepository, scanned: make(chan struct{})}
+	repository, err = NewBloomRepository(ctx, storage, 100, 0.01, WithBloomFilterFile(fs, filterPath), WithBloomLogger(zap.New(core)))
+	require.NoError(t, err)
+	<-storage.scanned
+
+	// загруженный файл удален, чтобы не использоваться после аварийного завершения
+	exists, err = afero.Exists(fs, filterPath)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, ok := repository.GetFullURL(ctx, "2")
+	assert.True(t, ok)
+	_, ok = repository.GetFullURL(ctx, "3")
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), repository.Rejected())
+
+	require.NoError(t, repository.Close())
+	assert.Equal(t, 1, logs.FilterMessage("failed to rebuild bloom filter").Len())
+}
+
+func TestBloomRepositoryCrashRecovery(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	const filterPath = "bloom.bin"
+
+	memoryRepository := NewMemoryRepository()
+	repository, err := NewBloomRepository(ctx, memoryRepository, 100, 0.01, WithBloomFilterFile(fs, filterPath))
+	require.NoError(t, err)
+	require.NoError(t, repository.Close())
+
+	// после загрузки сохраненного фильтра экземпляр завершается без Close,
+	// а хранилище пополняется в обход фильтра
+	_, err = NewBloomRepository(ctx, memoryRepository, 100, 0.01, WithBloomFilterFile(fs, filterPath))
+	require.NoError(t, err)
+	require.NoError(t, memoryRepository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+
+	// без файла фильтр заполняется из хранилища до начала работы
+	repository, err = NewBloomRepository(ctx, memoryRepository, 100, 0.01, WithBloomFilterFile(fs, filterPath))
+	require.NoError(t, err)
+	defer repository.Close()
+
+	_, ok := repository.GetFullURL(ctx, "1")
+	assert.True(t, ok)
+
+	storage := &unavailableScanRepository{MemoryRepository: memoryRepository, scanned: make(chan struct{})}
+	_, err = NewBloomRepository(ctx, storage, 100, 0.01, WithBloomFilterFile(fs, filterPath))
+	assert.Error(t, err)
+}
+
+// unavailableScanRepository репозиторий, обход которого завершается ошибкой
+type unavailableScanRepository struct {
+	*MemoryRepository
+	scanned chan struct{}
+}
+
+func (r *unavailableScanRepository) ForEachShortID(ctx context.Context, fn func(shortID string) error) error {
+	close(r.scanned)
+	return errors.New("storage is unavailable")
+}
+
+func TestBloomRepositoryInvalidParameters(t *testing.T) {
+	_, err := NewBloomRepository(context.Background(), NewMemoryRepository(), 0, 0.01)
+	assert.Error(t, err)
+}
@@ -0,0 +1,536 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// Бакеты хранилища bbolt
+var (
+	// linksBucket shortID -> информация о ссылке
+	linksBucket = []byte("links")
+	// urlsBucket полная ссылка в пространстве имен домена (см. domainURLKey) -> shortID
+	urlsBucket = []byte("urls")
+	// usersBucket ID пользователя -> пустое значение, последовательность бакета выдает новые ID
+	usersBucket = []byte("users")
+	// userLinksBucket ID пользователя -> вложенный бакет с набором shortID пользователя
+	userLinksBucket = []byte("user_links")
+	// metaBucket служебные значения хранилища
+	metaBucket = []byte("meta")
+	// deletedUsersBucket ID удаленного пользователя -> пустое значение
+	deletedUsersBucket = []byte("deleted_users")
+)
+
+// boltURLKeysVersionKey ключ версии ключей urlsBucket в metaBucket.
+// Отсутствует в хранилищах, где ключом ссылок всех доменов была полная ссылка без домена.
+var boltURLKeysVersionKey = []byte("url_keys_version")
+
+// boltURLKeysVersion версия ключей urlsBucket с доменом ссылки
+const boltURLKeysVersion = 1
+
+const boltOpenTimeout = time.Second
+
+// boltLink значение бакета linksBucket
+type boltLink struct {
+	UserID    int       `json:"user_id"`
+	FullURL   string    `json:"full_url"`
+	IsDeleted bool      `json:"is_deleted,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	Clicks    int64     `json:"clicks,omitempty"`
+}
+
+// info возвращает информацию о ссылке shortID
+func (link boltLink) info(shortID string) ShortenedURLInfo {
+	return ShortenedURLInfo{
+		UserID:    link.UserID,
+		ShortID:   shortID,
+		FullURL:   link.FullURL,
+		IsDeleted: link.IsDeleted,
+		CreatedAt: link.CreatedAt,
+		Clicks:    link.Clicks,
+	}
+}
+
+// BoltRepository репозиторий, хранящий информацию во встроенной key-value базе данных bbolt.
+// Данные читаются с диска по запросу, поэтому запуск не требует чтения всего хранилища,
+// а потребление памяти не зависит от количества ссылок.
+type BoltRepository struct {
+	db *bolt.DB
+}
+
+// NewBoltRepository открывает файл базы данных bbolt по пути path
+func NewBoltRepository(ctx context.Context, path string) (*BoltRepository, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{linksBucket, urlsBucket, usersBucket, userLinksBucket, metaBucket, deletedUsersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return upgradeBoltURLKeys(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltRepository{db: db}, nil
+}
+
+// GetFullURL ищет в хранилище полную ссылку на ресурс по короткому ID
+func (repository *BoltRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
+	err := repository.db.View(func(tx *bolt.Tx) error {
+		link, found, err := getBoltLink(tx, shortID)
+		if err != nil || !found {
+			return err
+		}
+
+		info := link.info(shortID)
+		shortenedURLInfo = &info
+		return nil
+	})
+
+	if err != nil || shortenedURLInfo == nil {
+		return nil, false
+	}
+
+	return shortenedURLInfo, true
+}
+
+// SaveEntry сохраняет в хранилище информацию о сокращенной ссылке.
+// Возвращает ErrConflict, если ссылка или короткий ID уже сохранены.
+func (repository *BoltRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
+	createdAt := time.Now().UTC()
+	return repository.db.Update(func(tx *bolt.Tx) error {
+		saved, err := putBoltLink(tx, shortID, boltLink{UserID: userID, FullURL: fullURL, CreatedAt: createdAt})
+		if err != nil {
+			return err
+		}
+
+		if !saved {
+			return ErrConflict
+		}
+
+		return nil
+	})
+}
+
+// SaveEntries атомарно записывает набор сокращенных ссылок.
+// Уже сохраненные ссылки пропускаются и возвращаются как конфликтующие.
+func (repository *BoltRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	createdAt := time.Now().UTC()
+	err = repository.db.Update(func(tx *bolt.Tx) error {
+		conflicts = make(URLMapping)
+		for shortID, fullURL := range shortIDMap {
+			saved, err := putBoltLink(tx, shortID, boltLink{UserID: userID, FullURL: fullURL, CreatedAt: createdAt})
+			if err != nil {
+				return err
+			}
+
+			if !saved {
+				conflicts[shortID] = fullURL
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+// GetShortID возвращает shortID сокращенной ссылки в пространстве имен домена.
+// Возвращает ErrNotFound, если ссылка не сохранена.
+func (repository *BoltRepository) GetShortID(ctx context.Context, domain string, fullURL string) (shortID string, err error) {
+	err = repository.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(urlsBucket).Get([]byte(domainURLKey(domain, fullURL)))
+		if value == nil {
+			return ErrNotFound
+		}
+
+		shortID = string(value)
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return shortID, nil
+}
+
+// GetUserEntries возвращает сокращенный пользователем ссылки по userID
+func (repository *BoltRepository) GetUserEntries(ctx context.Context, userID int) (shortIDMap URLMapping, err error) {
+	shortIDMap = make(URLMapping)
+
+	err = repository.db.View(func(tx *bolt.Tx) error {
+		userLinks := tx.Bucket(userLinksBucket).Bucket(boltUserKey(userID))
+		if userLinks == nil {
+			return nil
+		}
+
+		return userLinks.ForEach(func(key, _ []byte) error {
+			link, found, err := getBoltLink(tx, string(key))
+			if err != nil || !found {
+				return err
+			}
+
+			shortIDMap[string(key)] = link.FullURL
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return shortIDMap, nil
+}
+
+// ListUserEntries возвращает ссылки пользователя в порядке и с ограничениями query.
+// Ссылки пользователя читаются целиком и упорядочиваются в памяти.
+func (repository *BoltRepository) ListUserEntries(ctx context.Context, userID int, query UserEntriesQuery) (entries []ShortenedURLInfo, err error) {
+	entries = make([]ShortenedURLInfo, 0)
+
+	err = repository.db.View(func(tx *bolt.Tx) error {
+		userLinks := tx.Bucket(userLinksBucket).Bucket(boltUserKey(userID))
+		if userLinks == nil {
+			return nil
+		}
+
+		return userLinks.ForEach(func(key, _ []byte) error {
+			link, found, err := getBoltLink(tx, string(key))
+			if err != nil || !found {
+				return err
+			}
+
+			entries = append(entries, link.info(string(key)))
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return query.page(entries), nil
+}
+
+// AddClicks атомарно увеличивает счетчики переходов по ссылкам
+func (repository *BoltRepository) AddClicks(ctx context.Context, clicks map[string]int64) error {
+	return repository.db.Update(func(tx *bolt.Tx) error {
+		for shortID, count := range clicks {
+			link, found, err := getBoltLink(tx, shortID)
+			if err != nil {
+				return err
+			}
+
+			if !found {
+				continue
+			}
+
+			link.Clicks += count
+			if err = saveBoltLink(tx, shortID, link); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ForEachShortID вызывает fn для shortID каждой сохраненной ссылки.
+// fn вызывается внутри транзакции чтения и не должна изменять хранилище.
+func (repository *BoltRepository) ForEachShortID(ctx context.Context, fn func(shortID string) error) error {
+	return repository.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(linksBucket).ForEach(func(key, _ []byte) error {
+			return fn(string(key))
+		})
+	})
+}
+
+// GetNewUserID возвращает ID нового пользователя
+func (repository *BoltRepository) GetNewUserID(ctx context.Context) (userID int, err error) {
+	err = repository.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+
+		id, err := users.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		userID = int(id)
+		return users.Put(boltUserKey(userID), nil)
+	})
+
+	if err != nil {
+		return -1, err
+	}
+
+	return userID, nil
+}
+
+// DeleteUserURLs атомарно помечает удаленными ссылки, принадлежащие пользователям из запросов
+func (repository *BoltRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
+	return repository.db.Update(func(tx *bolt.Tx) error {
+		for _, request := range deleteRequests {
+			if err := markBoltLinkDeleted(tx, request.UserID, request.ShortIDToDelete); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ForEachEntry вызывает fn для каждой ссылки с shortID больше afterShortID в порядке возрастания shortID.
+// fn вызывается внутри транзакции чтения и не должна изменять хранилище.
+func (repository *BoltRepository) ForEachEntry(ctx context.Context, afterShortID string, fn func(info ShortenedURLInfo) error) error {
+	return repository.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(linksBucket).Cursor()
+
+		key, value := cursor.Seek([]byte(afterShortID))
+		if key != nil && string(key) == afterShortID {
+			key, value = cursor.Next()
+		}
+
+		for ; key != nil; key, value = cursor.Next() {
+			var link boltLink
+			if err := json.Unmarshal(value, &link); err != nil {
+				return err
+			}
+
+			if err := fn(link.info(string(key))); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteUser удаляет пользователя из бакета пользователей и сохраняет пометку его удаления
+func (repository *BoltRepository) DeleteUser(ctx context.Context, userID int) error {
+	return repository.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(usersBucket).Delete(boltUserKey(userID)); err != nil {
+			return err
+		}
+
+		return tx.Bucket(deletedUsersBucket).Put(boltUserKey(userID), nil)
+	})
+}
+
+// DeletedUsers возвращает ID удаленных пользователей в порядке возрастания
+func (repository *BoltRepository) DeletedUsers(ctx context.Context) (userIDs []int, err error) {
+	userIDs = make([]int, 0)
+	err = repository.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deletedUsersBucket).ForEach(func(key, _ []byte) error {
+			userIDs = append(userIDs, int(binary.BigEndian.Uint64(key)))
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// LastUserID возвращает наибольший выданный ID пользователя
+func (repository *BoltRepository) LastUserID(ctx context.Context) (userID int, err error) {
+	err = repository.db.View(func(tx *bolt.Tx) error {
+		userID = int(tx.Bucket(usersBucket).Sequence())
+		return nil
+	})
+
+	return userID, err
+}
+
+// ImportEntries атомарно сохраняет ссылки с их владельцами и пометками удаления
+func (repository *BoltRepository) ImportEntries(ctx context.Context, entries []ShortenedURLInfo) error {
+	return repository.db.Update(func(tx *bolt.Tx) error {
+		for _, info := range entries {
+			link := boltLink{UserID: info.UserID, FullURL: info.FullURL, CreatedAt: info.CreatedAt, Clicks: info.Clicks}
+			if _, err := putBoltLink(tx, info.ShortID, link); err != nil {
+				return err
+			}
+
+			if !info.IsDeleted {
+				continue
+			}
+
+			if err := markBoltLinkDeleted(tx, info.UserID, info.ShortID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ReserveUserIDs гарантирует, что новые ID пользователей будут больше lastUserID
+func (repository *BoltRepository) ReserveUserIDs(ctx context.Context, lastUserID int) error {
+	return repository.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		if lastUserID <= 0 || uint64(lastUserID) <= users.Sequence() {
+			return nil
+		}
+
+		return users.SetSequence(uint64(lastUserID))
+	})
+}
+
+// Ping проверяет доступность базы данных
+func (repository *BoltRepository) Ping(ctx context.Context) error {
+	return repository.db.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
+// Close закрывает базу данных
+func (repository *BoltRepository) Close() error {
+	return repository.db.Close()
+}
+
+// putBoltLink сохраняет ссылку без пометки удаления во всех бакетах,
+// если ни shortID, ни полная ссылка в домене ссылки еще не заняты
+func putBoltLink(tx *bolt.Tx, shortID string, link boltLink) (saved bool, err error) {
+	links := tx.Bucket(linksBucket)
+	urls := tx.Bucket(urlsBucket)
+	urlKey := []byte(linkURLKey(shortID, link.FullURL))
+
+	if links.Get([]byte(shortID)) != nil || urls.Get(urlKey) != nil {
+		return false, nil
+	}
+
+	link.IsDeleted = false
+	if err = saveBoltLink(tx, shortID, link); err != nil {
+		return false, err
+	}
+
+	if err = urls.Put(urlKey, []byte(shortID)); err != nil {
+		return false, err
+	}
+
+	userLinks, err := tx.Bucket(userLinksBucket).CreateBucketIfNotExists(boltUserKey(link.UserID))
+	if err != nil {
+		return false, err
+	}
+
+	if err = userLinks.Put([]byte(shortID), nil); err != nil {
+		return false, err
+	}
+
+	if err = ensureBoltUser(tx, link.UserID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// upgradeBoltURLKeys переносит ссылки дополнительных доменов в urlsBucket под ключи с доменом ссылки
+func upgradeBoltURLKeys(tx *bolt.Tx) error {
+	meta := tx.Bucket(metaBucket)
+	if meta.Get(boltURLKeysVersionKey) != nil {
+		return nil
+	}
+
+	urls := tx.Bucket(urlsBucket)
+	moved := make(map[string][]byte)
+	err := urls.ForEach(func(key, value []byte) error {
+		if linkDomain(string(value)) != "" {
+			moved[string(key)] = bytes.Clone(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for fullURL, shortID := range moved {
+		if err = urls.Delete([]byte(fullURL)); err != nil {
+			return err
+		}
+		if err = urls.Put([]byte(linkURLKey(string(shortID), fullURL)), shortID); err != nil {
+			return err
+		}
+	}
+
+	return meta.Put(boltURLKeysVersionKey, []byte(strconv.Itoa(boltURLKeysVersion)))
+}
+
+// ensureBoltUser сохраняет пользователя и сдвигает последовательность ID,
+// чтобы новые пользователи не получили уже использованный ID
+func ensureBoltUser(tx *bolt.Tx, userID int) error {
+	users := tx.Bucket(usersBucket)
+
+	if userID > 0 && uint64(userID) > users.Sequence() {
+		if err := users.SetSequence(uint64(userID)); err != nil {
+			return err
+		}
+	}
+
+	return users.Put(boltUserKey(userID), nil)
+}
+
+// markBoltLinkDeleted помечает удаленной ссылку, если она принадлежит пользователю userID
+func markBoltLinkDeleted(tx *bolt.Tx, userID int, shortID string) error {
+	link, found, err := getBoltLink(tx, shortID)
+	if err != nil {
+		return err
+	}
+
+	if !found || link.UserID != userID || link.IsDeleted {
+		return nil
+	}
+
+	link.IsDeleted = true
+
+	return saveBoltLink(tx, shortID, link)
+}
+
+func getBoltLink(tx *bolt.Tx, shortID string) (link boltLink, found bool, err error) {
+	value := tx.Bucket(linksBucket).Get([]byte(shortID))
+	if value == nil {
+		return boltLink{}, false, nil
+	}
+
+	if err = json.Unmarshal(value, &link); err != nil {
+		return boltLink{}, false, err
+	}
+
+	return link, true, nil
+}
+
+func saveBoltLink(tx *bolt.Tx, shortID string, link boltLink) error {
+	value, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(linksBucket).Put([]byte(shortID), value)
+}
+
+// boltUserKey возвращает ключ пользователя; big-endian сохраняет порядок ID при обходе бакета
+func boltUserKey(userID int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(userID))
+
+	return key
+}
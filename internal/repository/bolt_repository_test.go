@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestBoltRepository(t *testing.T) (*BoltRepository, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "shortener.bolt")
+	repository, err := NewBoltRepository(context.Background(), path)
+	require.NoError(t, err)
+	t.Cleanup(func() { repository.Close() })
+
+	return repository, path
+}
+
+func TestBoltRepositoryPersistence(t *testing.T) {
+	ctx := context.Background()
+	repository, path := newTestBoltRepository(t)
+
+	userID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, userID)
+
+	require.NoError(t, repository.SaveEntry(ctx, 5, "1", "http://example.com/1"))
+	require.NoError(t, repository.Ping(ctx))
+	require.NoError(t, repository.Close())
+
+	repository, err = NewBoltRepository(ctx, path)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	info, ok := repository.GetFullURL(ctx, "1")
+	require.True(t, ok)
+	assert.Equal(t, 5, info.UserID)
+
+	// новый ID не совпадает с ID пользователя уже сохраненных ссылок
+	userID, err = repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 6, userID)
+}
+
+func TestBoltRepositoryUpgradeURLKeys(t *testing.T) {
+	ctx := context.Background()
+	repository, path := newTestBoltRepository(t)
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "go.brand.com/1", "http://example.com/1"))
+
+	// хранилище, в котором ключом ссылки дополнительного домена была полная ссылка
+	err := repository.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(urlsBucket)
+		if err := urls.Delete([]byte(linkURLKey("go.brand.com/1", "http://example.com/1"))); err != nil {
+			return err
+		}
+		if err := urls.Put([]byte("http://example.com/1"), []byte("go.brand.com/1")); err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Delete(boltURLKeysVersionKey)
+	})
+	require.NoError(t, err)
+	require.NoError(t, repository.Close())
+
+	repository, err = NewBoltRepository(ctx, path)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	shortID, err := repository.GetShortID(ctx, "go.brand.com", "http://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, "go.brand.com/1", shortID)
+
+	_, err = repository.GetShortID(ctx, "", "http://example.com/1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, repository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// CacheStats статистика кэша CachedRepository
+type CacheStats struct {
+	// Hits количество запросов, обслуженных из кэша
+	Hits uint64
+	// Misses количество запросов, переданных в хранилище
+	Misses uint64
+	// Size текущее количество записей в кэше
+	Size int
+}
+
+// CachedRepository декоратор репозитория, кэширующий результаты GetFullURL,
+// в том числе отсутствие ссылки в хранилище. Записи кэша инвалидируются при сохранении
+// и удалении ссылок через этот репозиторий и по истечении времени жизни.
+// Увеличение счетчиков переходов (AddClicks) кэш не инвалидирует, поэтому счетчик
+// в результате GetFullURL может отставать: актуальные счетчики возвращает ListUserEntries.
+type CachedRepository struct {
+	Repository
+	cache       *lruCache
+	ttl         time.Duration
+	negativeTTL time.Duration
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+}
+
+// CachedRepositoryOption функциональная опция CachedRepository
+type CachedRepositoryOption func(*CachedRepository)
+
+// WithCacheTTL задает время жизни найденных ссылок в кэше.
+// Нулевое значение хранит ссылки до вытеснения или инвалидации.
+func WithCacheTTL(ttl time.Duration) CachedRepositoryOption {
+	return func(r *CachedRepository) {
+		r.ttl = ttl
+	}
+}
+
+// WithNegativeCacheTTL задает время жизни в кэше отсутствующих в хранилище ссылок.
+// Нулевое значение отключает негативное кэширование.
+func WithNegativeCacheTTL(ttl time.Duration) CachedRepositoryOption {
+	return func(r *CachedRepository) {
+		r.negativeTTL = ttl
+	}
+}
+
+// NewCachedRepository создает декоратор repository с кэшем на size записей
+func NewCachedRepository(repository Repository, size int, opts ...CachedRepositoryOption) *CachedRepository {
+	cachedRepository := &CachedRepository{
+		Repository: repository,
+		cache:      newLRUCache(size),
+	}
+
+	for _, opt := range opts {
+		opt(cachedRepository)
+	}
+
+	return cachedRepository
+}
+
+// GetFullURL ищет ссылку в кэше, а при промахе - в хранилище, и кэширует результат
+func (r *CachedRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
+	if info, found := r.cache.get(shortID); found {
+		r.hits.Add(1)
+		if info == nil {
+			return nil, false
+		}
+
+		infoCopy := *info
+		return &infoCopy, true
+	}
+
+	r.misses.Add(1)
+
+	version := r.cache.currentVersion()
+	shortenedURLInfo, ok = r.Repository.GetFullURL(ctx, shortID)
+	if ok {
+		infoCopy := *shortenedURLInfo
+		r.cache.set(shortID, &infoCopy, r.ttl, version)
+	} else if r.negativeTTL > 0 && ctx.Err() == nil {
+		r.cache.set(shortID, nil, r.negativeTTL, version)
+	}
+
+	return shortenedURLInfo, ok
+}
+
+// SaveEntry сохраняет ссылку в хранилище и удаляет ее shortID из кэша
+func (r *CachedRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
+	defer r.cache.invalidate(shortID)
+
+	return r.Repository.SaveEntry(ctx, userID, shortID, fullURL)
+}
+
+// SaveEntries сохраняет набор ссылок в хранилище и удаляет их shortID из кэша
+func (r *CachedRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	shortIDs := make([]string, 0, len(shortIDMap))
+	for shortID := range shortIDMap {
+		shortIDs = append(shortIDs, shortID)
+	}
+	defer r.cache.invalidate(shortIDs...)
+
+	return r.Repository.SaveEntries(ctx, userID, shortIDMap)
+}
+
+// DeleteUserURLs удаляет ссылки в хранилище и из кэша
+func (r *CachedRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
+	shortIDs := make([]string, len(deleteRequests))
+	for i, request := range deleteRequests {
+		shortIDs[i] = request.ShortIDToDelete
+	}
+	defer r.cache.invalidate(shortIDs...)
+
+	return r.Repository.DeleteUserURLs(ctx, deleteRequests)
+}
+
+// Stats возвращает статистику кэша
+func (r *CachedRepository) Stats() CacheStats {
+	return CacheStats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+		Size:   r.cache.len(),
+	}
+}
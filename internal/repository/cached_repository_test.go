@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+func TestCachedRepositoryGetFullURL(t *testing.T) {
+	ctx := context.Background()
+	memoryRepository := NewMemoryRepository()
+	require.NoError(t, memoryRepository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+
+	repository := NewCachedRepository(memoryRepository, 10, WithNegativeCacheTTL(time.Minute))
+
+	for i := 0; i < 2; i++ {
+		info, ok := repository.GetFullURL(ctx, "1")
+		require.True(t, ok)
+		assert.Equal(t, "http://example.com/1", info.FullURL)
+
+		_, ok = repository.GetFullURL(ctx, "2")
+		assert.False(t, ok)
+	}
+
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2, Size: 2}, repository.Stats())
+
+	// изменения в обход декоратора не видны до инвалидации записей
+	require.NoError(t, memoryRepository.SaveEntry(ctx, 1, "2", "http://example.com/2"))
+	_, ok := repository.GetFullURL(ctx, "2")
+	assert.False(t, ok)
+}
+
+func TestCachedRepositoryWithoutNegativeCache(t *testing.T) {
+	ctx := context.Background()
+	repository := NewCachedRepository(NewMemoryRepository(), 10)
+
+	_, ok := repository.GetFullURL(ctx, "1")
+	assert.False(t, ok)
+
+	assert.Equal(t, CacheStats{Misses: 1}, repository.Stats())
+}
+
+func TestCachedRepositoryInvalidation(t *testing.T) {
+	ctx := context.Background()
+	repository := NewCachedRepository(NewMemoryRepository(), 10, WithNegativeCacheTTL(time.Minute))
+
+	_, ok := repository.GetFullURL(ctx, "1")
+	require.False(t, ok)
+	_, ok = repository.GetFullURL(ctx, "2")
+	require.False(t, ok)
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+	_, err := repository.SaveEntries(ctx, 1, URLMapping{"2": "http://example.com/2"})
+	require.NoError(t, err)
+
+	info, ok := repository.GetFullURL(ctx, "1")
+	require.True(t, ok)
+	assert.False(t, info.IsDeleted)
+
+	_, ok = repository.GetFullURL(ctx, "2")
+	require.True(t, ok)
+
+	err = repository.DeleteUserURLs(ctx, []models.UserDeleteRequest{{UserID: 1, ShortIDToDelete: "1"}})
+	require.NoError(t, err)
+
+	info, ok = repository.GetFullURL(ctx, "1")
+	require.True(t, ok)
+	assert.True(t, info.IsDeleted)
+}
+
+func TestCachedRepositoryReturnsCopy(t *testing.T) {
+	ctx := context.Background()
+	memoryRepository := NewMemoryRepository()
+	require.NoError(t, memoryRepository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+	repository := NewCachedRepository(memoryRepository, 10)
+
+	info, ok := repository.GetFullURL(ctx, "1")
+	require.True(t, ok)
+	info.FullURL = "http://example.com/changed"
+
+	info, ok = repository.GetFullURL(ctx, "1")
+	require.True(t, ok)
+	assert.Equal(t, "http://example.com/1", info.FullURL)
+}
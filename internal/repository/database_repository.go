@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/rovany706/url-shortener/internal/database"
 	"github.com/rovany706/url-shortener/internal/models"
@@ -16,56 +20,231 @@ var (
 	insertEntrySQL = fmt.Sprintf(
 		`INSERT INTO %s (short_id, full_url, user_id, is_deleted)
 		VALUES ($1, $2, $3, false)`, database.ShortLinksTableName)
-	insertEntrySQLBatch = fmt.Sprintf(
+	insertEntriesUnnestSQL = fmt.Sprintf(
 		`INSERT INTO %s (short_id, full_url, user_id, is_deleted)
-			VALUES ($1, $2, $3, false)
-			ON CONFLICT (full_url) DO NOTHING`, database.ShortLinksTableName)
+			SELECT short_id, full_url, $3, false FROM unnest($1::text[], $2::text[]) AS batch(short_id, full_url)
+			ON CONFLICT DO NOTHING
+			RETURNING short_id`, database.ShortLinksTableName)
+	createBatchTableSQL = fmt.Sprintf(
+		`CREATE TEMP TABLE %s (short_id text, full_url text) ON COMMIT DROP`, batchTableName)
+	insertEntriesFromBatchTableSQL = fmt.Sprintf(
+		`INSERT INTO %s (short_id, full_url, user_id, is_deleted)
+			SELECT short_id, full_url, $1, false FROM %s
+			ON CONFLICT DO NOTHING
+			RETURNING short_id`, database.ShortLinksTableName, batchTableName)
 	selectFullURLSQL = fmt.Sprintf(
-		`SELECT user_id, short_id, full_url, is_deleted FROM %s
+		`SELECT user_id, short_id, full_url, is_deleted, created_at, clicks FROM %s
 		WHERE short_id = $1`, database.ShortLinksTableName)
 	selectShortIDSQL = fmt.Sprintf(
 		`SELECT short_id FROM %s
-		WHERE full_url = $1`, database.ShortLinksTableName)
+		WHERE domain = $1 AND full_url = $2`, database.ShortLinksTableName)
 	selectUserURLs = fmt.Sprintf(
 		`SELECT short_id, full_url FROM %s
 		WHERE user_id = $1`, database.ShortLinksTableName)
+	selectShortIDsSQL = fmt.Sprintf(
+		`SELECT short_id FROM %s`, database.ShortLinksTableName)
+	selectEntriesAfterSQL = fmt.Sprintf(
+		`SELECT user_id, short_id, full_url, is_deleted, created_at, clicks FROM %s
+		WHERE short_id COLLATE "C" > $1
+		ORDER BY short_id COLLATE "C"`, database.ShortLinksTableName)
+	importUsersSQL = fmt.Sprintf(
+		`INSERT INTO %s (id) OVERRIDING SYSTEM VALUE
+			SELECT DISTINCT unnest($1::int[])
+			ON CONFLICT DO NOTHING`, database.UsersTableName)
+	importEntriesSQL = fmt.Sprintf(
+		`INSERT INTO %[1]s (short_id, full_url, user_id, is_deleted, created_at, clicks)
+			SELECT batch.short_id, batch.full_url, batch.user_id, batch.is_deleted, COALESCE(batch.created_at, now()), batch.clicks
+			FROM unnest($1::text[], $2::text[], $3::int[], $4::bool[], $5::timestamptz[], $6::bigint[])
+				AS batch(short_id, full_url, user_id, is_deleted, created_at, clicks)
+			WHERE NOT EXISTS (SELECT 1 FROM %[1]s WHERE %[1]s.short_id = batch.short_id)
+			ON CONFLICT DO NOTHING`, database.ShortLinksTableName)
+	selectLastUserIDSQL = fmt.Sprintf(
+		`SELECT COALESCE(MAX(id), 0) FROM %s`, database.UsersTableName)
+	// reserveUserIDsSQL сдвигает последовательность ID пользователей за наибольший
+	// из сохраненных и переданного ID, так как явно заданные ID ее не сдвигают
+	reserveUserIDsSQL = fmt.Sprintf(
+		`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), GREATEST(last_id, $1, 1), GREATEST(last_id, $1) > 0)
+			FROM (SELECT COALESCE(MAX(id), 0) AS last_id FROM %[1]s) AS users`, database.UsersTableName)
+	purgeEntriesSQL = fmt.Sprintf(
+		`DELETE FROM %s WHERE short_id = ANY($1::text[])`, database.ShortLinksTableName)
 	insertNewUserSQL = fmt.Sprintf(
 		`INSERT INTO %s DEFAULT VALUES RETURNING id;`,
 		database.UsersTableName)
-	deleteShortLinkSQL = fmt.Sprintf(
+	deleteUserSQL = fmt.Sprintf(
+		`UPDATE %s SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`, database.UsersTableName)
+	selectDeletedUsersSQL = fmt.Sprintf(
+		`SELECT id FROM %s WHERE deleted_at IS NOT NULL ORDER BY id`, database.UsersTableName)
+	deleteShortLinksSQL = fmt.Sprintf(
 		`UPDATE %s
 		SET is_deleted = true
-		WHERE short_id = $1 AND user_id = $2`,
+		WHERE (short_id, user_id) IN (SELECT * FROM unnest($1::text[], $2::int[]))`,
+		database.ShortLinksTableName)
+	addClicksSQL = fmt.Sprintf(
+		`UPDATE %[1]s
+		SET clicks = %[1]s.clicks + batch.clicks
+		FROM unnest($1::text[], $2::bigint[]) AS batch(short_id, clicks)
+		WHERE %[1]s.short_id = batch.short_id`,
 		database.ShortLinksTableName)
 )
 
-// DatabaseRepository репозиторий, использующий БД
+// listUserEntriesSQL возвращает запрос выборки ссылок пользователя для query.
+// Аргументы запроса: userID, ограничение количества (NULL - без ограничения)
+// и позиция query.After, если она задана.
+func listUserEntriesSQL(query UserEntriesQuery) string {
+	sortColumn, direction, operator := "created_at", "ASC", ">"
+	if query.sortBy() == SortByClicks {
+		sortColumn = "clicks"
+	}
+	if query.Descending {
+		direction, operator = "DESC", "<"
+	}
+
+	var afterCondition string
+	if query.After != nil {
+		afterCondition = fmt.Sprintf(`AND (%s, short_id COLLATE "C") %s ($3, $4)`, sortColumn, operator)
+	}
+
+	return fmt.Sprintf(
+		`SELECT user_id, short_id, full_url, is_deleted, created_at, clicks FROM %s
+		WHERE user_id = $1 %s
+		ORDER BY %[3]s %[4]s, short_id COLLATE "C" %[4]s
+		LIMIT $2`, database.ShortLinksTableName, afterCondition, sortColumn, direction)
+}
+
+// batchTableName имя временной таблицы для загрузки больших наборов ссылок через COPY
+const batchTableName = "short_links_batch"
+
+// copyBatchThreshold размер набора ссылок, начиная с которого используется COPY
+const copyBatchThreshold = 1000
+
+// defaultReplicaHealthCheckInterval период проверки доступности реплик по умолчанию
+const defaultReplicaHealthCheckInterval = time.Second * 5
+
+// DatabaseRepository репозиторий, использующий БД.
+//
+// При заданных репликах запросы чтения распределяются между доступными репликами по кругу,
+// а запись выполняется на основной БД. Чтение ключей, измененных в течение окна
+// read-your-writes, выполняется на основной БД, чтобы не зависеть от задержки репликации.
 type DatabaseRepository struct {
-	db *database.Database
+	pool           *pgxpool.Pool
+	acquireTimeout time.Duration
+
+	replicaConnStrings   []string
+	replicaInterval      time.Duration
+	readYourWritesWindow time.Duration
+	replicas             *replicaSet
+	writes               *writeTracker
 }
 
-// NewDatabaseRepository инициирует подключение к БД
-func NewDatabaseRepository(ctx context.Context, connString string) (Repository, error) {
-	db, err := database.InitConnection(ctx, connString)
+// DatabaseRepositoryOption функциональная опция DatabaseRepository
+type DatabaseRepositoryOption func(*DatabaseRepository)
+
+// WithReplicas задает строки подключения к репликам для запросов чтения
+// и период проверки их доступности
+func WithReplicas(connStrings []string, healthCheckInterval time.Duration) DatabaseRepositoryOption {
+	return func(r *DatabaseRepository) {
+		r.replicaConnStrings = connStrings
+		if healthCheckInterval > 0 {
+			r.replicaInterval = healthCheckInterval
+		}
+	}
+}
+
+// WithReadYourWritesWindow задает окно после записи, в течение которого
+// чтение измененных ссылок и ссылок пользователя выполняется на основной БД
+func WithReadYourWritesWindow(window time.Duration) DatabaseRepositoryOption {
+	return func(r *DatabaseRepository) {
+		r.readYourWritesWindow = window
+	}
+}
 
+// NewDatabaseRepository создает пул подключений к БД и применяет миграции схемы
+func NewDatabaseRepository(ctx context.Context, connString string, poolConfig database.PoolConfig, opts ...DatabaseRepositoryOption) (Repository, error) {
+	repository := &DatabaseRepository{
+		acquireTimeout:  poolConfig.AcquireTimeout,
+		replicaInterval: defaultReplicaHealthCheckInterval,
+	}
+
+	for _, opt := range opts {
+		opt(repository)
+	}
+
+	pool, err := database.InitPool(ctx, connString, poolConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	dbRepository := DatabaseRepository{db: db}
+	if err = database.MigratePool(ctx, pool); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	repository.pool = pool
 
-	if err = dbRepository.db.EnsureCreated(ctx); err != nil {
+	if err = repository.initReplicas(ctx, poolConfig); err != nil {
+		pool.Close()
 		return nil, err
 	}
+	repository.writes = newWriteTracker(repository.readYourWritesWindow)
+
+	return repository, nil
+}
 
-	return &dbRepository, nil
+// DatabasePool возвращает пул подключений к основной БД Postgres репозитория (первого шарда
+// при шардировании) или nil, если ссылки хранятся не в Postgres. Через пул с миграциями схемы,
+// уже примененными репозиторием, работают журнал аудита, вебхуки и очередь удаления.
+// Пул закрывается вместе с репозиторием.
+func DatabasePool(repository Repository) *pgxpool.Pool {
+	switch r := repository.(type) {
+	case *DatabaseRepository:
+		return r.pool
+	case *ShardedRepository:
+		return DatabasePool(r.shards[0])
+	case *CachedRepository:
+		return DatabasePool(r.Repository)
+	case *BloomRepository:
+		return DatabasePool(r.Repository)
+	case *ShadowRepository:
+		return DatabasePool(r.Repository)
+	default:
+		return nil
+	}
+}
+
+// initReplicas создает пулы подключений к репликам и запускает проверку их доступности
+func (repository *DatabaseRepository) initReplicas(ctx context.Context, poolConfig database.PoolConfig) error {
+	if len(repository.replicaConnStrings) == 0 {
+		return nil
+	}
+
+	pools := make([]*pgxpool.Pool, 0, len(repository.replicaConnStrings))
+	for _, connString := range repository.replicaConnStrings {
+		pool, err := database.InitPool(ctx, connString, poolConfig)
+		if err != nil {
+			for _, pool := range pools {
+				pool.Close()
+			}
+			return err
+		}
+		pools = append(pools, pool)
+	}
+
+	repository.replicas = newReplicaSet(pools, repository.replicaInterval)
+	repository.replicas.start(ctx)
+
+	return nil
 }
 
 // GetFullURL ищет в хранилище полную ссылку на ресурс по короткому ID
 func (repository *DatabaseRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
-	shortenedURLInfo = &ShortenedURLInfo{}
-	row := repository.db.DBConnection.QueryRowContext(ctx, selectFullURLSQL, shortID)
-	err := row.Scan(&shortenedURLInfo.UserID, &shortenedURLInfo.ShortID, &shortenedURLInfo.FullURL, &shortenedURLInfo.IsDeleted)
+	err := repository.read(ctx, []string{shortIDWriteKey(shortID)}, func(conn *pgxpool.Conn) error {
+		info, err := scanDatabaseEntry(conn.QueryRow(ctx, selectFullURLSQL, shortID))
+		if err != nil {
+			return err
+		}
+
+		shortenedURLInfo = info
+		return nil
+	})
 
 	if err != nil {
 		return nil, false
@@ -76,13 +255,15 @@ func (repository *DatabaseRepository) GetFullURL(ctx context.Context, shortID st
 
 // SaveEntry сохраняет в хранилище информацию о сокращенной ссылке
 func (repository *DatabaseRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
-	stmt, err := repository.db.DBConnection.PrepareContext(ctx, insertEntrySQL)
+	defer repository.writes.mark(userWriteKey(userID), shortIDWriteKey(shortID), fullURLWriteKey(linkURLKey(shortID, fullURL)))
+
+	conn, err := repository.acquire(ctx)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer conn.Release()
 
-	_, err = stmt.ExecContext(ctx, shortID, fullURL, userID)
+	_, err = conn.Exec(ctx, insertEntrySQL, shortID, fullURL, userID)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
@@ -93,104 +274,252 @@ func (repository *DatabaseRepository) SaveEntry(ctx context.Context, userID int,
 	return err
 }
 
-// GetShortID возвращает shortID сокращенной ссылки
-func (repository *DatabaseRepository) GetShortID(ctx context.Context, fullURL string) (shortID string, err error) {
-	stmt, err := repository.db.DBConnection.PrepareContext(ctx, selectShortIDSQL)
-	if err != nil {
-		return "", err
+// GetShortID возвращает shortID сокращенной ссылки в пространстве имен домена.
+// Возвращает ErrNotFound, если ссылка не сохранена.
+func (repository *DatabaseRepository) GetShortID(ctx context.Context, domain string, fullURL string) (shortID string, err error) {
+	err = repository.read(ctx, []string{fullURLWriteKey(domainURLKey(domain, fullURL))}, func(conn *pgxpool.Conn) error {
+		return conn.QueryRow(ctx, selectShortIDSQL, domain, fullURL).Scan(&shortID)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
 	}
-	defer stmt.Close()
-
-	row := stmt.QueryRowContext(ctx, fullURL)
-	err = row.Scan(&shortID)
-
 	if err != nil {
 		return "", err
 	}
 
-	return
+	return shortID, nil
 }
 
 // GetUserEntries возвращает сокращенный пользователем ссылки по userID
 func (repository *DatabaseRepository) GetUserEntries(ctx context.Context, userID int) (shortIDMap URLMapping, err error) {
-	stmt, err := repository.db.DBConnection.PrepareContext(ctx, selectUserURLs)
-	if err != nil {
-		return nil, err
-	}
-	defer stmt.Close()
+	err = repository.read(ctx, []string{userWriteKey(userID)}, func(conn *pgxpool.Conn) error {
+		rows, err := conn.Query(ctx, selectUserURLs, userID)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		shortIDMap = make(URLMapping)
+		for rows.Next() {
+			var shortID, fullURL string
 
-	rows, err := stmt.QueryContext(ctx, userID)
+			err = rows.Scan(&shortID, &fullURL)
+			if err != nil {
+				return err
+			}
+
+			shortIDMap[shortID] = fullURL
+		}
+
+		return rows.Err()
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	defer rows.Close()
+	return shortIDMap, nil
+}
 
-	shortIDMap = make(URLMapping, 0)
-	for rows.Next() {
-		var userEntry struct {
-			shortID string
-			fullURL string
+// ListUserEntries возвращает ссылки пользователя в порядке и с ограничениями query
+func (repository *DatabaseRepository) ListUserEntries(ctx context.Context, userID int, query UserEntriesQuery) (entries []ShortenedURLInfo, err error) {
+	var limit *int
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+
+	args := []any{userID, limit}
+	if query.After != nil {
+		if query.sortBy() == SortByClicks {
+			args = append(args, query.After.Clicks, query.After.ShortID)
+		} else {
+			args = append(args, query.After.CreatedAt, query.After.ShortID)
 		}
+	}
 
-		err = rows.Scan(&userEntry.shortID, &userEntry.fullURL)
+	err = repository.read(ctx, []string{userWriteKey(userID)}, func(conn *pgxpool.Conn) error {
+		rows, err := conn.Query(ctx, listUserEntriesSQL(query), args...)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		shortIDMap[(userEntry.shortID)] = userEntry.fullURL
-	}
+		defer rows.Close()
+
+		entries = make([]ShortenedURLInfo, 0)
+		for rows.Next() {
+			info, err := scanDatabaseEntry(rows)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, *info)
+		}
+
+		return rows.Err()
+	})
 
-	err = rows.Err()
 	if err != nil {
 		return nil, err
 	}
 
-	return shortIDMap, nil
+	return entries, nil
+}
+
+// AddClicks увеличивает счетчики переходов по ссылкам одним запросом UPDATE
+func (repository *DatabaseRepository) AddClicks(ctx context.Context, clicks map[string]int64) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	shortIDs := make([]string, 0, len(clicks))
+	counts := make([]int64, 0, len(clicks))
+	for shortID, count := range clicks {
+		shortIDs = append(shortIDs, shortID)
+		counts = append(counts, count)
+	}
+
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, addClicksSQL, shortIDs, counts)
+
+	return err
 }
 
-// Close закрывает подключение к БД
+// ForEachShortID вызывает fn для shortID каждой сохраненной ссылки
+func (repository *DatabaseRepository) ForEachShortID(ctx context.Context, fn func(shortID string) error) error {
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, selectShortIDsSQL)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortID string
+		if err = rows.Scan(&shortID); err != nil {
+			return err
+		}
+
+		if err = fn(shortID); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Close закрывает пулы подключений к БД и репликам
 func (repository *DatabaseRepository) Close() error {
-	return repository.db.DBConnection.Close()
+	if repository.replicas != nil {
+		repository.replicas.close()
+	}
+	repository.pool.Close()
+
+	return nil
 }
 
 // Ping проверяет подключение к БД
 func (repository *DatabaseRepository) Ping(ctx context.Context) error {
-	return repository.db.DBConnection.PingContext(ctx)
+	return repository.pool.Ping(ctx)
 }
 
-// SaveEntries записывает набор сокращенных ссылок в БД
-func (repository *DatabaseRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) error {
-	tx, err := repository.db.DBConnection.BeginTx(ctx, nil)
+// SaveEntries записывает набор сокращенных ссылок одним запросом INSERT ... SELECT FROM unnest,
+// большие наборы загружаются во временную таблицу через COPY.
+// Уже сохраненные ссылки пропускаются и возвращаются как конфликтующие.
+func (repository *DatabaseRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	if len(shortIDMap) == 0 {
+		return URLMapping{}, nil
+	}
+
+	defer func() {
+		keys := make([]string, 0, len(shortIDMap)*2+1)
+		keys = append(keys, userWriteKey(userID))
+		for shortID, fullURL := range shortIDMap {
+			keys = append(keys, shortIDWriteKey(shortID), fullURLWriteKey(linkURLKey(shortID, fullURL)))
+		}
+		repository.writes.mark(keys...)
+	}()
+
+	conn, err := repository.acquire(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer conn.Release()
 
-	defer tx.Rollback()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	stmt, err := tx.PrepareContext(ctx, insertEntrySQLBatch)
+	defer tx.Rollback(ctx)
+
+	var rows pgx.Rows
+	if len(shortIDMap) < copyBatchThreshold {
+		shortIDs, fullURLs := splitURLMapping(shortIDMap)
+		rows, err = tx.Query(ctx, insertEntriesUnnestSQL, shortIDs, fullURLs, userID)
+	} else {
+		rows, err = copyEntries(ctx, tx, userID, shortIDMap)
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	savedShortIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	saved := make(map[string]struct{}, len(savedShortIDs))
+	for _, shortID := range savedShortIDs {
+		saved[shortID] = struct{}{}
 	}
-	defer stmt.Close()
 
+	return conflictingEntries(shortIDMap, saved), nil
+}
+
+// copyEntries загружает ссылки во временную таблицу через COPY и переносит их в основную таблицу
+func copyEntries(ctx context.Context, tx pgx.Tx, userID int, shortIDMap URLMapping) (pgx.Rows, error) {
+	if _, err := tx.Exec(ctx, createBatchTableSQL); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]any, 0, len(shortIDMap))
 	for shortID, fullURL := range shortIDMap {
-		_, err := stmt.ExecContext(ctx, shortID, fullURL, userID)
+		rows = append(rows, []any{shortID, fullURL})
+	}
 
-		if err != nil {
-			return err
-		}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{batchTableName}, []string{"short_id", "full_url"}, pgx.CopyFromRows(rows))
+	if err != nil {
+		return nil, err
 	}
 
-	return tx.Commit()
+	return tx.Query(ctx, insertEntriesFromBatchTableSQL, userID)
 }
 
 // GetNewUserID возвращает ID нового пользователя
 func (repository *DatabaseRepository) GetNewUserID(ctx context.Context) (userID int, err error) {
-	row := repository.db.DBConnection.QueryRowContext(ctx, insertNewUserSQL)
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Release()
 
-	err = row.Scan(&userID)
+	err = conn.QueryRow(ctx, insertNewUserSQL).Scan(&userID)
 
 	if err != nil {
 		return -1, err
@@ -199,28 +528,316 @@ func (repository *DatabaseRepository) GetNewUserID(ctx context.Context) (userID
 	return userID, nil
 }
 
-// DeleteUserURLs удаляет набор сокращенных ссылок
+// DeleteUser сохраняет время удаления пользователя userID
+func (repository *DatabaseRepository) DeleteUser(ctx context.Context, userID int) error {
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, deleteUserSQL, userID)
+
+	return err
+}
+
+// DeletedUsers возвращает ID удаленных пользователей в порядке возрастания
+func (repository *DatabaseRepository) DeletedUsers(ctx context.Context) (userIDs []int, err error) {
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, selectDeletedUsersSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs, err = pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// DeleteUserURLs помечает удаленными набор сокращенных ссылок одним запросом UPDATE
 func (repository *DatabaseRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
-	tx, err := repository.db.DBConnection.BeginTx(ctx, nil)
+	if len(deleteRequests) == 0 {
+		return nil
+	}
+
+	shortIDs := make([]string, len(deleteRequests))
+	userIDs := make([]int32, len(deleteRequests))
+	keys := make([]string, 0, len(deleteRequests)*2)
+	for i, request := range deleteRequests {
+		shortIDs[i] = request.ShortIDToDelete
+		userIDs[i] = int32(request.UserID)
+		keys = append(keys, userWriteKey(request.UserID), shortIDWriteKey(request.ShortIDToDelete))
+	}
+	defer repository.writes.mark(keys...)
+
+	conn, err := repository.acquire(ctx)
 	if err != nil {
 		return err
 	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, deleteShortLinksSQL, shortIDs, userIDs)
+
+	return err
+}
+
+// acquire получает подключение из пула основной БД
+func (repository *DatabaseRepository) acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	return repository.acquireFrom(ctx, repository.pool)
+}
+
+// acquireFrom получает подключение из пула, ожидая свободное подключение не дольше acquireTimeout
+func (repository *DatabaseRepository) acquireFrom(ctx context.Context, pool *pgxpool.Pool) (*pgxpool.Conn, error) {
+	if repository.acquireTimeout <= 0 {
+		return pool.Acquire(ctx)
+	}
+
+	acquireCtx, cancel := context.WithTimeout(ctx, repository.acquireTimeout)
+	defer cancel()
+
+	return pool.Acquire(acquireCtx)
+}
 
-	defer tx.Rollback()
+// read выполняет запрос чтения на доступной реплике, если ни один из ключей writeKeys
+// не изменялся в окне read-your-writes. При ошибке реплики запрос повторяется на основной БД.
+func (repository *DatabaseRepository) read(ctx context.Context, writeKeys []string, fn func(conn *pgxpool.Conn) error) error {
+	if repository.replicas != nil && !repository.writes.isPinned(writeKeys...) {
+		if replica := repository.replicas.pick(); replica != nil {
+			err := repository.withConn(ctx, replica.pool, fn)
+			if err == nil || errors.Is(err, pgx.ErrNoRows) || ctx.Err() != nil {
+				return err
+			}
+
+			replica.healthy.Store(false)
+		}
+	}
+
+	return repository.withConn(ctx, repository.pool, fn)
+}
 
-	stmt, err := tx.PrepareContext(ctx, deleteShortLinkSQL)
+// withConn выполняет fn на подключении из пула
+func (repository *DatabaseRepository) withConn(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := repository.acquireFrom(ctx, pool)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer conn.Release()
 
-	for _, request := range deleteRequests {
-		_, err = stmt.ExecContext(ctx, request.ShortIDToDelete, request.UserID)
+	return fn(conn)
+}
+
+// scanDatabaseEntry читает ссылку из строки результата запроса
+func scanDatabaseEntry(row pgx.Row) (*ShortenedURLInfo, error) {
+	var info ShortenedURLInfo
+	if err := row.Scan(&info.UserID, &info.ShortID, &info.FullURL, &info.IsDeleted, &info.CreatedAt, &info.Clicks); err != nil {
+		return nil, err
+	}
+
+	info.CreatedAt = info.CreatedAt.UTC()
+
+	return &info, nil
+}
+
+func userWriteKey(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+func shortIDWriteKey(shortID string) string {
+	return "short_id:" + shortID
+}
+
+// fullURLWriteKey возвращает ключ записи полной ссылки по ключу ее уникальности (см. domainURLKey)
+func fullURLWriteKey(urlKey string) string {
+	return "full_url:" + urlKey
+}
+
+// splitURLMapping возвращает shortID и полные ссылки набора в виде параллельных слайсов
+func splitURLMapping(shortIDMap URLMapping) (shortIDs []string, fullURLs []string) {
+	shortIDs = make([]string, 0, len(shortIDMap))
+	fullURLs = make([]string, 0, len(shortIDMap))
+	for shortID, fullURL := range shortIDMap {
+		shortIDs = append(shortIDs, shortID)
+		fullURLs = append(fullURLs, fullURL)
+	}
+
+	return shortIDs, fullURLs
+}
 
+// ForEachEntry вызывает fn для каждой ссылки с shortID больше afterShortID в порядке возрастания shortID
+func (repository *DatabaseRepository) ForEachEntry(ctx context.Context, afterShortID string, fn func(info ShortenedURLInfo) error) error {
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, selectEntriesAfterSQL, afterShortID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		info, err := scanDatabaseEntry(rows)
 		if err != nil {
 			return err
 		}
+
+		if err = fn(*info); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// LastUserID возвращает наибольший выданный ID пользователя
+func (repository *DatabaseRepository) LastUserID(ctx context.Context) (userID int, err error) {
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Release()
+
+	err = conn.QueryRow(ctx, selectLastUserIDSQL).Scan(&userID)
+
+	return userID, err
+}
+
+// ImportEntries атомарно сохраняет ссылки с их владельцами и пометками удаления
+func (repository *DatabaseRepository) ImportEntries(ctx context.Context, entries []ShortenedURLInfo) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	shortIDs := make([]string, len(entries))
+	fullURLs := make([]string, len(entries))
+	userIDs := make([]int32, len(entries))
+	deleted := make([]bool, len(entries))
+	createdAt := make([]*time.Time, len(entries))
+	clicks := make([]int64, len(entries))
+	deletedShortIDs := make([]string, 0)
+	deletedUserIDs := make([]int32, 0)
+	for i, info := range entries {
+		shortIDs[i] = info.ShortID
+		fullURLs[i] = info.FullURL
+		userIDs[i] = int32(info.UserID)
+		deleted[i] = info.IsDeleted
+		clicks[i] = info.Clicks
+		if !info.CreatedAt.IsZero() {
+			createdAt[i] = &info.CreatedAt
+		}
+
+		if info.IsDeleted {
+			deletedShortIDs = append(deletedShortIDs, info.ShortID)
+			deletedUserIDs = append(deletedUserIDs, int32(info.UserID))
+		}
+	}
+
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, importUsersSQL, userIDs); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, reserveUserIDsSQL, 0); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, importEntriesSQL, shortIDs, fullURLs, userIDs, deleted, createdAt, clicks); err != nil {
+		return err
+	}
+
+	if len(deletedShortIDs) > 0 {
+		if _, err = tx.Exec(ctx, deleteShortLinksSQL, deletedShortIDs, deletedUserIDs); err != nil {
+			return err
+		}
 	}
 
-	return tx.Commit()
+	return tx.Commit(ctx)
+}
+
+// ImportUsers сохраняет пользователей с заданными ID и сдвигает за них последовательность ID
+func (repository *DatabaseRepository) ImportUsers(ctx context.Context, userIDs []int) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]int32, len(userIDs))
+	for i, userID := range userIDs {
+		ids[i] = int32(userID)
+	}
+
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, importUsersSQL, ids); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, reserveUserIDsSQL, 0); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// PurgeEntries удаляет ссылки из БД
+func (repository *DatabaseRepository) PurgeEntries(ctx context.Context, shortIDs []string) error {
+	if len(shortIDs) == 0 {
+		return nil
+	}
+
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, purgeEntriesSQL, shortIDs)
+
+	return err
+}
+
+// ReserveUserIDs гарантирует, что новые ID пользователей будут больше lastUserID
+func (repository *DatabaseRepository) ReserveUserIDs(ctx context.Context, lastUserID int) error {
+	conn, err := repository.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, reserveUserIDsSQL, lastUserID)
+
+	return err
 }
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/database"
+)
+
+// testDatabaseDSNEnv переменная окружения со строкой подключения к тестовой БД Postgres
+const testDatabaseDSNEnv = "TEST_DATABASE_DSN"
+
+func TestDatabaseRepositoryReplicas(t *testing.T) {
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseDSNEnv)
+	}
+
+	ctx := context.Background()
+	// основная БД используется и как реплика, вторая реплика недоступна
+	repository, err := NewDatabaseRepository(ctx, dsn, database.PoolConfig{},
+		WithReplicas([]string{dsn, "postgresql://localhost:1/unavailable?connect_timeout=1"}, time.Second),
+		WithReadYourWritesWindow(time.Second),
+	)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	userID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+
+	id := "replica" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	fullURL := "http://example.com/" + id
+	require.NoError(t, repository.SaveEntry(ctx, userID, id, fullURL))
+
+	for i := 0; i < 4; i++ {
+		info, ok := repository.GetFullURL(ctx, id)
+		require.True(t, ok)
+		assert.Equal(t, fullURL, info.FullURL)
+
+		shortID, err := repository.GetShortID(ctx, "", fullURL)
+		require.NoError(t, err)
+		assert.Equal(t, id, shortID)
+
+		entries, err := repository.GetUserEntries(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, URLMapping{id: fullURL}, entries)
+	}
+}
+
+// BenchmarkDatabaseRepository сравнивает подготовку выражения на каждый запрос через database/sql
+// (прежняя реализация) с пулом pgxpool и кешем подготовленных выражений
+// на параллельной нагрузке, аналогичной scripts/attack.sh.
+func BenchmarkDatabaseRepository(b *testing.B) {
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		b.Skipf("%s is not set", testDatabaseDSNEnv)
+	}
+
+	ctx := context.Background()
+	repository, err := NewDatabaseRepository(ctx, dsn, database.PoolConfig{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer repository.Close()
+
+	userID, err := repository.GetNewUserID(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	const fullURL = "http://example.com/benchmark"
+	if err = repository.SaveEntry(ctx, userID, "bench", fullURL); err != nil && !errors.Is(err, ErrConflict) {
+		b.Fatal(err)
+	}
+
+	var counter atomic.Int64
+	prefix := strconv.FormatInt(int64(os.Getpid()), 36)
+
+	b.Run("GetShortID/PrepareEachCall", func(b *testing.B) {
+		db, err := database.InitConnection(ctx, dsn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer db.DBConnection.Close()
+
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := prepareEachCallGetShortID(ctx, db.DBConnection, fullURL); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+
+	b.Run("GetShortID/Pool", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := repository.GetShortID(ctx, "", fullURL); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+
+	b.Run("SaveEntry/PrepareEachCall", func(b *testing.B) {
+		db, err := database.InitConnection(ctx, dsn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer db.DBConnection.Close()
+
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				id := prefix + strconv.FormatInt(counter.Add(1), 36)
+				if err := prepareEachCallSaveEntry(ctx, db.DBConnection, userID, id, "http://example.com/"+id); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+
+	b.Run("SaveEntry/Pool", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				id := prefix + strconv.FormatInt(counter.Add(1), 36)
+				if err := repository.SaveEntry(ctx, userID, id, "http://example.com/"+id); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+}
+
+func prepareEachCallGetShortID(ctx context.Context, db *sql.DB, fullURL string) (shortID string, err error) {
+	stmt, err := db.PrepareContext(ctx, selectShortIDSQL)
+	if err != nil {
+		return "", err
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, "", fullURL).Scan(&shortID)
+
+	return shortID, err
+}
+
+func prepareEachCallSaveEntry(ctx context.Context, db *sql.DB, userID int, shortID string, fullURL string) error {
+	stmt, err := db.PrepareContext(ctx, insertEntrySQL)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, shortID, fullURL, userID)
+
+	return err
+}
+
+func TestDatabasePool(t *testing.T) {
+	// пул подключается к БД только при первом запросе
+	pool, err := database.InitPool(context.Background(), "postgres://user@localhost/db", database.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	databaseRepository := &DatabaseRepository{pool: pool}
+	memoryRepository := NewMemoryRepository()
+
+	tests := []struct {
+		name       string
+		repository Repository
+		want       *pgxpool.Pool
+	}{
+		{"database", databaseRepository, pool},
+		{"cached database", &CachedRepository{Repository: databaseRepository}, pool},
+		{"bloom over shadow database", &BloomRepository{Repository: &ShadowRepository{Repository: databaseRepository}}, pool},
+		{"sharded database", NewShardedRepository([]Repository{databaseRepository, memoryRepository}), pool},
+		{"memory", memoryRepository, nil},
+		{"cached memory", &CachedRepository{Repository: memoryRepository}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Same(t, tt.want, DatabasePool(tt.repository))
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package repository
+
+import "strings"
+
+// domainSeparator разделяет домен и короткий ID в ключе ссылки (см. app.DomainShortID)
+const domainSeparator = "/"
+
+// domainURLSeparator разделяет домен и полную ссылку в ключе уникальности ссылки.
+// Управляющий символ не встречается ни в хосте домена, ни в проверенной полной ссылке.
+const domainURLSeparator = "\x00"
+
+// linkDomain возвращает домен из ключа ссылки shortID; для основного домена - пустую строку
+func linkDomain(shortID string) string {
+	domain, _, ok := strings.Cut(shortID, domainSeparator)
+	if !ok {
+		return ""
+	}
+
+	return domain
+}
+
+// domainURLKey возвращает ключ, по которому полная ссылка уникальна в пространстве имен домена.
+// Для основного домена ключ совпадает с полной ссылкой, поэтому ранее сохраненные ключи остаются верны.
+func domainURLKey(domain string, fullURL string) string {
+	if domain == "" {
+		return fullURL
+	}
+
+	return domain + domainURLSeparator + fullURL
+}
+
+// linkURLKey возвращает ключ уникальности полной ссылки fullURL, сохраненной под ключом shortID
+func linkURLKey(shortID string, fullURL string) string {
+	return domainURLKey(linkDomain(shortID), fullURL)
+}
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/spf13/afero"
 
@@ -10,122 +13,358 @@ import (
 	"github.com/rovany706/url-shortener/internal/storage"
 )
 
-// FileRepository репозиторий, использующий файл
+const (
+	snapshotFileSuffix = ".snapshot"
+	sealedFileSuffix   = ".compacting"
+)
+
+// FileRepository репозиторий, использующий файл.
+// Состояние хранится в памяти, а изменения дописываются в файл в формате JSON lines:
+// ссылки, надгробия удаленных ссылок и выданные ID пользователей.
+//
+// Журнал периодически (или по вызову Compact) сжимается: текущий файл журнала
+// закрывается для записи, а актуальное состояние сохраняется в файл снимка.
+// При запуске читается снимок и только та часть журнала, что была записана после него.
+//
+// Изменение применяется к состоянию до записи в журнал, чтобы проверка конфликтов
+// и запись не требовали общей блокировки. Если записать изменение не удалось,
+// оно откатывается в состоянии.
 type FileRepository struct {
-	fs              afero.Fs
-	storageFilepath string
-	shortURLMap     *sync.Map
+	fs                 afero.Fs
+	storageFilepath    string
+	state              *MemoryRepository
+	writer             *storage.GroupCommitWriter
+	compression        storage.Compression
+	upgradeFormat      bool
+	syncPolicy         storage.SyncPolicy
+	syncInterval       time.Duration
+	compactMutex       sync.Mutex
+	compactionInterval time.Duration
+	stopCompaction     context.CancelFunc
+	compactionDone     chan struct{}
+}
+
+// FileRepositoryOption функциональная опция FileRepository
+type FileRepositoryOption func(*FileRepository)
+
+// WithCompactionInterval задает период автоматического сжатия журнала.
+// Нулевое значение отключает автоматическое сжатие.
+func WithCompactionInterval(interval time.Duration) FileRepositoryOption {
+	return func(r *FileRepository) {
+		r.compactionInterval = interval
+	}
+}
+
+// WithSyncPolicy задает политику сброса записей на диск.
+// interval используется только для политики storage.SyncInterval.
+func WithSyncPolicy(policy storage.SyncPolicy, interval time.Duration) FileRepositoryOption {
+	return func(r *FileRepository) {
+		r.syncPolicy = policy
+		r.syncInterval = interval
+	}
+}
+
+// WithCompression задает сжатие для новых файлов журнала и снимков
+func WithCompression(compression storage.Compression) FileRepositoryOption {
+	return func(r *FileRepository) {
+		r.compression = compression
+	}
+}
+
+// WithFormatUpgrade включает перевод существующих файлов хранилища
+// в текущую версию формата при запуске
+func WithFormatUpgrade() FileRepositoryOption {
+	return func(r *FileRepository) {
+		r.upgradeFormat = true
+	}
 }
 
 // NewFileRepository создает файл для хранения данных
-func NewFileRepository(fs afero.Fs, storageFilepath string) (*FileRepository, error) {
-	fileStorageReader, err := storage.NewFileStorageReader(fs, storageFilepath)
+func NewFileRepository(fs afero.Fs, storageFilepath string, opts ...FileRepositoryOption) (*FileRepository, error) {
+	repository := FileRepository{
+		fs:              fs,
+		storageFilepath: storageFilepath,
+		state:           NewMemoryRepository(),
+		compression:     storage.CompressionNone,
+		syncPolicy:      storage.SyncNever,
+	}
 
-	if err != nil {
+	for _, opt := range opts {
+		opt(&repository)
+	}
+
+	if err := repository.truncateTornTails(); err != nil {
 		return nil, err
 	}
 
-	defer fileStorageReader.Close()
+	if repository.upgradeFormat {
+		if err := repository.upgrade(); err != nil {
+			return nil, err
+		}
+	}
 
-	storage, err := fileStorageReader.ReadAllEntries()
+	if err := repository.load(); err != nil {
+		return nil, err
+	}
 
+	writer, err := storage.NewGroupCommitWriter(fs, storageFilepath, repository.compression, repository.syncPolicy, repository.syncInterval)
 	if err != nil {
 		return nil, err
 	}
+	repository.writer = writer
 
-	repository := FileRepository{
-		fs:              fs,
-		storageFilepath: storageFilepath,
-		shortURLMap:     initializeShortURLMap(storage),
+	if repository.compactionInterval > 0 {
+		repository.startCompaction()
 	}
 
 	return &repository, nil
 }
 
-func initializeShortURLMap(storage storage.Storage) *sync.Map {
-	var shortURLMap sync.Map
-	for _, v := range storage {
-		shortURLMap.Store(v.ShortID, v.FullURL)
-	}
+func (repository *FileRepository) snapshotFilepath() string {
+	return repository.storageFilepath + snapshotFileSuffix
+}
 
-	return &shortURLMap
+func (repository *FileRepository) sealedFilepath() string {
+	return repository.storageFilepath + sealedFileSuffix
 }
 
-// GetFullURL ищет в хранилище полную ссылку на ресурс по короткому ID
-func (repository *FileRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
-	v, ok := repository.shortURLMap.Load(shortID)
+func (repository *FileRepository) filepaths() []string {
+	return []string{repository.snapshotFilepath(), repository.sealedFilepath(), repository.storageFilepath}
+}
 
-	if ok {
-		fullURL := v.(string)
-		shortenedURLInfo = &ShortenedURLInfo{
-			UserID:    1,
-			FullURL:   fullURL,
-			ShortID:   shortID,
-			IsDeleted: false,
+// truncateTornTails отбрасывает записи, недописанные в файлы хранилища при аварийном завершении
+func (repository *FileRepository) truncateTornTails() error {
+	for _, filename := range repository.filepaths() {
+		if _, err := storage.TruncateTornTail(repository.fs, filename); err != nil {
+			return err
 		}
 	}
 
-	return shortenedURLInfo, ok
+	return nil
 }
 
-// SaveEntry сохраняет в хранилище информацию о сокращенной ссылке
-func (repository *FileRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
-	_, exists := repository.shortURLMap.LoadOrStore(shortID, fullURL)
+// upgrade переводит файлы хранилища в текущую версию формата
+func (repository *FileRepository) upgrade() error {
+	for _, filename := range repository.filepaths() {
+		if _, err := storage.UpgradeFile(repository.fs, filename, repository.compression); err != nil {
+			return err
+		}
+	}
 
-	if !exists {
-		return repository.saveNewEntry(shortID, fullURL)
+	return nil
+}
+
+// load восстанавливает состояние: снимок, сегмент журнала от прерванного сжатия и текущий журнал
+func (repository *FileRepository) load() error {
+	for _, filename := range repository.filepaths() {
+		err := storage.ForEachFileEntry(repository.fs, filename, func(entry storage.StorageEntry) error {
+			applyEntry(repository.state, entry)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (repository *FileRepository) saveNewEntry(shortID string, fullURL string) error {
-	storageWriter, err := storage.NewFileStorageWriter(repository.fs, repository.storageFilepath)
+// applyEntry применяет запись к состоянию. Повторное применение записей, кроме записей
+// переходов прежних версий, не меняет состояние: снимок может уже учитывать часть журнала.
+func applyEntry(state *MemoryRepository, entry storage.StorageEntry) {
+	switch entry.GetType() {
+	case storage.EntryTypeLink:
+		state.restore(ShortenedURLInfo{
+			UserID:    entry.UserID,
+			ShortID:   entry.ShortID,
+			FullURL:   entry.FullURL,
+			IsDeleted: entry.IsDeleted,
+			CreatedAt: entry.CreatedAt,
+			Clicks:    entry.Clicks,
+		})
+	case storage.EntryTypeClicks:
+		state.restoreClicks(entry.ShortID, entry.Clicks)
+	case storage.EntryTypeClickCount:
+		state.restoreClickCount(entry.ShortID, entry.Clicks)
+	case storage.EntryTypeDelete:
+		state.restoreDeleted(entry.UserID, entry.ShortID)
+	case storage.EntryTypeUser:
+		state.restoreUserID(entry.UserID)
+	case storage.EntryTypeUserDeleted:
+		state.restoreDeletedUser(entry.UserID)
+	}
+}
+
+func (repository *FileRepository) startCompaction() {
+	ctx, cancel := context.WithCancel(context.Background())
+	repository.stopCompaction = cancel
+	repository.compactionDone = make(chan struct{})
 
-	if err != nil {
+	go func() {
+		defer close(repository.compactionDone)
+
+		ticker := time.NewTicker(repository.compactionInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				_ = repository.Compact(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Compact сжимает журнал: записывает актуальное состояние в снимок и удаляет
+// уже учтенную в нем часть журнала. Параллельные вызовы SaveEntry не блокируются
+// на время записи снимка.
+func (repository *FileRepository) Compact(ctx context.Context) error {
+	repository.compactMutex.Lock()
+	defer repository.compactMutex.Unlock()
+
+	if err := repository.sealLog(); err != nil {
 		return err
 	}
 
-	defer storageWriter.Close()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	entry := storage.StorageEntry{
-		ShortID: shortID,
-		FullURL: fullURL,
+	if err := repository.writeSnapshot(); err != nil {
+		return err
 	}
 
-	return storageWriter.WriteEntry(&entry)
+	return repository.fs.Remove(repository.sealedFilepath())
 }
 
-// SaveEntries записывает набор сокращенных ссылок
-func (repository *FileRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) error {
-	storageWriter, err := storage.NewFileStorageWriter(repository.fs, repository.storageFilepath)
+// sealLog переименовывает текущий журнал в закрытый сегмент, новые записи
+// начинают писаться в новый файл журнала
+func (repository *FileRepository) sealLog() error {
+	if _, err := repository.fs.Stat(repository.sealedFilepath()); err == nil {
+		// сегмент остался от прерванного сжатия: его записи уже в состоянии,
+		// он будет удален после записи снимка. Записи текущего журнала тоже попадут
+		// в снимок и будут прочитаны повторно, что не меняет состояние (см. applyEntries).
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return repository.writer.Rotate(repository.sealedFilepath())
+}
 
+func (repository *FileRepository) writeSnapshot() error {
+	infos, lastUserID := repository.state.snapshot()
+	deletedUsers, err := repository.state.DeletedUsers(context.Background())
 	if err != nil {
 		return err
 	}
 
-	defer storageWriter.Close()
-
-	entries := make([]storage.StorageEntry, 0)
-	for shortID, fullURL := range shortIDMap {
-		_, exists := repository.shortURLMap.LoadOrStore(shortID, fullURL)
+	return storage.WriteSnapshot(repository.fs, repository.snapshotFilepath(), repository.compression, func(write func(entry storage.StorageEntry) error) error {
+		err := write(storage.StorageEntry{
+			Type:   storage.EntryTypeUser,
+			UserID: lastUserID,
+		})
+		if err != nil {
+			return err
+		}
 
-		if !exists {
-			entry := storage.StorageEntry{
-				ShortID: shortID,
-				FullURL: fullURL,
+		for _, userID := range deletedUsers {
+			err = write(storage.StorageEntry{
+				Type:   storage.EntryTypeUserDeleted,
+				UserID: userID,
+			})
+			if err != nil {
+				return err
 			}
+		}
 
-			entries = append(entries, entry)
+		for _, info := range infos {
+			err = write(storage.StorageEntry{
+				Type:      storage.EntryTypeLink,
+				ShortID:   info.ShortID,
+				FullURL:   info.FullURL,
+				UserID:    info.UserID,
+				IsDeleted: info.IsDeleted,
+				CreatedAt: info.CreatedAt,
+				Clicks:    info.Clicks,
+			})
+			if err != nil {
+				return err
+			}
 		}
+
+		return nil
+	})
+}
+
+// GetFullURL ищет в хранилище полную ссылку на ресурс по короткому ID
+func (repository *FileRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
+	return repository.state.GetFullURL(ctx, shortID)
+}
+
+// SaveEntry сохраняет в хранилище информацию о сокращенной ссылке
+func (repository *FileRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
+	info, err := repository.state.saveEntry(userID, shortID, fullURL)
+	if err != nil {
+		return err
 	}
 
-	return storageWriter.WriteEntries(entries)
+	entry := storage.StorageEntry{
+		Type:      storage.EntryTypeLink,
+		ShortID:   shortID,
+		FullURL:   fullURL,
+		UserID:    userID,
+		CreatedAt: info.CreatedAt,
+		UpdatedAt: info.CreatedAt,
+	}
+
+	if err = repository.writeEntries([]storage.StorageEntry{entry}); err != nil {
+		repository.state.index.purge([]string{shortID})
+		return err
+	}
+
+	return nil
+}
+
+// SaveEntries записывает набор сокращенных ссылок.
+// Уже сохраненные ссылки пропускаются и возвращаются как конфликтующие.
+func (repository *FileRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	saved := repository.state.saveNewEntries(userID, shortIDMap)
+
+	entries := make([]storage.StorageEntry, 0, len(saved))
+	for _, info := range saved {
+		entries = append(entries, storage.StorageEntry{
+			Type:      storage.EntryTypeLink,
+			ShortID:   info.ShortID,
+			FullURL:   info.FullURL,
+			UserID:    info.UserID,
+			CreatedAt: info.CreatedAt,
+			UpdatedAt: info.CreatedAt,
+		})
+	}
+
+	if err = repository.writeEntries(entries); err != nil {
+		repository.state.index.purge(infoShortIDs(saved))
+		return nil, err
+	}
+
+	return conflictingEntries(shortIDMap, savedShortIDs(saved)), nil
 }
 
-// Close завершает работу с хранилищем
+func (repository *FileRepository) writeEntries(entries []storage.StorageEntry) error {
+	return repository.writer.WriteEntries(entries)
+}
+
+// Close завершает работу с хранилищем, дописывая в файл накопленные записи
 func (repository *FileRepository) Close() error {
-	return nil
+	if repository.stopCompaction != nil {
+		repository.stopCompaction()
+		<-repository.compactionDone
+	}
+
+	return repository.writer.Close()
 }
 
 // Ping не поддерживается FileRepository
@@ -133,30 +372,189 @@ func (repository *FileRepository) Ping(ctx context.Context) error {
 	return ErrPingNotSupported
 }
 
-// GetShortID возвращает shortID сокращенной ссылки
-func (repository *FileRepository) GetShortID(ctx context.Context, fullURL string) (shortID string, err error) {
-	repository.shortURLMap.Range(func(id, url any) bool {
-		if url.(string) == fullURL {
-			shortID = id.(string)
-			return false
-		}
-		return true
-	})
-
-	return
+// GetShortID возвращает shortID сокращенной ссылки.
+// Возвращает ErrNotFound, если ссылка не сохранена.
+func (repository *FileRepository) GetShortID(ctx context.Context, domain string, fullURL string) (shortID string, err error) {
+	return repository.state.GetShortID(ctx, domain, fullURL)
 }
 
 // GetUserEntries возвращает сокращенный пользователем ссылки по userID
 func (repository *FileRepository) GetUserEntries(ctx context.Context, userID int) (shortIDMap URLMapping, err error) {
-	return nil, ErrNotImplemented
+	return repository.state.GetUserEntries(ctx, userID)
 }
 
-// GetNewUserID возвращает ID нового пользователя
+// ListUserEntries возвращает ссылки пользователя в порядке и с ограничениями query
+func (repository *FileRepository) ListUserEntries(ctx context.Context, userID int, query UserEntriesQuery) (entries []ShortenedURLInfo, err error) {
+	return repository.state.ListUserEntries(ctx, userID, query)
+}
+
+// AddClicks увеличивает счетчики переходов по ссылкам и дописывает в файл их новые значения.
+// Значения, а не приращения, позволяют читать журнал поверх снимка, который уже учел часть
+// его записей: при сжатии во время AddClicks и после прерванного сжатия.
+func (repository *FileRepository) AddClicks(ctx context.Context, clicks map[string]int64) error {
+	totals := repository.state.index.addClicks(clicks)
+
+	now := time.Now().UTC()
+	entries := make([]storage.StorageEntry, 0, len(totals))
+	for shortID, count := range totals {
+		entries = append(entries, storage.StorageEntry{
+			Type:      storage.EntryTypeClickCount,
+			ShortID:   shortID,
+			Clicks:    count,
+			UpdatedAt: now,
+		})
+	}
+
+	if err := repository.writeEntries(entries); err != nil {
+		added := make(map[string]int64, len(totals))
+		for shortID := range totals {
+			added[shortID] = -clicks[shortID]
+		}
+		repository.state.index.addClicks(added)
+
+		return err
+	}
+
+	return nil
+}
+
+// ForEachShortID вызывает fn для shortID каждой сохраненной ссылки
+func (repository *FileRepository) ForEachShortID(ctx context.Context, fn func(shortID string) error) error {
+	return repository.state.ForEachShortID(ctx, fn)
+}
+
+// GetNewUserID возвращает ID нового пользователя.
+// Выданный ID сохраняется в файл, чтобы счетчик пережил перезапуск.
+// Если записать ID не удалось, он не возвращается и остается пропущенным:
+// счетчик нельзя откатить, не задев параллельно выданные ID.
 func (repository *FileRepository) GetNewUserID(ctx context.Context) (userID int, err error) {
-	return -1, nil
+	userID, err = repository.state.GetNewUserID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	entry := storage.StorageEntry{
+		Type:      storage.EntryTypeUser,
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err = repository.writeEntries([]storage.StorageEntry{entry}); err != nil {
+		return -1, err
+	}
+
+	return userID, nil
 }
 
-// DeleteUserURLs удаляет набор сокращенных ссылок
+// DeleteUserURLs помечает удаленными набор сокращенных ссылок и сохраняет надгробия в файл
 func (repository *FileRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
+	deleted := repository.state.deleteEntries(deleteRequests)
+
+	now := time.Now().UTC()
+	entries := make([]storage.StorageEntry, 0, len(deleted))
+	for _, request := range deleted {
+		entries = append(entries, storage.StorageEntry{
+			Type:      storage.EntryTypeDelete,
+			ShortID:   request.ShortIDToDelete,
+			UserID:    request.UserID,
+			IsDeleted: true,
+			UpdatedAt: now,
+		})
+	}
+
+	if err := repository.writeEntries(entries); err != nil {
+		repository.state.index.unmarkDeleted(deleted)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUser помечает пользователя удаленным и сохраняет пометку в файл
+func (repository *FileRepository) DeleteUser(ctx context.Context, userID int) error {
+	if !repository.state.deleteUser(userID) {
+		return nil
+	}
+
+	entry := storage.StorageEntry{
+		Type:      storage.EntryTypeUserDeleted,
+		UserID:    userID,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if err := repository.writeEntries([]storage.StorageEntry{entry}); err != nil {
+		repository.state.deletedUsers.Delete(userID)
+		return err
+	}
+
+	return nil
+}
+
+// DeletedUsers возвращает ID удаленных пользователей в порядке возрастания
+func (repository *FileRepository) DeletedUsers(ctx context.Context) (userIDs []int, err error) {
+	return repository.state.DeletedUsers(ctx)
+}
+
+// ForEachEntry вызывает fn для каждой ссылки с shortID больше afterShortID в порядке возрастания shortID
+func (repository *FileRepository) ForEachEntry(ctx context.Context, afterShortID string, fn func(info ShortenedURLInfo) error) error {
+	return repository.state.ForEachEntry(ctx, afterShortID, fn)
+}
+
+// LastUserID возвращает наибольший выданный ID пользователя
+func (repository *FileRepository) LastUserID(ctx context.Context) (int, error) {
+	return repository.state.LastUserID(ctx)
+}
+
+// ImportEntries сохраняет ссылки с их владельцами и пометками удаления и дописывает изменения в файл
+func (repository *FileRepository) ImportEntries(ctx context.Context, entries []ShortenedURLInfo) error {
+	inserted, deleted := repository.state.importEntries(entries)
+
+	now := time.Now().UTC()
+	storageEntries := make([]storage.StorageEntry, 0, len(inserted)+len(deleted))
+	for _, info := range inserted {
+		storageEntries = append(storageEntries, storage.StorageEntry{
+			Type:      storage.EntryTypeLink,
+			ShortID:   info.ShortID,
+			FullURL:   info.FullURL,
+			UserID:    info.UserID,
+			IsDeleted: info.IsDeleted,
+			CreatedAt: info.CreatedAt,
+			UpdatedAt: now,
+			Clicks:    info.Clicks,
+		})
+	}
+
+	for _, request := range deleted {
+		storageEntries = append(storageEntries, storage.StorageEntry{
+			Type:      storage.EntryTypeDelete,
+			ShortID:   request.ShortIDToDelete,
+			UserID:    request.UserID,
+			IsDeleted: true,
+			UpdatedAt: now,
+		})
+	}
+
+	if err := repository.writeEntries(storageEntries); err != nil {
+		repository.state.index.purge(infoShortIDs(inserted))
+		repository.state.index.unmarkDeleted(deleted)
+		return err
+	}
+
 	return nil
 }
+
+// ReserveUserIDs гарантирует, что новые ID пользователей будут больше lastUserID,
+// и сохраняет счетчик в файл
+func (repository *FileRepository) ReserveUserIDs(ctx context.Context, lastUserID int) error {
+	if current, _ := repository.state.LastUserID(ctx); current >= lastUserID {
+		return nil
+	}
+
+	repository.state.restoreUserID(lastUserID)
+
+	return repository.writeEntries([]storage.StorageEntry{{
+		Type:      storage.EntryTypeUser,
+		UserID:    lastUserID,
+		CreatedAt: time.Now().UTC(),
+	}})
+}
@@ -3,11 +3,17 @@ package repository
 import (
 	"context"
 	"os"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/storage"
 )
 
 func loadTestData(t *testing.T, fs afero.Fs, testDataFilePath string, mockFilePath string) {
@@ -36,7 +42,7 @@ func TestGetFullURL(t *testing.T) {
 					FullURL:   "http://example.com",
 					ShortID:   "89dce6a4",
 					IsDeleted: false,
-					UserID:    1,
+					UserID:    0,
 				},
 				ok: true,
 			},
@@ -113,7 +119,11 @@ func TestSaveEntry(t *testing.T) {
 			require.NoError(t, err)
 
 			err = repository.SaveEntry(ctx, 1, tt.shortID, tt.fullURL)
-			require.NoError(t, err)
+			if tt.wantWriteNewData {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrConflict)
+			}
 
 			fi, err = fs.Stat(testStoragePath)
 			require.NoError(t, err)
@@ -133,6 +143,7 @@ func TestSaveEntries(t *testing.T) {
 	tests := []struct {
 		name             string
 		newEntries       map[string]string
+		wantConflicts    URLMapping
 		wantWriteNewData bool
 	}{
 		{
@@ -141,6 +152,7 @@ func TestSaveEntries(t *testing.T) {
 				"1": "https://ya.ru",
 				"2": "https://google.com",
 			},
+			wantConflicts:    URLMapping{},
 			wantWriteNewData: true,
 		},
 		{
@@ -149,6 +161,10 @@ func TestSaveEntries(t *testing.T) {
 				"89dce6a4": "https://ya.ru",
 				"ec2c0086": "https://google.com",
 			},
+			wantConflicts: URLMapping{
+				"89dce6a4": "https://ya.ru",
+				"ec2c0086": "https://google.com",
+			},
 			wantWriteNewData: false,
 		},
 	}
@@ -168,8 +184,9 @@ func TestSaveEntries(t *testing.T) {
 			repository, err := NewFileRepository(fs, testStoragePath)
 			require.NoError(t, err)
 
-			err = repository.SaveEntries(ctx, 1, tt.newEntries)
+			conflicts, err := repository.SaveEntries(ctx, 1, tt.newEntries)
 			require.NoError(t, err)
+			assert.Equal(t, tt.wantConflicts, conflicts)
 
 			fi, err = fs.Stat(testStoragePath)
 			require.NoError(t, err)
@@ -217,6 +234,7 @@ func TestGetShortID(t *testing.T) {
 		name        string
 		fullURL     string
 		wantShortID string
+		wantErr     error
 	}{
 		{
 			name:        "existing full URL",
@@ -227,6 +245,7 @@ func TestGetShortID(t *testing.T) {
 			name:        "non existing full URL",
 			fullURL:     "https://ya.ru",
 			wantShortID: "",
+			wantErr:     ErrNotFound,
 		},
 	}
 
@@ -242,9 +261,421 @@ func TestGetShortID(t *testing.T) {
 			repository, err := NewFileRepository(fs, testStoragePath)
 			require.NoError(t, err)
 
-			shortID, err := repository.GetShortID(ctx, tt.fullURL)
-			require.NoError(t, err)
+			shortID, err := repository.GetShortID(ctx, "", tt.fullURL)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
 			assert.Equal(t, tt.wantShortID, shortID)
 		})
 	}
 }
+
+func TestFileRepositoryPersistence(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	fs.MkdirAll("/home/test", 0755)
+	testStoragePath := "/home/test/storage.json"
+	loadTestData(t, fs, "testdata/test_storage.json", testStoragePath)
+
+	repository, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	userID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, userID)
+
+	otherUserID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, otherUserID)
+
+	require.NoError(t, repository.SaveEntry(ctx, userID, "id1", "https://ya.ru"))
+	_, err = repository.SaveEntries(ctx, userID, URLMapping{"id2": "https://google.com"})
+	require.NoError(t, err)
+	require.NoError(t, repository.DeleteUserURLs(ctx, []models.UserDeleteRequest{
+		{UserID: userID, ShortIDToDelete: "id1"},
+		{UserID: otherUserID, ShortIDToDelete: "id2"},
+	}))
+	require.NoError(t, repository.AddClicks(ctx, map[string]int64{"id1": 2}))
+	saved, ok := repository.GetFullURL(ctx, "id1")
+	require.True(t, ok)
+	require.NoError(t, repository.Close())
+
+	reopened, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	entries, err := reopened.GetUserEntries(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, URLMapping{"id1": "https://ya.ru", "id2": "https://google.com"}, entries)
+
+	info, ok := reopened.GetFullURL(ctx, "id1")
+	require.True(t, ok)
+	assert.Equal(t, ShortenedURLInfo{UserID: userID, ShortID: "id1", FullURL: "https://ya.ru", IsDeleted: true, CreatedAt: saved.CreatedAt, Clicks: 2}, *info)
+
+	info, ok = reopened.GetFullURL(ctx, "id2")
+	require.True(t, ok)
+	assert.False(t, info.IsDeleted)
+
+	info, ok = reopened.GetFullURL(ctx, "89dce6a4")
+	require.True(t, ok)
+	assert.Equal(t, 0, info.UserID)
+
+	newUserID, err := reopened.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, newUserID)
+}
+
+func TestFileRepositoryEntryMigration(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	testStoragePath := "storage.json"
+
+	repository, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	createdAt := time.Date(2024, time.January, 2, 3, 4, 5, 6000, time.UTC)
+	require.NoError(t, repository.ImportEntries(ctx, []ShortenedURLInfo{
+		{UserID: 2, ShortID: "b", FullURL: "http://example.com/b", CreatedAt: createdAt, Clicks: 7},
+	}))
+	// пометка удаления переносится повторной загрузкой
+	require.NoError(t, repository.ImportEntries(ctx, []ShortenedURLInfo{
+		{UserID: 2, ShortID: "b", FullURL: "http://example.com/b", IsDeleted: true, CreatedAt: createdAt, Clicks: 7},
+	}))
+	require.NoError(t, repository.ReserveUserIDs(ctx, 10))
+	require.NoError(t, repository.Close())
+
+	// загруженные ссылки и счетчик пользователей сохраняются в файл
+	reopened, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	info, ok := reopened.GetFullURL(ctx, "b")
+	require.True(t, ok)
+	assert.Equal(t, ShortenedURLInfo{
+		UserID:    2,
+		ShortID:   "b",
+		FullURL:   "http://example.com/b",
+		IsDeleted: true,
+		CreatedAt: createdAt,
+		Clicks:    7,
+	}, *info)
+
+	lastUserID, err := reopened.LastUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 10, lastUserID)
+}
+
+func TestFileRepositoryCompact(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	fs.MkdirAll("/home/test", 0755)
+	testStoragePath := "/home/test/storage.json"
+	loadTestData(t, fs, "testdata/test_storage.json", testStoragePath)
+
+	repository, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	userID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	require.NoError(t, repository.SaveEntry(ctx, userID, "id1", "https://ya.ru"))
+	require.NoError(t, repository.DeleteUserURLs(ctx, []models.UserDeleteRequest{{UserID: userID, ShortIDToDelete: "id1"}}))
+
+	require.NoError(t, repository.Compact(ctx))
+
+	exists, err := afero.Exists(fs, testStoragePath+snapshotFileSuffix)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = afero.Exists(fs, testStoragePath+sealedFileSuffix)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	logEntries, err := storage.ReadFileEntries(fs, testStoragePath)
+	require.NoError(t, err)
+	assert.Empty(t, logEntries, "compacted log must be truncated")
+
+	require.NoError(t, repository.SaveEntry(ctx, userID, "id2", "https://google.com"))
+	require.NoError(t, repository.Close())
+
+	logData, err := afero.ReadFile(fs, testStoragePath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(logData), "id1", "log tail must contain only entries written after compaction")
+
+	reopened, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	info, ok := reopened.GetFullURL(ctx, "id1")
+	require.True(t, ok)
+	assert.True(t, info.IsDeleted)
+
+	_, ok = reopened.GetFullURL(ctx, "id2")
+	assert.True(t, ok)
+
+	_, ok = reopened.GetFullURL(ctx, "89dce6a4")
+	assert.True(t, ok)
+
+	newUserID, err := reopened.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, userID+1, newUserID)
+}
+
+func TestFileRepositoryLoadInterruptedCompaction(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	fs.MkdirAll("/home/test", 0755)
+	testStoragePath := "/home/test/storage.json"
+	loadTestData(t, fs, "testdata/test_storage.json", testStoragePath+sealedFileSuffix)
+
+	repository, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+	require.NoError(t, repository.SaveEntry(ctx, 1, "id1", "https://ya.ru"))
+
+	_, ok := repository.GetFullURL(ctx, "89dce6a4")
+	require.True(t, ok, "sealed segment must be replayed")
+
+	require.NoError(t, repository.Compact(ctx))
+
+	reopened, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	for _, shortID := range []string{"89dce6a4", "ec2c0086", "id1"} {
+		_, ok := reopened.GetFullURL(ctx, shortID)
+		assert.True(t, ok, shortID)
+	}
+}
+
+func TestFileRepositoryReplayOverSnapshot(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	fs.MkdirAll("/home/test", 0755)
+	testStoragePath := "/home/test/storage.json"
+
+	repository, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "id1", "https://ya.ru"))
+	require.NoError(t, repository.AddClicks(ctx, map[string]int64{"id1": 2}))
+	require.NoError(t, repository.Compact(ctx))
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "id2", "https://google.com"))
+	require.NoError(t, repository.AddClicks(ctx, map[string]int64{"id1": 3, "id2": 4}))
+	require.NoError(t, repository.DeleteUserURLs(ctx, []models.UserDeleteRequest{{UserID: 1, ShortIDToDelete: "id2"}}))
+
+	// сжатие прервано после записи снимка: закрытый сегмент и журнал уже учтены в снимке
+	require.NoError(t, repository.sealLog())
+	require.NoError(t, repository.AddClicks(ctx, map[string]int64{"id1": 1}))
+	require.NoError(t, repository.writeSnapshot())
+	require.NoError(t, repository.Close())
+
+	reopened, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	info, ok := reopened.GetFullURL(ctx, "id1")
+	require.True(t, ok)
+	assert.Equal(t, int64(6), info.Clicks)
+
+	info, ok = reopened.GetFullURL(ctx, "id2")
+	require.True(t, ok)
+	assert.Equal(t, int64(4), info.Clicks)
+	assert.True(t, info.IsDeleted)
+
+	// повторное сжатие удаляет оставшийся сегмент, не меняя счетчики
+	require.NoError(t, reopened.Compact(ctx))
+
+	info, ok = reopened.GetFullURL(ctx, "id1")
+	require.True(t, ok)
+	assert.Equal(t, int64(6), info.Clicks)
+}
+
+func TestFileRepositoryRollbackOnWriteError(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	testStoragePath := "storage.json"
+
+	repository, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "id1", "https://ya.ru"))
+	require.NoError(t, repository.AddClicks(ctx, map[string]int64{"id1": 2}))
+
+	// после закрытия журнала запись изменений завершается ошибкой
+	require.NoError(t, repository.writer.Close())
+
+	err = repository.SaveEntry(ctx, 1, "id2", "https://google.com")
+	assert.ErrorIs(t, err, storage.ErrWriterClosed)
+
+	_, err = repository.SaveEntries(ctx, 1, URLMapping{"id3": "https://example.com"})
+	assert.ErrorIs(t, err, storage.ErrWriterClosed)
+
+	err = repository.ImportEntries(ctx, []ShortenedURLInfo{
+		{UserID: 1, ShortID: "id4", FullURL: "https://example.org"},
+		{UserID: 1, ShortID: "id1", FullURL: "https://ya.ru", IsDeleted: true},
+	})
+	assert.ErrorIs(t, err, storage.ErrWriterClosed)
+
+	err = repository.AddClicks(ctx, map[string]int64{"id1": 3})
+	assert.ErrorIs(t, err, storage.ErrWriterClosed)
+
+	err = repository.DeleteUserURLs(ctx, []models.UserDeleteRequest{{UserID: 1, ShortIDToDelete: "id1"}})
+	assert.ErrorIs(t, err, storage.ErrWriterClosed)
+
+	for _, shortID := range []string{"id2", "id3", "id4"} {
+		_, ok := repository.GetFullURL(ctx, shortID)
+		assert.False(t, ok, shortID)
+	}
+
+	_, err = repository.GetShortID(ctx, "", "https://google.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	info, ok := repository.GetFullURL(ctx, "id1")
+	require.True(t, ok)
+	assert.False(t, info.IsDeleted)
+	assert.Equal(t, int64(2), info.Clicks)
+}
+
+func TestFileRepositoryLoadTornLog(t *testing.T) {
+	ctx := context.Background()
+
+	for _, compression := range []storage.Compression{storage.CompressionNone, storage.CompressionGzip, storage.CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			testStoragePath := "storage.json"
+
+			repository, err := NewFileRepository(fs, testStoragePath, WithCompression(compression))
+			require.NoError(t, err)
+			require.NoError(t, repository.SaveEntry(ctx, 1, "id1", "https://ya.ru"))
+			require.NoError(t, repository.SaveEntry(ctx, 1, "id2", "https://google.com"))
+			require.NoError(t, repository.Close())
+
+			// последняя запись недописана при аварийном завершении
+			data, err := afero.ReadFile(fs, testStoragePath)
+			require.NoError(t, err)
+			require.NoError(t, afero.WriteFile(fs, testStoragePath, data[:len(data)-3], 0644))
+
+			reopened, err := NewFileRepository(fs, testStoragePath, WithCompression(compression))
+			require.NoError(t, err)
+
+			_, ok := reopened.GetFullURL(ctx, "id1")
+			assert.True(t, ok)
+
+			_, ok = reopened.GetFullURL(ctx, "id2")
+			assert.False(t, ok)
+
+			// новые записи дописываются после последней целой записи
+			require.NoError(t, reopened.SaveEntry(ctx, 1, "id3", "https://example.com"))
+			require.NoError(t, reopened.Close())
+
+			reopened, err = NewFileRepository(fs, testStoragePath, WithCompression(compression))
+			require.NoError(t, err)
+			defer reopened.Close()
+
+			_, ok = reopened.GetFullURL(ctx, "id3")
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestFileRepositoryCompactConcurrentSaves(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	fs.MkdirAll("/home/test", 0755)
+	testStoragePath := "/home/test/storage.json"
+
+	repository, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	const count = 200
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			shortID := strconv.Itoa(i)
+			assert.NoError(t, repository.SaveEntry(ctx, 1, shortID, "https://example.com/"+shortID))
+		}()
+
+		if i%50 == 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, repository.Compact(ctx))
+			}()
+		}
+	}
+	wg.Wait()
+	require.NoError(t, repository.Close())
+
+	reopened, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	entries, err := reopened.GetUserEntries(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, entries, count)
+}
+
+func TestFileRepositoryFormatUpgrade(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	fs.MkdirAll("/home/test", 0755)
+	testStoragePath := "/home/test/storage.json"
+	loadTestData(t, fs, "testdata/test_storage.json", testStoragePath)
+
+	repository, err := NewFileRepository(fs, testStoragePath, WithCompression(storage.CompressionZstd), WithFormatUpgrade())
+	require.NoError(t, err)
+	require.NoError(t, repository.SaveEntry(ctx, 1, "id1", "https://ya.ru"))
+	require.NoError(t, repository.Close())
+
+	header, ok, err := storage.DetectFileHeader(fs, testStoragePath)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, storage.NewFileHeader(storage.CompressionZstd), header)
+
+	reopened, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	for _, shortID := range []string{"89dce6a4", "ec2c0086", "id1"} {
+		_, ok := reopened.GetFullURL(ctx, shortID)
+		assert.True(t, ok, shortID)
+	}
+}
+
+func TestFileRepositoryDeletedUsersPersistence(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	fs.MkdirAll("/home/test", 0755)
+	testStoragePath := "/home/test/storage.json"
+
+	repository, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	userID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	require.NoError(t, repository.DeleteUser(ctx, userID))
+	require.NoError(t, repository.Close())
+
+	reopened, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+
+	deletedUsers, err := reopened.DeletedUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{userID}, deletedUsers)
+
+	// пометка удаления переносится в снимок при сжатии
+	require.NoError(t, reopened.Compact(ctx))
+	require.NoError(t, reopened.Close())
+
+	compacted, err := NewFileRepository(fs, testStoragePath)
+	require.NoError(t, err)
+	defer compacted.Close()
+
+	deletedUsers, err = compacted.DeletedUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{userID}, deletedUsers)
+
+	newUserID, err := compacted.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, newUserID, userID)
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"strconv"
+)
+
+// hashRingVirtualNodes количество точек каждого шарда на кольце; чем их больше,
+// тем равномернее ключи распределяются между шардами
+const hashRingVirtualNodes = 256
+
+// ringPoint точка шарда на кольце
+type ringPoint struct {
+	hash  uint64
+	shard int
+}
+
+// hashRing кольцо консистентного хеширования.
+// Шарды идентифицируются порядковым номером, поэтому при добавлении шарда в конец
+// на новый шард переходит примерно 1/N ключей, а остальные остаются на месте.
+type hashRing struct {
+	points []ringPoint
+}
+
+func newHashRing(shardCount int) *hashRing {
+	points := make([]ringPoint, 0, shardCount*hashRingVirtualNodes)
+	for shard := 0; shard < shardCount; shard++ {
+		for node := 0; node < hashRingVirtualNodes; node++ {
+			points = append(points, ringPoint{
+				hash:  ringHash("shard-" + strconv.Itoa(shard) + "#" + strconv.Itoa(node)),
+				shard: shard,
+			})
+		}
+	}
+
+	slices.SortFunc(points, func(a, b ringPoint) int {
+		if a.hash < b.hash {
+			return -1
+		} else if a.hash > b.hash {
+			return 1
+		}
+		return a.shard - b.shard
+	})
+
+	return &hashRing{points: points}
+}
+
+// locate возвращает номер шарда ключа: первую точку кольца по часовой стрелке от хеша ключа
+func (r *hashRing) locate(key string) int {
+	hash := ringHash(key)
+	i, _ := slices.BinarySearchFunc(r.points, hash, func(point ringPoint, hash uint64) int {
+		if point.hash < hash {
+			return -1
+		} else if point.hash > hash {
+			return 1
+		}
+		return 0
+	})
+
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.points[i].shard
+}
+
+func ringHash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+
+	return binary.BigEndian.Uint64(sum[:8])
+}
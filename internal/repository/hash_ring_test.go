@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashRingDistribution(t *testing.T) {
+	const keyCount = 30000
+	ring := newHashRing(3)
+
+	counts := make([]int, 3)
+	for i := 0; i < keyCount; i++ {
+		counts[ring.locate(strconv.Itoa(i))]++
+	}
+
+	for shard, count := range counts {
+		assert.InDelta(t, keyCount/3, count, keyCount/3*0.15, "shard %d", shard)
+	}
+}
+
+func TestHashRingAddShard(t *testing.T) {
+	const keyCount = 30000
+	before := newHashRing(3)
+	after := newHashRing(4)
+
+	moved := 0
+	for i := 0; i < keyCount; i++ {
+		key := strconv.Itoa(i)
+		from, to := before.locate(key), after.locate(key)
+		if from != to {
+			moved++
+			assert.Equal(t, 3, to, "keys only move to the new shard")
+		}
+	}
+
+	assert.InDelta(t, keyCount/4, moved, keyCount/4*0.15)
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"sync"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// urlIndex потокобезопасный двунаправленный индекс сокращенных ссылок:
+// shortID -> информация о ссылке, полная ссылка в пространстве имен домена -> shortID
+// и пользователь -> набор shortID.
+// Все индексы изменяются под одной блокировкой, поэтому всегда согласованы между собой.
+type urlIndex struct {
+	mutex     sync.RWMutex
+	byShortID map[string]ShortenedURLInfo
+	// byFullURL shortID по ключу уникальности полной ссылки (см. domainURLKey)
+	byFullURL map[string]string
+	byUser    map[int]map[string]struct{}
+}
+
+func newURLIndex() *urlIndex {
+	return &urlIndex{
+		byShortID: make(map[string]ShortenedURLInfo),
+		byFullURL: make(map[string]string),
+		byUser:    make(map[int]map[string]struct{}),
+	}
+}
+
+// get возвращает информацию о ссылке по shortID
+func (i *urlIndex) get(shortID string) (ShortenedURLInfo, bool) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	info, ok := i.byShortID[shortID]
+
+	return info, ok
+}
+
+// lookupShortID возвращает shortID по полной ссылке в пространстве имен домена
+func (i *urlIndex) lookupShortID(domain string, fullURL string) (string, bool) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	shortID, ok := i.byFullURL[domainURLKey(domain, fullURL)]
+
+	return shortID, ok
+}
+
+// userEntries возвращает ссылки пользователя
+func (i *urlIndex) userEntries(userID int) URLMapping {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	shortIDMap := make(URLMapping, len(i.byUser[userID]))
+	for shortID := range i.byUser[userID] {
+		shortIDMap[shortID] = i.byShortID[shortID].FullURL
+	}
+
+	return shortIDMap
+}
+
+// userInfos возвращает информацию о ссылках пользователя
+func (i *urlIndex) userInfos(userID int) []ShortenedURLInfo {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	infos := make([]ShortenedURLInfo, 0, len(i.byUser[userID]))
+	for shortID := range i.byUser[userID] {
+		infos = append(infos, i.byShortID[shortID])
+	}
+
+	return infos
+}
+
+// insert добавляет ссылку, если ни shortID, ни полная ссылка в домене ссылки еще не заняты
+func (i *urlIndex) insert(info ShortenedURLInfo) bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.isConflict(info) {
+		return false
+	}
+
+	i.add(info)
+
+	return true
+}
+
+// insertBatch атомарно добавляет ссылки без конфликтов и возвращает добавленные
+func (i *urlIndex) insertBatch(infos []ShortenedURLInfo) []ShortenedURLInfo {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	inserted := make([]ShortenedURLInfo, 0, len(infos))
+	for _, info := range infos {
+		if i.isConflict(info) {
+			continue
+		}
+
+		i.add(info)
+		inserted = append(inserted, info)
+	}
+
+	return inserted
+}
+
+// put добавляет или заменяет ссылку без проверки конфликтов.
+// Пометка удаления и счетчик переходов ссылки с тем же shortID и полной ссылкой не уменьшаются:
+// ссылки не восстанавливаются после удаления, а переходы не отменяются.
+func (i *urlIndex) put(info ShortenedURLInfo) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if existing, ok := i.byShortID[info.ShortID]; ok {
+		if existing.FullURL == info.FullURL {
+			info.IsDeleted = info.IsDeleted || existing.IsDeleted
+			info.Clicks = max(info.Clicks, existing.Clicks)
+		}
+
+		i.remove(existing)
+	}
+
+	if shortID, ok := i.byFullURL[linkURLKey(info.ShortID, info.FullURL)]; ok {
+		i.remove(i.byShortID[shortID])
+	}
+
+	i.add(info)
+}
+
+// markDeleted атомарно помечает удаленными ссылки из запросов
+// и возвращает фактически примененные запросы
+func (i *urlIndex) markDeleted(deleteRequests []models.UserDeleteRequest) []models.UserDeleteRequest {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	deleted := make([]models.UserDeleteRequest, 0, len(deleteRequests))
+	for _, request := range deleteRequests {
+		info, ok := i.byShortID[request.ShortIDToDelete]
+		if !ok || info.UserID != request.UserID || info.IsDeleted {
+			continue
+		}
+
+		info.IsDeleted = true
+		i.byShortID[info.ShortID] = info
+		deleted = append(deleted, request)
+	}
+
+	return deleted
+}
+
+// unmarkDeleted снимает пометку удаления, установленную markDeleted по тем же запросам
+func (i *urlIndex) unmarkDeleted(deleteRequests []models.UserDeleteRequest) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	for _, request := range deleteRequests {
+		info, ok := i.byShortID[request.ShortIDToDelete]
+		if !ok || info.UserID != request.UserID {
+			continue
+		}
+
+		info.IsDeleted = false
+		i.byShortID[info.ShortID] = info
+	}
+}
+
+// addClicks атомарно увеличивает счетчики переходов и возвращает новые значения измененных счетчиков
+func (i *urlIndex) addClicks(clicks map[string]int64) map[string]int64 {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	totals := make(map[string]int64, len(clicks))
+	for shortID, count := range clicks {
+		info, ok := i.byShortID[shortID]
+		if !ok {
+			continue
+		}
+
+		info.Clicks += count
+		i.byShortID[shortID] = info
+		totals[shortID] = info.Clicks
+	}
+
+	return totals
+}
+
+// raiseClicks увеличивает счетчик переходов ссылки до clicks, если он меньше
+func (i *urlIndex) raiseClicks(shortID string, clicks int64) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	info, ok := i.byShortID[shortID]
+	if !ok || info.Clicks >= clicks {
+		return
+	}
+
+	info.Clicks = clicks
+	i.byShortID[shortID] = info
+}
+
+// purge удаляет ссылки по shortID
+func (i *urlIndex) purge(shortIDs []string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	for _, shortID := range shortIDs {
+		if info, ok := i.byShortID[shortID]; ok {
+			i.remove(info)
+		}
+	}
+}
+
+// shortIDs возвращает shortID всех ссылок
+func (i *urlIndex) shortIDs() []string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	shortIDs := make([]string, 0, len(i.byShortID))
+	for shortID := range i.byShortID {
+		shortIDs = append(shortIDs, shortID)
+	}
+
+	return shortIDs
+}
+
+// all возвращает копию всех ссылок
+func (i *urlIndex) all() []ShortenedURLInfo {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	infos := make([]ShortenedURLInfo, 0, len(i.byShortID))
+	for _, info := range i.byShortID {
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+func (i *urlIndex) isConflict(info ShortenedURLInfo) bool {
+	_, shortIDExists := i.byShortID[info.ShortID]
+	_, fullURLExists := i.byFullURL[linkURLKey(info.ShortID, info.FullURL)]
+
+	return shortIDExists || fullURLExists
+}
+
+func (i *urlIndex) add(info ShortenedURLInfo) {
+	i.byShortID[info.ShortID] = info
+	i.byFullURL[linkURLKey(info.ShortID, info.FullURL)] = info.ShortID
+
+	userShortIDs, ok := i.byUser[info.UserID]
+	if !ok {
+		userShortIDs = make(map[string]struct{})
+		i.byUser[info.UserID] = userShortIDs
+	}
+	userShortIDs[info.ShortID] = struct{}{}
+}
+
+func (i *urlIndex) remove(info ShortenedURLInfo) {
+	delete(i.byShortID, info.ShortID)
+	delete(i.byFullURL, linkURLKey(info.ShortID, info.FullURL))
+
+	if userShortIDs, ok := i.byUser[info.UserID]; ok {
+		delete(userShortIDs, info.ShortID)
+		if len(userShortIDs) == 0 {
+			delete(i.byUser, info.UserID)
+		}
+	}
+}
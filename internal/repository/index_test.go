@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+func TestURLIndexInsert(t *testing.T) {
+	index := newURLIndex()
+
+	require.True(t, index.insert(ShortenedURLInfo{UserID: 1, ShortID: "1", FullURL: "http://example.com/1"}))
+	assert.False(t, index.insert(ShortenedURLInfo{UserID: 2, ShortID: "1", FullURL: "http://example.com/2"}))
+	assert.False(t, index.insert(ShortenedURLInfo{UserID: 2, ShortID: "2", FullURL: "http://example.com/1"}))
+
+	shortID, ok := index.lookupShortID("", "http://example.com/1")
+	require.True(t, ok)
+	assert.Equal(t, "1", shortID)
+
+	_, ok = index.lookupShortID("", "http://example.com/2")
+	assert.False(t, ok)
+
+	assert.Equal(t, URLMapping{"1": "http://example.com/1"}, index.userEntries(1))
+	assert.Empty(t, index.userEntries(2))
+}
+
+func TestURLIndexPut(t *testing.T) {
+	index := newURLIndex()
+	index.insert(ShortenedURLInfo{UserID: 1, ShortID: "1", FullURL: "http://example.com/1"})
+	index.insert(ShortenedURLInfo{UserID: 1, ShortID: "2", FullURL: "http://example.com/2"})
+
+	// замена записи удаляет устаревшие связи во всех индексах
+	index.put(ShortenedURLInfo{UserID: 2, ShortID: "1", FullURL: "http://example.com/2"})
+
+	_, ok := index.lookupShortID("", "http://example.com/1")
+	assert.False(t, ok)
+	_, ok = index.get("2")
+	assert.False(t, ok)
+
+	shortID, ok := index.lookupShortID("", "http://example.com/2")
+	require.True(t, ok)
+	assert.Equal(t, "1", shortID)
+
+	assert.Empty(t, index.userEntries(1))
+	assert.Equal(t, URLMapping{"1": "http://example.com/2"}, index.userEntries(2))
+	assert.Len(t, index.all(), 1)
+}
+
+func TestURLIndexMarkDeleted(t *testing.T) {
+	index := newURLIndex()
+	index.insert(ShortenedURLInfo{UserID: 1, ShortID: "1", FullURL: "http://example.com/1"})
+
+	deleted := index.markDeleted([]models.UserDeleteRequest{
+		{UserID: 2, ShortIDToDelete: "1"},
+		{UserID: 1, ShortIDToDelete: "1"},
+		{UserID: 1, ShortIDToDelete: "1"},
+		{UserID: 1, ShortIDToDelete: "unknown"},
+	})
+	assert.Equal(t, []models.UserDeleteRequest{{UserID: 1, ShortIDToDelete: "1"}}, deleted)
+
+	info, ok := index.get("1")
+	require.True(t, ok)
+	assert.True(t, info.IsDeleted)
+
+	// удаленная ссылка остается в обратном индексе
+	shortID, ok := index.lookupShortID("", "http://example.com/1")
+	require.True(t, ok)
+	assert.Equal(t, "1", shortID)
+}
+
+func BenchmarkGetShortID(b *testing.B) {
+	const count = 1_000_000
+
+	lookupURL := "http://example.com/" + strconv.Itoa(count-1)
+
+	b.Run("SyncMapRange", func(b *testing.B) {
+		var entries sync.Map
+		for i := 0; i < count; i++ {
+			id := strconv.Itoa(i)
+			entries.Store(id, ShortenedURLInfo{ShortID: id, FullURL: "http://example.com/" + id})
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var shortID string
+			entries.Range(func(key, value any) bool {
+				if value.(ShortenedURLInfo).FullURL == lookupURL {
+					shortID = key.(string)
+					return false
+				}
+				return true
+			})
+			_ = shortID
+		}
+	})
+
+	b.Run("URLIndex", func(b *testing.B) {
+		index := newURLIndex()
+		for i := 0; i < count; i++ {
+			id := strconv.Itoa(i)
+			index.insert(ShortenedURLInfo{ShortID: id, FullURL: "http://example.com/" + id})
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = index.lookupShortID("", lookupURL)
+		}
+	})
+}
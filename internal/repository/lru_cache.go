@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache потокобезопасный кэш информации о ссылках по shortID с ограниченным размером
+// и вытеснением давно не использованных записей. Значение nil означает отсутствие ссылки
+// в хранилище (негативное кэширование).
+type lruCache struct {
+	mutex   sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	// version увеличивается при каждой инвалидации и позволяет не сохранять
+	// в кэш значения, прочитанные из хранилища до инвалидации
+	version uint64
+	now     func() time.Time
+}
+
+type lruCacheEntry struct {
+	shortID   string
+	info      *ShortenedURLInfo
+	expiresAt time.Time
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get возвращает закэшированное значение и флаг его наличия в кэше.
+// Устаревшие записи удаляются.
+func (c *lruCache) get(shortID string) (info *ShortenedURLInfo, found bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[shortID]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruCacheEntry)
+	if !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt) {
+		c.removeElement(element)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+
+	return entry.info, true
+}
+
+// currentVersion возвращает версию кэша для последующего вызова set
+func (c *lruCache) currentVersion() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.version
+}
+
+// set сохраняет значение на время ttl (0 - без ограничения), если с момента получения
+// version кэш не инвалидировался. При переполнении вытесняется давно не использованная запись.
+func (c *lruCache) set(shortID string, info *ShortenedURLInfo, ttl time.Duration, version uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.version != version {
+		return
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = c.now().Add(ttl)
+	}
+
+	if element, ok := c.entries[shortID]; ok {
+		entry := element.Value.(*lruCacheEntry)
+		entry.info = info
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[shortID] = c.order.PushFront(&lruCacheEntry{
+		shortID:   shortID,
+		info:      info,
+		expiresAt: expiresAt,
+	})
+
+	if c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// invalidate удаляет записи по shortID
+func (c *lruCache) invalidate(shortIDs ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.version++
+	for _, shortID := range shortIDs {
+		if element, ok := c.entries[shortID]; ok {
+			c.removeElement(element)
+		}
+	}
+}
+
+// len возвращает количество записей в кэше
+func (c *lruCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *lruCache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*lruCacheEntry).shortID)
+}
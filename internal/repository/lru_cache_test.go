@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+	cache := newLRUCache(2)
+
+	cache.set("1", &ShortenedURLInfo{ShortID: "1"}, 0, cache.currentVersion())
+	cache.set("2", &ShortenedURLInfo{ShortID: "2"}, 0, cache.currentVersion())
+
+	// "1" становится недавно использованной записью, вытесняется "2"
+	_, found := cache.get("1")
+	assert.True(t, found)
+
+	cache.set("3", nil, 0, cache.currentVersion())
+	assert.Equal(t, 2, cache.len())
+
+	_, found = cache.get("2")
+	assert.False(t, found)
+
+	info, found := cache.get("3")
+	assert.True(t, found)
+	assert.Nil(t, info)
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	now := time.Now()
+	cache := newLRUCache(10)
+	cache.now = func() time.Time { return now }
+
+	cache.set("1", &ShortenedURLInfo{ShortID: "1"}, time.Minute, cache.currentVersion())
+	cache.set("2", &ShortenedURLInfo{ShortID: "2"}, 0, cache.currentVersion())
+
+	now = now.Add(time.Minute)
+
+	_, found := cache.get("1")
+	assert.False(t, found)
+
+	_, found = cache.get("2")
+	assert.True(t, found)
+	assert.Equal(t, 1, cache.len())
+}
+
+func TestLRUCacheInvalidate(t *testing.T) {
+	cache := newLRUCache(10)
+
+	version := cache.currentVersion()
+	cache.set("1", &ShortenedURLInfo{ShortID: "1"}, 0, version)
+	cache.invalidate("1", "unknown")
+
+	_, found := cache.get("1")
+	assert.False(t, found)
+
+	// значение, прочитанное до инвалидации, не попадает в кэш
+	cache.set("1", &ShortenedURLInfo{ShortID: "1"}, 0, version)
+	_, found = cache.get("1")
+	assert.False(t, found)
+}
@@ -2,43 +2,44 @@ package repository
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rovany706/url-shortener/internal/models"
 )
 
 // MemoryRepository репозиторий, хранящий информацию в памяти
 type MemoryRepository struct {
-	shortURLMap sync.Map
+	index        *urlIndex
+	lastUserID   atomic.Int64
+	deletedUsers sync.Map
 }
 
 // NewMemoryRepository инициализирует работу с хранилищем в памяти
 func NewMemoryRepository() *MemoryRepository {
-	return &MemoryRepository{}
+	return &MemoryRepository{
+		index: newURLIndex(),
+	}
 }
 
 // GetFullURL ищет в хранилище полную ссылку на ресурс по короткому ID
 func (r *MemoryRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
-	v, ok := r.shortURLMap.Load(shortID)
-
-	if ok {
-		fullURL := v.(string)
-		shortenedURLInfo = &ShortenedURLInfo{
-			UserID:    1,
-			FullURL:   fullURL,
-			ShortID:   shortID,
-			IsDeleted: false,
-		}
+	info, ok := r.index.get(shortID)
+	if !ok {
+		return nil, false
 	}
 
-	return shortenedURLInfo, ok
+	return &info, true
 }
 
-// SaveEntry сохраняет в хранилище информацию о сокращенной ссылке
+// SaveEntry сохраняет в хранилище информацию о сокращенной ссылке.
+// Возвращает ErrConflict, если ссылка или короткий ID уже сохранены.
 func (r *MemoryRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
-	r.shortURLMap.LoadOrStore(shortID, fullURL)
+	_, err := r.saveEntry(userID, shortID, fullURL)
 
-	return nil
+	return err
 }
 
 // Close завершает работу с хранилищем
@@ -51,43 +52,264 @@ func (r *MemoryRepository) Ping(ctx context.Context) error {
 	return ErrPingNotSupported
 }
 
-// SaveEntries записывает набор сокращенных ссылок
-func (r *MemoryRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) error {
-	for shortID, fullURL := range shortIDMap {
-		err := r.SaveEntry(ctx, userID, shortID, fullURL)
+// SaveEntries записывает набор сокращенных ссылок.
+// Уже сохраненные ссылки пропускаются и возвращаются как конфликтующие.
+func (r *MemoryRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	saved := r.saveNewEntries(userID, shortIDMap)
 
-		if err != nil {
-			return err
-		}
+	return conflictingEntries(shortIDMap, savedShortIDs(saved)), nil
+}
+
+// GetShortID возвращает shortID сокращенной ссылки в пространстве имен домена.
+// Возвращает ErrNotFound, если ссылка не сохранена.
+func (r *MemoryRepository) GetShortID(ctx context.Context, domain string, fullURL string) (shortID string, err error) {
+	shortID, ok := r.index.lookupShortID(domain, fullURL)
+	if !ok {
+		return "", ErrNotFound
 	}
 
-	return nil
+	return shortID, nil
 }
 
-// GetShortID возвращает shortID сокращенной ссылки
-func (r *MemoryRepository) GetShortID(ctx context.Context, fullURL string) (shortID string, err error) {
-	r.shortURLMap.Range(func(id, url any) bool {
-		if url.(string) == fullURL {
-			shortID = id.(string)
-			return false
+// ForEachShortID вызывает fn для shortID каждой сохраненной ссылки
+func (r *MemoryRepository) ForEachShortID(ctx context.Context, fn func(shortID string) error) error {
+	for _, shortID := range r.index.shortIDs() {
+		if err := fn(shortID); err != nil {
+			return err
 		}
-		return true
-	})
+	}
 
-	return
+	return nil
 }
 
 // GetUserEntries возвращает сокращенный пользователем ссылки по userID
 func (r *MemoryRepository) GetUserEntries(ctx context.Context, userID int) (shortIDMap URLMapping, err error) {
-	return nil, ErrNotImplemented
+	return r.index.userEntries(userID), nil
+}
+
+// ListUserEntries возвращает ссылки пользователя в порядке и с ограничениями query
+func (r *MemoryRepository) ListUserEntries(ctx context.Context, userID int, query UserEntriesQuery) (entries []ShortenedURLInfo, err error) {
+	return query.page(r.index.userInfos(userID)), nil
+}
+
+// AddClicks увеличивает счетчики переходов по ссылкам
+func (r *MemoryRepository) AddClicks(ctx context.Context, clicks map[string]int64) error {
+	r.index.addClicks(clicks)
+
+	return nil
 }
 
 // GetNewUserID возвращает ID нового пользователя
 func (r *MemoryRepository) GetNewUserID(ctx context.Context) (userID int, err error) {
-	return -1, nil
+	return int(r.lastUserID.Add(1)), nil
 }
 
-// DeleteUserURLs удаляет набор сокращенных ссылок
+// DeleteUserURLs помечает удаленными ссылки, принадлежащие пользователям из запросов
 func (r *MemoryRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
+	r.deleteEntries(deleteRequests)
+
+	return nil
+}
+
+// DeleteUser помечает пользователя удаленным
+func (r *MemoryRepository) DeleteUser(ctx context.Context, userID int) error {
+	r.deleteUser(userID)
+
 	return nil
 }
+
+// DeletedUsers возвращает ID удаленных пользователей в порядке возрастания
+func (r *MemoryRepository) DeletedUsers(ctx context.Context) (userIDs []int, err error) {
+	userIDs = make([]int, 0)
+	r.deletedUsers.Range(func(key, value any) bool {
+		userIDs = append(userIDs, key.(int))
+		return true
+	})
+	sort.Ints(userIDs)
+
+	return userIDs, nil
+}
+
+// deleteUser помечает пользователя удаленным и возвращает false, если он уже был удален
+func (r *MemoryRepository) deleteUser(userID int) bool {
+	_, loaded := r.deletedUsers.LoadOrStore(userID, struct{}{})
+	r.restoreUserID(userID)
+
+	return !loaded
+}
+
+// saveEntry сохраняет ссылку с текущим временем создания и возвращает сохраненную запись.
+// Возвращает ErrConflict, если ссылка или короткий ID уже сохранены.
+func (r *MemoryRepository) saveEntry(userID int, shortID string, fullURL string) (ShortenedURLInfo, error) {
+	info := ShortenedURLInfo{
+		UserID:    userID,
+		ShortID:   shortID,
+		FullURL:   fullURL,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if !r.index.insert(info) {
+		return ShortenedURLInfo{}, ErrConflict
+	}
+
+	r.restoreUserID(userID)
+
+	return info, nil
+}
+
+// saveNewEntries сохраняет ссылки без конфликтов и возвращает сохраненные записи
+func (r *MemoryRepository) saveNewEntries(userID int, shortIDMap URLMapping) []ShortenedURLInfo {
+	now := time.Now().UTC()
+	infos := make([]ShortenedURLInfo, 0, len(shortIDMap))
+	for shortID, fullURL := range shortIDMap {
+		infos = append(infos, ShortenedURLInfo{
+			UserID:    userID,
+			ShortID:   shortID,
+			FullURL:   fullURL,
+			CreatedAt: now,
+		})
+	}
+
+	saved := r.index.insertBatch(infos)
+	if len(saved) > 0 {
+		r.restoreUserID(userID)
+	}
+
+	return saved
+}
+
+// savedShortIDs возвращает множество shortID сохраненных записей
+func savedShortIDs(saved []ShortenedURLInfo) map[string]struct{} {
+	shortIDs := make(map[string]struct{}, len(saved))
+	for _, info := range saved {
+		shortIDs[info.ShortID] = struct{}{}
+	}
+
+	return shortIDs
+}
+
+// infoShortIDs возвращает shortID записей
+func infoShortIDs(infos []ShortenedURLInfo) []string {
+	shortIDs := make([]string, len(infos))
+	for i, info := range infos {
+		shortIDs[i] = info.ShortID
+	}
+
+	return shortIDs
+}
+
+// deleteEntries помечает ссылки удаленными и возвращает фактически примененные запросы
+func (r *MemoryRepository) deleteEntries(deleteRequests []models.UserDeleteRequest) []models.UserDeleteRequest {
+	return r.index.markDeleted(deleteRequests)
+}
+
+// restore восстанавливает запись без проверки конфликтов (при чтении хранилища)
+func (r *MemoryRepository) restore(info ShortenedURLInfo) {
+	r.index.put(info)
+	r.restoreUserID(info.UserID)
+}
+
+// restoreClicks прибавляет переходы к счетчику (при чтении записей хранилища прежних версий)
+func (r *MemoryRepository) restoreClicks(shortID string, clicks int64) {
+	r.index.addClicks(map[string]int64{shortID: clicks})
+}
+
+// restoreClickCount восстанавливает значение счетчика переходов (при чтении хранилища)
+func (r *MemoryRepository) restoreClickCount(shortID string, clicks int64) {
+	r.index.raiseClicks(shortID, clicks)
+}
+
+// restoreDeleted восстанавливает пометку удаления (при чтении хранилища)
+func (r *MemoryRepository) restoreDeleted(userID int, shortID string) {
+	r.index.markDeleted([]models.UserDeleteRequest{{UserID: userID, ShortIDToDelete: shortID}})
+}
+
+// restoreDeletedUser восстанавливает пометку удаления пользователя (при чтении хранилища)
+func (r *MemoryRepository) restoreDeletedUser(userID int) {
+	r.deleteUser(userID)
+}
+
+// restoreUserID восстанавливает счетчик ID пользователей (при чтении хранилища)
+func (r *MemoryRepository) restoreUserID(userID int) {
+	for {
+		lastUserID := r.lastUserID.Load()
+		if int64(userID) <= lastUserID || r.lastUserID.CompareAndSwap(lastUserID, int64(userID)) {
+			return
+		}
+	}
+}
+
+// snapshot возвращает копию всех записей и счетчика ID пользователей
+func (r *MemoryRepository) snapshot() (entries []ShortenedURLInfo, lastUserID int) {
+	return r.index.all(), int(r.lastUserID.Load())
+}
+
+// ForEachEntry вызывает fn для каждой ссылки с shortID больше afterShortID в порядке возрастания shortID
+func (r *MemoryRepository) ForEachEntry(ctx context.Context, afterShortID string, fn func(info ShortenedURLInfo) error) error {
+	infos := r.index.all()
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ShortID < infos[j].ShortID
+	})
+
+	for _, info := range infos {
+		if info.ShortID <= afterShortID {
+			continue
+		}
+
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LastUserID возвращает наибольший выданный ID пользователя
+func (r *MemoryRepository) LastUserID(ctx context.Context) (int, error) {
+	return int(r.lastUserID.Load()), nil
+}
+
+// ImportEntries сохраняет ссылки с их владельцами и пометками удаления
+func (r *MemoryRepository) ImportEntries(ctx context.Context, entries []ShortenedURLInfo) error {
+	r.importEntries(entries)
+
+	return nil
+}
+
+// ReserveUserIDs гарантирует, что новые ID пользователей будут больше lastUserID
+func (r *MemoryRepository) ReserveUserIDs(ctx context.Context, lastUserID int) error {
+	r.restoreUserID(lastUserID)
+
+	return nil
+}
+
+// PurgeEntries удаляет ссылки из хранилища
+func (r *MemoryRepository) PurgeEntries(ctx context.Context, shortIDs []string) error {
+	r.index.purge(shortIDs)
+
+	return nil
+}
+
+// importEntries сохраняет ссылки без конфликтов и переносит пометки удаления на уже сохраненные.
+// Возвращает добавленные ссылки и фактически примененные пометки удаления.
+func (r *MemoryRepository) importEntries(entries []ShortenedURLInfo) (inserted []ShortenedURLInfo, deleted []models.UserDeleteRequest) {
+	inserted = r.index.insertBatch(entries)
+
+	insertedShortIDs := savedShortIDs(inserted)
+	deleteRequests := make([]models.UserDeleteRequest, 0)
+	for _, info := range entries {
+		if _, ok := insertedShortIDs[info.ShortID]; !ok && info.IsDeleted {
+			deleteRequests = append(deleteRequests, models.UserDeleteRequest{
+				UserID:          info.UserID,
+				ShortIDToDelete: info.ShortID,
+			})
+		}
+	}
+	deleted = r.index.markDeleted(deleteRequests)
+
+	for _, info := range inserted {
+		r.restoreUserID(info.UserID)
+	}
+
+	return inserted, deleted
+}
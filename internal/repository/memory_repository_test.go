@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+func TestMemoryRepositorySaveEntry(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository()
+
+	err := repository.SaveEntry(ctx, 1, "id1", "http://example.com")
+	require.NoError(t, err)
+
+	err = repository.SaveEntry(ctx, 2, "id2", "http://example.com")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	err = repository.SaveEntry(ctx, 2, "id1", "http://example1.com")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	info, ok := repository.GetFullURL(ctx, "id1")
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now(), info.CreatedAt, time.Minute)
+	assert.Equal(t, ShortenedURLInfo{UserID: 1, ShortID: "id1", FullURL: "http://example.com", CreatedAt: info.CreatedAt}, *info)
+
+	_, ok = repository.GetFullURL(ctx, "id2")
+	assert.False(t, ok)
+
+	shortID, err := repository.GetShortID(ctx, "", "http://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "id1", shortID)
+}
+
+func TestMemoryRepositorySaveEntries(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository()
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "id1", "http://example.com"))
+
+	conflicts, err := repository.SaveEntries(ctx, 2, URLMapping{
+		"id1": "http://example.com",
+		"id2": "http://example2.com",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, URLMapping{"id1": "http://example.com"}, conflicts)
+
+	entries, err := repository.GetUserEntries(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, URLMapping{"id2": "http://example2.com"}, entries)
+
+	entries, err = repository.GetUserEntries(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, URLMapping{"id1": "http://example.com"}, entries)
+
+	entries, err = repository.GetUserEntries(ctx, 3)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestMemoryRepositoryDeleteUserURLs(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository()
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "id1", "http://example.com/1"))
+	require.NoError(t, repository.SaveEntry(ctx, 2, "id2", "http://example.com/2"))
+
+	err := repository.DeleteUserURLs(ctx, []models.UserDeleteRequest{
+		{UserID: 1, ShortIDToDelete: "id1"},
+		{UserID: 1, ShortIDToDelete: "id2"},
+		{UserID: 1, ShortIDToDelete: "missing"},
+	})
+	require.NoError(t, err)
+
+	info, ok := repository.GetFullURL(ctx, "id1")
+	require.True(t, ok)
+	assert.True(t, info.IsDeleted)
+
+	info, ok = repository.GetFullURL(ctx, "id2")
+	require.True(t, ok)
+	assert.False(t, info.IsDeleted, "links of other users must not be deleted")
+}
+
+func TestMemoryRepositoryGetNewUserID(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository()
+
+	const count = 100
+	var wg sync.WaitGroup
+	ids := make(chan int, count)
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			userID, err := repository.GetNewUserID(ctx)
+			assert.NoError(t, err)
+			ids <- userID
+		}()
+	}
+
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]struct{}, count)
+	for userID := range ids {
+		assert.Positive(t, userID)
+		seen[userID] = struct{}{}
+	}
+	assert.Len(t, seen, count)
+}
@@ -0,0 +1,41 @@
+package repository
+
+import "context"
+
+// EntryExporter хранилище, поддерживающее выгрузку всех ссылок для переноса в другое хранилище
+type EntryExporter interface {
+	// ForEachEntry вызывает fn для каждой ссылки с shortID больше afterShortID
+	// в порядке возрастания shortID (побайтовое сравнение строк).
+	// Ошибка fn прерывает обход и возвращается вызывающему.
+	ForEachEntry(ctx context.Context, afterShortID string, fn func(info ShortenedURLInfo) error) error
+	// LastUserID возвращает наибольший выданный ID пользователя
+	LastUserID(ctx context.Context) (int, error)
+	// DeletedUsers возвращает ID удаленных пользователей в порядке возрастания
+	DeletedUsers(ctx context.Context) (userIDs []int, err error)
+}
+
+// EntryImporter хранилище, поддерживающее загрузку ссылок из другого хранилища
+type EntryImporter interface {
+	// ImportEntries сохраняет ссылки с их владельцами и пометками удаления.
+	// Ссылки, чей shortID или полная ссылка уже сохранены, не добавляются,
+	// но пометка удаления переносится на сохраненную ссылку того же владельца,
+	// поэтому повторная загрузка тех же ссылок не изменяет хранилище.
+	ImportEntries(ctx context.Context, entries []ShortenedURLInfo) error
+	// ReserveUserIDs гарантирует, что новые ID пользователей будут больше lastUserID
+	ReserveUserIDs(ctx context.Context, lastUserID int) error
+	// DeleteUser помечает пользователя удаленным, повторная пометка не изменяет хранилище.
+	// Хранилища UserImporter помечают только сохраненных пользователей.
+	DeleteUser(ctx context.Context, userID int) error
+}
+
+// UserImporter хранилище, в котором пользователь должен быть сохранен до сохранения его ссылок
+type UserImporter interface {
+	// ImportUsers сохраняет пользователей с заданными ID, уже сохраненные пропускаются
+	ImportUsers(ctx context.Context, userIDs []int) error
+}
+
+// EntryPurger хранилище, поддерживающее безвозвратное удаление ссылок
+type EntryPurger interface {
+	// PurgeEntries удаляет ссылки из хранилища вместе с пометками удаления
+	PurgeEntries(ctx context.Context, shortIDs []string) error
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func collectEntries(t *testing.T, repository EntryExporter, afterShortID string) []ShortenedURLInfo {
+	t.Helper()
+
+	entries := make([]ShortenedURLInfo, 0)
+	err := repository.ForEachEntry(context.Background(), afterShortID, func(info ShortenedURLInfo) error {
+		entries = append(entries, info)
+		return nil
+	})
+	require.NoError(t, err)
+
+	return entries
+}
@@ -43,6 +43,20 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddClicks mocks base method.
+func (m *MockRepository) AddClicks(ctx context.Context, clicks map[string]int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddClicks", ctx, clicks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddClicks indicates an expected call of AddClicks.
+func (mr *MockRepositoryMockRecorder) AddClicks(ctx, clicks any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddClicks", reflect.TypeOf((*MockRepository)(nil).AddClicks), ctx, clicks)
+}
+
 // Close mocks base method.
 func (m *MockRepository) Close() error {
 	m.ctrl.T.Helper()
@@ -57,6 +71,20 @@ func (mr *MockRepositoryMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close))
 }
 
+// DeleteUser mocks base method.
+func (m *MockRepository) DeleteUser(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockRepositoryMockRecorder) DeleteUser(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockRepository)(nil).DeleteUser), ctx, userID)
+}
+
 // DeleteUserURLs mocks base method.
 func (m *MockRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
 	m.ctrl.T.Helper()
@@ -71,6 +99,35 @@ func (mr *MockRepositoryMockRecorder) DeleteUserURLs(ctx, deleteRequests any) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserURLs", reflect.TypeOf((*MockRepository)(nil).DeleteUserURLs), ctx, deleteRequests)
 }
 
+// DeletedUsers mocks base method.
+func (m *MockRepository) DeletedUsers(ctx context.Context) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletedUsers", ctx)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeletedUsers indicates an expected call of DeletedUsers.
+func (mr *MockRepositoryMockRecorder) DeletedUsers(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletedUsers", reflect.TypeOf((*MockRepository)(nil).DeletedUsers), ctx)
+}
+
+// ForEachShortID mocks base method.
+func (m *MockRepository) ForEachShortID(ctx context.Context, fn func(string) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForEachShortID", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForEachShortID indicates an expected call of ForEachShortID.
+func (mr *MockRepositoryMockRecorder) ForEachShortID(ctx, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForEachShortID", reflect.TypeOf((*MockRepository)(nil).ForEachShortID), ctx, fn)
+}
+
 // GetFullURL mocks base method.
 func (m *MockRepository) GetFullURL(ctx context.Context, shortID string) (*repository.ShortenedURLInfo, bool) {
 	m.ctrl.T.Helper()
@@ -102,18 +159,18 @@ func (mr *MockRepositoryMockRecorder) GetNewUserID(ctx any) *gomock.Call {
 }
 
 // GetShortID mocks base method.
-func (m *MockRepository) GetShortID(ctx context.Context, fullURL string) (string, error) {
+func (m *MockRepository) GetShortID(ctx context.Context, domain, fullURL string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetShortID", ctx, fullURL)
+	ret := m.ctrl.Call(m, "GetShortID", ctx, domain, fullURL)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetShortID indicates an expected call of GetShortID.
-func (mr *MockRepositoryMockRecorder) GetShortID(ctx, fullURL any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetShortID(ctx, domain, fullURL any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShortID", reflect.TypeOf((*MockRepository)(nil).GetShortID), ctx, fullURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShortID", reflect.TypeOf((*MockRepository)(nil).GetShortID), ctx, domain, fullURL)
 }
 
 // GetUserEntries mocks base method.
@@ -131,6 +188,21 @@ func (mr *MockRepositoryMockRecorder) GetUserEntries(ctx, userID any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserEntries", reflect.TypeOf((*MockRepository)(nil).GetUserEntries), ctx, userID)
 }
 
+// ListUserEntries mocks base method.
+func (m *MockRepository) ListUserEntries(ctx context.Context, userID int, query repository.UserEntriesQuery) ([]repository.ShortenedURLInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserEntries", ctx, userID, query)
+	ret0, _ := ret[0].([]repository.ShortenedURLInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserEntries indicates an expected call of ListUserEntries.
+func (mr *MockRepositoryMockRecorder) ListUserEntries(ctx, userID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserEntries", reflect.TypeOf((*MockRepository)(nil).ListUserEntries), ctx, userID, query)
+}
+
 // Ping mocks base method.
 func (m *MockRepository) Ping(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -146,11 +218,12 @@ func (mr *MockRepositoryMockRecorder) Ping(ctx any) *gomock.Call {
 }
 
 // SaveEntries mocks base method.
-func (m *MockRepository) SaveEntries(ctx context.Context, userID int, shortIDMap repository.URLMapping) error {
+func (m *MockRepository) SaveEntries(ctx context.Context, userID int, shortIDMap repository.URLMapping) (repository.URLMapping, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SaveEntries", ctx, userID, shortIDMap)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(repository.URLMapping)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // SaveEntries indicates an expected call of SaveEntries.
@@ -0,0 +1,577 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// Ключи хранилища Redis
+const (
+	redisKeyPrefix = "shortener:"
+	// redisLinkKeyPrefix хеш с информацией о ссылке по shortID
+	redisLinkKeyPrefix = redisKeyPrefix + "link:"
+	// redisURLKeyPrefix shortID по полной ссылке в пространстве имен домена (см. domainURLKey)
+	redisURLKeyPrefix = redisKeyPrefix + "url:"
+	// redisUserLinksKeyPrefix множество shortID пользователя
+	redisUserLinksKeyPrefix = redisKeyPrefix + "user_links:"
+	// redisUserIDKey счетчик ID пользователей
+	redisUserIDKey = redisKeyPrefix + "user_id"
+	// redisDeletedUsersKey множество ID удаленных пользователей
+	redisDeletedUsersKey = redisKeyPrefix + "deleted_users"
+	// redisURLKeysVersionKey версия ключей redisURLKeyPrefix. Отсутствует в хранилищах,
+	// где ключом ссылок всех доменов была полная ссылка без домена.
+	redisURLKeysVersionKey = redisKeyPrefix + "url_keys_version"
+)
+
+// redisURLKeysVersion версия ключей redisURLKeyPrefix с доменом ссылки
+const redisURLKeysVersion = 1
+
+// Поля хеша ссылки
+const (
+	redisUserIDField    = "user_id"
+	redisFullURLField   = "full_url"
+	redisIsDeletedField = "is_deleted"
+	redisCreatedAtField = "created_at"
+	redisClicksField    = "clicks"
+)
+
+// redisLinkFields поля хеша ссылки в порядке, ожидаемом parseRedisLink
+var redisLinkFields = []string{redisUserIDField, redisFullURLField, redisIsDeletedField, redisCreatedAtField, redisClicksField}
+
+// redisScanCount количество ключей, запрашиваемых за одну итерацию SCAN
+const redisScanCount = 1000
+
+// redisMaxTxRetries количество повторов транзакции при изменении отслеживаемых ключей
+const redisMaxTxRetries = 10
+
+// redisAddClicksScript увеличивает поле clicks ссылок KEYS[i] на ARGV[i], пропуская удаленные из хранилища ссылки
+var redisAddClicksScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	if redis.call('EXISTS', key) == 1 then
+		redis.call('HINCRBY', key, '` + redisClicksField + `', ARGV[i])
+	end
+end
+return 0
+`)
+
+// redisDeleteUserURLsScript помечает удаленными ссылки KEYS[i], принадлежащие пользователю ARGV[i]
+var redisDeleteUserURLsScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	if redis.call('HGET', key, '` + redisUserIDField + `') == ARGV[i] then
+		redis.call('HSET', key, '` + redisIsDeletedField + `', '1')
+	end
+end
+return 0
+`)
+
+// RedisRepository репозиторий, хранящий информацию в Redis или совместимом хранилище.
+// Несколько экземпляров сервиса могут работать с одним хранилищем одновременно.
+type RedisRepository struct {
+	client *redis.Client
+}
+
+// NewRedisRepository подключается к Redis по адресу addr (host:port или redis:// URL)
+func NewRedisRepository(ctx context.Context, addr string) (*RedisRepository, error) {
+	options := &redis.Options{Addr: addr}
+	if strings.Contains(addr, "://") {
+		var err error
+		if options, err = redis.ParseURL(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	repository := &RedisRepository{client: client}
+	if err := repository.upgradeURLKeys(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+// upgradeURLKeys переносит ссылки дополнительных доменов под ключи полных ссылок с доменом ссылки.
+// Перенос идемпотентен, поэтому экземпляры сервиса, запущенные одновременно, могут выполнить его вместе.
+func (repository *RedisRepository) upgradeURLKeys(ctx context.Context) error {
+	upgraded, err := repository.client.Exists(ctx, redisURLKeysVersionKey).Result()
+	if err != nil || upgraded > 0 {
+		return err
+	}
+
+	iter := repository.client.Scan(ctx, 0, redisURLKeyPrefix+"*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		fullURL := strings.TrimPrefix(iter.Val(), redisURLKeyPrefix)
+		if strings.Contains(fullURL, domainURLSeparator) {
+			continue
+		}
+
+		shortID, err := repository.client.Get(ctx, iter.Val()).Result()
+		if errors.Is(err, redis.Nil) || (err == nil && linkDomain(shortID) == "") {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = repository.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetNX(ctx, redisURLKey(linkURLKey(shortID, fullURL)), shortID, 0)
+			pipe.Del(ctx, iter.Val())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = iter.Err(); err != nil {
+		return err
+	}
+
+	return repository.client.Set(ctx, redisURLKeysVersionKey, redisURLKeysVersion, 0).Err()
+}
+
+// GetFullURL ищет в хранилище полную ссылку на ресурс по короткому ID
+func (repository *RedisRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
+	values, err := repository.client.HMGet(ctx, redisLinkKey(shortID), redisLinkFields...).Result()
+	if err != nil {
+		return nil, false
+	}
+
+	shortenedURLInfo, ok = parseRedisLink(shortID, values)
+
+	return shortenedURLInfo, ok
+}
+
+// SaveEntry сохраняет в хранилище информацию о сокращенной ссылке.
+// Возвращает ErrConflict, если ссылка или короткий ID уже сохранены.
+func (repository *RedisRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
+	info := ShortenedURLInfo{UserID: userID, ShortID: shortID, FullURL: fullURL, CreatedAt: time.Now().UTC()}
+	_, err := repository.saveEntries(ctx, []ShortenedURLInfo{info}, true)
+
+	return err
+}
+
+// SaveEntries записывает набор сокращенных ссылок в одной транзакции MULTI.
+// Уже сохраненные ссылки пропускаются и возвращаются как конфликтующие.
+func (repository *RedisRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	if len(shortIDMap) == 0 {
+		return URLMapping{}, nil
+	}
+
+	createdAt := time.Now().UTC()
+	infos := make([]ShortenedURLInfo, 0, len(shortIDMap))
+	for shortID, fullURL := range shortIDMap {
+		infos = append(infos, ShortenedURLInfo{UserID: userID, ShortID: shortID, FullURL: fullURL, CreatedAt: createdAt})
+	}
+
+	return repository.saveEntries(ctx, infos, false)
+}
+
+// GetShortID возвращает shortID сокращенной ссылки в пространстве имен домена.
+// Возвращает ErrNotFound, если ссылка не сохранена.
+func (repository *RedisRepository) GetShortID(ctx context.Context, domain string, fullURL string) (shortID string, err error) {
+	shortID, err = repository.client.Get(ctx, redisURLKey(domainURLKey(domain, fullURL))).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return shortID, nil
+}
+
+// GetUserEntries возвращает сокращенный пользователем ссылки по userID
+func (repository *RedisRepository) GetUserEntries(ctx context.Context, userID int) (shortIDMap URLMapping, err error) {
+	shortIDs, err := repository.client.SMembers(ctx, redisUserLinksKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := repository.client.Pipeline()
+	fullURLs := make([]*redis.StringCmd, len(shortIDs))
+	for i, shortID := range shortIDs {
+		fullURLs[i] = pipe.HGet(ctx, redisLinkKey(shortID), redisFullURLField)
+	}
+
+	if _, err = pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	shortIDMap = make(URLMapping, len(shortIDs))
+	for i, shortID := range shortIDs {
+		if fullURL, err := fullURLs[i].Result(); err == nil {
+			shortIDMap[shortID] = fullURL
+		}
+	}
+
+	return shortIDMap, nil
+}
+
+// ListUserEntries возвращает ссылки пользователя в порядке и с ограничениями query.
+// Ссылки пользователя читаются целиком и упорядочиваются в памяти.
+func (repository *RedisRepository) ListUserEntries(ctx context.Context, userID int, query UserEntriesQuery) (entries []ShortenedURLInfo, err error) {
+	shortIDs, err := repository.client.SMembers(ctx, redisUserLinksKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := repository.client.Pipeline()
+	links := make([]*redis.SliceCmd, len(shortIDs))
+	for i, shortID := range shortIDs {
+		links[i] = pipe.HMGet(ctx, redisLinkKey(shortID), redisLinkFields...)
+	}
+
+	if len(shortIDs) > 0 {
+		if _, err = pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	entries = make([]ShortenedURLInfo, 0, len(shortIDs))
+	for i, shortID := range shortIDs {
+		if info, ok := parseRedisLink(shortID, links[i].Val()); ok {
+			entries = append(entries, *info)
+		}
+	}
+
+	return query.page(entries), nil
+}
+
+// AddClicks увеличивает счетчики переходов по существующим ссылкам одним скриптом.
+// Скрипт выполняется атомарно и не требует отслеживания ключей, поэтому одновременные
+// сохранения счетчиков несколькими экземплярами сервиса не повторяются.
+func (repository *RedisRepository) AddClicks(ctx context.Context, clicks map[string]int64) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(clicks))
+	counts := make([]any, 0, len(clicks))
+	for shortID, count := range clicks {
+		keys = append(keys, redisLinkKey(shortID))
+		counts = append(counts, count)
+	}
+
+	return redisAddClicksScript.Run(ctx, repository.client, keys, counts...).Err()
+}
+
+// ForEachShortID вызывает fn для shortID каждой сохраненной ссылки.
+// Ключи обходятся командой SCAN, поэтому ссылки, сохраненные во время обхода, могут быть пропущены.
+func (repository *RedisRepository) ForEachShortID(ctx context.Context, fn func(shortID string) error) error {
+	iter := repository.client.Scan(ctx, 0, redisLinkKeyPrefix+"*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		if err := fn(strings.TrimPrefix(iter.Val(), redisLinkKeyPrefix)); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
+// GetNewUserID возвращает ID нового пользователя
+func (repository *RedisRepository) GetNewUserID(ctx context.Context) (userID int, err error) {
+	id, err := repository.client.Incr(ctx, redisUserIDKey).Result()
+	if err != nil {
+		return -1, err
+	}
+
+	return int(id), nil
+}
+
+// DeleteUserURLs атомарно помечает удаленными ссылки, принадлежащие пользователям из запросов
+func (repository *RedisRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
+	if len(deleteRequests) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(deleteRequests))
+	userIDs := make([]any, len(deleteRequests))
+	for i, request := range deleteRequests {
+		keys[i] = redisLinkKey(request.ShortIDToDelete)
+		userIDs[i] = request.UserID
+	}
+
+	return redisDeleteUserURLsScript.Run(ctx, repository.client, keys, userIDs...).Err()
+}
+
+// ForEachEntry вызывает fn для каждой ссылки с shortID больше afterShortID в порядке возрастания shortID.
+// Для упорядочивания shortID всех ссылок загружаются в память.
+func (repository *RedisRepository) ForEachEntry(ctx context.Context, afterShortID string, fn func(info ShortenedURLInfo) error) error {
+	shortIDs := make([]string, 0)
+	err := repository.ForEachShortID(ctx, func(shortID string) error {
+		if shortID > afterShortID {
+			shortIDs = append(shortIDs, shortID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(shortIDs)
+
+	for start := 0; start < len(shortIDs); start += redisScanCount {
+		batch := shortIDs[start:min(start+redisScanCount, len(shortIDs))]
+
+		pipe := repository.client.Pipeline()
+		links := make([]*redis.SliceCmd, len(batch))
+		for i, shortID := range batch {
+			links[i] = pipe.HMGet(ctx, redisLinkKey(shortID), redisLinkFields...)
+		}
+
+		if _, err = pipe.Exec(ctx); err != nil {
+			return err
+		}
+
+		for i, shortID := range batch {
+			info, ok := parseRedisLink(shortID, links[i].Val())
+			if !ok {
+				continue
+			}
+
+			if err = fn(*info); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DeleteUser добавляет пользователя в множество удаленных пользователей
+func (repository *RedisRepository) DeleteUser(ctx context.Context, userID int) error {
+	return repository.client.SAdd(ctx, redisDeletedUsersKey, userID).Err()
+}
+
+// DeletedUsers возвращает ID удаленных пользователей в порядке возрастания
+func (repository *RedisRepository) DeletedUsers(ctx context.Context) (userIDs []int, err error) {
+	members, err := repository.client.SMembers(ctx, redisDeletedUsersKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs = make([]int, 0, len(members))
+	for _, member := range members {
+		userID, err := strconv.Atoi(member)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	sort.Ints(userIDs)
+
+	return userIDs, nil
+}
+
+// LastUserID возвращает наибольший выданный ID пользователя
+func (repository *RedisRepository) LastUserID(ctx context.Context) (int, error) {
+	userID, err := repository.client.Get(ctx, redisUserIDKey).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return userID, err
+}
+
+// ImportEntries сохраняет ссылки с их владельцами и пометками удаления.
+// Ссылки сохраняются одной транзакцией, пометки удаления - следующей.
+func (repository *RedisRepository) ImportEntries(ctx context.Context, entries []ShortenedURLInfo) error {
+	deleteRequests := make([]models.UserDeleteRequest, 0)
+	for _, info := range entries {
+		if info.IsDeleted {
+			deleteRequests = append(deleteRequests, models.UserDeleteRequest{
+				UserID:          info.UserID,
+				ShortIDToDelete: info.ShortID,
+			})
+		}
+	}
+
+	if len(entries) > 0 {
+		if _, err := repository.saveEntries(ctx, entries, false); err != nil {
+			return err
+		}
+	}
+
+	return repository.DeleteUserURLs(ctx, deleteRequests)
+}
+
+// ReserveUserIDs гарантирует, что новые ID пользователей будут больше lastUserID
+func (repository *RedisRepository) ReserveUserIDs(ctx context.Context, lastUserID int) error {
+	return repository.watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, redisUserIDKey).Int()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		if current >= lastUserID {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, redisUserIDKey, lastUserID, 0)
+			return nil
+		})
+
+		return err
+	}, redisUserIDKey)
+}
+
+// Ping проверяет подключение к Redis
+func (repository *RedisRepository) Ping(ctx context.Context) error {
+	return repository.client.Ping(ctx).Err()
+}
+
+// Close закрывает подключение к Redis
+func (repository *RedisRepository) Close() error {
+	return repository.client.Close()
+}
+
+// saveEntries проверяет конфликты под WATCH и записывает новые ссылки без пометок удаления транзакцией MULTI.
+// При failOnConflict конфликт возвращает ErrConflict, иначе конфликтующие ссылки пропускаются и возвращаются.
+func (repository *RedisRepository) saveEntries(ctx context.Context, infos []ShortenedURLInfo, failOnConflict bool) (conflicts URLMapping, err error) {
+	keys := make([]string, 0, len(infos)*2)
+	for _, info := range infos {
+		keys = append(keys, redisLinkKey(info.ShortID), redisURLKey(linkURLKey(info.ShortID, info.FullURL)))
+	}
+
+	err = repository.watch(ctx, func(tx *redis.Tx) error {
+		exists := make(map[string]*redis.IntCmd, len(keys))
+		_, err := tx.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				exists[key] = pipe.Exists(ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		conflicts = make(URLMapping)
+		newEntries := make([]ShortenedURLInfo, 0, len(infos))
+		// shortID и полные ссылки, уже занятые ссылками этого набора
+		newShortIDs := make(map[string]struct{}, len(infos))
+		newURLs := make(map[string]struct{}, len(infos))
+		for _, info := range infos {
+			urlKey := linkURLKey(info.ShortID, info.FullURL)
+			_, shortIDClaimed := newShortIDs[info.ShortID]
+			_, urlClaimed := newURLs[urlKey]
+			if shortIDClaimed || urlClaimed || exists[redisLinkKey(info.ShortID)].Val() > 0 || exists[redisURLKey(urlKey)].Val() > 0 {
+				if failOnConflict {
+					return ErrConflict
+				}
+				conflicts[info.ShortID] = info.FullURL
+				continue
+			}
+			newEntries = append(newEntries, info)
+			newShortIDs[info.ShortID] = struct{}{}
+			newURLs[urlKey] = struct{}{}
+		}
+
+		if len(newEntries) == 0 {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, info := range newEntries {
+				pipe.HSet(ctx, redisLinkKey(info.ShortID),
+					redisUserIDField, info.UserID,
+					redisFullURLField, info.FullURL,
+					redisIsDeletedField, false,
+					redisCreatedAtField, info.CreatedAt.Format(time.RFC3339Nano),
+					redisClicksField, info.Clicks)
+				pipe.Set(ctx, redisURLKey(linkURLKey(info.ShortID, info.FullURL)), info.ShortID, 0)
+				pipe.SAdd(ctx, redisUserLinksKey(info.UserID), info.ShortID)
+			}
+			return nil
+		})
+
+		return err
+	}, keys...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+// watch выполняет транзакцию над ключами keys, повторяя ее, если ключи изменились
+func (repository *RedisRepository) watch(ctx context.Context, fn func(tx *redis.Tx) error, keys ...string) error {
+	for i := 0; i < redisMaxTxRetries; i++ {
+		err := repository.client.Watch(ctx, fn, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+
+	return redis.TxFailedErr
+}
+
+func parseRedisLink(shortID string, values []any) (*ShortenedURLInfo, bool) {
+	userID, ok := values[0].(string)
+	if !ok {
+		return nil, false
+	}
+
+	fullURL, ok := values[1].(string)
+	if !ok {
+		return nil, false
+	}
+
+	isDeleted, _ := values[2].(string)
+
+	info := &ShortenedURLInfo{
+		ShortID: shortID,
+		FullURL: fullURL,
+	}
+
+	var err error
+	if info.UserID, err = strconv.Atoi(userID); err != nil {
+		return nil, false
+	}
+
+	if info.IsDeleted, err = strconv.ParseBool(isDeleted); err != nil {
+		return nil, false
+	}
+
+	// ссылки, сохраненные до появления полей created_at и clicks, их не содержат
+	if createdAt, ok := values[3].(string); ok {
+		if info.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+			return nil, false
+		}
+	}
+
+	if clicks, ok := values[4].(string); ok {
+		if info.Clicks, err = strconv.ParseInt(clicks, 10, 64); err != nil {
+			return nil, false
+		}
+	}
+
+	return info, true
+}
+
+func redisLinkKey(shortID string) string {
+	return redisLinkKeyPrefix + shortID
+}
+
+func redisURLKey(urlKey string) string {
+	return redisURLKeyPrefix + urlKey
+}
+
+func redisUserLinksKey(userID int) string {
+	return redisUserLinksKeyPrefix + strconv.Itoa(userID)
+}
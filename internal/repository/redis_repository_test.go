@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisRepository(t *testing.T) (*RedisRepository, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	repository, err := NewRedisRepository(context.Background(), server.Addr())
+	require.NoError(t, err)
+	t.Cleanup(func() { repository.Close() })
+
+	return repository, server
+}
+
+func TestRedisRepositoryConcurrentAddClicks(t *testing.T) {
+	ctx := context.Background()
+	repository, server := newTestRedisRepository(t)
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+
+	// одновременные сохранения счетчиков одной ссылки не конфликтуют между собой
+	const count = 20
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repository.AddClicks(ctx, map[string]int64{"1": 2, "unknown": 1})
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	info, ok := repository.GetFullURL(ctx, "1")
+	require.True(t, ok)
+	assert.Equal(t, int64(count*2), info.Clicks)
+	// переходы по отсутствующей ссылке не создают ее
+	assert.False(t, server.Exists(redisLinkKey("unknown")))
+}
+
+func TestRedisRepositoryPing(t *testing.T) {
+	ctx := context.Background()
+	repository, server := newTestRedisRepository(t)
+
+	require.NoError(t, repository.Ping(ctx))
+
+	server.Close()
+	assert.Error(t, repository.Ping(ctx))
+}
+
+func TestRedisRepositoryUpgradeURLKeys(t *testing.T) {
+	ctx := context.Background()
+	repository, server := newTestRedisRepository(t)
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "go.brand.com/1", "http://example.com/1"))
+	require.NoError(t, repository.SaveEntry(ctx, 1, "2", "http://example.com/2"))
+
+	// хранилище, в котором ключом ссылки дополнительного домена была полная ссылка
+	server.Del(redisURLKey(linkURLKey("go.brand.com/1", "http://example.com/1")))
+	require.NoError(t, server.Set(redisURLKey("http://example.com/1"), "go.brand.com/1"))
+	server.Del(redisURLKeysVersionKey)
+
+	upgraded, err := NewRedisRepository(ctx, server.Addr())
+	require.NoError(t, err)
+	defer upgraded.Close()
+
+	shortID, err := upgraded.GetShortID(ctx, "go.brand.com", "http://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, "go.brand.com/1", shortID)
+
+	shortID, err = upgraded.GetShortID(ctx, "", "http://example.com/2")
+	require.NoError(t, err)
+	assert.Equal(t, "2", shortID)
+
+	_, err = upgraded.GetShortID(ctx, "", "http://example.com/1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, upgraded.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replica реплика БД для запросов чтения
+type replica struct {
+	pool    *pgxpool.Pool
+	ping    func(ctx context.Context) error
+	healthy atomic.Bool
+}
+
+// replicaSet набор реплик БД, выбираемых по кругу среди доступных.
+// Доступность реплик периодически проверяется запросом Ping.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	interval time.Duration
+	stop     context.CancelFunc
+	done     chan struct{}
+}
+
+func newReplicaSet(pools []*pgxpool.Pool, interval time.Duration) *replicaSet {
+	replicas := make([]*replica, len(pools))
+	for i, pool := range pools {
+		replicas[i] = &replica{pool: pool, ping: pool.Ping}
+	}
+
+	return &replicaSet{
+		replicas: replicas,
+		interval: interval,
+	}
+}
+
+// pick возвращает следующую доступную реплику или nil, если доступных реплик нет
+func (s *replicaSet) pick() *replica {
+	count := uint64(len(s.replicas))
+	for i := uint64(0); i < count; i++ {
+		replica := s.replicas[s.next.Add(1)%count]
+		if replica.healthy.Load() {
+			return replica
+		}
+	}
+
+	return nil
+}
+
+// check проверяет доступность всех реплик
+func (s *replicaSet) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, replica := range s.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			pingCtx, cancel := context.WithTimeout(ctx, s.interval)
+			defer cancel()
+
+			replica.healthy.Store(replica.ping(pingCtx) == nil)
+		}()
+	}
+	wg.Wait()
+}
+
+// start проверяет доступность реплик и продолжает проверять ее в фоне
+func (s *replicaSet) start(ctx context.Context) {
+	s.check(ctx)
+
+	checkCtx, cancel := context.WithCancel(context.Background())
+	s.stop = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.check(checkCtx)
+			case <-checkCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// close останавливает проверку доступности и закрывает пулы подключений реплик
+func (s *replicaSet) close() {
+	if s.stop != nil {
+		s.stop()
+		<-s.done
+	}
+
+	for _, replica := range s.replicas {
+		replica.pool.Close()
+	}
+}
+
+// writeTracker запоминает недавно измененные ключи, чтобы чтение по ним
+// в течение окна window выполнялось на основной БД (read-your-writes)
+type writeTracker struct {
+	mutex      sync.Mutex
+	window     time.Duration
+	until      map[string]time.Time
+	lastPruned time.Time
+	now        func() time.Time
+}
+
+func newWriteTracker(window time.Duration) *writeTracker {
+	return &writeTracker{
+		window: window,
+		until:  make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// mark отмечает ключи как измененные в текущий момент
+func (t *writeTracker) mark(keys ...string) {
+	if t.window <= 0 {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	until := now.Add(t.window)
+	for _, key := range keys {
+		t.until[key] = until
+	}
+
+	t.prune(now)
+}
+
+// isPinned проверяет, изменялся ли какой-либо из ключей в течение окна
+func (t *writeTracker) isPinned(keys ...string) bool {
+	if t.window <= 0 {
+		return false
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	for _, key := range keys {
+		if until, ok := t.until[key]; ok && now.Before(until) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// prune удаляет ключи с истекшим окном не чаще одного раза за окно
+func (t *writeTracker) prune(now time.Time) {
+	if now.Sub(t.lastPruned) < t.window {
+		return
+	}
+	t.lastPruned = now
+
+	for key, until := range t.until {
+		if !now.Before(until) {
+			delete(t.until, key)
+		}
+	}
+}
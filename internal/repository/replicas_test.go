@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestReplicaSet(pings ...func(ctx context.Context) error) *replicaSet {
+	replicas := make([]*replica, len(pings))
+	for i, ping := range pings {
+		replicas[i] = &replica{ping: ping}
+	}
+
+	return &replicaSet{replicas: replicas, interval: time.Second}
+}
+
+func TestReplicaSetPick(t *testing.T) {
+	available := func(ctx context.Context) error { return nil }
+	unavailable := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	replicas := newTestReplicaSet(available, unavailable, available)
+	assert.Nil(t, replicas.pick())
+
+	replicas.check(context.Background())
+
+	picked := make(map[*replica]int)
+	for i := 0; i < 10; i++ {
+		picked[replicas.pick()]++
+	}
+
+	// доступные реплики выбираются по очереди, недоступная пропускается
+	assert.Equal(t, map[*replica]int{replicas.replicas[0]: 5, replicas.replicas[2]: 5}, picked)
+
+	replicas.replicas[0].healthy.Store(false)
+	replicas.replicas[2].healthy.Store(false)
+	assert.Nil(t, replicas.pick())
+}
+
+func TestWriteTracker(t *testing.T) {
+	now := time.Now()
+	tracker := newWriteTracker(time.Second)
+	tracker.now = func() time.Time { return now }
+
+	assert.False(t, tracker.isPinned("user:1"))
+
+	tracker.mark("user:1", "short_id:1")
+	assert.True(t, tracker.isPinned("user:1"))
+	assert.True(t, tracker.isPinned("user:2", "short_id:1"))
+	assert.False(t, tracker.isPinned("user:2"))
+
+	now = now.Add(time.Second)
+	assert.False(t, tracker.isPinned("user:1"))
+
+	// устаревшие ключи удаляются при следующей записи
+	tracker.mark("user:2")
+	assert.Len(t, tracker.until, 1)
+}
+
+func TestWriteTrackerDisabled(t *testing.T) {
+	tracker := newWriteTracker(0)
+
+	tracker.mark("user:1")
+	assert.False(t, tracker.isPinned("user:1"))
+	assert.Empty(t, tracker.until)
+}
@@ -3,11 +3,15 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/spf13/afero"
+	"go.uber.org/zap"
 
 	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/database"
 	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/storage"
 )
 
 // URLMapping словарь идентификатора и сокращенной ссылки
@@ -23,6 +27,10 @@ type ShortenedURLInfo struct {
 	ShortID string
 	// IsDeleted флаг удаленной ссылки
 	IsDeleted bool
+	// CreatedAt время создания ссылки (UTC)
+	CreatedAt time.Time
+	// Clicks количество переходов по ссылке
+	Clicks int64
 }
 
 // Repository интерфейс работы с данными сервиса
@@ -31,16 +39,31 @@ type Repository interface {
 	GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool)
 	// SaveEntry сохраняет в хранилище информацию о сокращенной ссылке
 	SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error
-	// SaveEntries записывает набор сокращенных ссылок
-	SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) error
-	// GetShortID возвращает shortID сокращенной ссылки
-	GetShortID(ctx context.Context, fullURL string) (shortID string, err error)
+	// SaveEntries записывает набор сокращенных ссылок и возвращает ссылки,
+	// не сохраненные из-за конфликта с уже существующими
+	SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error)
+	// GetShortID возвращает shortID ссылки fullURL в пространстве имен домена domain
+	// (пустой - основной домен) или ErrNotFound
+	GetShortID(ctx context.Context, domain string, fullURL string) (shortID string, err error)
 	// GetUserEntries возвращает сокращенный пользователем ссылки по userID
 	GetUserEntries(ctx context.Context, userID int) (shortIDMap URLMapping, err error)
+	// ListUserEntries возвращает ссылки пользователя, включая удаленные, в порядке и с ограничениями query
+	ListUserEntries(ctx context.Context, userID int, query UserEntriesQuery) (entries []ShortenedURLInfo, err error)
+	// AddClicks увеличивает счетчики переходов по ссылкам на значения из clicks (shortID -> количество).
+	// Несуществующие ссылки пропускаются.
+	AddClicks(ctx context.Context, clicks map[string]int64) error
+	// ForEachShortID вызывает fn для shortID каждой сохраненной ссылки, включая удаленные.
+	// Ошибка fn прерывает обход и возвращается вызывающему.
+	ForEachShortID(ctx context.Context, fn func(shortID string) error) error
 	// GetNewUserID возвращает ID нового пользователя
 	GetNewUserID(ctx context.Context) (userID int, err error)
 	// DeleteUserURLs удаляет набор сокращенных ссылок
 	DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error
+	// DeleteUser сохраняет пометку удаления пользователя userID.
+	// Повторное удаление пользователя не является ошибкой.
+	DeleteUser(ctx context.Context, userID int) error
+	// DeletedUsers возвращает ID удаленных пользователей в порядке возрастания
+	DeletedUsers(ctx context.Context) (userIDs []int, err error)
 	// Ping проверяет подключение к источнику данных
 	Ping(ctx context.Context) error
 	// Close завершает работу с источником данных
@@ -57,15 +80,156 @@ var (
 	ErrConflict = errors.New("entry conflict")
 	// ErrNotImplemented ошибка нереализованного метода
 	ErrNotImplemented = errors.New("method is not implemented")
+	// ErrNotFound ошибка отсутствия записи
+	ErrNotFound = errors.New("entry not found")
+	// ErrShadowNotSupported ошибка хранилища, которое не может быть теневым
+	ErrShadowNotSupported = errors.New("storage cannot be used as a shadow storage")
 )
 
-// NewAppRepository создает репозиторий по типу хранилища из конфига
-func NewAppRepository(ctx context.Context, appConfig *config.AppConfig) (Repository, error) {
+// conflictingEntries возвращает ссылки из shortIDMap, shortID которых нет среди сохраненных
+func conflictingEntries(shortIDMap URLMapping, saved map[string]struct{}) URLMapping {
+	conflicts := make(URLMapping)
+	for shortID, fullURL := range shortIDMap {
+		if _, ok := saved[shortID]; !ok {
+			conflicts[shortID] = fullURL
+		}
+	}
+
+	return conflicts
+}
+
+// NewAppRepository создает репозиторий по типу хранилища из конфига.
+// При заданном размере кэша репозиторий оборачивается в CachedRepository,
+// при заданной емкости фильтра Блума - в BloomRepository.
+// В logger пишутся расхождения теневого хранилища и ошибки перестроения фильтра Блума.
+func NewAppRepository(ctx context.Context, appConfig *config.AppConfig, logger *zap.Logger) (Repository, error) {
+	repository, err := newStorageRepository(ctx, appConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if appConfig.ShadowStorage != "" {
+		if repository, err = newShadowRepository(ctx, appConfig, repository, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	if appConfig.CacheSize > 0 {
+		repository = NewCachedRepository(repository, appConfig.CacheSize,
+			WithCacheTTL(appConfig.CacheTTL),
+			WithNegativeCacheTTL(appConfig.CacheNegativeTTL),
+		)
+	}
+
+	if appConfig.BloomFilterCapacity > 0 {
+		opts := []BloomRepositoryOption{
+			WithBloomRebuildInterval(appConfig.BloomFilterRebuildInterval),
+			WithBloomLogger(logger),
+		}
+		if appConfig.BloomFilterPath != "" {
+			opts = append(opts, WithBloomFilterFile(afero.NewOsFs(), appConfig.BloomFilterPath))
+		}
+
+		bloomRepository, err := NewBloomRepository(ctx, repository, appConfig.BloomFilterCapacity, appConfig.BloomFilterFalsePositiveRate, opts...)
+		if err != nil {
+			repository.Close()
+			return nil, err
+		}
+		repository = bloomRepository
+	}
+
+	return repository, nil
+}
+
+// newShadowRepository создает теневое хранилище из конфига и объединяет его с основным
+// в ShadowRepository; при ShadowPromote хранилища меняются местами
+func newShadowRepository(ctx context.Context, appConfig *config.AppConfig, primary Repository, logger *zap.Logger) (Repository, error) {
+	storageType, err := config.ParseStorageType(appConfig.ShadowStorage)
+	if err != nil {
+		primary.Close()
+		return nil, err
+	}
+
+	shadowConfig := *appConfig
+	config.WithStorageLocation(storageType, appConfig.ShadowStorageLocation)(&shadowConfig)
+
+	secondary, err := newStorageRepository(ctx, &shadowConfig)
+	if err != nil {
+		primary.Close()
+		return nil, err
+	}
+
+	if appConfig.ShadowPromote {
+		primary, secondary = secondary, primary
+	}
+
+	shadowSecondary, ok := secondary.(ShadowSecondary)
+	if !ok {
+		primary.Close()
+		secondary.Close()
+		return nil, ErrShadowNotSupported
+	}
+
+	return NewShadowRepository(primary, shadowSecondary,
+		WithShadowReadSampleRate(appConfig.ShadowReadSampleRate),
+		WithShadowLogger(logger),
+	), nil
+}
+
+// newShardedDatabaseRepository подключается к шардам БД и объединяет их в ShardedRepository
+func newShardedDatabaseRepository(ctx context.Context, dsns []string, poolConfig database.PoolConfig) (Repository, error) {
+	shards := make([]Repository, 0, len(dsns))
+	for _, dsn := range dsns {
+		shard, err := NewDatabaseRepository(ctx, dsn, poolConfig)
+		if err != nil {
+			for _, opened := range shards {
+				opened.Close()
+			}
+			return nil, err
+		}
+		shards = append(shards, shard)
+	}
+
+	return NewShardedRepository(shards), nil
+}
+
+// newStorageRepository создает репозиторий по типу хранилища из конфига
+func newStorageRepository(ctx context.Context, appConfig *config.AppConfig) (Repository, error) {
 	switch appConfig.StorageType {
 	case config.Database:
-		return NewDatabaseRepository(ctx, appConfig.DatabaseDSN)
+		poolConfig := database.PoolConfig{
+			MaxConns:        int32(appConfig.DatabaseMaxConns),
+			MinConns:        int32(appConfig.DatabaseMinConns),
+			MaxConnLifetime: appConfig.DatabaseMaxConnLifetime,
+			MaxConnIdleTime: appConfig.DatabaseMaxConnIdleTime,
+			AcquireTimeout:  appConfig.DatabaseAcquireTimeout,
+		}
+
+		if len(appConfig.DatabaseShardDSNs) > 0 {
+			return newShardedDatabaseRepository(ctx, appConfig.DatabaseShardDSNs, poolConfig)
+		}
+
+		return NewDatabaseRepository(ctx, appConfig.DatabaseDSN, poolConfig,
+			WithReplicas(appConfig.DatabaseReplicaDSNs, appConfig.DatabaseReplicaHealthCheckInterval),
+			WithReadYourWritesWindow(appConfig.DatabaseReadYourWritesWindow),
+		)
+	case config.SQLite:
+		return NewSQLiteRepository(ctx, appConfig.SQLitePath)
+	case config.Bolt:
+		return NewBoltRepository(ctx, appConfig.BoltPath)
+	case config.Redis:
+		return NewRedisRepository(ctx, appConfig.RedisAddr)
 	case config.File:
-		return NewFileRepository(afero.NewOsFs(), appConfig.FileStoragePath)
+		opts := []FileRepositoryOption{
+			WithCompactionInterval(appConfig.FileCompactionInterval),
+			WithSyncPolicy(storage.SyncPolicy(appConfig.FileSyncPolicy), appConfig.FileSyncInterval),
+			WithCompression(storage.Compression(appConfig.FileCompression)),
+		}
+		if appConfig.FileFormatUpgrade {
+			opts = append(opts, WithFormatUpgrade())
+		}
+
+		return NewFileRepository(afero.NewOsFs(), appConfig.FileStoragePath, opts...)
 	case config.None:
 		return NewMemoryRepository(), nil
 	default:
@@ -0,0 +1,667 @@
+// Package repositorytest содержит общий набор тестов поведения для реализаций repository.Repository.
+//
+// Набор запускается функцией Run для фабрики репозиториев:
+//
+//	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+//		return repository.NewMemoryRepository()
+//	})
+//
+// Тесты не рассчитывают на пустое хранилище: короткие ID и ссылки уникальны
+// для каждого запуска, поэтому набор можно запускать на общей БД.
+// Выгрузка и загрузка ссылок проверяются только для репозиториев,
+// реализующих repository.EntryExporter и repository.EntryImporter.
+package repositorytest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/repository"
+)
+
+// concurrency количество горутин в тестах конкурентного доступа
+const concurrency = 16
+
+// Factory создает репозиторий для одного теста. Репозиторий закрывается набором тестов.
+type Factory func(t *testing.T) repository.Repository
+
+// Run запускает набор тестов поведения для репозиториев, создаваемых newRepository
+func Run(t *testing.T, newRepository Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *suite)
+	}{
+		{"SaveEntry", testSaveEntry},
+		{"SaveEntryConflicts", testSaveEntryConflicts},
+		{"NotFound", testNotFound},
+		{"SaveEntries", testSaveEntries},
+		{"SaveEntriesConflicts", testSaveEntriesConflicts},
+		{"SaveEntriesDuplicateURLs", testSaveEntriesDuplicateURLs},
+		{"DomainNamespaces", testDomainNamespaces},
+		{"GetUserEntries", testGetUserEntries},
+		{"GetNewUserID", testGetNewUserID},
+		{"DeleteUserURLs", testDeleteUserURLs},
+		{"DeleteUser", testDeleteUser},
+		{"ForEachShortID", testForEachShortID},
+		{"ListUserEntries", testListUserEntries},
+		{"ListUserEntriesPages", testListUserEntriesPages},
+		{"AddClicks", testAddClicks},
+		{"ConcurrentSaveEntry", testConcurrentSaveEntry},
+		{"ConcurrentSaveEntries", testConcurrentSaveEntries},
+		{"ConcurrentGetNewUserID", testConcurrentGetNewUserID},
+		{"EntryMigration", testEntryMigration},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepository(t)
+			t.Cleanup(func() {
+				assert.NoError(t, repo.Close())
+			})
+
+			tt.fn(t, &suite{
+				ctx:    context.Background(),
+				repo:   repo,
+				prefix: strconv.FormatInt(time.Now().UnixNano(), 36),
+			})
+		})
+	}
+}
+
+// suite состояние одного теста набора
+type suite struct {
+	ctx    context.Context
+	repo   repository.Repository
+	prefix string
+	// counter счетчик для уникальных коротких ID и ссылок
+	counter atomic.Int64
+}
+
+// shortID возвращает короткий ID, уникальный для запуска теста
+func (s *suite) shortID() string {
+	return s.prefix + "-" + strconv.FormatInt(s.counter.Add(1), 10)
+}
+
+// fullURL возвращает ссылку, уникальную для запуска теста
+func (s *suite) fullURL() string {
+	return fmt.Sprintf("http://example.com/%s/%d", s.prefix, s.counter.Add(1))
+}
+
+// newUserID выдает ID нового пользователя
+func (s *suite) newUserID(t *testing.T) int {
+	userID, err := s.repo.GetNewUserID(s.ctx)
+	require.NoError(t, err)
+
+	return userID
+}
+
+// save сохраняет ссылку и возвращает ее короткий ID
+func (s *suite) save(t *testing.T, userID int, fullURL string) string {
+	shortID := s.shortID()
+	require.NoError(t, s.repo.SaveEntry(s.ctx, userID, shortID, fullURL))
+
+	return shortID
+}
+
+// requireEntry проверяет сохраненную ссылку.
+// Время создания проверяется только на близость к текущему, так как его задает хранилище.
+func (s *suite) requireEntry(t *testing.T, want repository.ShortenedURLInfo) {
+	info, ok := s.repo.GetFullURL(s.ctx, want.ShortID)
+	require.True(t, ok, "entry %q not found", want.ShortID)
+
+	got := *info
+	assert.WithinDuration(t, time.Now(), got.CreatedAt, time.Minute)
+	assert.Equal(t, time.UTC, got.CreatedAt.Location())
+	got.CreatedAt = time.Time{}
+	assert.Equal(t, want, got)
+}
+
+// saveUserEntries сохраняет count ссылок пользователя, добавляет им переходы из clicks
+// и возвращает сохраненные записи
+func (s *suite) saveUserEntries(t *testing.T, userID int, clicks []int64) []repository.ShortenedURLInfo {
+	shortIDs := make([]string, len(clicks))
+	clicksMap := make(map[string]int64, len(clicks))
+	for i, count := range clicks {
+		shortIDs[i] = s.save(t, userID, s.fullURL())
+		if count > 0 {
+			clicksMap[shortIDs[i]] = count
+		}
+	}
+	require.NoError(t, s.repo.AddClicks(s.ctx, clicksMap))
+
+	infos := make([]repository.ShortenedURLInfo, len(shortIDs))
+	for i, shortID := range shortIDs {
+		info, ok := s.repo.GetFullURL(s.ctx, shortID)
+		require.True(t, ok, "entry %q not found", shortID)
+		infos[i] = *info
+	}
+
+	return infos
+}
+
+// sortedEntries возвращает копию infos в порядке выборки query
+func sortedEntries(infos []repository.ShortenedURLInfo, query repository.UserEntriesQuery) []repository.ShortenedURLInfo {
+	sorted := slices.Clone(infos)
+	slices.SortFunc(sorted, func(a, b repository.ShortenedURLInfo) int {
+		result := a.CreatedAt.Compare(b.CreatedAt)
+		if query.Sort == repository.SortByClicks {
+			result = cmp.Compare(a.Clicks, b.Clicks)
+		}
+		if result == 0 {
+			result = strings.Compare(a.ShortID, b.ShortID)
+		}
+		if query.Descending {
+			return -result
+		}
+		return result
+	})
+
+	return sorted
+}
+
+func testSaveEntry(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	fullURL := s.fullURL()
+	shortID := s.save(t, userID, fullURL)
+
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: shortID, FullURL: fullURL})
+
+	found, err := s.repo.GetShortID(s.ctx, "", fullURL)
+	require.NoError(t, err)
+	assert.Equal(t, shortID, found)
+}
+
+func testSaveEntryConflicts(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	otherUserID := s.newUserID(t)
+	fullURL := s.fullURL()
+	shortID := s.save(t, userID, fullURL)
+
+	// занятый короткий ID
+	err := s.repo.SaveEntry(s.ctx, otherUserID, shortID, s.fullURL())
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	// уже сокращенная ссылка
+	err = s.repo.SaveEntry(s.ctx, otherUserID, s.shortID(), fullURL)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	// повторное сохранение той же ссылки тем же пользователем
+	err = s.repo.SaveEntry(s.ctx, userID, shortID, fullURL)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: shortID, FullURL: fullURL})
+
+	found, err := s.repo.GetShortID(s.ctx, "", fullURL)
+	require.NoError(t, err)
+	assert.Equal(t, shortID, found)
+}
+
+func testNotFound(t *testing.T, s *suite) {
+	info, ok := s.repo.GetFullURL(s.ctx, s.shortID())
+	assert.False(t, ok)
+	assert.Nil(t, info)
+
+	_, err := s.repo.GetShortID(s.ctx, "", s.fullURL())
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testSaveEntries(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+
+	batch := make(repository.URLMapping)
+	for i := 0; i < 10; i++ {
+		batch[s.shortID()] = s.fullURL()
+	}
+
+	conflicts, err := s.repo.SaveEntries(s.ctx, userID, batch)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	for shortID, fullURL := range batch {
+		s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: shortID, FullURL: fullURL})
+	}
+
+	conflicts, err = s.repo.SaveEntries(s.ctx, userID, repository.URLMapping{})
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+}
+
+func testSaveEntriesConflicts(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	otherUserID := s.newUserID(t)
+	existingURL := s.fullURL()
+	existingShortID := s.save(t, userID, existingURL)
+
+	newShortID, newURL := s.shortID(), s.fullURL()
+	sameURLShortID := s.shortID()
+	batch := repository.URLMapping{
+		existingShortID: s.fullURL(),
+		sameURLShortID:  existingURL,
+		newShortID:      newURL,
+	}
+
+	// каждая ссылка набора либо сохраняется, либо возвращается как конфликтующая
+	conflicts, err := s.repo.SaveEntries(s.ctx, otherUserID, batch)
+	require.NoError(t, err)
+	assert.Equal(t, repository.URLMapping{
+		existingShortID: batch[existingShortID],
+		sameURLShortID:  existingURL,
+	}, conflicts)
+
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: existingShortID, FullURL: existingURL})
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: otherUserID, ShortID: newShortID, FullURL: newURL})
+
+	_, ok := s.repo.GetFullURL(s.ctx, sameURLShortID)
+	assert.False(t, ok)
+}
+
+func testSaveEntriesDuplicateURLs(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	fullURL := s.fullURL()
+	batch := repository.URLMapping{s.shortID(): fullURL, s.shortID(): fullURL}
+
+	// из ссылок набора с одинаковой полной ссылкой сохраняется ровно одна
+	conflicts, err := s.repo.SaveEntries(s.ctx, userID, batch)
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+
+	saved, err := s.repo.GetShortID(s.ctx, "", fullURL)
+	require.NoError(t, err)
+	assert.Contains(t, batch, saved)
+	assert.NotContains(t, conflicts, saved)
+}
+
+func testDomainNamespaces(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	domain := "brand-" + s.prefix + ".example"
+	fullURL := s.fullURL()
+	defaultShortID := s.save(t, userID, fullURL)
+
+	// полная ссылка уникальна только в пределах домена
+	domainShortID := app.DomainShortID(domain, s.shortID())
+	require.NoError(t, s.repo.SaveEntry(s.ctx, userID, domainShortID, fullURL))
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: domainShortID, FullURL: fullURL})
+
+	err := s.repo.SaveEntry(s.ctx, userID, app.DomainShortID(domain, s.shortID()), fullURL)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	found, err := s.repo.GetShortID(s.ctx, "", fullURL)
+	require.NoError(t, err)
+	assert.Equal(t, defaultShortID, found)
+
+	found, err = s.repo.GetShortID(s.ctx, domain, fullURL)
+	require.NoError(t, err)
+	assert.Equal(t, domainShortID, found)
+
+	_, err = s.repo.GetShortID(s.ctx, "other-"+domain, fullURL)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// в наборе ссылка другого домена не конфликтует, а повтор в домене - конфликтует
+	otherDomainShortID := app.DomainShortID("other-"+domain, s.shortID())
+	sameDomainShortID := app.DomainShortID(domain, s.shortID())
+	conflicts, err := s.repo.SaveEntries(s.ctx, userID, repository.URLMapping{
+		otherDomainShortID: fullURL,
+		sameDomainShortID:  fullURL,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, repository.URLMapping{sameDomainShortID: fullURL}, conflicts)
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: otherDomainShortID, FullURL: fullURL})
+}
+
+func testGetUserEntries(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	otherUserID := s.newUserID(t)
+
+	want := make(repository.URLMapping)
+	for i := 0; i < 3; i++ {
+		fullURL := s.fullURL()
+		want[s.save(t, userID, fullURL)] = fullURL
+	}
+	otherURL := s.fullURL()
+	otherShortID := s.save(t, otherUserID, otherURL)
+
+	entries, err := s.repo.GetUserEntries(s.ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, want, entries)
+
+	entries, err = s.repo.GetUserEntries(s.ctx, otherUserID)
+	require.NoError(t, err)
+	assert.Equal(t, repository.URLMapping{otherShortID: otherURL}, entries)
+
+	entries, err = s.repo.GetUserEntries(s.ctx, s.newUserID(t))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func testGetNewUserID(t *testing.T, s *suite) {
+	first := s.newUserID(t)
+	second := s.newUserID(t)
+
+	assert.Positive(t, first)
+	assert.Greater(t, second, first)
+}
+
+func testDeleteUserURLs(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	otherUserID := s.newUserID(t)
+	deletedURL, keptURL, otherURL := s.fullURL(), s.fullURL(), s.fullURL()
+	deletedShortID := s.save(t, userID, deletedURL)
+	keptShortID := s.save(t, userID, keptURL)
+	otherShortID := s.save(t, otherUserID, otherURL)
+
+	deleteRequests := []models.UserDeleteRequest{
+		{UserID: userID, ShortIDToDelete: deletedShortID},
+		// ссылка другого пользователя не удаляется
+		{UserID: userID, ShortIDToDelete: otherShortID},
+		// несуществующая ссылка пропускается
+		{UserID: userID, ShortIDToDelete: s.shortID()},
+	}
+	require.NoError(t, s.repo.DeleteUserURLs(s.ctx, deleteRequests))
+	// повторное удаление ничего не меняет
+	require.NoError(t, s.repo.DeleteUserURLs(s.ctx, deleteRequests))
+
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: deletedShortID, FullURL: deletedURL, IsDeleted: true})
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: keptShortID, FullURL: keptURL})
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: otherUserID, ShortID: otherShortID, FullURL: otherURL})
+
+	// удаленная ссылка остается занятой
+	found, err := s.repo.GetShortID(s.ctx, "", deletedURL)
+	require.NoError(t, err)
+	assert.Equal(t, deletedShortID, found)
+
+	err = s.repo.SaveEntry(s.ctx, userID, s.shortID(), deletedURL)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	require.NoError(t, s.repo.DeleteUserURLs(s.ctx, nil))
+}
+
+func testDeleteUser(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	keptUserID := s.newUserID(t)
+
+	require.NoError(t, s.repo.DeleteUser(s.ctx, userID))
+	// повторное удаление не является ошибкой
+	require.NoError(t, s.repo.DeleteUser(s.ctx, userID))
+
+	deletedUsers, err := s.repo.DeletedUsers(s.ctx)
+	require.NoError(t, err)
+	assert.Contains(t, deletedUsers, userID)
+	assert.NotContains(t, deletedUsers, keptUserID)
+	assert.IsIncreasing(t, deletedUsers)
+
+	// ID удаленного пользователя не выдается повторно
+	assert.Greater(t, s.newUserID(t), keptUserID)
+}
+
+func testForEachShortID(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	want := []string{s.save(t, userID, s.fullURL()), s.save(t, userID, s.fullURL())}
+	require.NoError(t, s.repo.DeleteUserURLs(s.ctx, []models.UserDeleteRequest{{UserID: userID, ShortIDToDelete: want[0]}}))
+
+	// обход включает удаленные ссылки
+	shortIDs := make([]string, 0)
+	err := s.repo.ForEachShortID(s.ctx, func(shortID string) error {
+		shortIDs = append(shortIDs, shortID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Subset(t, shortIDs, want)
+
+	// ошибка fn прерывает обход
+	errStop := errors.New("stop")
+	calls := 0
+	err = s.repo.ForEachShortID(s.ctx, func(shortID string) error {
+		calls++
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+}
+
+func testListUserEntries(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	otherUserID := s.newUserID(t)
+	infos := s.saveUserEntries(t, userID, []int64{3, 1, 3, 0, 5})
+	s.saveUserEntries(t, otherUserID, []int64{2})
+
+	queries := []repository.UserEntriesQuery{
+		{},
+		{Sort: repository.SortByCreatedAt, Descending: true},
+		{Sort: repository.SortByClicks},
+		{Sort: repository.SortByClicks, Descending: true},
+	}
+	for _, query := range queries {
+		entries, err := s.repo.ListUserEntries(s.ctx, userID, query)
+		require.NoError(t, err)
+		assert.Equal(t, sortedEntries(infos, query), entries, "query %+v", query)
+	}
+
+	// ограничение количества
+	query := repository.UserEntriesQuery{Sort: repository.SortByClicks, Descending: true, Limit: 2}
+	entries, err := s.repo.ListUserEntries(s.ctx, userID, query)
+	require.NoError(t, err)
+	assert.Equal(t, sortedEntries(infos, query)[:2], entries)
+
+	entries, err = s.repo.ListUserEntries(s.ctx, s.newUserID(t), repository.UserEntriesQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func testListUserEntriesPages(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	infos := s.saveUserEntries(t, userID, []int64{2, 0, 2, 1, 2, 0, 1})
+
+	for _, sort := range []repository.UserEntriesSort{repository.SortByCreatedAt, repository.SortByClicks} {
+		for _, descending := range []bool{false, true} {
+			query := repository.UserEntriesQuery{Sort: sort, Descending: descending, Limit: 3}
+
+			// страницы, выбранные по позиции последней ссылки, покрывают все ссылки без повторов
+			pages := make([]repository.ShortenedURLInfo, 0, len(infos))
+			for range len(infos) {
+				page, err := s.repo.ListUserEntries(s.ctx, userID, query)
+				require.NoError(t, err)
+				require.LessOrEqual(t, len(page), query.Limit)
+				if len(page) == 0 {
+					break
+				}
+
+				pages = append(pages, page...)
+				query.After = repository.NewUserEntriesCursor(page[len(page)-1])
+			}
+
+			assert.Equal(t, sortedEntries(infos, query), pages, "sort %s, descending %t", sort, descending)
+		}
+	}
+}
+
+func testAddClicks(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	fullURL, otherURL := s.fullURL(), s.fullURL()
+	shortID := s.save(t, userID, fullURL)
+	otherShortID := s.save(t, userID, otherURL)
+
+	// счетчики накапливаются, несуществующие ссылки пропускаются
+	require.NoError(t, s.repo.AddClicks(s.ctx, map[string]int64{shortID: 2, s.shortID(): 1}))
+	require.NoError(t, s.repo.AddClicks(s.ctx, map[string]int64{shortID: 3}))
+	require.NoError(t, s.repo.AddClicks(s.ctx, nil))
+
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: shortID, FullURL: fullURL, Clicks: 5})
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: otherShortID, FullURL: otherURL})
+
+	// счетчик удаленной ссылки сохраняется
+	require.NoError(t, s.repo.DeleteUserURLs(s.ctx, []models.UserDeleteRequest{{UserID: userID, ShortIDToDelete: shortID}}))
+	s.requireEntry(t, repository.ShortenedURLInfo{UserID: userID, ShortID: shortID, FullURL: fullURL, IsDeleted: true, Clicks: 5})
+}
+
+func testConcurrentSaveEntry(t *testing.T, s *suite) {
+	userID := s.newUserID(t)
+	fullURL := s.fullURL()
+	shortID := s.shortID()
+
+	shortIDs := make([]string, concurrency)
+	fullURLs := make([]string, concurrency)
+	for i := range concurrency {
+		shortIDs[i] = s.shortID()
+		fullURLs[i] = s.fullURL()
+	}
+
+	var wg sync.WaitGroup
+	sameURLErrs := make([]error, concurrency)
+	sameShortIDErrs := make([]error, concurrency)
+	for i := range concurrency {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			sameURLErrs[i] = s.repo.SaveEntry(s.ctx, userID, shortIDs[i], fullURL)
+		}()
+		go func() {
+			defer wg.Done()
+			sameShortIDErrs[i] = s.repo.SaveEntry(s.ctx, userID, shortID, fullURLs[i])
+		}()
+	}
+	wg.Wait()
+
+	// из одновременных записей одной ссылки или одного короткого ID успешна ровно одна
+	for _, errs := range [][]error{sameURLErrs, sameShortIDErrs} {
+		saved := 0
+		for _, err := range errs {
+			if err == nil {
+				saved++
+				continue
+			}
+			assert.ErrorIs(t, err, repository.ErrConflict)
+		}
+		assert.Equal(t, 1, saved)
+	}
+}
+
+func testConcurrentSaveEntries(t *testing.T, s *suite) {
+	batch := make(repository.URLMapping)
+	for i := 0; i < 20; i++ {
+		batch[s.shortID()] = s.fullURL()
+	}
+
+	userIDs := make([]int, concurrency)
+	for i := range userIDs {
+		userIDs[i] = s.newUserID(t)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]repository.URLMapping, concurrency)
+	errs := make([]error, concurrency)
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = s.repo.SaveEntries(s.ctx, userIDs[i], batch)
+		}()
+	}
+	wg.Wait()
+
+	// каждая ссылка сохранена ровно одним из наборов, остальные получили ее как конфликт
+	owners := make(map[string]int, len(batch))
+	for i := range concurrency {
+		require.NoError(t, errs[i])
+		for shortID := range batch {
+			if _, conflict := results[i][shortID]; !conflict {
+				owners[shortID]++
+				s.requireEntry(t, repository.ShortenedURLInfo{UserID: userIDs[i], ShortID: shortID, FullURL: batch[shortID]})
+			}
+		}
+	}
+
+	for shortID := range batch {
+		assert.Equal(t, 1, owners[shortID], "entry %q", shortID)
+	}
+}
+
+func testConcurrentGetNewUserID(t *testing.T, s *suite) {
+	var wg sync.WaitGroup
+	userIDs := make([]int, concurrency)
+	errs := make([]error, concurrency)
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			userIDs[i], errs[i] = s.repo.GetNewUserID(s.ctx)
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool, concurrency)
+	for i := range concurrency {
+		require.NoError(t, errs[i])
+		assert.False(t, seen[userIDs[i]], "user ID %d issued twice", userIDs[i])
+		seen[userIDs[i]] = true
+	}
+}
+
+// testEntryMigration проверяет выгрузку и идемпотентную загрузку ссылок хранилищами,
+// поддерживающими перенос данных
+func testEntryMigration(t *testing.T, s *suite) {
+	exporter, exportOK := s.repo.(repository.EntryExporter)
+	importer, importOK := s.repo.(repository.EntryImporter)
+	if !exportOK || !importOK {
+		t.Skip("repository does not support entry migration")
+	}
+
+	userIDs := []int{s.newUserID(t), s.newUserID(t), s.newUserID(t), s.newUserID(t)}
+	createdAt := time.Date(2024, time.January, 2, 3, 4, 5, 6000, time.UTC)
+	entries := []repository.ShortenedURLInfo{
+		{UserID: userIDs[1], ShortID: s.prefix + "-b", FullURL: s.fullURL(), CreatedAt: createdAt, Clicks: 7},
+		{UserID: userIDs[0], ShortID: s.prefix + "-a", FullURL: s.fullURL(), IsDeleted: true, CreatedAt: createdAt.Add(time.Hour)},
+		{UserID: userIDs[2], ShortID: s.prefix + "-c", FullURL: s.fullURL(), CreatedAt: createdAt, Clicks: 1},
+	}
+	require.NoError(t, importer.ImportEntries(s.ctx, entries))
+
+	assert.Equal(t, []repository.ShortenedURLInfo{entries[1], entries[0], entries[2]}, s.collectEntries(t, exporter, ""))
+	assert.Equal(t, []repository.ShortenedURLInfo{entries[0], entries[2]}, s.collectEntries(t, exporter, entries[1].ShortID))
+	assert.Empty(t, s.collectEntries(t, exporter, entries[2].ShortID))
+
+	// повторная загрузка не создает дубликатов, но переносит пометки удаления
+	entries[0].IsDeleted = true
+	require.NoError(t, importer.ImportEntries(s.ctx, append(slices.Clone(entries),
+		repository.ShortenedURLInfo{UserID: userIDs[0], ShortID: s.prefix + "-d", FullURL: entries[1].FullURL},
+		repository.ShortenedURLInfo{UserID: userIDs[3], ShortID: entries[2].ShortID, FullURL: s.fullURL(), IsDeleted: true},
+	)))
+
+	assert.Equal(t, []repository.ShortenedURLInfo{entries[1], entries[0], entries[2]}, s.collectEntries(t, exporter, ""))
+
+	lastUserID, err := exporter.LastUserID(s.ctx)
+	require.NoError(t, err)
+	require.NoError(t, importer.ReserveUserIDs(s.ctx, lastUserID+10))
+	require.NoError(t, importer.ReserveUserIDs(s.ctx, lastUserID+5))
+
+	reservedUserID, err := exporter.LastUserID(s.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, lastUserID+10, reservedUserID)
+	assert.Equal(t, lastUserID+11, s.newUserID(t))
+}
+
+// collectEntries выгружает ссылки теста с shortID больше afterShortID
+func (s *suite) collectEntries(t *testing.T, exporter repository.EntryExporter, afterShortID string) []repository.ShortenedURLInfo {
+	entries := make([]repository.ShortenedURLInfo, 0)
+	err := exporter.ForEachEntry(s.ctx, afterShortID, func(info repository.ShortenedURLInfo) error {
+		if strings.HasPrefix(info.ShortID, s.prefix+"-") {
+			entries = append(entries, info)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	return entries
+}
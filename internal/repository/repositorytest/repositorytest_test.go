@@ -0,0 +1,89 @@
+package repositorytest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/database"
+	"github.com/rovany706/url-shortener/internal/repository"
+)
+
+// testDatabaseDSNEnv переменная окружения со строкой подключения к тестовой БД Postgres
+const testDatabaseDSNEnv = "TEST_DATABASE_DSN"
+
+func TestMemoryRepository(t *testing.T) {
+	Run(t, func(t *testing.T) repository.Repository {
+		return repository.NewMemoryRepository()
+	})
+}
+
+func TestFileRepository(t *testing.T) {
+	Run(t, func(t *testing.T) repository.Repository {
+		repo, err := repository.NewFileRepository(afero.NewMemMapFs(), "storage.json")
+		require.NoError(t, err)
+
+		return repo
+	})
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	Run(t, func(t *testing.T) repository.Repository {
+		repo, err := repository.NewSQLiteRepository(context.Background(), filepath.Join(t.TempDir(), "shortener.db"))
+		require.NoError(t, err)
+
+		return repo
+	})
+}
+
+func TestBoltRepository(t *testing.T) {
+	Run(t, func(t *testing.T) repository.Repository {
+		repo, err := repository.NewBoltRepository(context.Background(), filepath.Join(t.TempDir(), "shortener.bolt"))
+		require.NoError(t, err)
+
+		return repo
+	})
+}
+
+func TestRedisRepository(t *testing.T) {
+	Run(t, func(t *testing.T) repository.Repository {
+		repo, err := repository.NewRedisRepository(context.Background(), miniredis.RunT(t).Addr())
+		require.NoError(t, err)
+
+		return repo
+	})
+}
+
+func TestDatabaseRepository(t *testing.T) {
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseDSNEnv)
+	}
+
+	Run(t, func(t *testing.T) repository.Repository {
+		repo, err := repository.NewDatabaseRepository(context.Background(), dsn, database.PoolConfig{})
+		require.NoError(t, err)
+
+		return repo
+	})
+}
+
+func TestCachedRepository(t *testing.T) {
+	Run(t, func(t *testing.T) repository.Repository {
+		return repository.NewCachedRepository(repository.NewMemoryRepository(), 100)
+	})
+}
+
+func TestBloomRepository(t *testing.T) {
+	Run(t, func(t *testing.T) repository.Repository {
+		repo, err := repository.NewBloomRepository(context.Background(), repository.NewMemoryRepository(), 1000, 0.01)
+		require.NoError(t, err)
+
+		return repo
+	})
+}
@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// shadowMaxInFlightSamples максимальное количество одновременных сверок чтения;
+// чтения сверх лимита не сверяются, чтобы медленное теневое хранилище не копило горутины
+const shadowMaxInFlightSamples = 64
+
+// ShadowSecondary теневое хранилище, в которое дублируется запись.
+// Ссылки загружаются через EntryImporter, чтобы сохранить ID пользователей основного хранилища.
+type ShadowSecondary interface {
+	Repository
+	EntryImporter
+}
+
+// ShadowStats статистика теневого хранилища
+type ShadowStats struct {
+	// Sampled количество чтений, сверенных с теневым хранилищем
+	Sampled uint64
+	// Mismatches количество сверенных чтений с различающимся результатом
+	Mismatches uint64
+	// SecondaryErrors количество ошибок записи и чтения теневого хранилища
+	SecondaryErrors uint64
+}
+
+// ShadowRepository репозиторий, дублирующий запись из основного хранилища в теневое.
+// Чтение выполняется из основного хранилища, часть чтений в фоне сверяется с теневым.
+// Ошибки теневого хранилища не возвращаются вызывающему, а только подсчитываются;
+// каждое расхождение логируется вместе с результатами обоих хранилищ,
+// поэтому с его помощью можно без остановки сервиса перейти на другое хранилище:
+// перенести данные командой shortener-migrate, включить теневую запись,
+// убедиться в отсутствии расхождений и поменять хранилища местами.
+type ShadowRepository struct {
+	Repository
+	secondary  ShadowSecondary
+	sampleRate float64
+	logger     *zap.Logger
+
+	samples         sync.WaitGroup
+	inFlight        chan struct{}
+	sampled         atomic.Uint64
+	mismatches      atomic.Uint64
+	secondaryErrors atomic.Uint64
+}
+
+// ShadowRepositoryOption функциональная опция ShadowRepository
+type ShadowRepositoryOption func(*ShadowRepository)
+
+// WithShadowReadSampleRate задает долю чтений, сверяемых с теневым хранилищем
+func WithShadowReadSampleRate(rate float64) ShadowRepositoryOption {
+	return func(r *ShadowRepository) {
+		r.sampleRate = rate
+	}
+}
+
+// WithShadowLogger задает логгер расхождений основного и теневого хранилищ
+func WithShadowLogger(logger *zap.Logger) ShadowRepositoryOption {
+	return func(r *ShadowRepository) {
+		r.logger = logger
+	}
+}
+
+// NewShadowRepository создает репозиторий, читающий из primary и дублирующий запись в secondary
+func NewShadowRepository(primary Repository, secondary ShadowSecondary, opts ...ShadowRepositoryOption) *ShadowRepository {
+	shadowRepository := &ShadowRepository{
+		Repository: primary,
+		secondary:  secondary,
+		logger:     zap.NewNop(),
+		inFlight:   make(chan struct{}, shadowMaxInFlightSamples),
+	}
+
+	for _, opt := range opts {
+		opt(shadowRepository)
+	}
+
+	return shadowRepository
+}
+
+// GetFullURL ищет ссылку в основном хранилище
+func (r *ShadowRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
+	shortenedURLInfo, ok = r.Repository.GetFullURL(ctx, shortID)
+	if !r.acquireSample() {
+		return shortenedURLInfo, ok
+	}
+
+	var primaryInfo *ShortenedURLInfo
+	if ok {
+		primaryInfo = new(ShortenedURLInfo)
+		*primaryInfo = *shortenedURLInfo
+	}
+
+	r.runSample(ctx, "GetFullURL", []zap.Field{zap.String("short_id", shortID), zap.Any("primary", primaryInfo)},
+		func(ctx context.Context) (bool, zap.Field, error) {
+			secondaryInfo, secondaryOK := r.secondary.GetFullURL(ctx, shortID)
+			if !secondaryOK {
+				secondaryInfo = nil
+			}
+			secondary := zap.Any("secondary", secondaryInfo)
+
+			if !ok || !secondaryOK {
+				return ok == secondaryOK, secondary, nil
+			}
+
+			return sameShadowEntry(*primaryInfo, *secondaryInfo), secondary, nil
+		})
+
+	return shortenedURLInfo, ok
+}
+
+// GetShortID ищет shortID ссылки в основном хранилище
+func (r *ShadowRepository) GetShortID(ctx context.Context, domain string, fullURL string) (shortID string, err error) {
+	shortID, err = r.Repository.GetShortID(ctx, domain, fullURL)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+
+	if !r.acquireSample() {
+		return shortID, err
+	}
+
+	r.runSample(ctx, "GetShortID", []zap.Field{zap.String("domain", domain), zap.String("full_url", fullURL), zap.String("primary", shortID)},
+		func(ctx context.Context) (bool, zap.Field, error) {
+			secondaryShortID, secondaryErr := r.secondary.GetShortID(ctx, domain, fullURL)
+			if secondaryErr != nil && !errors.Is(secondaryErr, ErrNotFound) {
+				return false, zap.Skip(), secondaryErr
+			}
+
+			match := shortID == secondaryShortID && errors.Is(secondaryErr, ErrNotFound) == errors.Is(err, ErrNotFound)
+
+			return match, zap.String("secondary", secondaryShortID), nil
+		})
+
+	return shortID, err
+}
+
+// GetUserEntries возвращает ссылки пользователя из основного хранилища
+func (r *ShadowRepository) GetUserEntries(ctx context.Context, userID int) (shortIDMap URLMapping, err error) {
+	shortIDMap, err = r.Repository.GetUserEntries(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.acquireSample() {
+		return shortIDMap, nil
+	}
+
+	primaryMap := maps.Clone(shortIDMap)
+	r.runSample(ctx, "GetUserEntries", []zap.Field{zap.Int("user_id", userID)},
+		func(ctx context.Context) (bool, zap.Field, error) {
+			secondaryMap, err := r.secondary.GetUserEntries(ctx, userID)
+			if err != nil {
+				return false, zap.Skip(), err
+			}
+
+			// ссылок пользователя может быть много, поэтому логируются только различающиеся
+			return maps.Equal(primaryMap, secondaryMap), zap.Object("diff", mappingDiff{primaryMap, secondaryMap}), nil
+		})
+
+	return shortIDMap, nil
+}
+
+// SaveEntry сохраняет ссылку в основном хранилище и при успехе дублирует ее в теневое
+func (r *ShadowRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
+	if err := r.Repository.SaveEntry(ctx, userID, shortID, fullURL); err != nil {
+		return err
+	}
+
+	r.importEntries(ctx, userID, URLMapping{shortID: fullURL})
+
+	return nil
+}
+
+// SaveEntries сохраняет ссылки в основном хранилище и дублирует сохраненные в теневое
+func (r *ShadowRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	conflicts, err = r.Repository.SaveEntries(ctx, userID, shortIDMap)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := make(URLMapping, len(shortIDMap))
+	for shortID, fullURL := range shortIDMap {
+		if _, ok := conflicts[shortID]; !ok {
+			saved[shortID] = fullURL
+		}
+	}
+	r.importEntries(ctx, userID, saved)
+
+	return conflicts, nil
+}
+
+// GetNewUserID выдает ID пользователя в основном хранилище и резервирует его в теневом
+func (r *ShadowRepository) GetNewUserID(ctx context.Context) (userID int, err error) {
+	userID, err = r.Repository.GetNewUserID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	r.countSecondaryError(r.secondary.ReserveUserIDs(ctx, userID))
+
+	return userID, nil
+}
+
+// DeleteUserURLs удаляет ссылки в основном хранилище и при успехе в теневом
+func (r *ShadowRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
+	if err := r.Repository.DeleteUserURLs(ctx, deleteRequests); err != nil {
+		return err
+	}
+
+	r.countSecondaryError(r.secondary.DeleteUserURLs(ctx, deleteRequests))
+
+	return nil
+}
+
+// DeleteUser помечает пользователя удаленным в основном хранилище и при успехе в теневом
+func (r *ShadowRepository) DeleteUser(ctx context.Context, userID int) error {
+	if err := r.Repository.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+
+	r.countSecondaryError(r.secondary.DeleteUser(ctx, userID))
+
+	return nil
+}
+
+// AddClicks увеличивает счетчики переходов в основном хранилище и при успехе в теневом
+func (r *ShadowRepository) AddClicks(ctx context.Context, clicks map[string]int64) error {
+	if err := r.Repository.AddClicks(ctx, clicks); err != nil {
+		return err
+	}
+
+	r.countSecondaryError(r.secondary.AddClicks(ctx, clicks))
+
+	return nil
+}
+
+// Stats возвращает статистику теневого хранилища
+func (r *ShadowRepository) Stats() ShadowStats {
+	return ShadowStats{
+		Sampled:         r.sampled.Load(),
+		Mismatches:      r.mismatches.Load(),
+		SecondaryErrors: r.secondaryErrors.Load(),
+	}
+}
+
+// Close дожидается завершения сверок и закрывает оба хранилища
+func (r *ShadowRepository) Close() error {
+	r.samples.Wait()
+
+	return errors.Join(r.Repository.Close(), r.secondary.Close())
+}
+
+// sameShadowEntry сравнивает ссылки основного и теневого хранилищ без учета времени создания,
+// которое теневое хранилище получает при дублировании записи
+func sameShadowEntry(primary ShortenedURLInfo, secondary ShortenedURLInfo) bool {
+	primary.CreatedAt, secondary.CreatedAt = time.Time{}, time.Time{}
+
+	return primary == secondary
+}
+
+// importEntries дублирует ссылки пользователя в теневое хранилище
+func (r *ShadowRepository) importEntries(ctx context.Context, userID int, shortIDMap URLMapping) {
+	if len(shortIDMap) == 0 {
+		return
+	}
+
+	createdAt := time.Now().UTC()
+	entries := make([]ShortenedURLInfo, 0, len(shortIDMap))
+	for shortID, fullURL := range shortIDMap {
+		entries = append(entries, ShortenedURLInfo{UserID: userID, ShortID: shortID, FullURL: fullURL, CreatedAt: createdAt})
+	}
+
+	r.countSecondaryError(r.secondary.ImportEntries(ctx, entries))
+}
+
+// acquireSample проверяет, попало ли чтение в выборку, и занимает место для сверки.
+// При успехе вызывающий должен передать сверку в runSample.
+func (r *ShadowRepository) acquireSample() bool {
+	if r.sampleRate <= 0 || rand.Float64() >= r.sampleRate {
+		return false
+	}
+
+	select {
+	case r.inFlight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// runSample в фоне сверяет результат чтения operation с теневым хранилищем.
+// compare возвращает совпадение результатов и поле лога с результатом теневого хранилища
+// или ошибку его чтения; fields описывают запрос и результат основного хранилища.
+func (r *ShadowRepository) runSample(ctx context.Context, operation string, fields []zap.Field,
+	compare func(ctx context.Context) (bool, zap.Field, error)) {
+	r.samples.Add(1)
+	go func() {
+		defer func() {
+			<-r.inFlight
+			r.samples.Done()
+		}()
+
+		match, secondary, err := compare(context.WithoutCancel(ctx))
+		if err != nil {
+			r.countSecondaryError(err)
+			return
+		}
+
+		r.sampled.Add(1)
+		if !match {
+			r.mismatches.Add(1)
+			fields = append([]zap.Field{zap.String("operation", operation)}, append(fields, secondary)...)
+			r.logger.Warn("shadow storage mismatch", fields...)
+		}
+	}()
+}
+
+func (r *ShadowRepository) countSecondaryError(err error) {
+	if err != nil {
+		r.secondaryErrors.Add(1)
+	}
+}
+
+// mappingDiff логирует ссылки, различающиеся в основном и теневом хранилищах
+type mappingDiff struct {
+	primary   URLMapping
+	secondary URLMapping
+}
+
+// MarshalLogObject добавляет в лог shortID -> полные ссылки обоих хранилищ для различающихся ссылок
+func (d mappingDiff) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	shortIDs := slices.Collect(maps.Keys(d.primary))
+	for shortID := range d.secondary {
+		if _, ok := d.primary[shortID]; !ok {
+			shortIDs = append(shortIDs, shortID)
+		}
+	}
+	slices.Sort(shortIDs)
+
+	for _, shortID := range shortIDs {
+		primary, secondary := d.primary[shortID], d.secondary[shortID]
+		if primary == secondary {
+			continue
+		}
+
+		if err := enc.AddObject(shortID, zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("primary", primary)
+			enc.AddString("secondary", secondary)
+			return nil
+		})); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
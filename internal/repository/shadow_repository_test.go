@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// unavailableSecondaryRepository теневое хранилище, запись в которое завершается ошибкой
+type unavailableSecondaryRepository struct {
+	*MemoryRepository
+}
+
+func (r *unavailableSecondaryRepository) ImportEntries(ctx context.Context, entries []ShortenedURLInfo) error {
+	return errors.New("secondary is unavailable")
+}
+
+func TestShadowRepositoryWrites(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMemoryRepository()
+	sqliteRepository, err := NewSQLiteRepository(ctx, filepath.Join(t.TempDir(), "shadow.db"))
+	require.NoError(t, err)
+	secondary := sqliteRepository.(*SQLiteRepository)
+
+	repository := NewShadowRepository(primary, secondary)
+	defer repository.Close()
+
+	userID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, repository.SaveEntry(ctx, userID, "1", "http://example.com/1"))
+	conflicts, err := repository.SaveEntries(ctx, userID, URLMapping{"1": "http://example.com/1", "2": "http://example.com/2"})
+	require.NoError(t, err)
+	assert.Equal(t, URLMapping{"1": "http://example.com/1"}, conflicts)
+
+	err = repository.DeleteUserURLs(ctx, []models.UserDeleteRequest{{UserID: userID, ShortIDToDelete: "2"}})
+	require.NoError(t, err)
+
+	// ошибка основного хранилища не дублируется в теневое
+	assert.ErrorIs(t, repository.SaveEntry(ctx, userID, "1", "http://example.com/3"), ErrConflict)
+
+	require.NoError(t, repository.AddClicks(ctx, map[string]int64{"1": 2}))
+
+	// время создания в теневом хранилище задается при дублировании записи
+	primaryEntries, secondaryEntries := collectEntries(t, primary, ""), collectEntries(t, secondary, "")
+	require.Len(t, secondaryEntries, len(primaryEntries))
+	for i := range primaryEntries {
+		assert.WithinDuration(t, primaryEntries[i].CreatedAt, secondaryEntries[i].CreatedAt, time.Second)
+		secondaryEntries[i].CreatedAt = primaryEntries[i].CreatedAt
+	}
+	assert.Equal(t, primaryEntries, secondaryEntries)
+	assert.Equal(t, int64(2), primaryEntries[0].Clicks)
+	assert.Equal(t, ShadowStats{}, repository.Stats())
+
+	// ID пользователя, выданный основным хранилищем, зарезервирован в теневом
+	secondaryUserID, err := secondary.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, userID+1, secondaryUserID)
+}
+
+func TestShadowRepositorySampledReads(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMemoryRepository()
+	secondary := NewMemoryRepository()
+
+	core, logs := observer.New(zap.WarnLevel)
+	repository := NewShadowRepository(primary, secondary, WithShadowReadSampleRate(1), WithShadowLogger(zap.New(core)))
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+	// ссылка, сохраненная в обход репозитория, есть только в основном хранилище
+	require.NoError(t, primary.SaveEntry(ctx, 1, "2", "http://example.com/2"))
+
+	info, ok := repository.GetFullURL(ctx, "1")
+	require.True(t, ok)
+	assert.Equal(t, "http://example.com/1", info.FullURL)
+
+	_, ok = repository.GetFullURL(ctx, "2")
+	require.True(t, ok)
+
+	_, ok = repository.GetFullURL(ctx, "3")
+	require.False(t, ok)
+
+	shortID, err := repository.GetShortID(ctx, "", "http://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, "1", shortID)
+
+	entries, err := repository.GetUserEntries(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	require.NoError(t, repository.Close())
+	assert.Equal(t, ShadowStats{Sampled: 5, Mismatches: 2}, repository.Stats())
+
+	// каждое расхождение логируется с результатами обоих хранилищ
+	mismatches := make(map[string]map[string]any)
+	for _, entry := range logs.FilterMessage("shadow storage mismatch").AllUntimed() {
+		fields := entry.ContextMap()
+		mismatches[fields["operation"].(string)] = fields
+	}
+	require.Len(t, mismatches, 2)
+
+	getFullURL := mismatches["GetFullURL"]
+	assert.Equal(t, "2", getFullURL["short_id"])
+	assert.Equal(t, "http://example.com/2", getFullURL["primary"].(*ShortenedURLInfo).FullURL)
+	assert.Nil(t, getFullURL["secondary"])
+
+	getUserEntries := mismatches["GetUserEntries"]
+	assert.Equal(t, int64(1), getUserEntries["user_id"])
+	assert.Equal(t, map[string]any{"2": map[string]any{"primary": "http://example.com/2", "secondary": ""}}, getUserEntries["diff"])
+}
+
+func TestShadowRepositorySecondaryErrors(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMemoryRepository()
+
+	repository := NewShadowRepository(primary, &unavailableSecondaryRepository{NewMemoryRepository()}, WithShadowReadSampleRate(0))
+	defer repository.Close()
+
+	require.NoError(t, repository.SaveEntry(ctx, 1, "1", "http://example.com/1"))
+	_, err := repository.SaveEntries(ctx, 1, URLMapping{"2": "http://example.com/2"})
+	require.NoError(t, err)
+
+	_, ok := repository.GetFullURL(ctx, "1")
+	assert.True(t, ok)
+	assert.Equal(t, ShadowStats{SecondaryErrors: 2}, repository.Stats())
+}
+
+func TestNewAppRepositoryShadowStorage(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	appConfig := config.NewConfig(
+		config.WithStorageLocation(config.SQLite, filepath.Join(dir, "shortener.db")),
+		config.WithShadowStorage("bolt", filepath.Join(dir, "shortener.bolt"), 0, true),
+	)
+
+	repository, err := NewAppRepository(ctx, appConfig, zap.NewNop())
+	require.NoError(t, err)
+	defer repository.Close()
+
+	shadowRepository, ok := repository.(*ShadowRepository)
+	require.True(t, ok)
+	assert.IsType(t, &BoltRepository{}, shadowRepository.Repository)
+	assert.IsType(t, &SQLiteRepository{}, shadowRepository.secondary)
+}
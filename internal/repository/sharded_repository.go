@@ -0,0 +1,484 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// errStopIteration останавливает обход ForEachEntry после заполнения пакета
+var errStopIteration = errors.New("stop iteration")
+
+// RebalanceStats результат перераспределения ссылок между шардами
+type RebalanceStats struct {
+	// Moved количество ссылок, скопированных на новый шард
+	Moved int
+	// Purged количество ссылок, удаленных с прежнего шарда
+	Purged int
+	// Conflicts shortID ссылок, оставленных на прежнем шарде: свой шард не сохранил их,
+	// потому что на нем уже есть другая ссылка с тем же shortID или полной ссылкой
+	Conflicts []string
+}
+
+// ShardedRepository репозиторий, распределяющий ссылки между шардами
+// по консистентному хешу shortID.
+//
+// Запросы по shortID направляются на один шард, GetShortID, GetUserEntries и ListUserEntries
+// опрашивают все шарды параллельно, SaveEntries, DeleteUserURLs и AddClicks разбиваются по шардам
+// и выполняются параллельно, поэтому запись набора ссылок атомарна только в пределах шарда.
+// Полная ссылка уникальна в пространстве имен домена, а ключ ссылки вычисляется из домена
+// и полной ссылки, поэтому одна и та же ссылка домена попадает на один шард, и конфликты
+// обнаруживаются самим шардом. При коллизии коротких ID ключ берется из следующего варианта
+// той же последовательности (см. app.GetShortID), поэтому повторное сокращение ссылки
+// упирается в ту же коллизию и находит сохраненную ссылку через GetShortID.
+// Одна ссылка в разных доменах имеет разные ключи и может храниться на разных шардах.
+//
+// ID пользователей выдает первый шард; шарды, которым пользователь нужен до сохранения
+// его ссылок (UserImporter), получают его при выдаче ID.
+//
+// Шард учитывает только ссылки, которые принадлежат ему по кольцу, поэтому копии,
+// оставшиеся на прежнем шарде после добавления шардов, не видны при чтении.
+type ShardedRepository struct {
+	shards []Repository
+	ring   *hashRing
+}
+
+// NewShardedRepository создает репозиторий поверх shards.
+// Порядок шардов определяет распределение ссылок: новые шарды добавляются только в конец.
+func NewShardedRepository(shards []Repository) *ShardedRepository {
+	return &ShardedRepository{
+		shards: shards,
+		ring:   newHashRing(len(shards)),
+	}
+}
+
+// GetFullURL ищет ссылку на шарде shortID
+func (r *ShardedRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
+	return r.shardOf(shortID).GetFullURL(ctx, shortID)
+}
+
+// SaveEntry сохраняет ссылку на шарде shortID
+func (r *ShardedRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
+	return r.shardOf(shortID).SaveEntry(ctx, userID, shortID, fullURL)
+}
+
+// SaveEntries сохраняет ссылки, параллельно записывая на каждый шард его часть набора
+func (r *ShardedRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	parts := make([]URLMapping, len(r.shards))
+	for shortID, fullURL := range shortIDMap {
+		shard := r.ring.locate(shortID)
+		if parts[shard] == nil {
+			parts[shard] = make(URLMapping)
+		}
+		parts[shard][shortID] = fullURL
+	}
+
+	var mutex sync.Mutex
+	conflicts = make(URLMapping)
+	err = r.forEachShard(func(shard int) error {
+		if parts[shard] == nil {
+			return nil
+		}
+
+		shardConflicts, err := r.shards[shard].SaveEntries(ctx, userID, parts[shard])
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		for shortID, fullURL := range shardConflicts {
+			conflicts[shortID] = fullURL
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+// GetShortID ищет shortID ссылки домена на всех шардах
+func (r *ShardedRepository) GetShortID(ctx context.Context, domain string, fullURL string) (shortID string, err error) {
+	var mutex sync.Mutex
+	err = r.forEachShard(func(shard int) error {
+		found, err := r.shards[shard].GetShortID(ctx, domain, fullURL)
+		if errors.Is(err, ErrNotFound) || (err == nil && !r.owns(shard, found)) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		shortID = found
+
+		return nil
+	})
+
+	if shortID != "" {
+		return shortID, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return "", ErrNotFound
+}
+
+// GetUserEntries объединяет ссылки пользователя со всех шардов
+func (r *ShardedRepository) GetUserEntries(ctx context.Context, userID int) (shortIDMap URLMapping, err error) {
+	var mutex sync.Mutex
+	shortIDMap = make(URLMapping)
+	err = r.forEachShard(func(shard int) error {
+		entries, err := r.shards[shard].GetUserEntries(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		for shortID, fullURL := range entries {
+			if r.owns(shard, shortID) {
+				shortIDMap[shortID] = fullURL
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return shortIDMap, nil
+}
+
+// ListUserEntries параллельно выбирает страницу ссылок пользователя на каждом шарде
+// и объединяет их в одну страницу
+func (r *ShardedRepository) ListUserEntries(ctx context.Context, userID int, query UserEntriesQuery) (entries []ShortenedURLInfo, err error) {
+	var mutex sync.Mutex
+	entries = make([]ShortenedURLInfo, 0)
+	err = r.forEachShard(func(shard int) error {
+		shardEntries, err := r.listShardUserEntries(ctx, shard, userID, query)
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		entries = append(entries, shardEntries...)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return query.page(entries), nil
+}
+
+// listShardUserEntries выбирает на шарде до query.Limit принадлежащих ему ссылок пользователя.
+// Ссылки других шардов пропускаются, и выборка продолжается с позиции последней полученной ссылки.
+func (r *ShardedRepository) listShardUserEntries(ctx context.Context, shard int, userID int, query UserEntriesQuery) ([]ShortenedURLInfo, error) {
+	owned := make([]ShortenedURLInfo, 0)
+	for {
+		entries, err := r.shards[shard].ListUserEntries(ctx, userID, query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, info := range entries {
+			if r.owns(shard, info.ShortID) {
+				owned = append(owned, info)
+			}
+		}
+
+		if query.Limit <= 0 || len(entries) < query.Limit || len(owned) >= query.Limit {
+			return owned, nil
+		}
+
+		query.After = NewUserEntriesCursor(entries[len(entries)-1])
+	}
+}
+
+// AddClicks параллельно увеличивает счетчики переходов на шардах ссылок
+func (r *ShardedRepository) AddClicks(ctx context.Context, clicks map[string]int64) error {
+	parts := make([]map[string]int64, len(r.shards))
+	for shortID, count := range clicks {
+		shard := r.ring.locate(shortID)
+		if parts[shard] == nil {
+			parts[shard] = make(map[string]int64)
+		}
+		parts[shard][shortID] = count
+	}
+
+	return r.forEachShard(func(shard int) error {
+		if parts[shard] == nil {
+			return nil
+		}
+
+		return r.shards[shard].AddClicks(ctx, parts[shard])
+	})
+}
+
+// ForEachShortID последовательно обходит shortID всех шардов
+func (r *ShardedRepository) ForEachShortID(ctx context.Context, fn func(shortID string) error) error {
+	for shard, repository := range r.shards {
+		err := repository.ForEachShortID(ctx, func(shortID string) error {
+			if !r.owns(shard, shortID) {
+				return nil
+			}
+			return fn(shortID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetNewUserID выдает ID пользователя на первом шарде и сохраняет пользователя на остальных
+func (r *ShardedRepository) GetNewUserID(ctx context.Context) (userID int, err error) {
+	userID, err = r.shards[0].GetNewUserID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	err = r.forEachShard(func(shard int) error {
+		importer, ok := r.shards[shard].(UserImporter)
+		if shard == 0 || !ok {
+			return nil
+		}
+
+		return importer.ImportUsers(ctx, []int{userID})
+	})
+
+	if err != nil {
+		return -1, err
+	}
+
+	return userID, nil
+}
+
+// DeleteUserURLs параллельно удаляет ссылки на их шардах
+func (r *ShardedRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
+	parts := make([][]models.UserDeleteRequest, len(r.shards))
+	for _, request := range deleteRequests {
+		shard := r.ring.locate(request.ShortIDToDelete)
+		parts[shard] = append(parts[shard], request)
+	}
+
+	return r.forEachShard(func(shard int) error {
+		if len(parts[shard]) == 0 {
+			return nil
+		}
+
+		return r.shards[shard].DeleteUserURLs(ctx, parts[shard])
+	})
+}
+
+// DeleteUser помечает пользователя удаленным на всех шардах
+func (r *ShardedRepository) DeleteUser(ctx context.Context, userID int) error {
+	return r.forEachShard(func(shard int) error {
+		return r.shards[shard].DeleteUser(ctx, userID)
+	})
+}
+
+// DeletedUsers возвращает ID удаленных пользователей с первого шарда, который выдает ID пользователей
+func (r *ShardedRepository) DeletedUsers(ctx context.Context) (userIDs []int, err error) {
+	return r.shards[0].DeletedUsers(ctx)
+}
+
+// Ping проверяет доступность всех шардов
+func (r *ShardedRepository) Ping(ctx context.Context) error {
+	return r.forEachShard(func(shard int) error {
+		return r.shards[shard].Ping(ctx)
+	})
+}
+
+// Close закрывает все шарды
+func (r *ShardedRepository) Close() error {
+	errs := make([]error, len(r.shards))
+	for i, shard := range r.shards {
+		errs[i] = shard.Close()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Rebalance переносит ссылки, оказавшиеся не на своем шарде после добавления шардов.
+// Сначала на шарды, которым это нужно, копируются пользователи первого шарда,
+// затем ссылки пакетами по batchSize копируются на свой шард и удаляются с прежнего,
+// если он поддерживает удаление (EntryPurger). С прежнего шарда удаляются только ссылки,
+// которые после копирования читаются со своего шарда без изменений; остальные остаются
+// на месте и возвращаются в Conflicts. Повторный запуск безопасен и переносит
+// ссылки, сохраненные на прежние шарды после предыдущего запуска.
+// Все шарды должны поддерживать EntryExporter и EntryImporter.
+func (r *ShardedRepository) Rebalance(ctx context.Context, batchSize int) (stats RebalanceStats, err error) {
+	exporters := make([]EntryExporter, len(r.shards))
+	importers := make([]EntryImporter, len(r.shards))
+	for shard, repository := range r.shards {
+		exporter, exportOK := repository.(EntryExporter)
+		importer, importOK := repository.(EntryImporter)
+		if !exportOK || !importOK {
+			return RebalanceStats{}, ErrNotImplemented
+		}
+		exporters[shard] = exporter
+		importers[shard] = importer
+	}
+
+	if err = r.syncUsers(ctx, exporters[0], batchSize); err != nil {
+		return RebalanceStats{}, err
+	}
+
+	for shard := range r.shards {
+		shardStats, err := r.rebalanceShard(ctx, shard, exporters[shard], importers, batchSize)
+		stats.Moved += shardStats.Moved
+		stats.Purged += shardStats.Purged
+		stats.Conflicts = append(stats.Conflicts, shardStats.Conflicts...)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// syncUsers сохраняет пользователей первого шарда на остальных шардах, которым они нужны
+func (r *ShardedRepository) syncUsers(ctx context.Context, first EntryExporter, batchSize int) error {
+	lastUserID, err := first.LastUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, repository := range r.shards[1:] {
+		importer, ok := repository.(UserImporter)
+		if !ok {
+			continue
+		}
+
+		for from := 1; from <= lastUserID; from += batchSize {
+			userIDs := make([]int, 0, batchSize)
+			for userID := from; userID <= lastUserID && userID < from+batchSize; userID++ {
+				userIDs = append(userIDs, userID)
+			}
+
+			if err = importer.ImportUsers(ctx, userIDs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// rebalanceShard переносит с шарда ссылки, принадлежащие другим шардам
+func (r *ShardedRepository) rebalanceShard(ctx context.Context, shard int, exporter EntryExporter, importers []EntryImporter, batchSize int) (stats RebalanceStats, err error) {
+	purger, canPurge := r.shards[shard].(EntryPurger)
+
+	for afterShortID, done := "", false; !done; {
+		batch := make([]ShortenedURLInfo, 0, batchSize)
+		done = true
+		err = exporter.ForEachEntry(ctx, afterShortID, func(info ShortenedURLInfo) error {
+			afterShortID = info.ShortID
+			if r.owns(shard, info.ShortID) {
+				return nil
+			}
+
+			batch = append(batch, info)
+			if len(batch) < batchSize {
+				return nil
+			}
+
+			done = false
+			return errStopIteration
+		})
+
+		if err != nil && !errors.Is(err, errStopIteration) {
+			return stats, err
+		}
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		owners := make(map[int][]ShortenedURLInfo)
+		for _, info := range batch {
+			owner := r.ring.locate(info.ShortID)
+			owners[owner] = append(owners[owner], info)
+		}
+
+		for owner, entries := range owners {
+			if err = importers[owner].ImportEntries(ctx, entries); err != nil {
+				return stats, err
+			}
+		}
+
+		// ImportEntries пропускает ссылки, shortID или полная ссылка которых уже есть на шарде,
+		// поэтому с прежнего шарда удаляются только ссылки, действительно сохраненные на своем
+		shortIDs := make([]string, 0, len(batch))
+		for _, info := range batch {
+			if !r.storedOnOwner(ctx, info) {
+				stats.Conflicts = append(stats.Conflicts, info.ShortID)
+				continue
+			}
+			shortIDs = append(shortIDs, info.ShortID)
+		}
+		stats.Moved += len(shortIDs)
+
+		if canPurge && len(shortIDs) > 0 {
+			if err = purger.PurgeEntries(ctx, shortIDs); err != nil {
+				return stats, err
+			}
+			stats.Purged += len(shortIDs)
+		}
+	}
+
+	return stats, nil
+}
+
+// storedOnOwner проверяет, что ссылка читается со своего шарда с тем же владельцем,
+// полной ссылкой и пометкой удаления
+func (r *ShardedRepository) storedOnOwner(ctx context.Context, info ShortenedURLInfo) bool {
+	stored, ok := r.shardOf(info.ShortID).GetFullURL(ctx, info.ShortID)
+
+	return ok && stored.UserID == info.UserID && stored.FullURL == info.FullURL && stored.IsDeleted == info.IsDeleted
+}
+
+// shardOf возвращает шард, которому принадлежит shortID
+func (r *ShardedRepository) shardOf(shortID string) Repository {
+	return r.shards[r.ring.locate(shortID)]
+}
+
+// owns проверяет, принадлежит ли shortID шарду
+func (r *ShardedRepository) owns(shard int, shortID string) bool {
+	return r.ring.locate(shortID) == shard
+}
+
+// forEachShard параллельно вызывает fn для каждого шарда и объединяет ошибки
+func (r *ShardedRepository) forEachShard(fn func(shard int) error) error {
+	errs := make([]error, len(r.shards))
+
+	var wg sync.WaitGroup
+	for shard := range r.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[shard] = fn(shard)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
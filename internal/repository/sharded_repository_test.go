@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+// newTestShards создает count шардов SQLite, требующих сохранения пользователей до их ссылок
+func newTestShards(t *testing.T, dir string, count int) []Repository {
+	shards := make([]Repository, count)
+	for i := range shards {
+		shard, err := NewSQLiteRepository(context.Background(), filepath.Join(dir, fmt.Sprintf("shard%d.db", i)))
+		require.NoError(t, err)
+		shards[i] = shard
+	}
+
+	return shards
+}
+
+func TestShardedRepository(t *testing.T) {
+	ctx := context.Background()
+	shards := newTestShards(t, t.TempDir(), 3)
+	repository := NewShardedRepository(shards)
+	defer repository.Close()
+
+	userID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+
+	shortIDMap := make(URLMapping)
+	for i := 0; i < 30; i++ {
+		shortIDMap[fmt.Sprintf("id%02d", i)] = fmt.Sprintf("http://example.com/%d", i)
+	}
+
+	conflicts, err := repository.SaveEntries(ctx, userID, shortIDMap)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	for shard, repo := range shards {
+		entries, err := repo.GetUserEntries(ctx, userID)
+		require.NoError(t, err)
+		assert.NotEmpty(t, entries, "shard %d", shard)
+		for shortID := range entries {
+			assert.Equal(t, shard, repository.ring.locate(shortID))
+		}
+	}
+
+	require.NoError(t, repository.SaveEntry(ctx, userID, "extra", "http://example.com/extra"))
+	assert.ErrorIs(t, repository.SaveEntry(ctx, userID, "extra", "http://example.com/extra"), ErrConflict)
+	shortIDMap["extra"] = "http://example.com/extra"
+
+	conflicts, err = repository.SaveEntries(ctx, userID, URLMapping{"id00": "http://example.com/0", "new": "http://example.com/new"})
+	require.NoError(t, err)
+	assert.Equal(t, URLMapping{"id00": "http://example.com/0"}, conflicts)
+	shortIDMap["new"] = "http://example.com/new"
+
+	entries, err := repository.GetUserEntries(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, shortIDMap, entries)
+
+	shortID, err := repository.GetShortID(ctx, "", "http://example.com/7")
+	require.NoError(t, err)
+	assert.Equal(t, "id07", shortID)
+
+	_, err = repository.GetShortID(ctx, "", "http://example.com/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	deleteRequests := make([]models.UserDeleteRequest, 0, 10)
+	for i := 0; i < 10; i++ {
+		deleteRequests = append(deleteRequests, models.UserDeleteRequest{UserID: userID, ShortIDToDelete: fmt.Sprintf("id%02d", i)})
+	}
+	require.NoError(t, repository.DeleteUserURLs(ctx, deleteRequests))
+
+	for i := 0; i < 30; i++ {
+		info, ok := repository.GetFullURL(ctx, fmt.Sprintf("id%02d", i))
+		require.True(t, ok)
+		assert.Equal(t, i < 10, info.IsDeleted)
+	}
+
+	count := 0
+	require.NoError(t, repository.ForEachShortID(ctx, func(shortID string) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, len(shortIDMap), count)
+
+	nextUserID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, userID+1, nextUserID)
+}
+
+func TestShardedRepositoryRebalance(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	shards := newTestShards(t, dir, 3)
+
+	// данные двух шардов до добавления третьего
+	before := NewShardedRepository(shards[:2])
+	userIDs := make([]int, 3)
+	for i := range userIDs {
+		userID, err := before.GetNewUserID(ctx)
+		require.NoError(t, err)
+		userIDs[i] = userID
+	}
+
+	shortIDMap := make(URLMapping)
+	for i := 0; i < 60; i++ {
+		shortIDMap[fmt.Sprintf("id%02d", i)] = fmt.Sprintf("http://example.com/%d", i)
+	}
+	_, err := before.SaveEntries(ctx, userIDs[0], shortIDMap)
+	require.NoError(t, err)
+	require.NoError(t, before.DeleteUserURLs(ctx, []models.UserDeleteRequest{{UserID: userIDs[0], ShortIDToDelete: "id01"}}))
+
+	repository := NewShardedRepository(shards)
+	defer repository.Close()
+
+	stats, err := repository.Rebalance(ctx, 4)
+	require.NoError(t, err)
+	assert.NotZero(t, stats.Moved)
+	assert.Equal(t, stats.Moved, stats.Purged)
+
+	entries, err := repository.GetUserEntries(ctx, userIDs[0])
+	require.NoError(t, err)
+	assert.Equal(t, shortIDMap, entries)
+
+	info, ok := repository.GetFullURL(ctx, "id01")
+	require.True(t, ok)
+	assert.True(t, info.IsDeleted)
+
+	for shard, repo := range shards {
+		err := repo.(EntryExporter).ForEachEntry(ctx, "", func(info ShortenedURLInfo) error {
+			assert.Equal(t, shard, repository.ring.locate(info.ShortID))
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	// пользователи первого шарда скопированы на новый шард
+	newShortID := ""
+	for i := 0; newShortID == ""; i++ {
+		if candidate := fmt.Sprintf("new%d", i); repository.ring.locate(candidate) == 2 {
+			newShortID = candidate
+		}
+	}
+	require.NoError(t, repository.SaveEntry(ctx, userIDs[2], newShortID, "http://example.com/new"))
+
+	stats, err = repository.Rebalance(ctx, 4)
+	require.NoError(t, err)
+	assert.Equal(t, RebalanceStats{}, stats)
+}
+
+func TestShardedRepositoryRebalanceConflict(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	shards := newTestShards(t, dir, 3)
+
+	before := NewShardedRepository(shards[:2])
+	userID, err := before.GetNewUserID(ctx)
+	require.NoError(t, err)
+
+	repository := NewShardedRepository(shards)
+	defer repository.Close()
+
+	// ссылки, которые после добавления третьего шарда принадлежат ему
+	movedShortID, conflictShortID := "", ""
+	for i := 0; conflictShortID == ""; i++ {
+		candidate := fmt.Sprintf("id%d", i)
+		if repository.ring.locate(candidate) != 2 {
+			continue
+		}
+		if movedShortID == "" {
+			movedShortID = candidate
+		} else {
+			conflictShortID = candidate
+		}
+	}
+
+	require.NoError(t, before.SaveEntry(ctx, userID, movedShortID, "http://example.com/moved"))
+	require.NoError(t, before.SaveEntry(ctx, userID, conflictShortID, "http://example.com/old"))
+	oldShard := before.ring.locate(conflictShortID)
+
+	// на новом шарде уже есть другая ссылка с тем же shortID
+	require.NoError(t, shards[2].(UserImporter).ImportUsers(ctx, []int{userID}))
+	require.NoError(t, shards[2].SaveEntry(ctx, userID, conflictShortID, "http://example.com/new"))
+
+	stats, err := repository.Rebalance(ctx, 4)
+	require.NoError(t, err)
+	assert.Equal(t, RebalanceStats{Moved: 1, Purged: 1, Conflicts: []string{conflictShortID}}, stats)
+
+	info, ok := repository.GetFullURL(ctx, movedShortID)
+	require.True(t, ok)
+	assert.Equal(t, "http://example.com/moved", info.FullURL)
+
+	// ссылка с прежнего шарда не удалена
+	info, ok = shards[oldShard].GetFullURL(ctx, conflictShortID)
+	require.True(t, ok)
+	assert.Equal(t, "http://example.com/old", info.FullURL)
+
+	info, ok = repository.GetFullURL(ctx, conflictShortID)
+	require.True(t, ok)
+	assert.Equal(t, "http://example.com/new", info.FullURL)
+}
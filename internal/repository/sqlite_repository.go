@@ -0,0 +1,520 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/rovany706/url-shortener/internal/database"
+	"github.com/rovany706/url-shortener/internal/models"
+)
+
+var (
+	sqliteInsertEntrySQL = fmt.Sprintf(
+		`INSERT INTO %s (short_id, full_url, user_id, is_deleted, created_at)
+		VALUES (?, ?, ?, false, ?)`, database.ShortLinksTableName)
+	sqliteInsertEntrySQLBatch = fmt.Sprintf(
+		`INSERT INTO %s (short_id, full_url, user_id, is_deleted, created_at)
+			VALUES (?, ?, ?, false, ?)
+			ON CONFLICT DO NOTHING`, database.ShortLinksTableName)
+	sqliteSelectFullURLSQL = fmt.Sprintf(
+		`SELECT user_id, short_id, full_url, is_deleted, created_at, clicks FROM %s
+		WHERE short_id = ?`, database.ShortLinksTableName)
+	sqliteSelectShortIDSQL = fmt.Sprintf(
+		`SELECT short_id FROM %s
+		WHERE domain = ? AND full_url = ?`, database.ShortLinksTableName)
+	sqliteSelectUserURLs = fmt.Sprintf(
+		`SELECT short_id, full_url FROM %s
+		WHERE user_id = ?`, database.ShortLinksTableName)
+	sqliteSelectShortIDsSQL = fmt.Sprintf(
+		`SELECT short_id FROM %s`, database.ShortLinksTableName)
+	sqliteSelectEntriesAfterSQL = fmt.Sprintf(
+		`SELECT user_id, short_id, full_url, is_deleted, created_at, clicks FROM %s
+		WHERE short_id > ?
+		ORDER BY short_id`, database.ShortLinksTableName)
+	sqliteImportEntrySQL = fmt.Sprintf(
+		`INSERT INTO %[1]s (short_id, full_url, user_id, is_deleted, created_at, clicks)
+			SELECT ?, ?, ?, ?, ?, ?
+			WHERE NOT EXISTS (SELECT 1 FROM %[1]s WHERE short_id = ?)
+			ON CONFLICT DO NOTHING`, database.ShortLinksTableName)
+	sqliteAddClicksSQL = fmt.Sprintf(
+		`UPDATE %s
+		SET clicks = clicks + ?
+		WHERE short_id = ?`, database.ShortLinksTableName)
+	sqliteInsertUserSQL = fmt.Sprintf(
+		`INSERT OR IGNORE INTO %s (id) VALUES (?)`, database.UsersTableName)
+	sqlitePurgeEntrySQL = fmt.Sprintf(
+		`DELETE FROM %s WHERE short_id = ?`, database.ShortLinksTableName)
+	sqliteSelectLastUserIDSQL = fmt.Sprintf(
+		`SELECT COALESCE(MAX(id), 0) FROM %s`, database.UsersTableName)
+	sqliteInsertNewUserSQL = fmt.Sprintf(
+		`INSERT INTO %s DEFAULT VALUES RETURNING id;`,
+		database.UsersTableName)
+	sqliteDeleteUserSQL = fmt.Sprintf(
+		`UPDATE %s SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, database.UsersTableName)
+	sqliteSelectDeletedUsersSQL = fmt.Sprintf(
+		`SELECT id FROM %s WHERE deleted_at IS NOT NULL ORDER BY id`, database.UsersTableName)
+	sqliteDeleteShortLinkSQL = fmt.Sprintf(
+		`UPDATE %s
+		SET is_deleted = true
+		WHERE short_id = ? AND user_id = ?`,
+		database.ShortLinksTableName)
+)
+
+// sqliteListUserEntriesSQL возвращает запрос выборки ссылок пользователя для query.
+// Аргументы запроса: userID, позиция query.After (если задана) и ограничение количества.
+func sqliteListUserEntriesSQL(query UserEntriesQuery) string {
+	sortColumn, direction, operator := "created_at", "ASC", ">"
+	if query.sortBy() == SortByClicks {
+		sortColumn = "clicks"
+	}
+	if query.Descending {
+		direction, operator = "DESC", "<"
+	}
+
+	var afterCondition string
+	if query.After != nil {
+		afterCondition = fmt.Sprintf("AND (%s, short_id) %s (?, ?)", sortColumn, operator)
+	}
+
+	return fmt.Sprintf(
+		`SELECT user_id, short_id, full_url, is_deleted, created_at, clicks FROM %s
+		WHERE user_id = ? %s
+		ORDER BY %[3]s %[4]s, short_id %[4]s
+		LIMIT ?`, database.ShortLinksTableName, afterCondition, sortColumn, direction)
+}
+
+// SQLiteRepository репозиторий, использующий встроенную базу данных SQLite
+type SQLiteRepository struct {
+	db *database.Database
+}
+
+// NewSQLiteRepository открывает базу данных SQLite по пути path
+func NewSQLiteRepository(ctx context.Context, path string) (Repository, error) {
+	db, err := database.InitSQLiteConnection(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	sqliteRepository := SQLiteRepository{db: db}
+
+	if err = sqliteRepository.db.EnsureCreatedSQLite(ctx); err != nil {
+		db.DBConnection.Close()
+		return nil, err
+	}
+
+	return &sqliteRepository, nil
+}
+
+// GetFullURL ищет в хранилище полную ссылку на ресурс по короткому ID
+func (repository *SQLiteRepository) GetFullURL(ctx context.Context, shortID string) (shortenedURLInfo *ShortenedURLInfo, ok bool) {
+	row := repository.db.DBConnection.QueryRowContext(ctx, sqliteSelectFullURLSQL, shortID)
+	shortenedURLInfo, err := scanSQLiteEntry(row)
+	if err != nil {
+		return nil, false
+	}
+
+	return shortenedURLInfo, true
+}
+
+// SaveEntry сохраняет в хранилище информацию о сокращенной ссылке.
+// Возвращает ErrConflict, если ссылка или короткий ID уже сохранены.
+func (repository *SQLiteRepository) SaveEntry(ctx context.Context, userID int, shortID string, fullURL string) error {
+	_, err := repository.db.DBConnection.ExecContext(ctx, sqliteInsertEntrySQL, shortID, fullURL, userID, sqliteTime(time.Now()))
+	if isSQLiteConstraintViolation(err) {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// SaveEntries записывает набор сокращенных ссылок.
+// Уже сохраненные ссылки пропускаются и возвращаются как конфликтующие.
+func (repository *SQLiteRepository) SaveEntries(ctx context.Context, userID int, shortIDMap URLMapping) (conflicts URLMapping, err error) {
+	tx, err := repository.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, sqliteInsertEntrySQLBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	createdAt := sqliteTime(time.Now())
+	conflicts = make(URLMapping)
+	for shortID, fullURL := range shortIDMap {
+		result, err := stmt.ExecContext(ctx, shortID, fullURL, userID, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+
+		if inserted == 0 {
+			conflicts[shortID] = fullURL
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+// GetShortID возвращает shortID сокращенной ссылки в пространстве имен домена.
+// Возвращает ErrNotFound, если ссылка не сохранена.
+func (repository *SQLiteRepository) GetShortID(ctx context.Context, domain string, fullURL string) (shortID string, err error) {
+	row := repository.db.DBConnection.QueryRowContext(ctx, sqliteSelectShortIDSQL, domain, fullURL)
+	err = row.Scan(&shortID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return shortID, nil
+}
+
+// GetUserEntries возвращает сокращенный пользователем ссылки по userID
+func (repository *SQLiteRepository) GetUserEntries(ctx context.Context, userID int) (shortIDMap URLMapping, err error) {
+	rows, err := repository.db.DBConnection.QueryContext(ctx, sqliteSelectUserURLs, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	shortIDMap = make(URLMapping)
+	for rows.Next() {
+		var shortID, fullURL string
+		if err = rows.Scan(&shortID, &fullURL); err != nil {
+			return nil, err
+		}
+
+		shortIDMap[shortID] = fullURL
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shortIDMap, nil
+}
+
+// ForEachShortID вызывает fn для shortID каждой сохраненной ссылки
+func (repository *SQLiteRepository) ForEachShortID(ctx context.Context, fn func(shortID string) error) error {
+	rows, err := repository.db.DBConnection.QueryContext(ctx, sqliteSelectShortIDsSQL)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortID string
+		if err = rows.Scan(&shortID); err != nil {
+			return err
+		}
+
+		if err = fn(shortID); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ListUserEntries возвращает ссылки пользователя в порядке и с ограничениями query
+func (repository *SQLiteRepository) ListUserEntries(ctx context.Context, userID int, query UserEntriesQuery) (entries []ShortenedURLInfo, err error) {
+	args := []any{userID}
+	if query.After != nil {
+		if query.sortBy() == SortByClicks {
+			args = append(args, query.After.Clicks, query.After.ShortID)
+		} else {
+			args = append(args, sqliteTime(query.After.CreatedAt), query.After.ShortID)
+		}
+	}
+
+	limit := -1
+	if query.Limit > 0 {
+		limit = query.Limit
+	}
+	args = append(args, limit)
+
+	rows, err := repository.db.DBConnection.QueryContext(ctx, sqliteListUserEntriesSQL(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries = make([]ShortenedURLInfo, 0)
+	for rows.Next() {
+		info, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, *info)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// AddClicks увеличивает счетчики переходов по ссылкам
+func (repository *SQLiteRepository) AddClicks(ctx context.Context, clicks map[string]int64) error {
+	shortIDs := make([]string, 0, len(clicks))
+	for shortID := range clicks {
+		shortIDs = append(shortIDs, shortID)
+	}
+
+	return repository.execEach(ctx, sqliteAddClicksSQL, len(shortIDs), func(i int) []any {
+		return []any{clicks[shortIDs[i]], shortIDs[i]}
+	})
+}
+
+// GetNewUserID возвращает ID нового пользователя
+func (repository *SQLiteRepository) GetNewUserID(ctx context.Context) (userID int, err error) {
+	row := repository.db.DBConnection.QueryRowContext(ctx, sqliteInsertNewUserSQL)
+
+	if err = row.Scan(&userID); err != nil {
+		return -1, err
+	}
+
+	return userID, nil
+}
+
+// DeleteUser сохраняет время удаления пользователя userID
+func (repository *SQLiteRepository) DeleteUser(ctx context.Context, userID int) error {
+	_, err := repository.db.DBConnection.ExecContext(ctx, sqliteDeleteUserSQL, sqliteTime(time.Now()), userID)
+
+	return err
+}
+
+// DeletedUsers возвращает ID удаленных пользователей в порядке возрастания
+func (repository *SQLiteRepository) DeletedUsers(ctx context.Context) (userIDs []int, err error) {
+	rows, err := repository.db.DBConnection.QueryContext(ctx, sqliteSelectDeletedUsersSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	userIDs = make([]int, 0)
+	for rows.Next() {
+		var userID int
+		if err = rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// DeleteUserURLs помечает удаленными ссылки, принадлежащие пользователям из запросов
+func (repository *SQLiteRepository) DeleteUserURLs(ctx context.Context, deleteRequests []models.UserDeleteRequest) error {
+	tx, err := repository.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, sqliteDeleteShortLinkSQL)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, request := range deleteRequests {
+		if _, err = stmt.ExecContext(ctx, request.ShortIDToDelete, request.UserID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Ping проверяет подключение к БД
+func (repository *SQLiteRepository) Ping(ctx context.Context) error {
+	return repository.db.DBConnection.PingContext(ctx)
+}
+
+// Close закрывает подключение к БД
+func (repository *SQLiteRepository) Close() error {
+	return repository.db.DBConnection.Close()
+}
+
+// ForEachEntry вызывает fn для каждой ссылки с shortID больше afterShortID в порядке возрастания shortID
+func (repository *SQLiteRepository) ForEachEntry(ctx context.Context, afterShortID string, fn func(info ShortenedURLInfo) error) error {
+	rows, err := repository.db.DBConnection.QueryContext(ctx, sqliteSelectEntriesAfterSQL, afterShortID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		info, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return err
+		}
+
+		if err = fn(*info); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// LastUserID возвращает наибольший выданный ID пользователя
+func (repository *SQLiteRepository) LastUserID(ctx context.Context) (userID int, err error) {
+	err = repository.db.DBConnection.QueryRowContext(ctx, sqliteSelectLastUserIDSQL).Scan(&userID)
+
+	return userID, err
+}
+
+// ImportEntries атомарно сохраняет ссылки с их владельцами и пометками удаления
+func (repository *SQLiteRepository) ImportEntries(ctx context.Context, entries []ShortenedURLInfo) error {
+	tx, err := repository.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, info := range entries {
+		if _, err = tx.ExecContext(ctx, sqliteInsertUserSQL, info.UserID); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, sqliteImportEntrySQL,
+			info.ShortID, info.FullURL, info.UserID, info.IsDeleted, sqliteTime(info.CreatedAt), info.Clicks, info.ShortID)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDeleted {
+			if _, err = tx.ExecContext(ctx, sqliteDeleteShortLinkSQL, info.ShortID, info.UserID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReserveUserIDs гарантирует, что новые ID пользователей будут больше lastUserID
+func (repository *SQLiteRepository) ReserveUserIDs(ctx context.Context, lastUserID int) error {
+	if lastUserID <= 0 {
+		return nil
+	}
+
+	_, err := repository.db.DBConnection.ExecContext(ctx, sqliteInsertUserSQL, lastUserID)
+
+	return err
+}
+
+// ImportUsers сохраняет пользователей с заданными ID
+func (repository *SQLiteRepository) ImportUsers(ctx context.Context, userIDs []int) error {
+	return repository.execEach(ctx, sqliteInsertUserSQL, len(userIDs), func(i int) []any {
+		return []any{userIDs[i]}
+	})
+}
+
+// PurgeEntries удаляет ссылки из БД
+func (repository *SQLiteRepository) PurgeEntries(ctx context.Context, shortIDs []string) error {
+	return repository.execEach(ctx, sqlitePurgeEntrySQL, len(shortIDs), func(i int) []any {
+		return []any{shortIDs[i]}
+	})
+}
+
+// execEach выполняет запрос query в транзакции count раз с аргументами args(i)
+func (repository *SQLiteRepository) execEach(ctx context.Context, query string, count int, args func(i int) []any) error {
+	tx, err := repository.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := 0; i < count; i++ {
+		if _, err = stmt.ExecContext(ctx, args(i)...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// sqliteRow строка результата запроса
+type sqliteRow interface {
+	Scan(dest ...any) error
+}
+
+// scanSQLiteEntry читает ссылку из строки результата запроса
+func scanSQLiteEntry(row sqliteRow) (*ShortenedURLInfo, error) {
+	var info ShortenedURLInfo
+	var createdAt int64
+	if err := row.Scan(&info.UserID, &info.ShortID, &info.FullURL, &info.IsDeleted, &createdAt, &info.Clicks); err != nil {
+		return nil, err
+	}
+
+	info.CreatedAt = sqliteParseTime(createdAt)
+
+	return &info, nil
+}
+
+// sqliteTime возвращает время в наносекундах Unix (0 для нулевого времени)
+func sqliteTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+// sqliteParseTime возвращает время по количеству наносекунд Unix (нулевое время для 0)
+func sqliteParseTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos).UTC()
+}
+
+func isSQLiteConstraintViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code()&0xff == sqlite3.SQLITE_CONSTRAINT
+}
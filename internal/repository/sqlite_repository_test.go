@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/database"
+)
+
+func newTestSQLiteRepository(t *testing.T) (Repository, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "shortener.db")
+	repository, err := NewSQLiteRepository(context.Background(), path)
+	require.NoError(t, err)
+	t.Cleanup(func() { repository.Close() })
+
+	return repository, path
+}
+
+func TestSQLiteRepositoryPersistence(t *testing.T) {
+	ctx := context.Background()
+	repository, path := newTestSQLiteRepository(t)
+
+	userID, err := repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, userID)
+
+	require.NoError(t, repository.SaveEntry(ctx, userID, "1", "http://example.com/1"))
+	require.NoError(t, repository.Ping(ctx))
+	require.NoError(t, repository.Close())
+
+	repository, err = NewSQLiteRepository(ctx, path)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	_, ok := repository.GetFullURL(ctx, "1")
+	assert.True(t, ok)
+
+	userID, err = repository.GetNewUserID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, userID)
+}
+
+func TestSQLiteRepositoryUpgradeSchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "shortener.db")
+
+	// база данных, созданная до добавления времени создания и счетчика переходов
+	db, err := database.InitSQLiteConnection(ctx, path)
+	require.NoError(t, err)
+	_, err = db.DBConnection.ExecContext(ctx, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT);
+		CREATE TABLE short_links (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			short_id text NOT NULL,
+			full_url text UNIQUE NOT NULL,
+			is_deleted boolean NOT NULL,
+			user_id INTEGER REFERENCES users(id)
+		);
+		INSERT INTO users (id) VALUES (1), (2);
+		INSERT INTO short_links (short_id, full_url, is_deleted, user_id) VALUES ('1', 'http://example.com/1', false, 1);`)
+	require.NoError(t, err)
+	require.NoError(t, db.DBConnection.Close())
+
+	repository, err := NewSQLiteRepository(ctx, path)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	info, ok := repository.GetFullURL(ctx, "1")
+	require.True(t, ok)
+	assert.Equal(t, &ShortenedURLInfo{UserID: 1, ShortID: "1", FullURL: "http://example.com/1"}, info)
+
+	require.NoError(t, repository.AddClicks(ctx, map[string]int64{"1": 1}))
+	require.NoError(t, repository.SaveEntry(ctx, 1, "2", "http://example.com/2"))
+
+	// ссылка уникальна в пределах домена, а не всего хранилища
+	require.NoError(t, repository.SaveEntry(ctx, 2, "go.brand.com/1", "http://example.com/1"))
+	err = repository.SaveEntry(ctx, 2, "go.brand.com/2", "http://example.com/1")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	entries, err := repository.ListUserEntries(ctx, 1, UserEntriesQuery{Sort: SortByClicks, Descending: true})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "1", entries[0].ShortID)
+	assert.Equal(t, int64(1), entries[0].Clicks)
+	assert.False(t, entries[1].CreatedAt.IsZero())
+}
+
+func TestSQLiteRepositoryRekeyDuplicateShortIDs(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "shortener.db")
+
+	// база данных, созданная до уникального индекса коротких ID
+	db, err := database.InitSQLiteConnection(ctx, path)
+	require.NoError(t, err)
+	_, err = db.DBConnection.ExecContext(ctx, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT);
+		CREATE TABLE short_links (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			short_id text NOT NULL,
+			full_url text UNIQUE NOT NULL,
+			is_deleted boolean NOT NULL,
+			user_id INTEGER REFERENCES users(id)
+		);
+		CREATE INDEX short_links_short_id_idx ON short_links (short_id);
+		INSERT INTO users (id) VALUES (1), (2);
+		INSERT INTO short_links (short_id, full_url, is_deleted, user_id) VALUES
+			('1', 'http://example.com/1', false, 1),
+			('1', 'http://example.com/2', false, 2);`)
+	require.NoError(t, err)
+	require.NoError(t, db.DBConnection.Close())
+
+	repository, err := NewSQLiteRepository(ctx, path)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	info, ok := repository.GetFullURL(ctx, "1")
+	require.True(t, ok)
+	assert.Equal(t, "http://example.com/1", info.FullURL)
+
+	entries, err := repository.GetUserEntries(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, URLMapping{"1-2": "http://example.com/2"}, entries)
+
+	err = repository.SaveEntry(ctx, 1, "1", "http://example.com/3")
+	assert.ErrorIs(t, err, ErrConflict)
+}
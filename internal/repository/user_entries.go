@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UserEntriesSort поле сортировки ссылок пользователя
+type UserEntriesSort string
+
+// Поля сортировки ссылок пользователя
+const (
+	// SortByCreatedAt сортировка по времени создания ссылки
+	SortByCreatedAt UserEntriesSort = "created_at"
+	// SortByClicks сортировка по количеству переходов по ссылке
+	SortByClicks UserEntriesSort = "clicks"
+)
+
+// UserEntriesCursor позиция ссылки в порядке сортировки, после которой начинается страница
+type UserEntriesCursor struct {
+	// CreatedAt время создания ссылки
+	CreatedAt time.Time
+	// Clicks количество переходов по ссылке
+	Clicks int64
+	// ShortID короткий ID ссылки; упорядочивает ссылки с одинаковым значением поля сортировки
+	ShortID string
+}
+
+// UserEntriesQuery параметры выборки ссылок пользователя.
+// Ссылки упорядочиваются по полю Sort, а при его равенстве - по shortID (побайтовое сравнение строк),
+// поэтому порядок стабилен, и страницы выбираются по позиции последней ссылки (keyset).
+type UserEntriesQuery struct {
+	// Sort поле сортировки (пусто - SortByCreatedAt)
+	Sort UserEntriesSort
+	// Descending флаг сортировки по убыванию
+	Descending bool
+	// After позиция, после которой начинается страница (nil - с первой ссылки)
+	After *UserEntriesCursor
+	// Limit максимальное количество ссылок (0 - без ограничения)
+	Limit int
+}
+
+// NewUserEntriesCursor возвращает позицию ссылки info
+func NewUserEntriesCursor(info ShortenedURLInfo) *UserEntriesCursor {
+	return &UserEntriesCursor{
+		CreatedAt: info.CreatedAt,
+		Clicks:    info.Clicks,
+		ShortID:   info.ShortID,
+	}
+}
+
+// sortBy возвращает поле сортировки с учетом значения по умолчанию
+func (q UserEntriesQuery) sortBy() UserEntriesSort {
+	if q.Sort == "" {
+		return SortByCreatedAt
+	}
+
+	return q.Sort
+}
+
+// compare сравнивает позиции двух ссылок в порядке выборки
+func (q UserEntriesQuery) compare(a, b *UserEntriesCursor) int {
+	var result int
+	if q.sortBy() == SortByClicks {
+		result = cmp.Compare(a.Clicks, b.Clicks)
+	} else {
+		result = a.CreatedAt.Compare(b.CreatedAt)
+	}
+
+	if result == 0 {
+		result = strings.Compare(a.ShortID, b.ShortID)
+	}
+
+	if q.Descending {
+		return -result
+	}
+
+	return result
+}
+
+// page упорядочивает ссылки и возвращает страницу выборки
+func (q UserEntriesQuery) page(infos []ShortenedURLInfo) []ShortenedURLInfo {
+	if q.After != nil {
+		infos = slices.DeleteFunc(infos, func(info ShortenedURLInfo) bool {
+			return q.compare(NewUserEntriesCursor(info), q.After) <= 0
+		})
+	}
+
+	slices.SortFunc(infos, func(a, b ShortenedURLInfo) int {
+		return q.compare(NewUserEntriesCursor(a), NewUserEntriesCursor(b))
+	})
+
+	if q.Limit > 0 && len(infos) > q.Limit {
+		infos = infos[:q.Limit]
+	}
+
+	return infos
+}
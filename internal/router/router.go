@@ -2,6 +2,7 @@ package router
 
 import (
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
 
 	"github.com/rovany706/url-shortener/internal/handlers"
@@ -14,11 +15,13 @@ func GetRouter(
 	shortenHandlers handlers.ShortenURLHandlers,
 	userHandlers handlers.UserHandlers,
 	redirectHandlers handlers.RedirectHandlers,
+	webhookHandlers handlers.WebhookHandlers,
 	repository repository.Repository,
 	logger *zap.Logger,
 ) chi.Router {
 	r := chi.NewRouter()
 
+	r.Use(chimiddleware.RequestID)
 	r.Use(middleware.ResponseLogger(logger))
 	r.Use(middleware.RequestLogger(logger))
 	r.Use(middleware.RequestGzipCompress())
@@ -28,6 +31,7 @@ func GetRouter(
 
 	registerShortenHandlers(r, shortenHandlers)
 	registerUserHandlers(r, userHandlers)
+	registerWebhookHandlers(r, webhookHandlers)
 	registerRedirectHandlers(r, redirectHandlers)
 
 	return r
@@ -46,4 +50,14 @@ func registerShortenHandlers(router chi.Router, shortenHandlers handlers.Shorten
 func registerUserHandlers(router chi.Router, userHandlers handlers.UserHandlers) {
 	router.Get("/api/user/urls", userHandlers.GetUserURLsHandler())
 	router.Delete("/api/user/urls", userHandlers.DeleteUserURLsHandler())
+	router.Get("/api/user/export", userHandlers.ExportUserDataHandler())
+	router.Get("/api/user/audit", userHandlers.GetUserAuditHandler())
+	router.Delete("/api/user", userHandlers.DeleteUserHandler())
+}
+
+func registerWebhookHandlers(router chi.Router, webhookHandlers handlers.WebhookHandlers) {
+	router.Post("/api/user/webhooks", webhookHandlers.CreateWebhookHandler())
+	router.Get("/api/user/webhooks", webhookHandlers.GetWebhooksHandler())
+	router.Get("/api/user/webhooks/deliveries", webhookHandlers.GetWebhookDeliveriesHandler())
+	router.Delete("/api/user/webhooks/{id}", webhookHandlers.DeleteWebhookHandler())
 }
@@ -15,11 +15,13 @@ import (
 	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/audit"
 	authMock "github.com/rovany706/url-shortener/internal/auth/mock"
 	"github.com/rovany706/url-shortener/internal/config"
 	"github.com/rovany706/url-shortener/internal/handlers"
 	"github.com/rovany706/url-shortener/internal/repository/mock"
 	serviceMock "github.com/rovany706/url-shortener/internal/service/mock"
+	"github.com/rovany706/url-shortener/internal/webhook"
 )
 
 func gzipCompressString(s string) ([]byte, error) {
@@ -152,6 +154,20 @@ func TestMainRouter(t *testing.T) {
 			body:         "",
 			expectedCode: http.StatusMethodNotAllowed,
 		},
+		{
+			name:         "GET /api/user/audit unauthorized test",
+			request:      "/api/user/audit",
+			method:       http.MethodGet,
+			body:         "",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "GET /api/user/webhooks unauthorized test",
+			request:      "/api/user/webhooks",
+			method:       http.MethodGet,
+			body:         "",
+			expectedCode: http.StatusUnauthorized,
+		},
 	}
 
 	for _, tt := range tests {
@@ -168,11 +184,13 @@ func TestMainRouter(t *testing.T) {
 			tokenManager.EXPECT().CreateToken(1).Return("token", nil).AnyTimes()
 			deleteService := serviceMock.NewMockDeleteService(ctrl)
 
-			userHandlers := handlers.NewUserHandlers(deleteService, tokenManager, repository, appConfig, logger)
-			redirectHandlers := handlers.NewRedirectHandlers(shortener)
-			shortenHandlers := handlers.NewShortenURLHandlers(shortener, tokenManager, repository, appConfig, logger)
+			userHandlers := handlers.NewUserHandlers(deleteService, tokenManager, repository, audit.NopLog{}, appConfig, logger)
+			clickService := serviceMock.NewMockClickService(ctrl)
+			clickService.EXPECT().Add(gomock.Any()).AnyTimes()
+			redirectHandlers := handlers.NewRedirectHandlers(shortener, clickService, webhook.NopPublisher{}, appConfig, logger)
+			shortenHandlers := handlers.NewShortenURLHandlers(shortener, tokenManager, repository, audit.NopLog{}, webhook.NopPublisher{}, appConfig, logger)
 
-			r := GetRouter(shortenHandlers, userHandlers, redirectHandlers, repository, logger)
+			r := GetRouter(shortenHandlers, userHandlers, redirectHandlers, handlers.NewWebhookHandlers(webhook.NewMemoryStore(), tokenManager, logger), repository, logger)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 
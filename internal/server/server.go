@@ -2,33 +2,50 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
 
 	"github.com/rovany706/url-shortener/internal/app"
+	"github.com/rovany706/url-shortener/internal/audit"
 	"github.com/rovany706/url-shortener/internal/auth"
 	"github.com/rovany706/url-shortener/internal/config"
+	"github.com/rovany706/url-shortener/internal/deletequeue"
 	"github.com/rovany706/url-shortener/internal/handlers"
 	"github.com/rovany706/url-shortener/internal/repository"
 	"github.com/rovany706/url-shortener/internal/router"
 	"github.com/rovany706/url-shortener/internal/service"
+	"github.com/rovany706/url-shortener/internal/webhook"
 )
 
+// shutdownTimeout время ожидания обработки начатых запросов при остановке сервера
+const shutdownTimeout = time.Second * 10
+
 // Server сервер приложения
 type Server struct {
 	appConfig     *config.AppConfig
 	app           app.URLShortener
 	repository    repository.Repository
+	auditLog      audit.Log
+	webhookStore  webhook.Store
+	dispatcher    *webhook.Dispatcher
+	deleteQueue   deletequeue.Queue
 	deleteService service.DeleteService
+	clickService  service.ClickService
 	tokenManager  auth.TokenManager
 	logger        *zap.Logger
+	// stopWorkers завершает фоновые обработчики, запущенные RunServer
+	stopWorkers context.CancelFunc
+	// statsDone закрывается после завершения периодического логирования статистики
+	statsDone chan struct{}
 }
 
 // NewServer инициализирует работу сервера
 func NewServer(appConfig *config.AppConfig, logger *zap.Logger) (*Server, error) {
-	repository, err := repository.NewAppRepository(context.Background(), appConfig)
+	repo, err := repository.NewAppRepository(context.Background(), appConfig, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -36,51 +53,125 @@ func NewServer(appConfig *config.AppConfig, logger *zap.Logger) (*Server, error)
 	tokenManager, err := auth.NewJWTTokenManager(nil)
 
 	if err != nil {
+		repo.Close()
+		return nil, err
+	}
+
+	if err = revokeDeletedUsers(context.Background(), repo, tokenManager); err != nil {
+		repo.Close()
 		return nil, err
 	}
 
-	app := app.NewURLShortenerApp(repository)
+	// журнал аудита, вебхуки и очередь удаления в Postgres работают через пул хранилища ссылок
+	pool := repository.DatabasePool(repo)
+
+	auditLog, err := audit.NewAppLog(appConfig, pool)
+	if err != nil {
+		repo.Close()
+		return nil, err
+	}
 
-	deleteService := service.NewDeleteService(repository)
+	webhookStore, err := webhook.NewAppStore(context.Background(), appConfig, pool)
+	if err != nil {
+		repo.Close()
+		auditLog.Close()
+		return nil, err
+	}
+
+	dispatcherOpts := []webhook.DispatcherOption{
+		webhook.WithClickSampleRate(appConfig.WebhookClickSampleRate),
+		webhook.WithRetry(appConfig.WebhookMaxAttempts, appConfig.WebhookRetryDelay, appConfig.WebhookMaxRetryDelay),
+		webhook.WithTimeout(appConfig.WebhookTimeout),
+		webhook.WithRetention(appConfig.WebhookRetention),
+		webhook.WithLogger(logger),
+	}
+	if appConfig.WebhookPrivateNetworks {
+		dispatcherOpts = append(dispatcherOpts, webhook.WithPrivateNetworks())
+	}
+	dispatcher := webhook.NewDispatcher(webhookStore, dispatcherOpts...)
+
+	deleteQueue, err := deletequeue.NewAppQueue(context.Background(), appConfig, pool)
+	if err != nil {
+		repo.Close()
+		auditLog.Close()
+		webhookStore.Close()
+		return nil, err
+	}
+
+	app := app.NewURLShortenerApp(repo)
+
+	deleteService := service.NewDeleteService(repo, deleteQueue, auditLog, dispatcher, service.WithDeleteLogger(logger))
+	clickService := service.NewClickService(repo, service.WithClickLogger(logger))
 
 	return &Server{
 		appConfig:     appConfig,
 		app:           app,
-		repository:    repository,
+		repository:    repo,
+		auditLog:      auditLog,
+		webhookStore:  webhookStore,
+		dispatcher:    dispatcher,
+		deleteQueue:   deleteQueue,
 		deleteService: deleteService,
+		clickService:  clickService,
 		tokenManager:  tokenManager,
 		logger:        logger,
 	}, nil
 }
 
-// RunServer зупаскает сервер
-func (server *Server) RunServer() error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	server.deleteService.StartWorker(ctx)
+// revokeDeletedUsers отзывает токены пользователей, удаленных до запуска сервера
+func revokeDeletedUsers(ctx context.Context, repository repository.Repository, tokenManager auth.TokenManager) error {
+	userIDs, err := repository.DeletedUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err = tokenManager.RevokeUserTokens(userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunServer зупаскает сервер и фоновые обработчики.
+// При завершении ctx сервер перестает принимать соединения и дожидается обработки начатых запросов.
+func (server *Server) RunServer(ctx context.Context) error {
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	server.stopWorkers = stopWorkers
+	server.deleteService.StartWorker(workersCtx)
+	server.clickService.StartWorker(workersCtx)
+	server.dispatcher.StartWorker(workersCtx)
+	server.startStatsLogger(workersCtx)
 
 	userHandlers := handlers.NewUserHandlers(
 		server.deleteService,
 		server.tokenManager,
 		server.repository,
+		server.auditLog,
 		server.appConfig,
 		server.logger,
 	)
 
-	redirectHandlers := handlers.NewRedirectHandlers(server.app)
+	redirectHandlers := handlers.NewRedirectHandlers(server.app, server.clickService, server.dispatcher, server.appConfig, server.logger)
 
 	shortenHandlers := handlers.NewShortenURLHandlers(
 		server.app,
 		server.tokenManager,
 		server.repository,
+		server.auditLog,
+		server.dispatcher,
 		server.appConfig,
 		server.logger,
 	)
 
+	webhookHandlers := handlers.NewWebhookHandlers(server.webhookStore, server.tokenManager, server.logger)
+
 	r := router.GetRouter(
 		shortenHandlers,
 		userHandlers,
 		redirectHandlers,
+		webhookHandlers,
 		server.repository,
 		server.logger,
 	)
@@ -90,10 +181,97 @@ func (server *Server) RunServer() error {
 		r.Mount("/debug", middleware.Profiler())
 	}
 
-	return http.ListenAndServe(server.appConfig.AppRunAddress, r)
+	httpServer := &http.Server{
+		Addr:    server.appConfig.AppRunAddress,
+		Handler: r,
+	}
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		shutdownDone <- httpServer.Shutdown(shutdownCtx)
+	}()
+
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return <-shutdownDone
 }
 
-// StopServer завершает работу сервера
+// StopServer завершает работу сервера: останавливает фоновые обработчики, дожидается записи
+// накопленных переходов и начатых удалений и только после этого закрывает хранилища
 func (server *Server) StopServer() {
+	if server.stopWorkers != nil {
+		server.stopWorkers()
+		server.clickService.Wait()
+		server.deleteService.Wait()
+		server.dispatcher.Wait()
+		<-server.statsDone
+	}
+
+	server.logRepositoryStats()
+	server.deleteQueue.Close()
+	server.webhookStore.Close()
+	server.auditLog.Close()
 	server.repository.Close()
 }
+
+// startStatsLogger логирует статистику декораторов репозитория каждые StatsLogInterval
+// до завершения ctx; при нулевом периоде статистика логируется только при остановке
+func (server *Server) startStatsLogger(ctx context.Context) {
+	server.statsDone = make(chan struct{})
+	if server.appConfig.StatsLogInterval <= 0 {
+		close(server.statsDone)
+		return
+	}
+
+	go func() {
+		defer close(server.statsDone)
+
+		ticker := time.NewTicker(server.appConfig.StatsLogInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				server.logRepositoryStats()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// logRepositoryStats логирует статистику декораторов репозитория
+func (server *Server) logRepositoryStats() {
+	for decorated := server.repository; ; {
+		switch r := decorated.(type) {
+		case *repository.BloomRepository:
+			server.logger.Info("bloom filter stats", zap.Uint64("rejected", r.Rejected()))
+			decorated = r.Repository
+		case *repository.CachedRepository:
+			stats := r.Stats()
+			server.logger.Info("redirect cache stats",
+				zap.Uint64("hits", stats.Hits),
+				zap.Uint64("misses", stats.Misses),
+				zap.Int("size", stats.Size),
+			)
+			decorated = r.Repository
+		case *repository.ShadowRepository:
+			stats := r.Stats()
+			server.logger.Info("shadow storage stats",
+				zap.Uint64("sampled", stats.Sampled),
+				zap.Uint64("mismatches", stats.Mismatches),
+				zap.Uint64("secondary_errors", stats.SecondaryErrors),
+			)
+			decorated = r.Repository
+		default:
+			return
+		}
+	}
+}
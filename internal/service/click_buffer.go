@@ -0,0 +1,47 @@
+package service
+
+import (
+	"sync"
+)
+
+// ClickBuffer буфер для накопления переходов по сокращенным ссылкам
+type ClickBuffer struct {
+	clicks map[string]int64
+	mutex  sync.Mutex
+}
+
+// NewClickBuffer создает экземпляр ClickBuffer
+func NewClickBuffer() *ClickBuffer {
+	return &ClickBuffer{
+		clicks: make(map[string]int64),
+	}
+}
+
+// Add учитывает переход по ссылке shortID
+func (cb *ClickBuffer) Add(shortID string) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.clicks[shortID]++
+}
+
+// Flush возвращает накопленные счетчики переходов и очищает буфер
+func (cb *ClickBuffer) Flush() map[string]int64 {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	clicks := cb.clicks
+	cb.clicks = make(map[string]int64)
+
+	return clicks
+}
+
+// Merge возвращает в буфер счетчики переходов clicks, например не записанные в хранилище
+func (cb *ClickBuffer) Merge(clicks map[string]int64) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	for shortID, count := range clicks {
+		cb.clicks[shortID] += count
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/repository/mock"
+)
+
+func TestClickBufferAddAndFlush(t *testing.T) {
+	cBuf := NewClickBuffer()
+	cBuf.Add("a")
+	cBuf.Add("b")
+	cBuf.Add("a")
+
+	assert.Equal(t, map[string]int64{"a": 2, "b": 1}, cBuf.Flush())
+	assert.Empty(t, cBuf.Flush())
+}
+
+func TestClickServiceFlush(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	require.NoError(t, repo.SaveEntry(ctx, 1, "a", "http://example.com/a"))
+
+	clickService := NewClickService(repo)
+	clickService.Add("a")
+	clickService.Add("a")
+	clickService.Add("unknown")
+	require.NoError(t, clickService.flush(ctx))
+	// пустой буфер не записывается
+	require.NoError(t, clickService.flush(ctx))
+
+	info, ok := repo.GetFullURL(ctx, "a")
+	require.True(t, ok)
+	assert.Equal(t, int64(2), info.Clicks)
+}
+
+func TestClickServiceFlushError(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock.NewMockRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().AddClicks(gomock.Any(), map[string]int64{"a": 1}).Return(errors.New("connection refused")),
+		// не записанные переходы объединяются с новыми
+		repo.EXPECT().AddClicks(gomock.Any(), map[string]int64{"a": 2, "b": 1}).Return(nil),
+	)
+
+	clickService := NewClickService(repo, WithClickLogger(zaptest.NewLogger(t)))
+	clickService.Add("a")
+	require.Error(t, clickService.flush(ctx))
+
+	clickService.Add("a")
+	clickService.Add("b")
+	require.NoError(t, clickService.flush(ctx))
+}
+
+func TestClickServiceWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := repository.NewMemoryRepository()
+	require.NoError(t, repo.SaveEntry(ctx, 1, "a", "http://example.com/a"))
+
+	clickService := NewClickService(repo)
+	clickService.StartWorker(ctx)
+	clickService.Add("a")
+
+	// после остановки обработчика накопленные переходы уже записаны
+	cancel()
+	clickService.Wait()
+
+	info, ok := repo.GetFullURL(context.Background(), "a")
+	require.True(t, ok)
+	assert.Equal(t, int64(1), info.Clicks)
+}
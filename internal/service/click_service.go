@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/rovany706/url-shortener/internal/repository"
+)
+
+const (
+	clickFlushTimePeriod = time.Second * 10
+)
+
+// ClickService интерфейс сервиса подсчета переходов по ссылкам
+type ClickService interface {
+	Add(shortID string)
+	StartWorker(context.Context)
+	// Wait ожидает завершения обработчика, запущенного StartWorker, после завершения его контекста
+	Wait()
+}
+
+// ClickServiceImpl сервис подсчета переходов по ссылкам.
+// Переходы накапливаются в буфере и периодически записываются в хранилище одним запросом;
+// не записанные из-за ошибки переходы возвращаются в буфер и записываются при следующей попытке.
+type ClickServiceImpl struct {
+	flushTicker *time.Ticker
+	clickBuffer *ClickBuffer
+	repo        repository.Repository
+	logger      *zap.Logger
+	done        chan struct{}
+}
+
+// ClickServiceOption функциональная опция ClickServiceImpl
+type ClickServiceOption func(*ClickServiceImpl)
+
+// WithClickLogger задает логгер ошибок записи переходов
+func WithClickLogger(logger *zap.Logger) ClickServiceOption {
+	return func(cs *ClickServiceImpl) {
+		cs.logger = logger
+	}
+}
+
+// NewClickService создает ClickServiceImpl
+func NewClickService(repo repository.Repository, opts ...ClickServiceOption) *ClickServiceImpl {
+	cs := &ClickServiceImpl{
+		clickBuffer: NewClickBuffer(),
+		repo:        repo,
+		logger:      zap.NewNop(),
+		done:        make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(cs)
+	}
+
+	return cs
+}
+
+// Add учитывает переход по ссылке
+func (cs *ClickServiceImpl) Add(shortID string) {
+	cs.clickBuffer.Add(shortID)
+}
+
+// StartWorker запускает сервис в отдельной горутине.
+// При завершении ctx накопленные переходы записываются в хранилище.
+func (cs *ClickServiceImpl) StartWorker(ctx context.Context) {
+	cs.flushTicker = time.NewTicker(clickFlushTimePeriod)
+
+	go func() {
+		defer close(cs.done)
+
+		for {
+			select {
+			case <-cs.flushTicker.C:
+				_ = cs.flush(context.Background())
+			case <-ctx.Done():
+				cs.flushTicker.Stop()
+				_ = cs.flush(context.Background())
+				return
+			}
+		}
+	}()
+}
+
+// Wait ожидает завершения обработчика и записи накопленных переходов
+func (cs *ClickServiceImpl) Wait() {
+	<-cs.done
+}
+
+// flush записывает накопленные переходы в хранилище.
+// При ошибке переходы возвращаются в буфер.
+func (cs *ClickServiceImpl) flush(ctx context.Context) error {
+	clicks := cs.clickBuffer.Flush()
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	if err := cs.repo.AddClicks(ctx, clicks); err != nil {
+		cs.clickBuffer.Merge(clicks)
+		cs.logger.Error("error saving clicks", zap.Int("links", len(clicks)), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
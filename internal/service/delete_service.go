@@ -2,60 +2,97 @@ package service
 
 import (
 	"context"
-	"sync"
+	"errors"
 	"time"
 
+	"go.uber.org/zap"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/deletequeue"
 	"github.com/rovany706/url-shortener/internal/models"
 	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/webhook"
 )
 
 const (
 	deleteFlushTimePeriod = time.Second * 10
+	// deleteBatchSize количество запросов, выполняемых за один вызов DeleteUserURLs
+	deleteBatchSize = 1000
+	// deleteClaimLease время, через которое невыполненные запросы снова забираются из очереди
+	deleteClaimLease = time.Minute
 )
 
 // DeleteService интерфейс сервиса удаления записей
 type DeleteService interface {
-	Put(deleteChan chan models.UserDeleteRequest)
+	// Put сохраняет запросы на удаление; после успешного возврата они будут выполнены и после перезапуска
+	Put(ctx context.Context, requests ...models.UserDeleteRequest) error
 	StartWorker(context.Context)
+	// Wait ожидает завершения обработчика, запущенного StartWorker, после завершения его контекста
+	Wait()
 }
 
-// DeleteServiceImpl сервис удаления записей
+// DeleteServiceImpl сервис удаления записей.
+// Запросы сохраняются в очереди и периодически выполняются пакетами; из очереди они удаляются
+// только после успешного DeleteUserURLs и публикации событий, поэтому невыполненные при остановке
+// запросы выполняются после запуска. Фактически удаленные ссылки записываются в журнал аудита
+// и публикуются на вебхуки их владельцев.
 type DeleteServiceImpl struct {
-	flushTicker  *time.Ticker
-	deleteBuffer *DeleteRequestBuffer
-	repo         repository.Repository
+	flushTicker *time.Ticker
+	queue       deletequeue.Queue
+	repo        repository.Repository
+	auditLog    audit.Log
+	webhooks    webhook.Publisher
+	logger      *zap.Logger
+	done        chan struct{}
+}
+
+// DeleteServiceOption функциональная опция DeleteServiceImpl
+type DeleteServiceOption func(*DeleteServiceImpl)
+
+// WithDeleteLogger задает логгер ошибок выполнения запросов на удаление
+func WithDeleteLogger(logger *zap.Logger) DeleteServiceOption {
+	return func(ds *DeleteServiceImpl) {
+		ds.logger = logger
+	}
 }
 
 // NewDeleteService создает DeleteServiceImpl
-func NewDeleteService(repo repository.Repository) *DeleteServiceImpl {
-	return &DeleteServiceImpl{
-		deleteBuffer: NewDeleteBuffer(),
-		repo:         repo,
+func NewDeleteService(repo repository.Repository, queue deletequeue.Queue, auditLog audit.Log, webhooks webhook.Publisher, opts ...DeleteServiceOption) *DeleteServiceImpl {
+	ds := &DeleteServiceImpl{
+		queue:    queue,
+		repo:     repo,
+		auditLog: auditLog,
+		webhooks: webhooks,
+		logger:   zap.NewNop(),
+		done:     make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(ds)
 	}
+
+	return ds
 }
 
-// Put добавляет запрос на удаление в буфер
-func (ds *DeleteServiceImpl) Put(deleteChan chan models.UserDeleteRequest) {
-	ds.deleteBuffer.Add(deleteChan)
+// Put сохраняет запросы на удаление в очереди
+func (ds *DeleteServiceImpl) Put(ctx context.Context, requests ...models.UserDeleteRequest) error {
+	return ds.queue.Enqueue(ctx, requests...)
 }
 
-// StartWorker запускает сервис в отдельной горутине
+// StartWorker запускает сервис в отдельной горутине.
+// Запросы, оставшиеся в очереди с прошлого запуска, выполняются сразу.
 func (ds *DeleteServiceImpl) StartWorker(ctx context.Context) {
 	ds.flushTicker = time.NewTicker(deleteFlushTimePeriod)
 
 	go func() {
+		defer close(ds.done)
+
+		_ = ds.flush(context.Background())
+
 		for {
 			select {
 			case <-ds.flushTicker.C:
-				deleteChs := ds.deleteBuffer.Flush()
-				deleteFanInCh := fanIn(deleteChs...)
-
-				deleteRequests := make([]models.UserDeleteRequest, 0)
-				for request := range deleteFanInCh {
-					deleteRequests = append(deleteRequests, request)
-				}
-
-				_ = ds.repo.DeleteUserURLs(context.Background(), deleteRequests)
+				_ = ds.flush(context.Background())
 			case <-ctx.Done():
 				ds.flushTicker.Stop()
 				return
@@ -64,27 +101,125 @@ func (ds *DeleteServiceImpl) StartWorker(ctx context.Context) {
 	}()
 }
 
-func fanIn(deleteChs ...chan models.UserDeleteRequest) chan models.UserDeleteRequest {
-	resultCh := make(chan models.UserDeleteRequest)
-	var wg sync.WaitGroup
+// Wait ожидает завершения обработчика; начатый пакет удалений выполняется до конца
+func (ds *DeleteServiceImpl) Wait() {
+	<-ds.done
+}
+
+// flush выполняет запросы из очереди пакетами по deleteBatchSize.
+// При ошибке запросы пакета остаются в очереди и повторяются после deleteClaimLease.
+func (ds *DeleteServiceImpl) flush(ctx context.Context) error {
+	for {
+		entries, err := ds.queue.Claim(ctx, time.Now().UTC(), deleteClaimLease, deleteBatchSize)
+		if err != nil {
+			ds.logger.Error("error claiming delete requests", zap.Error(err))
+			return err
+		}
 
-	for _, ch := range deleteChs {
-		chClosure := ch
-		wg.Add(1)
+		if len(entries) == 0 {
+			return nil
+		}
 
-		go func() {
-			defer wg.Done()
+		if err = ds.deleteBatch(ctx, entries); err != nil {
+			ds.logger.Error("error deleting links", zap.Int("requests", len(entries)), zap.Error(err))
+			return err
+		}
 
-			for request := range chClosure {
-				resultCh <- request
-			}
-		}()
+		if len(entries) < deleteBatchSize {
+			return nil
+		}
 	}
+}
 
-	go func() {
-		wg.Wait()
-		close(resultCh)
-	}()
+// deleteBatch удаляет ссылки из запросов пакета, записывает в журнал аудита и публикует на вебхуки события
+// удаления ссылок, которые принадлежали пользователям и еще не были удалены, и только затем удаляет запросы из очереди.
+// События сохраняются в очереди до удаления ссылок, поэтому после сбоя на любом шаге запросы повторяются
+// после deleteClaimLease вместе с событиями: события публикуются хотя бы один раз.
+func (ds *DeleteServiceImpl) deleteBatch(ctx context.Context, entries []deletequeue.Entry) error {
+	if err := ds.prepare(ctx, entries); err != nil {
+		return err
+	}
+
+	deleteRequests := make([]models.UserDeleteRequest, len(entries))
+	ids := make([]int64, len(entries))
+	events := make([]audit.Event, 0, len(entries))
+	webhookEvents := make([]webhook.Event, 0, len(entries))
+	for i, entry := range entries {
+		deleteRequests[i] = entry.Request
+		ids[i] = entry.ID
 
-	return resultCh
+		if entry.Event == nil {
+			continue
+		}
+		events = append(events, *entry.Event)
+		webhookEvents = append(webhookEvents, webhook.Event{
+			Type:        webhook.EventLinkDeleted,
+			UserID:      entry.Event.UserID,
+			ShortID:     entry.Event.ShortID,
+			OriginalURL: entry.Event.Before.FullURL,
+		})
+	}
+
+	if err := ds.repo.DeleteUserURLs(ctx, deleteRequests); err != nil {
+		return err
+	}
+
+	if err := errors.Join(ds.auditLog.Append(ctx, events...), ds.webhooks.Publish(ctx, webhookEvents...)); err != nil {
+		return err
+	}
+
+	return ds.queue.Done(ctx, ids...)
+}
+
+// prepare вычисляет события удаления для запросов, забранных впервые, и сохраняет их в очереди.
+// Повторный запрос той же ссылки в пакете не дает второго события.
+func (ds *DeleteServiceImpl) prepare(ctx context.Context, entries []deletequeue.Entry) error {
+	seen := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		if entry.Event != nil {
+			seen[entry.Event.ShortID] = struct{}{}
+		}
+	}
+
+	now := time.Now().UTC()
+	prepared := make([]deletequeue.Entry, 0, len(entries))
+	for i := range entries {
+		if entries[i].Prepared {
+			continue
+		}
+
+		entries[i].Prepared = true
+		entries[i].Event = ds.deleteEvent(ctx, entries[i].Request, now, seen)
+		prepared = append(prepared, entries[i])
+	}
+
+	if len(prepared) == 0 {
+		return nil
+	}
+
+	return ds.queue.Prepare(ctx, prepared...)
+}
+
+// deleteEvent возвращает событие аудита для запроса, который изменит ссылку, или nil
+func (ds *DeleteServiceImpl) deleteEvent(ctx context.Context, request models.UserDeleteRequest, now time.Time, seen map[string]struct{}) *audit.Event {
+	if _, ok := seen[request.ShortIDToDelete]; ok {
+		return nil
+	}
+
+	info, ok := ds.repo.GetFullURL(ctx, request.ShortIDToDelete)
+	if !ok || info.UserID != request.UserID || info.IsDeleted {
+		return nil
+	}
+	seen[request.ShortIDToDelete] = struct{}{}
+
+	return &audit.Event{
+		Time:      now,
+		Action:    audit.ActionLinkDeleted,
+		UserID:    request.UserID,
+		ClientIP:  request.ClientIP,
+		RequestID: request.RequestID,
+		ShortID:   request.ShortIDToDelete,
+		Before:    &audit.LinkState{FullURL: info.FullURL},
+		After:     &audit.LinkState{FullURL: info.FullURL, IsDeleted: true},
+	}
 }
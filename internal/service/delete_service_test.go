@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/rovany706/url-shortener/internal/audit"
+	"github.com/rovany706/url-shortener/internal/deletequeue"
+	"github.com/rovany706/url-shortener/internal/models"
+	"github.com/rovany706/url-shortener/internal/repository"
+	"github.com/rovany706/url-shortener/internal/repository/mock"
+	"github.com/rovany706/url-shortener/internal/webhook"
+)
+
+func TestDeleteServiceFlush(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	require.NoError(t, repo.SaveEntry(ctx, 1, "a", "http://example.com/a"))
+	require.NoError(t, repo.SaveEntry(ctx, 1, "b", "http://example.com/b"))
+	require.NoError(t, repo.SaveEntry(ctx, 2, "c", "http://example.com/c"))
+
+	auditLog, err := audit.NewFileLog(afero.NewMemMapFs(), "audit.log")
+	require.NoError(t, err)
+	defer auditLog.Close()
+
+	webhookStore := webhook.NewMemoryStore()
+	endpoint, err := webhook.NewEndpoint(1, "http://receiver.example/hook", []webhook.EventType{webhook.EventLinkDeleted})
+	require.NoError(t, err)
+	require.NoError(t, webhookStore.CreateEndpoint(ctx, endpoint))
+
+	queue := deletequeue.NewMemoryQueue()
+	deleteService := NewDeleteService(repo, queue, auditLog, webhook.NewDispatcher(webhookStore))
+
+	require.NoError(t, deleteService.Put(ctx,
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a", ClientIP: "192.0.2.1", RequestID: "request-1"},
+		// чужая и несуществующая ссылки не удаляются
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "c"},
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "x"},
+		// повторный запрос в том же сбросе дает одно событие
+		models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a"},
+	))
+	require.NoError(t, deleteService.Put(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "b"}))
+	require.NoError(t, deleteService.flush(ctx))
+	assert.Zero(t, queue.Len())
+
+	info, ok := repo.GetFullURL(ctx, "a")
+	require.True(t, ok)
+	assert.True(t, info.IsDeleted)
+	info, ok = repo.GetFullURL(ctx, "c")
+	require.True(t, ok)
+	assert.False(t, info.IsDeleted)
+
+	events, err := auditLog.UserEvents(ctx, 1, audit.DefaultUserEventsLimit)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	byShortID := make(map[string]audit.Event, len(events))
+	for _, event := range events {
+		assert.Equal(t, audit.ActionLinkDeleted, event.Action)
+		byShortID[event.ShortID] = event
+	}
+	require.Contains(t, byShortID, "a")
+	require.Contains(t, byShortID, "b")
+	assert.Equal(t, "192.0.2.1", byShortID["a"].ClientIP)
+	assert.Equal(t, "request-1", byShortID["a"].RequestID)
+	assert.Equal(t, &audit.LinkState{FullURL: "http://example.com/a"}, byShortID["a"].Before)
+	assert.Equal(t, &audit.LinkState{FullURL: "http://example.com/a", IsDeleted: true}, byShortID["a"].After)
+
+	deliveries, err := webhookStore.UserDeliveries(ctx, 1, webhook.DefaultUserDeliveriesLimit)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+
+	deletedURLs := make(map[string]string, len(deliveries))
+	for _, delivery := range deliveries {
+		assert.Equal(t, endpoint.ID, delivery.EndpointID)
+		assert.Equal(t, webhook.EventLinkDeleted, delivery.Event.Type)
+		deletedURLs[delivery.Event.ShortID] = delivery.Event.OriginalURL
+	}
+	assert.Equal(t, map[string]string{"a": "http://example.com/a", "b": "http://example.com/b"}, deletedURLs)
+
+	// повторное удаление уже удаленной ссылки не попадает в журнал
+	require.NoError(t, deleteService.Put(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a"}))
+	require.NoError(t, deleteService.flush(ctx))
+
+	events, err = auditLog.UserEvents(ctx, 1, audit.DefaultUserEventsLimit)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	deliveries, err = webhookStore.UserDeliveries(ctx, 1, webhook.DefaultUserDeliveriesLimit)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+}
+
+func TestDeleteServiceReplay(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	repo := repository.NewMemoryRepository()
+	require.NoError(t, repo.SaveEntry(ctx, 1, "a", "http://example.com/a"))
+
+	queue, err := deletequeue.NewFileQueue(fs, "deletes.log")
+	require.NoError(t, err)
+	deleteService := NewDeleteService(repo, queue, audit.NopLog{}, webhook.NopPublisher{})
+	require.NoError(t, deleteService.Put(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a"}))
+	// остановка до выполнения запроса
+	require.NoError(t, queue.Close())
+
+	queue, err = deletequeue.NewFileQueue(fs, "deletes.log")
+	require.NoError(t, err)
+	defer queue.Close()
+
+	deleteService = NewDeleteService(repo, queue, audit.NopLog{}, webhook.NopPublisher{})
+	require.NoError(t, deleteService.flush(ctx))
+
+	info, ok := repo.GetFullURL(ctx, "a")
+	require.True(t, ok)
+	assert.True(t, info.IsDeleted)
+
+	entries, err := queue.Claim(ctx, time.Now().Add(deleteClaimLease*2), deleteClaimLease, deleteBatchSize)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDeleteServiceRetry(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	request := models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a"}
+	repo := mock.NewMockRepository(ctrl)
+	repo.EXPECT().GetFullURL(gomock.Any(), "a").Return(&repository.ShortenedURLInfo{UserID: 1, FullURL: "http://example.com/a"}, true).AnyTimes()
+	repo.EXPECT().DeleteUserURLs(gomock.Any(), []models.UserDeleteRequest{request}).Return(errors.New("storage unavailable"))
+
+	queue := deletequeue.NewMemoryQueue()
+	deleteService := NewDeleteService(repo, queue, audit.NopLog{}, webhook.NopPublisher{})
+	require.NoError(t, deleteService.Put(ctx, request))
+
+	assert.Error(t, deleteService.flush(ctx))
+	assert.Equal(t, 1, queue.Len())
+
+	// запрос не повторяется до истечения аренды
+	require.NoError(t, deleteService.flush(ctx))
+
+	entries, err := queue.Claim(ctx, time.Now().Add(deleteClaimLease+time.Second), deleteClaimLease, deleteBatchSize)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, request, entries[0].Request)
+}
+
+// failingPublisher публикатор, хранилище которого недоступно
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(ctx context.Context, events ...webhook.Event) error {
+	return errors.New("webhook store unavailable")
+}
+
+func TestDeleteServiceEventsSurviveFailure(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	repo := repository.NewMemoryRepository()
+	require.NoError(t, repo.SaveEntry(ctx, 1, "a", "http://example.com/a"))
+
+	queue, err := deletequeue.NewFileQueue(fs, "deletes.log")
+	require.NoError(t, err)
+	core, logs := observer.New(zap.ErrorLevel)
+	deleteService := NewDeleteService(repo, queue, audit.NopLog{}, failingPublisher{}, WithDeleteLogger(zap.New(core)))
+	require.NoError(t, deleteService.Put(ctx, models.UserDeleteRequest{UserID: 1, ShortIDToDelete: "a", RequestID: "request-1"}))
+
+	// ссылка удалена, но события не опубликованы: запрос остается в очереди, ошибка пишется в лог
+	require.Error(t, deleteService.flush(ctx))
+	failures := logs.FilterMessage("error deleting links").All()
+	require.Len(t, failures, 1)
+	assert.Equal(t, int64(1), failures[0].ContextMap()["requests"])
+	info, ok := repo.GetFullURL(ctx, "a")
+	require.True(t, ok)
+	assert.True(t, info.IsDeleted)
+	require.NoError(t, queue.Close())
+
+	queue, err = deletequeue.NewFileQueue(fs, "deletes.log")
+	require.NoError(t, err)
+	defer queue.Close()
+
+	auditLog, err := audit.NewFileLog(afero.NewMemMapFs(), "audit.log")
+	require.NoError(t, err)
+	defer auditLog.Close()
+
+	// после перезапуска сохраненное событие публикуется, хотя ссылка уже удалена
+	deleteService = NewDeleteService(repo, queue, auditLog, webhook.NopPublisher{})
+	require.NoError(t, deleteService.flush(ctx))
+
+	events, err := auditLog.UserEvents(ctx, 1, audit.DefaultUserEventsLimit)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "a", events[0].ShortID)
+	assert.Equal(t, "request-1", events[0].RequestID)
+	assert.Equal(t, &audit.LinkState{FullURL: "http://example.com/a"}, events[0].Before)
+
+	entries, err := queue.Claim(ctx, time.Now().Add(deleteClaimLease*2), deleteClaimLease, deleteBatchSize)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -43,15 +43,22 @@ func (m *MockDeleteService) EXPECT() *MockDeleteServiceMockRecorder {
 }
 
 // Put mocks base method.
-func (m *MockDeleteService) Put(deleteChan chan models.UserDeleteRequest) {
+func (m *MockDeleteService) Put(ctx context.Context, requests ...models.UserDeleteRequest) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Put", deleteChan)
+	varargs := []any{ctx}
+	for _, a := range requests {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Put", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // Put indicates an expected call of Put.
-func (mr *MockDeleteServiceMockRecorder) Put(deleteChan any) *gomock.Call {
+func (mr *MockDeleteServiceMockRecorder) Put(ctx any, requests ...any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockDeleteService)(nil).Put), deleteChan)
+	varargs := append([]any{ctx}, requests...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockDeleteService)(nil).Put), varargs...)
 }
 
 // StartWorker mocks base method.
@@ -65,3 +72,15 @@ func (mr *MockDeleteServiceMockRecorder) StartWorker(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartWorker", reflect.TypeOf((*MockDeleteService)(nil).StartWorker), arg0)
 }
+
+// Wait mocks base method.
+func (m *MockDeleteService) Wait() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Wait")
+}
+
+// Wait indicates an expected call of Wait.
+func (mr *MockDeleteServiceMockRecorder) Wait() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wait", reflect.TypeOf((*MockDeleteService)(nil).Wait))
+}
@@ -73,3 +73,30 @@ func TestReadAllEntries(t *testing.T) {
 	require.NoError(t, err)
 	assert.ElementsMatch(t, want, entries)
 }
+
+func TestReadAllEntriesMixedFormat(t *testing.T) {
+	data := `{"short_id":"89dce6a4","full_url":"http://example.com"}
+{"type":"user","user_id":1,"created_at":"2025-01-01T00:00:00Z"}
+{"type":"link","short_id":"1","full_url":"https://ya.ru","user_id":1,"created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z"}
+{"type":"delete","short_id":"1","user_id":1,"is_deleted":true,"updated_at":"2025-01-02T00:00:00Z"}
+`
+	fs := afero.NewMemMapFs()
+	testStoragePath := "home/test/storage.json"
+	require.NoError(t, afero.WriteFile(fs, testStoragePath, []byte(data), 0644))
+
+	reader, err := NewFileStorageReader(fs, testStoragePath)
+	require.NoError(t, err)
+
+	entries, err := reader.ReadAllEntries()
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	wantTypes := []EntryType{EntryTypeLink, EntryTypeUser, EntryTypeLink, EntryTypeDelete}
+	for i, entry := range entries {
+		assert.Equal(t, wantTypes[i], entry.GetType())
+	}
+
+	assert.Equal(t, 0, entries[0].UserID)
+	assert.Equal(t, 1, entries[2].UserID)
+	assert.True(t, entries[3].IsDeleted)
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/spf13/afero"
+)
+
+// FileStorageReaderV2 объект для чтения записей из файла версии 2:
+// заголовок и сегменты записей с опциональным сжатием
+type FileStorageReaderV2 struct {
+	file         afero.File
+	decompressor io.ReadCloser
+	decoder      *json.Decoder
+	header       FileHeader
+}
+
+// Header возвращает заголовок файла
+func (r *FileStorageReaderV2) Header() FileHeader {
+	return r.header
+}
+
+// ReadEntry возвращает запись
+func (r *FileStorageReaderV2) ReadEntry() (*StorageEntry, error) {
+	entry := &StorageEntry{}
+	if err := r.decoder.Decode(&entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// ReadAllEntries возвращает все записи в файле
+func (r *FileStorageReaderV2) ReadAllEntries() (Storage, error) {
+	entries := make(Storage, 0)
+
+	for r.decoder.More() {
+		entry, err := r.ReadEntry()
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, *entry)
+	}
+
+	return entries, nil
+}
+
+// Close завершает работу с файлом
+func (r *FileStorageReaderV2) Close() error {
+	r.decompressor.Close()
+
+	return r.file.Close()
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// FileStorageWriterV2 объект для записи информации в файл версии 2.
+// Каждый вызов WriteEntries дописывает в файл отдельный сегмент.
+type FileStorageWriterV2 struct {
+	file    afero.File
+	encoder *segmentEncoder
+}
+
+// NewFileStorageWriterV2 создает FileStorageWriterV2.
+// В новый файл записывается заголовок, у существующего файла заголовок
+// должен совпадать с указанным сжатием.
+func NewFileStorageWriterV2(fs afero.Fs, filename string, compression Compression) (*FileStorageWriterV2, error) {
+	header, ok, err := DetectFileHeader(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && header != NewFileHeader(compression) {
+		return nil, ErrFormatMismatch
+	}
+
+	encoder, err := newSegmentEncoder(compression)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fs.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		if err = writeFileHeader(file, NewFileHeader(compression)); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &FileStorageWriterV2{
+		file:    file,
+		encoder: encoder,
+	}, nil
+}
+
+// WriteEntry записывает запись в файл
+func (w *FileStorageWriterV2) WriteEntry(entry *StorageEntry) error {
+	return w.WriteEntries([]StorageEntry{*entry})
+}
+
+// WriteEntries записывает множество записей в файл одним сегментом
+func (w *FileStorageWriterV2) WriteEntries(entries []StorageEntry) error {
+	segment, err := w.encoder.encode(entries)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.file.Write(segment)
+
+	return err
+}
+
+// Close завершает работу с файлом
+func (w *FileStorageWriterV2) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+)
+
+// Compression алгоритм сжатия сегментов файла хранилища
+type Compression string
+
+// Алгоритмы сжатия
+const (
+	// CompressionNone записи хранятся в виде JSON lines без сжатия
+	CompressionNone Compression = "none"
+	// CompressionGzip каждый сегмент записей сжат gzip
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd каждый сегмент записей сжат zstd
+	CompressionZstd Compression = "zstd"
+)
+
+// Версии формата файла хранилища
+const (
+	// FormatVersion1 JSON lines без заголовка
+	FormatVersion1 = 1
+	// FormatVersion2 заголовок и сегменты записей с опциональным сжатием
+	FormatVersion2 = 2
+	// CurrentFormatVersion версия формата для новых файлов
+	CurrentFormatVersion = FormatVersion2
+)
+
+const fileFormatName = "url-shortener-storage"
+
+// Ошибки
+var (
+	// ErrUnknownCompression ошибка неизвестного алгоритма сжатия
+	ErrUnknownCompression = errors.New("unknown compression")
+	// ErrUnsupportedFormat ошибка неподдерживаемой версии формата файла
+	ErrUnsupportedFormat = errors.New("unsupported storage file format")
+	// ErrFormatMismatch ошибка записи в файл другого формата
+	ErrFormatMismatch = errors.New("storage file format mismatch")
+)
+
+// ParseCompression возвращает алгоритм сжатия по названию
+func ParseCompression(name string) (Compression, error) {
+	switch compression := Compression(name); compression {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownCompression, name)
+	}
+}
+
+// FileHeader заголовок файла хранилища.
+// Файлы версии 1 заголовка не имеют.
+type FileHeader struct {
+	Format      string      `json:"format"`
+	Version     int         `json:"version"`
+	Compression Compression `json:"compression"`
+}
+
+// NewFileHeader возвращает заголовок текущей версии формата
+func NewFileHeader(compression Compression) FileHeader {
+	return FileHeader{
+		Format:      fileFormatName,
+		Version:     CurrentFormatVersion,
+		Compression: compression,
+	}
+}
+
+func legacyFileHeader() FileHeader {
+	return FileHeader{
+		Version:     FormatVersion1,
+		Compression: CompressionNone,
+	}
+}
+
+// readFileHeader определяет формат по началу файла и возвращает заголовок
+// и читатель, установленный на первую запись после заголовка
+func readFileHeader(file io.Reader) (FileHeader, *bufio.Reader, error) {
+	reader := bufio.NewReader(file)
+
+	firstLine, err := reader.Peek(reader.Size())
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return FileHeader{}, nil, err
+	}
+
+	if i := bytes.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i+1]
+	}
+
+	var header FileHeader
+	if json.Unmarshal(firstLine, &header) != nil || header.Format != fileFormatName {
+		return legacyFileHeader(), reader, nil
+	}
+
+	if header.Version != FormatVersion2 {
+		return FileHeader{}, nil, fmt.Errorf("%w: version %d", ErrUnsupportedFormat, header.Version)
+	}
+
+	if _, err := ParseCompression(string(header.Compression)); err != nil {
+		return FileHeader{}, nil, err
+	}
+
+	if _, err := reader.Discard(len(firstLine)); err != nil {
+		return FileHeader{}, nil, err
+	}
+
+	return header, reader, nil
+}
+
+// DetectFileHeader возвращает заголовок файла хранилища.
+// Для отсутствующего или пустого файла возвращается ok == false.
+func DetectFileHeader(fs afero.Fs, filename string) (header FileHeader, ok bool, err error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return FileHeader{}, false, nil
+		}
+		return FileHeader{}, false, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return FileHeader{}, false, err
+	}
+
+	if fi.Size() == 0 {
+		return FileHeader{}, false, nil
+	}
+
+	header, _, err = readFileHeader(file)
+	if err != nil {
+		return FileHeader{}, false, err
+	}
+
+	return header, true, nil
+}
+
+func writeFileHeader(w io.Writer, header FileHeader) error {
+	return json.NewEncoder(w).Encode(header)
+}
+
+// segmentEncoder кодирует набор записей в один сегмент файла.
+// Для сжатых форматов каждый сегмент является отдельным gzip-членом
+// или zstd-фреймом, поэтому сегменты можно дописывать в конец файла.
+type segmentEncoder struct {
+	compression Compression
+	buffer      bytes.Buffer
+	gzipWriter  *gzip.Writer
+	zstdWriter  *zstd.Encoder
+}
+
+func newSegmentEncoder(compression Compression) (*segmentEncoder, error) {
+	encoder := &segmentEncoder{compression: compression}
+
+	switch compression {
+	case CompressionNone:
+	case CompressionGzip:
+		encoder.gzipWriter = gzip.NewWriter(nil)
+	case CompressionZstd:
+		zstdWriter, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		encoder.zstdWriter = zstdWriter
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCompression, compression)
+	}
+
+	return encoder, nil
+}
+
+// encode возвращает закодированный сегмент. Результат действителен до следующего вызова.
+func (e *segmentEncoder) encode(entries []StorageEntry) ([]byte, error) {
+	e.buffer.Reset()
+
+	w := e.stream(&e.buffer)
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(&entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return e.buffer.Bytes(), nil
+}
+
+// stream начинает сегмент, записываемый в dst по мере записи в возвращаемый поток.
+// Сегмент завершается закрытием потока; dst при этом не закрывается.
+func (e *segmentEncoder) stream(dst io.Writer) io.WriteCloser {
+	switch e.compression {
+	case CompressionGzip:
+		e.gzipWriter.Reset(dst)
+		return e.gzipWriter
+	case CompressionZstd:
+		e.zstdWriter.Reset(dst)
+		return e.zstdWriter
+	default:
+		return nopWriteCloser{dst}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// newSegmentDecoder возвращает поток распакованных записей
+func newSegmentDecoder(reader *bufio.Reader, compression Compression) (io.ReadCloser, error) {
+	if compression == CompressionNone {
+		return io.NopCloser(reader), nil
+	}
+
+	// файл из одного заголовка не содержит ни одного сегмента
+	if _, err := reader.Peek(1); errors.Is(err, io.EOF) {
+		return io.NopCloser(reader), nil
+	}
+
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(reader)
+	case CompressionZstd:
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCompression, compression)
+	}
+}
+
+// NewStorageReader открывает файл хранилища любой поддерживаемой версии,
+// определяя формат по заголовку. Отсутствующий файл создается.
+func NewStorageReader(fs afero.Fs, filename string) (StorageReader, error) {
+	file, err := fs.OpenFile(filename, os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	header, reader, err := readFileHeader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if header.Version == FormatVersion1 {
+		return &FileStorageReader{
+			file:    file,
+			decoder: json.NewDecoder(reader),
+		}, nil
+	}
+
+	decompressor, err := newSegmentDecoder(reader, header.Compression)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &FileStorageReaderV2{
+		file:         file,
+		decompressor: decompressor,
+		decoder:      json.NewDecoder(decompressor),
+		header:       header,
+	}, nil
+}
+
+// NewStorageWriter открывает файл хранилища для дозаписи.
+// Новый файл создается в текущей версии формата с указанным сжатием,
+// существующий дописывается в своем формате.
+func NewStorageWriter(fs afero.Fs, filename string, compression Compression) (StorageWriter, error) {
+	header, ok, err := DetectFileHeader(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && header.Version == FormatVersion1 {
+		return NewFileStorageWriter(fs, filename)
+	}
+
+	return NewFileStorageWriterV2(fs, filename, compression)
+}
+
+// UpgradeFile безопасно переводит файл хранилища в текущую версию формата с указанным сжатием:
+// записи по мере чтения переписываются во временный файл, который затем атомарно заменяет исходный.
+// Возвращает false, если файл отсутствует или уже имеет нужный формат.
+func UpgradeFile(fs afero.Fs, filename string, compression Compression) (upgraded bool, err error) {
+	header, ok, err := DetectFileHeader(fs, filename)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if header == NewFileHeader(compression) {
+		return false, nil
+	}
+
+	err = WriteSnapshot(fs, filename, compression, func(write func(entry StorageEntry) error) error {
+		return ForEachFileEntry(fs, filename, write)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var allCompressions = []Compression{CompressionNone, CompressionGzip, CompressionZstd}
+
+func TestFileStorageWriterV2RoundTrip(t *testing.T) {
+	want := Storage{
+		{ShortID: "1", FullURL: "http://example.com/1", UserID: 1},
+		{ShortID: "2", FullURL: "http://example.com/2", UserID: 1},
+		{Type: EntryTypeDelete, ShortID: "1", UserID: 1, IsDeleted: true},
+	}
+
+	for _, compression := range allCompressions {
+		t.Run(string(compression), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			filePath := "home/test/storage.json"
+
+			writer, err := NewStorageWriter(fs, filePath, compression)
+			require.NoError(t, err)
+			require.NoError(t, writer.WriteEntries(want[:2]))
+			require.NoError(t, writer.Close())
+
+			// дозапись в существующий файл добавляет новый сегмент
+			writer, err = NewStorageWriter(fs, filePath, compression)
+			require.NoError(t, err)
+			require.NoError(t, writer.WriteEntry(&want[2]))
+			require.NoError(t, writer.Close())
+
+			header, ok, err := DetectFileHeader(fs, filePath)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, NewFileHeader(compression), header)
+
+			entries, err := ReadFileEntries(fs, filePath)
+			require.NoError(t, err)
+			assert.Equal(t, want, entries)
+		})
+	}
+}
+
+func TestNewStorageReaderLegacyFormat(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	testStoragePath := "home/test/storage.json"
+	loadTestData(t, fs, "testdata/test_storage.json", testStoragePath)
+
+	header, ok, err := DetectFileHeader(fs, testStoragePath)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, FormatVersion1, header.Version)
+
+	reader, err := NewStorageReader(fs, testStoragePath)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	entries, err := reader.ReadAllEntries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// в файл старого формата записи дописываются в старом формате
+	writer, err := NewStorageWriter(fs, testStoragePath, CompressionZstd)
+	require.NoError(t, err)
+	require.IsType(t, &FileStorageWriter{}, writer)
+	require.NoError(t, writer.Close())
+}
+
+func TestNewStorageReaderUnsupportedVersion(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	filePath := "home/test/storage.json"
+	require.NoError(t, afero.WriteFile(fs, filePath, []byte(`{"format":"url-shortener-storage","version":99,"compression":"none"}`+"\n"), 0644))
+
+	_, err := NewStorageReader(fs, filePath)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestFileStorageWriterV2FormatMismatch(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	filePath := "home/test/storage.json"
+
+	writer, err := NewFileStorageWriterV2(fs, filePath, CompressionGzip)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	_, err = NewFileStorageWriterV2(fs, filePath, CompressionZstd)
+	assert.ErrorIs(t, err, ErrFormatMismatch)
+}
+
+func TestUpgradeFile(t *testing.T) {
+	for _, compression := range allCompressions {
+		t.Run(string(compression), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			testStoragePath := "home/test/storage.json"
+			loadTestData(t, fs, "testdata/test_storage.json", testStoragePath)
+
+			want, err := ReadFileEntries(fs, testStoragePath)
+			require.NoError(t, err)
+
+			upgraded, err := UpgradeFile(fs, testStoragePath, compression)
+			require.NoError(t, err)
+			assert.True(t, upgraded)
+
+			header, _, err := DetectFileHeader(fs, testStoragePath)
+			require.NoError(t, err)
+			assert.Equal(t, NewFileHeader(compression), header)
+
+			entries, err := ReadFileEntries(fs, testStoragePath)
+			require.NoError(t, err)
+			assert.Equal(t, want, entries)
+
+			upgraded, err = UpgradeFile(fs, testStoragePath, compression)
+			require.NoError(t, err)
+			assert.False(t, upgraded)
+
+			exists, err := afero.Exists(fs, testStoragePath+snapshotTempSuffix)
+			require.NoError(t, err)
+			assert.False(t, exists)
+		})
+	}
+}
+
+func TestGroupCommitWriterCompression(t *testing.T) {
+	for _, compression := range allCompressions {
+		t.Run(string(compression), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			filePath := "home/test/storage.json"
+
+			writer, err := NewGroupCommitWriter(fs, filePath, compression, SyncAlways, time.Millisecond)
+			require.NoError(t, err)
+			require.NoError(t, writer.WriteEntry(&StorageEntry{ShortID: "1", FullURL: "http://example.com/1"}))
+			require.NoError(t, writer.Close())
+
+			// сжатие существующего файла определяется по его заголовку
+			writer, err = NewGroupCommitWriter(fs, filePath, CompressionNone, SyncAlways, time.Millisecond)
+			require.NoError(t, err)
+			require.NoError(t, writer.WriteEntry(&StorageEntry{ShortID: "2", FullURL: "http://example.com/2"}))
+			require.NoError(t, writer.Close())
+
+			entries, err := ReadFileEntries(fs, filePath)
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			assert.Equal(t, "1", entries[0].ShortID)
+			assert.Equal(t, "2", entries[1].ShortID)
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// SyncPolicy политика сброса записей на диск (fsync)
+type SyncPolicy string
+
+// Политики сброса записей на диск
+const (
+	// SyncAlways сбрасывать на диск каждую группу записей перед подтверждением
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval сбрасывать на диск раз в заданный интервал,
+	// запись подтверждается после ближайшего сброса
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever не сбрасывать на диск явно, запись подтверждается после передачи в ОС
+	SyncNever SyncPolicy = "never"
+)
+
+const maxGroupCommitBatch = 1024
+
+// Ошибки
+var (
+	// ErrWriterClosed ошибка записи в закрытый GroupCommitWriter
+	ErrWriterClosed = errors.New("storage writer is closed")
+	// ErrUnknownSyncPolicy ошибка неизвестной политики сброса на диск
+	ErrUnknownSyncPolicy = errors.New("unknown sync policy")
+)
+
+// ParseSyncPolicy возвращает политику сброса на диск по названию
+func ParseSyncPolicy(name string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(name); policy {
+	case SyncAlways, SyncInterval, SyncNever:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownSyncPolicy, name)
+	}
+}
+
+type writeRequest struct {
+	entries []StorageEntry
+	// rotateTo не пуст для запроса на переименование текущего файла
+	rotateTo string
+	result   chan error
+}
+
+// GroupCommitWriter держит файл открытым и пишет в него из одной горутины.
+// Записи, пришедшие одновременно, объединяются в одну операцию записи (group commit),
+// а вызывающая сторона получает ответ только после того, как ее записи
+// достигли уровня надежности, заданного SyncPolicy.
+type GroupCommitWriter struct {
+	fs           afero.Fs
+	filename     string
+	compression  Compression
+	policy       SyncPolicy
+	syncInterval time.Duration
+
+	file    afero.File
+	encoder *segmentEncoder
+
+	requests   chan *writeRequest
+	closeMutex sync.RWMutex
+	closed     bool
+	closing    chan struct{}
+	done       chan struct{}
+	closeErr   error
+	// pending запросы, ожидающие сброса на диск (SyncInterval)
+	pending []*writeRequest
+}
+
+// NewGroupCommitWriter открывает файл для дозаписи и запускает горутину записи.
+// Новые файлы создаются в текущей версии формата с указанным сжатием,
+// существующие дописываются в своем формате.
+// syncInterval используется только для политики SyncInterval.
+func NewGroupCommitWriter(fs afero.Fs, filename string, compression Compression, policy SyncPolicy, syncInterval time.Duration) (*GroupCommitWriter, error) {
+	if _, err := ParseCompression(string(compression)); err != nil {
+		return nil, err
+	}
+
+	if _, err := ParseSyncPolicy(string(policy)); err != nil {
+		return nil, err
+	}
+
+	if policy == SyncInterval && syncInterval <= 0 {
+		return nil, fmt.Errorf("sync interval must be positive, got %s", syncInterval)
+	}
+
+	w := &GroupCommitWriter{
+		fs:           fs,
+		filename:     filename,
+		compression:  compression,
+		policy:       policy,
+		syncInterval: syncInterval,
+		requests:     make(chan *writeRequest, maxGroupCommitBatch),
+		closing:      make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *GroupCommitWriter) open() error {
+	header, ok, err := DetectFileHeader(w.fs, w.filename)
+	if err != nil {
+		return err
+	}
+
+	file, err := w.fs.OpenFile(w.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		header = NewFileHeader(w.compression)
+		if err = writeFileHeader(file, header); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	encoder, err := newSegmentEncoder(header.Compression)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.encoder = encoder
+
+	return nil
+}
+
+// WriteEntry записывает запись в файл
+func (w *GroupCommitWriter) WriteEntry(entry *StorageEntry) error {
+	return w.WriteEntries([]StorageEntry{*entry})
+}
+
+// WriteEntries записывает множество записей в файл.
+// Возвращает управление после достижения уровня надежности SyncPolicy.
+func (w *GroupCommitWriter) WriteEntries(entries []StorageEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	return w.submit(&writeRequest{entries: entries})
+}
+
+// Rotate сбрасывает на диск все записи, переименовывает текущий файл в sealedFilename
+// и продолжает запись в новый пустой файл
+func (w *GroupCommitWriter) Rotate(sealedFilename string) error {
+	return w.submit(&writeRequest{rotateTo: sealedFilename})
+}
+
+func (w *GroupCommitWriter) submit(request *writeRequest) error {
+	request.result = make(chan error, 1)
+
+	w.closeMutex.RLock()
+	if w.closed {
+		w.closeMutex.RUnlock()
+		return ErrWriterClosed
+	}
+	w.requests <- request
+	w.closeMutex.RUnlock()
+
+	return <-request.result
+}
+
+// Close сбрасывает на диск накопленные записи и закрывает файл
+func (w *GroupCommitWriter) Close() error {
+	w.closeMutex.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.closing)
+	}
+	w.closeMutex.Unlock()
+
+	<-w.done
+
+	return w.closeErr
+}
+
+func (w *GroupCommitWriter) run() {
+	defer close(w.done)
+
+	var syncTicker <-chan time.Time
+	if w.policy == SyncInterval {
+		ticker := time.NewTicker(w.syncInterval)
+		defer ticker.Stop()
+		syncTicker = ticker.C
+	}
+
+	for {
+		select {
+		case request := <-w.requests:
+			w.handle(w.collectBatch(request))
+		case <-syncTicker:
+			w.ack(w.pending, w.file.Sync())
+			w.pending = nil
+		case <-w.closing:
+			w.shutdown()
+			return
+		}
+	}
+}
+
+// collectBatch добирает уже ожидающие запросы, чтобы записать их одной операцией
+func (w *GroupCommitWriter) collectBatch(first *writeRequest) []*writeRequest {
+	batch := []*writeRequest{first}
+
+	for len(batch) < maxGroupCommitBatch {
+		select {
+		case request := <-w.requests:
+			batch = append(batch, request)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+func (w *GroupCommitWriter) handle(batch []*writeRequest) {
+	writes := make([]*writeRequest, 0, len(batch))
+
+	for _, request := range batch {
+		if request.rotateTo == "" {
+			writes = append(writes, request)
+			continue
+		}
+
+		// записи до ротации должны попасть в старый файл
+		w.commit(writes)
+		writes = writes[:0]
+
+		request.result <- w.rotate(request.rotateTo)
+	}
+
+	w.commit(writes)
+}
+
+func (w *GroupCommitWriter) commit(requests []*writeRequest) {
+	if len(requests) == 0 {
+		return
+	}
+
+	err := w.write(requests)
+	if err != nil {
+		w.ack(requests, err)
+		return
+	}
+
+	switch w.policy {
+	case SyncAlways:
+		w.ack(requests, w.file.Sync())
+	case SyncInterval:
+		w.pending = append(w.pending, requests...)
+	default:
+		w.ack(requests, nil)
+	}
+}
+
+// write записывает записи всех запросов одним сегментом
+func (w *GroupCommitWriter) write(requests []*writeRequest) error {
+	entries := requests[0].entries
+	if len(requests) > 1 {
+		entries = make([]StorageEntry, 0, len(requests))
+		for _, request := range requests {
+			entries = append(entries, request.entries...)
+		}
+	}
+
+	segment, err := w.encoder.encode(entries)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.file.Write(segment)
+
+	return err
+}
+
+func (w *GroupCommitWriter) rotate(sealedFilename string) error {
+	syncErr := w.file.Sync()
+	w.ack(w.pending, syncErr)
+	w.pending = nil
+
+	if syncErr != nil {
+		return syncErr
+	}
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	renameErr := w.fs.Rename(w.filename, sealedFilename)
+
+	// файл нужно открыть заново даже при ошибке переименования, иначе запись остановится
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	return renameErr
+}
+
+func (w *GroupCommitWriter) shutdown() {
+	for {
+		select {
+		case request := <-w.requests:
+			w.handle(w.collectBatch(request))
+		default:
+			var err error
+			if w.policy != SyncNever {
+				err = w.file.Sync()
+			}
+			w.ack(w.pending, err)
+			w.pending = nil
+
+			if closeErr := w.file.Close(); err == nil {
+				err = closeErr
+			}
+			w.closeErr = err
+			return
+		}
+	}
+}
+
+func (w *GroupCommitWriter) ack(requests []*writeRequest, err error) {
+	for _, request := range requests {
+		request.result <- err
+	}
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupCommitWriterConcurrentWrites(t *testing.T) {
+	policies := []SyncPolicy{SyncAlways, SyncInterval, SyncNever}
+
+	for _, policy := range policies {
+		t.Run(string(policy), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			filePath := "home/test/storage.json"
+
+			writer, err := NewGroupCommitWriter(fs, filePath, CompressionNone, policy, time.Millisecond)
+			require.NoError(t, err)
+
+			const count = 500
+			var wg sync.WaitGroup
+			for i := 0; i < count; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := writer.WriteEntry(&StorageEntry{ShortID: strconv.Itoa(i), FullURL: "http://example.com"})
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+
+			require.NoError(t, writer.Close())
+
+			reader, err := NewStorageReader(fs, filePath)
+			require.NoError(t, err)
+			defer reader.Close()
+
+			entries, err := reader.ReadAllEntries()
+			require.NoError(t, err)
+			assert.Len(t, entries, count)
+		})
+	}
+}
+
+func TestGroupCommitWriterRotate(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	filePath := "home/test/storage.json"
+	sealedPath := "home/test/storage.json.sealed"
+
+	writer, err := NewGroupCommitWriter(fs, filePath, CompressionNone, SyncAlways, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.WriteEntry(&StorageEntry{ShortID: "1", FullURL: "http://example.com/1"}))
+	require.NoError(t, writer.Rotate(sealedPath))
+	require.NoError(t, writer.WriteEntry(&StorageEntry{ShortID: "2", FullURL: "http://example.com/2"}))
+	require.NoError(t, writer.Close())
+
+	sealed, err := afero.ReadFile(fs, sealedPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(sealed), `"short_id":"1"`)
+	assert.NotContains(t, string(sealed), `"short_id":"2"`)
+
+	current, err := ReadFileEntries(fs, filePath)
+	require.NoError(t, err)
+	require.Len(t, current, 1)
+	assert.Equal(t, "2", current[0].ShortID)
+}
+
+func TestGroupCommitWriterClosed(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	writer, err := NewGroupCommitWriter(fs, "home/test/storage.json", CompressionNone, SyncNever, 0)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, writer.Close())
+
+	err = writer.WriteEntry(&StorageEntry{ShortID: "1"})
+	assert.ErrorIs(t, err, ErrWriterClosed)
+}
+
+func TestNewGroupCommitWriterInvalidPolicy(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_, err := NewGroupCommitWriter(fs, "home/test/storage.json", CompressionNone, SyncPolicy("sometimes"), 0)
+	assert.ErrorIs(t, err, ErrUnknownSyncPolicy)
+
+	_, err = NewGroupCommitWriter(fs, "home/test/storage.json", CompressionNone, SyncInterval, 0)
+	assert.Error(t, err)
+}
+
+func BenchmarkWriters(b *testing.B) {
+	entry := StorageEntry{ShortID: "89dce6a4", FullURL: "http://example.com"}
+
+	b.Run("FileStorageWriterPerEntry", func(b *testing.B) {
+		fs := afero.NewMemMapFs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				writer, err := NewFileStorageWriter(fs, "storage.json")
+				if err != nil {
+					b.Fatal(err)
+				}
+				_ = writer.WriteEntry(&entry)
+				writer.Close()
+			}
+		})
+	})
+
+	b.Run("GroupCommitWriter", func(b *testing.B) {
+		fs := afero.NewMemMapFs()
+		writer, err := NewGroupCommitWriter(fs, "storage.json", CompressionNone, SyncNever, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer writer.Close()
+
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = writer.WriteEntry(&entry)
+			}
+		})
+	})
+}
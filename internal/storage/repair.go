@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+)
+
+const (
+	// tornTailChunkSize размер блока, которым файл читается с конца в поисках последней целой строки
+	tornTailChunkSize = 4096
+	// zstdBlockHeaderSize размер заголовка блока zstd-фрейма
+	zstdBlockHeaderSize = 3
+	// zstdChecksumSize размер контрольной суммы в конце zstd-фрейма
+	zstdChecksumSize = 4
+	// zstdBlockTypeRLE тип блока из одного повторяемого байта
+	zstdBlockTypeRLE = 1
+)
+
+// TruncateTornTail отбрасывает конец файла хранилища, недописанный при аварийном завершении:
+// неполную последнюю строку JSON lines или неполный последний сжатый сегмент версии 2.
+// Возвращает true, если файл был обрезан. Отсутствие файла не является ошибкой.
+// Поврежденный, но полностью записанный сегмент не отбрасывается и обнаруживается при чтении.
+func TruncateTornTail(fs afero.Fs, filename string) (truncated bool, err error) {
+	file, err := fs.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	size, err := completeSize(file, info.Size())
+	if err != nil || size == info.Size() {
+		return false, err
+	}
+
+	if err = file.Truncate(size); err != nil {
+		return false, err
+	}
+
+	return true, file.Sync()
+}
+
+// completeSize возвращает размер начала файла, состоящего только из полностью записанных данных
+func completeSize(file afero.File, size int64) (int64, error) {
+	if size == 0 {
+		return 0, nil
+	}
+
+	header, _, err := readFileHeader(io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return 0, err
+	}
+
+	if header.Version == FormatVersion1 {
+		return completeLinesSize(file, 0, size)
+	}
+
+	headerLine, err := bufio.NewReader(io.NewSectionReader(file, 0, size)).ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		// заголовок недописан
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	headerSize := int64(len(headerLine))
+
+	switch header.Compression {
+	case CompressionGzip:
+		return completeGzipSize(file, headerSize, size)
+	case CompressionZstd:
+		return completeZstdSize(file, headerSize, size)
+	default:
+		return completeLinesSize(file, headerSize, size)
+	}
+}
+
+// completeLinesSize возвращает конец последней целой строки после позиции start
+func completeLinesSize(file afero.File, start int64, size int64) (int64, error) {
+	chunk := make([]byte, tornTailChunkSize)
+	for end := size; end > start; {
+		chunkStart := max(end-tornTailChunkSize, start)
+		n, err := file.ReadAt(chunk[:end-chunkStart], chunkStart)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		if i := bytes.LastIndexByte(chunk[:n], '\n'); i >= 0 {
+			return chunkStart + int64(i) + 1, nil
+		}
+		end = chunkStart
+	}
+
+	return start, nil
+}
+
+// completeGzipSize возвращает конец последнего целого gzip-сегмента после позиции start
+func completeGzipSize(file afero.File, start int64, size int64) (int64, error) {
+	reader := &countingReader{reader: bufio.NewReader(io.NewSectionReader(file, start, size-start))}
+	gzipReader := new(gzip.Reader)
+
+	complete := start
+	for complete < size {
+		err := gzipReader.Reset(reader)
+		if err == nil {
+			gzipReader.Multistream(false)
+			_, err = io.Copy(io.Discard, gzipReader)
+		}
+
+		if isTornSegment(err) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		complete = start + reader.count
+	}
+
+	return complete, nil
+}
+
+// completeZstdSize возвращает конец последнего целого zstd-фрейма после позиции start
+func completeZstdSize(file afero.File, start int64, size int64) (int64, error) {
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return 0, err
+	}
+	defer decoder.Close()
+
+	complete := start
+	for complete < size {
+		frameSize, err := zstdFrameSize(io.NewSectionReader(file, complete, size-complete))
+		if isTornSegment(err) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if err = decoder.Reset(io.NewSectionReader(file, complete, frameSize)); err != nil {
+			return 0, err
+		}
+
+		if _, err = io.Copy(io.Discard, decoder); err != nil {
+			return 0, err
+		}
+
+		complete += frameSize
+	}
+
+	return complete, nil
+}
+
+// zstdFrameSize возвращает размер zstd-фрейма в начале reader по заголовкам фрейма и его блоков.
+// Для недописанного фрейма возвращается io.ErrUnexpectedEOF.
+func zstdFrameSize(r io.Reader) (int64, error) {
+	reader := bufio.NewReader(r)
+
+	headerData, err := reader.Peek(zstd.HeaderMaxSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+
+	var header zstd.Header
+	if err = header.Decode(headerData); err != nil {
+		return 0, err
+	}
+
+	frameSize := int64(header.HeaderSize)
+	if _, err = reader.Discard(header.HeaderSize); err != nil {
+		return 0, err
+	}
+
+	blockHeader := make([]byte, zstdBlockHeaderSize)
+	for last := false; !last; {
+		if _, err = io.ReadFull(reader, blockHeader); err != nil {
+			return 0, err
+		}
+
+		value := uint32(blockHeader[0]) | uint32(blockHeader[1])<<8 | uint32(blockHeader[2])<<16
+		last = value&1 == 1
+		blockSize := int(value >> 3)
+		if (value>>1)&3 == zstdBlockTypeRLE {
+			blockSize = 1
+		}
+
+		if _, err = reader.Discard(blockSize); err != nil {
+			return 0, err
+		}
+		frameSize += zstdBlockHeaderSize + int64(blockSize)
+	}
+
+	if header.HasCheckSum {
+		if _, err = reader.Discard(zstdChecksumSize); err != nil {
+			return 0, err
+		}
+		frameSize += zstdChecksumSize
+	}
+
+	return frameSize, nil
+}
+
+// isTornSegment проверяет, что сегмент не удалось прочитать из-за конца файла
+func isTornSegment(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// countingReader считает прочитанные байты. Реализует io.ByteReader, поэтому
+// gzip.Reader читает из него ровно один сегмент, не забегая вперед.
+type countingReader struct {
+	reader *bufio.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.count++
+	}
+
+	return b, err
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateTornTail(t *testing.T) {
+	want := Storage{
+		{ShortID: "1", FullURL: "http://example.com/1", UserID: 1},
+		{ShortID: "2", FullURL: "http://example.com/2", UserID: 1},
+	}
+	torn := Storage{
+		{ShortID: "3", FullURL: "http://example.com/3", UserID: 1},
+	}
+
+	for _, compression := range allCompressions {
+		t.Run(string(compression), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			filePath := "home/test/storage.json"
+
+			writer, err := NewStorageWriter(fs, filePath, compression)
+			require.NoError(t, err)
+			require.NoError(t, writer.WriteEntries(want[:1]))
+			require.NoError(t, writer.WriteEntries(want[1:]))
+			require.NoError(t, writer.Close())
+
+			complete, err := afero.ReadFile(fs, filePath)
+			require.NoError(t, err)
+
+			encoder, err := newSegmentEncoder(compression)
+			require.NoError(t, err)
+			segment, err := encoder.encode(torn)
+			require.NoError(t, err)
+
+			// сегмент, прерванный на любом байте, отбрасывается целиком
+			for _, n := range []int{1, len(segment) / 2, len(segment) - 1} {
+				require.NoError(t, afero.WriteFile(fs, filePath, append(append([]byte{}, complete...), segment[:n]...), 0644))
+
+				truncated, err := TruncateTornTail(fs, filePath)
+				require.NoError(t, err)
+				assert.True(t, truncated)
+
+				data, err := afero.ReadFile(fs, filePath)
+				require.NoError(t, err)
+				assert.Equal(t, complete, data)
+			}
+
+			truncated, err := TruncateTornTail(fs, filePath)
+			require.NoError(t, err)
+			assert.False(t, truncated)
+
+			entries, err := ReadFileEntries(fs, filePath)
+			require.NoError(t, err)
+			assert.Equal(t, want, entries)
+		})
+	}
+}
+
+func TestTruncateTornTailLegacyFormat(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	filePath := "home/test/storage.json"
+	complete := `{"short_id":"1","full_url":"http://example.com/1"}` + "\n"
+	require.NoError(t, afero.WriteFile(fs, filePath, []byte(complete+`{"short_id":"2","full`), 0644))
+
+	truncated, err := TruncateTornTail(fs, filePath)
+	require.NoError(t, err)
+	assert.True(t, truncated)
+
+	data, err := afero.ReadFile(fs, filePath)
+	require.NoError(t, err)
+	assert.Equal(t, complete, string(data))
+}
+
+func TestTruncateTornTailHeader(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	filePath := "home/test/storage.json"
+	require.NoError(t, afero.WriteFile(fs, filePath, []byte(`{"format":"url-shortener-storage","version":2,"compression":"gzip"}`), 0644))
+
+	truncated, err := TruncateTornTail(fs, filePath)
+	require.NoError(t, err)
+	assert.True(t, truncated)
+
+	exists, err := afero.Exists(fs, filePath)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	data, err := afero.ReadFile(fs, filePath)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestTruncateTornTailCorruptSegment(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	filePath := "home/test/storage.json"
+
+	writer, err := NewStorageWriter(fs, filePath, CompressionGzip)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteEntries(Storage{{ShortID: "1", FullURL: "http://example.com/1"}}))
+	require.NoError(t, writer.Close())
+
+	// полностью записанный, но поврежденный сегмент не отбрасывается
+	data, err := afero.ReadFile(fs, filePath)
+	require.NoError(t, err)
+	data[len(data)-5] ^= 0xff
+	require.NoError(t, afero.WriteFile(fs, filePath, data, 0644))
+
+	_, err = TruncateTornTail(fs, filePath)
+	assert.Error(t, err)
+}
+
+func TestTruncateTornTailMissingFile(t *testing.T) {
+	truncated, err := TruncateTornTail(afero.NewMemMapFs(), "home/test/storage.json")
+	require.NoError(t, err)
+	assert.False(t, truncated)
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+const snapshotTempSuffix = ".tmp"
+
+// WriteSnapshot атомарно записывает снимок хранилища в файл filename
+// в текущей версии формата с указанным сжатием: записи пишутся во временный файл,
+// который сбрасывается на диск (fsync) и переименовывается в filename.
+// Записи передаются по одной: forEach вызывает write для каждой записи снимка,
+// поэтому снимок не собирается в памяти целиком.
+func WriteSnapshot(fs afero.Fs, filename string, compression Compression, forEach func(write func(entry StorageEntry) error) error) error {
+	tempFilename := filename + snapshotTempSuffix
+
+	encoder, err := newSegmentEncoder(compression)
+	if err != nil {
+		return err
+	}
+
+	file, err := fs.OpenFile(tempFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	if err = writeSnapshotEntries(file, encoder, forEach); err != nil {
+		file.Close()
+		fs.Remove(tempFilename)
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		fs.Remove(tempFilename)
+		return err
+	}
+
+	return fs.Rename(tempFilename, filename)
+}
+
+func writeSnapshotEntries(file afero.File, encoder *segmentEncoder, forEach func(write func(entry StorageEntry) error) error) error {
+	buffered := bufio.NewWriter(file)
+
+	if err := writeFileHeader(buffered, NewFileHeader(encoder.compression)); err != nil {
+		return err
+	}
+
+	segment := encoder.stream(buffered)
+	jsonEncoder := json.NewEncoder(segment)
+	err := forEach(func(entry StorageEntry) error {
+		return jsonEncoder.Encode(&entry)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = segment.Close(); err != nil {
+		return err
+	}
+
+	if err = buffered.Flush(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// ForEachFileEntry вызывает fn для каждой записи файла снимка или сегмента журнала
+// любой версии формата по мере чтения файла. Отсутствие файла не является ошибкой.
+func ForEachFileEntry(fs afero.Fs, filename string, fn func(entry StorageEntry) error) error {
+	if _, err := fs.Stat(filename); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	reader, err := NewStorageReader(fs, filename)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		entry, err := reader.ReadEntry()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = fn(*entry); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadFileEntries читает все записи файла снимка или сегмента журнала любой версии формата.
+// Отсутствие файла не является ошибкой.
+func ReadFileEntries(fs afero.Fs, filename string) (Storage, error) {
+	entries := make(Storage, 0)
+	err := ForEachFileEntry(fs, filename, func(entry StorageEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	want := Storage{
+		{Type: EntryTypeUser, UserID: 2},
+		{ShortID: "1", FullURL: "http://example.com/1", UserID: 1},
+		{ShortID: "2", FullURL: "http://example.com/2", UserID: 2, IsDeleted: true, Clicks: 3},
+	}
+
+	for _, compression := range allCompressions {
+		t.Run(string(compression), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			filePath := "home/test/storage.json.snapshot"
+
+			err := WriteSnapshot(fs, filePath, compression, func(write func(entry StorageEntry) error) error {
+				for _, entry := range want {
+					if err := write(entry); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			require.NoError(t, err)
+
+			header, ok, err := DetectFileHeader(fs, filePath)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, NewFileHeader(compression), header)
+
+			var entries Storage
+			err = ForEachFileEntry(fs, filePath, func(entry StorageEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, want, entries)
+		})
+	}
+}
+
+func TestWriteSnapshotError(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	filePath := "home/test/storage.json.snapshot"
+	errStop := errors.New("stop")
+
+	err := WriteSnapshot(fs, filePath, CompressionGzip, func(write func(entry StorageEntry) error) error {
+		if err := write(StorageEntry{ShortID: "1", FullURL: "http://example.com/1"}); err != nil {
+			return err
+		}
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+
+	// недописанный снимок не заменяет прежний
+	for _, filename := range []string{filePath, filePath + snapshotTempSuffix} {
+		exists, err := afero.Exists(fs, filename)
+		require.NoError(t, err)
+		assert.False(t, exists, filename)
+	}
+}
+
+func TestForEachFileEntryStop(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	filePath := "home/test/storage.json"
+	loadTestData(t, fs, "testdata/test_storage.json", filePath)
+	errStop := errors.New("stop")
+
+	calls := 0
+	err := ForEachFileEntry(fs, filePath, func(entry StorageEntry) error {
+		calls++
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+
+	err = ForEachFileEntry(fs, "home/test/missing.json", func(entry StorageEntry) error {
+		return errStop
+	})
+	assert.NoError(t, err)
+}
@@ -1,12 +1,50 @@
 package storage
 
+import "time"
+
 // Storage записи
 type Storage []StorageEntry
 
+// EntryType тип записи хранилища
+type EntryType string
+
+// Типы записей хранилища
+const (
+	// EntryTypeLink запись о сокращенной ссылке.
+	// Записи старого формата без поля type также считаются ссылками.
+	EntryTypeLink EntryType = "link"
+	// EntryTypeDelete запись-надгробие об удалении ссылки
+	EntryTypeDelete EntryType = "delete"
+	// EntryTypeUser запись о выданном ID пользователя
+	EntryTypeUser EntryType = "user"
+	// EntryTypeUserDeleted запись об удалении пользователя
+	EntryTypeUserDeleted EntryType = "user_deleted"
+	// EntryTypeClicks запись прежних версий о переходах по ссылке; Clicks прибавляется к счетчику ссылки
+	EntryTypeClicks EntryType = "clicks"
+	// EntryTypeClickCount запись о переходах по ссылке; Clicks - значение счетчика ссылки после переходов.
+	// Повторное чтение записи не меняет счетчик, поэтому журнал можно читать поверх снимка, уже учитывающего ее.
+	EntryTypeClickCount EntryType = "click_count"
+)
+
 // StorageEntry запись
 type StorageEntry struct {
-	ShortID string `json:"short_id"`
-	FullURL string `json:"full_url"`
+	Type      EntryType `json:"type,omitempty"`
+	ShortID   string    `json:"short_id,omitempty"`
+	FullURL   string    `json:"full_url,omitempty"`
+	UserID    int       `json:"user_id,omitempty"`
+	IsDeleted bool      `json:"is_deleted,omitempty"`
+	Clicks    int64     `json:"clicks,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// GetType возвращает тип записи с учетом записей старого формата
+func (e *StorageEntry) GetType() EntryType {
+	if e.Type == "" {
+		return EntryTypeLink
+	}
+
+	return e.Type
 }
 
 // StorageWriter интерфейс для записи информации в файл
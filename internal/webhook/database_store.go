@@ -0,0 +1,286 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/rovany706/url-shortener/internal/database"
+)
+
+// deliveryColumns столбцы доставки в порядке scanDelivery
+const deliveryColumns = `id, endpoint_id, user_id, event::text, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at`
+
+var (
+	// lockUserEndpointsSQL упорядочивает создание вебхуков одного пользователя до конца транзакции
+	lockUserEndpointsSQL = fmt.Sprintf(
+		`SELECT pg_advisory_xact_lock(%d, $1)`, userEndpointsLockID)
+	// insertEndpointSQL сохраняет вебхук, если у пользователя меньше $7 вебхуков
+	insertEndpointSQL = fmt.Sprintf(
+		`INSERT INTO %[1]s (id, user_id, url, secret, events, created_at)
+		SELECT $1, $2, $3, $4, $5, $6
+		WHERE (SELECT COUNT(*) FROM %[1]s WHERE user_id = $2) < $7`, database.WebhookEndpointsTableName)
+	selectEndpointSQL = fmt.Sprintf(
+		`SELECT id, user_id, url, secret, events, created_at FROM %s
+		WHERE id = $1`, database.WebhookEndpointsTableName)
+	selectUserEndpointsSQL = fmt.Sprintf(
+		`SELECT id, user_id, url, secret, events, created_at FROM %s
+		WHERE user_id = $1
+		ORDER BY created_at, id`, database.WebhookEndpointsTableName)
+	deleteEndpointSQL = fmt.Sprintf(
+		`DELETE FROM %s WHERE id = $1 AND user_id = $2`, database.WebhookEndpointsTableName)
+	insertDeliveriesSQL = fmt.Sprintf(
+		`INSERT INTO %s (id, endpoint_id, user_id, event, status, attempts, next_attempt_at, created_at, updated_at)
+			SELECT batch.id, batch.endpoint_id, batch.user_id, batch.event::jsonb, batch.status, 0, batch.next_attempt_at, batch.created_at, batch.created_at
+			FROM unnest($1::text[], $2::text[], $3::int[], $4::text[], $5::text[], $6::timestamptz[], $7::timestamptz[])
+				AS batch(id, endpoint_id, user_id, event, status, next_attempt_at, created_at)`, database.WebhookDeliveriesTableName)
+	// claimDueDeliveriesSQL откладывает ожидающие доставки, время попытки которых наступило;
+	// SKIP LOCKED не дает нескольким экземплярам сервиса взять одну доставку
+	claimDueDeliveriesSQL = fmt.Sprintf(
+		`UPDATE %[1]s SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM %[1]s
+			WHERE status = '%[2]s' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %[3]s`, database.WebhookDeliveriesTableName, DeliveryPending, deliveryColumns)
+	updateDeliverySQL = fmt.Sprintf(
+		`UPDATE %s
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = $5, last_error = $6, updated_at = $7
+		WHERE id = $1`, database.WebhookDeliveriesTableName)
+	selectUserDeliveriesSQL = fmt.Sprintf(
+		`SELECT %s FROM %s
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, deliveryColumns, database.WebhookDeliveriesTableName)
+	pruneDeliveriesSQL = fmt.Sprintf(
+		`DELETE FROM %s WHERE status <> '%s' AND updated_at < $1`, database.WebhookDeliveriesTableName, DeliveryPending)
+)
+
+// userEndpointsLockID первый ключ advisory lock, под которым создаются вебхуки пользователя (второй - ID пользователя)
+const userEndpointsLockID int32 = 0x77656268 // "webh"
+
+// DatabaseStore хранилище вебхуков в БД Postgres
+type DatabaseStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewDatabaseStore создает хранилище в БД пула pool с примененными миграциями схемы.
+// Пул принадлежит вызывающему и не закрывается хранилищем.
+func NewDatabaseStore(pool *pgxpool.Pool) *DatabaseStore {
+	return &DatabaseStore{pool: pool}
+}
+
+// CreateEndpoint сохраняет вебхук, если у пользователя меньше MaxUserEndpoints вебхуков.
+// Вебхуки пользователя создаются под advisory lock, чтобы одновременные запросы не превысили предел.
+func (s *DatabaseStore) CreateEndpoint(ctx context.Context, endpoint Endpoint) error {
+	events := make([]string, len(endpoint.Events))
+	for i, eventType := range endpoint.Events {
+		events[i] = string(eventType)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, lockUserEndpointsSQL, endpoint.UserID); err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, insertEndpointSQL, endpoint.ID, endpoint.UserID, endpoint.URL, endpoint.Secret, events, endpoint.CreatedAt, MaxUserEndpoints)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrTooManyEndpoints
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Endpoint возвращает вебхук по ID
+func (s *DatabaseStore) Endpoint(ctx context.Context, endpointID string) (Endpoint, error) {
+	endpoint, err := scanEndpoint(s.pool.QueryRow(ctx, selectEndpointSQL, endpointID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Endpoint{}, ErrNotFound
+	}
+
+	return endpoint, err
+}
+
+// UserEndpoints возвращает вебхуки пользователя в порядке создания
+func (s *DatabaseStore) UserEndpoints(ctx context.Context, userID int) ([]Endpoint, error) {
+	rows, err := s.pool.Query(ctx, selectUserEndpointsSQL, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := make([]Endpoint, 0)
+	for rows.Next() {
+		endpoint, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, rows.Err()
+}
+
+// DeleteEndpoint удаляет вебхук пользователя
+func (s *DatabaseStore) DeleteEndpoint(ctx context.Context, userID int, endpointID string) error {
+	tag, err := s.pool.Exec(ctx, deleteEndpointSQL, endpointID, userID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateDeliveries сохраняет новые доставки одним запросом
+func (s *DatabaseStore) CreateDeliveries(ctx context.Context, deliveries ...Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(deliveries))
+	endpointIDs := make([]string, len(deliveries))
+	userIDs := make([]int, len(deliveries))
+	events := make([]string, len(deliveries))
+	statuses := make([]string, len(deliveries))
+	nextAttempts := make([]time.Time, len(deliveries))
+	createdAt := make([]time.Time, len(deliveries))
+
+	for i, delivery := range deliveries {
+		event, err := json.Marshal(delivery.Event)
+		if err != nil {
+			return err
+		}
+
+		ids[i] = delivery.ID
+		endpointIDs[i] = delivery.EndpointID
+		userIDs[i] = delivery.UserID
+		events[i] = string(event)
+		statuses[i] = string(delivery.Status)
+		nextAttempts[i] = delivery.NextAttemptAt
+		createdAt[i] = delivery.CreatedAt
+	}
+
+	_, err := s.pool.Exec(ctx, insertDeliveriesSQL, ids, endpointIDs, userIDs, events, statuses, nextAttempts, createdAt)
+
+	return err
+}
+
+// ClaimDueDeliveries возвращает ожидающие доставки, время попытки которых наступило
+func (s *DatabaseStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	return s.queryDeliveries(ctx, claimDueDeliveriesSQL, now, now.Add(lease), limit)
+}
+
+// UpdateDelivery сохраняет результат попытки доставки
+func (s *DatabaseStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	tag, err := s.pool.Exec(ctx, updateDeliverySQL, delivery.ID, string(delivery.Status), delivery.Attempts,
+		delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError, delivery.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UserDeliveries возвращает последние доставки пользователя, начиная с новых
+func (s *DatabaseStore) UserDeliveries(ctx context.Context, userID int, limit int) ([]Delivery, error) {
+	if limit < 1 {
+		return nil, ErrInvalidLimit
+	}
+
+	return s.queryDeliveries(ctx, selectUserDeliveriesSQL, userID, limit)
+}
+
+// PruneDeliveries удаляет доставленные и исчерпавшие попытки доставки, обновленные до before
+func (s *DatabaseStore) PruneDeliveries(ctx context.Context, before time.Time) error {
+	_, err := s.pool.Exec(ctx, pruneDeliveriesSQL, before)
+
+	return err
+}
+
+// Close ничего не делает: пул закрывает его владелец
+func (s *DatabaseStore) Close() error {
+	return nil
+}
+
+// queryDeliveries выполняет запрос, возвращающий столбцы deliveryColumns
+func (s *DatabaseStore) queryDeliveries(ctx context.Context, sql string, args ...any) ([]Delivery, error) {
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]Delivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// scanEndpoint читает вебхук из строки результата запроса
+func scanEndpoint(row pgx.Row) (Endpoint, error) {
+	var endpoint Endpoint
+	var events []string
+	if err := row.Scan(&endpoint.ID, &endpoint.UserID, &endpoint.URL, &endpoint.Secret, &events, &endpoint.CreatedAt); err != nil {
+		return Endpoint{}, err
+	}
+
+	endpoint.Events = make([]EventType, len(events))
+	for i, eventType := range events {
+		endpoint.Events[i] = EventType(eventType)
+	}
+	endpoint.CreatedAt = endpoint.CreatedAt.UTC()
+
+	return endpoint, nil
+}
+
+// scanDelivery читает доставку из строки результата запроса со столбцами deliveryColumns
+func scanDelivery(row pgx.Row) (Delivery, error) {
+	var delivery Delivery
+	var event, status string
+	err := row.Scan(&delivery.ID, &delivery.EndpointID, &delivery.UserID, &event, &status, &delivery.Attempts,
+		&delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError, &delivery.CreatedAt, &delivery.UpdatedAt)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	if err = json.Unmarshal([]byte(event), &delivery.Event); err != nil {
+		return Delivery{}, err
+	}
+	delivery.Status = DeliveryStatus(status)
+	delivery.NextAttemptAt = delivery.NextAttemptAt.UTC()
+	delivery.CreatedAt = delivery.CreatedAt.UTC()
+	delivery.UpdatedAt = delivery.UpdatedAt.UTC()
+
+	return delivery, nil
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/database"
+)
+
+// testDatabaseDSNEnv переменная окружения со строкой подключения к тестовой БД Postgres
+const testDatabaseDSNEnv = "TEST_DATABASE_DSN"
+
+func TestDatabaseStore(t *testing.T) {
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseDSNEnv)
+	}
+
+	ctx := context.Background()
+	pool, err := database.InitPool(ctx, dsn, database.PoolConfig{})
+	require.NoError(t, err)
+	defer pool.Close()
+	require.NoError(t, database.MigratePool(ctx, pool))
+
+	store := NewDatabaseStore(pool)
+
+	// уникальный пользователь, чтобы не зависеть от данных предыдущих запусков
+	userID := int(time.Now().UnixNano() % 1_000_000_000)
+
+	endpoint := createEndpoint(t, store, userID, "https://receiver.example/hook", EventLinkCreated, EventLinkDeleted)
+
+	endpoints, err := store.UserEndpoints(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	assert.Equal(t, endpoint.Secret, endpoints[0].Secret)
+	assert.Equal(t, endpoint.Events, endpoints[0].Events)
+
+	dispatcher := NewDispatcher(store)
+	require.NoError(t, dispatcher.Publish(ctx, Event{Type: EventLinkCreated, UserID: userID, ShortID: "abc", OriginalURL: "http://example.com/"}))
+
+	delivery := userDelivery(t, store, userID)
+	assert.Equal(t, DeliveryPending, delivery.Status)
+	assert.Equal(t, "abc", delivery.Event.ShortID)
+
+	now := time.Now().UTC()
+	claimed, err := store.ClaimDueDeliveries(ctx, now, time.Minute, claimBatchSize)
+	require.NoError(t, err)
+	assert.Contains(t, deliveryIDs(claimed), delivery.ID)
+
+	// забранная доставка не возвращается до истечения аренды
+	claimed, err = store.ClaimDueDeliveries(ctx, now, time.Minute, claimBatchSize)
+	require.NoError(t, err)
+	assert.NotContains(t, deliveryIDs(claimed), delivery.ID)
+
+	delivery.Status = DeliveryDead
+	delivery.Attempts = 1
+	delivery.LastError = "webhook endpoint deleted"
+	require.NoError(t, store.UpdateDelivery(ctx, delivery))
+	assert.Equal(t, DeliveryDead, userDelivery(t, store, userID).Status)
+
+	assert.ErrorIs(t, store.DeleteEndpoint(ctx, userID+1, endpoint.ID), ErrNotFound)
+	require.NoError(t, store.DeleteEndpoint(ctx, userID, endpoint.ID))
+	_, err = store.Endpoint(ctx, endpoint.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	requireEndpointLimit(t, store, userID+2)
+	requirePruneDeliveries(t, store, userID+3)
+}
+
+func deliveryIDs(deliveries []Delivery) []string {
+	ids := make([]string, len(deliveries))
+	for i, delivery := range deliveries {
+		ids[i] = delivery.ID
+	}
+
+	return ids
+}
@@ -0,0 +1,381 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultClickSampleRate = 0.01
+	defaultMaxAttempts     = 5
+	defaultRetryDelay      = time.Second
+	defaultMaxRetryDelay   = time.Hour
+	defaultTimeout         = time.Second * 5
+	defaultPollInterval    = time.Second
+	defaultRetention       = time.Hour * 24 * 7
+)
+
+// pruneInterval период удаления завершенных доставок старше времени хранения
+const pruneInterval = time.Hour
+
+// claimBatchSize количество доставок, забираемых из хранилища за один раз
+const claimBatchSize = 100
+
+// maxResponseBodySize объем тела ответа получателя, который вычитывается перед закрытием соединения
+const maxResponseBodySize = 64 << 10
+
+// ErrUnexpectedStatus ошибка ответа получателя с кодом не 2xx
+var ErrUnexpectedStatus = errors.New("unexpected webhook response status")
+
+// Dispatcher публикует события ссылок на вебхуки их владельцев и доставляет их.
+//
+// Publish сохраняет по доставке на каждый подписанный вебхук, фоновый обработчик отправляет их
+// POST-запросами с подписью HMAC-SHA256 секретом вебхука. Неуспешные попытки повторяются
+// с экспоненциально растущей задержкой; после исчерпания попыток доставка переходит в состояние dead.
+// Доставка на адрес непубличной сети сразу переходит в состояние dead.
+// События link.clicked публикуются только для доли переходов.
+// Завершенные доставки удаляются по истечении времени хранения.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	logger *zap.Logger
+
+	clickSampleRate float64
+	maxAttempts     int
+	retryDelay      time.Duration
+	maxRetryDelay   time.Duration
+	pollInterval    time.Duration
+	retention       time.Duration
+
+	wake chan struct{}
+	done chan struct{}
+}
+
+// DispatcherOption функциональная опция Dispatcher
+type DispatcherOption func(*Dispatcher)
+
+// WithClickSampleRate задает долю переходов, для которых публикуется событие link.clicked
+func WithClickSampleRate(rate float64) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.clickSampleRate = rate
+	}
+}
+
+// WithRetry задает количество попыток доставки, задержку перед второй попыткой
+// и наибольшую задержку между попытками
+func WithRetry(maxAttempts int, retryDelay time.Duration, maxRetryDelay time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		if maxAttempts > 0 {
+			d.maxAttempts = maxAttempts
+		}
+		if retryDelay > 0 {
+			d.retryDelay = retryDelay
+		}
+		if maxRetryDelay > 0 {
+			d.maxRetryDelay = maxRetryDelay
+		}
+	}
+}
+
+// WithTimeout задает время ожидания ответа получателя
+func WithTimeout(timeout time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		if timeout > 0 {
+			d.client.Timeout = timeout
+		}
+	}
+}
+
+// WithPollInterval задает период проверки доставок, время попытки которых наступило
+func WithPollInterval(interval time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		if interval > 0 {
+			d.pollInterval = interval
+		}
+	}
+}
+
+// WithRetention задает время хранения доставленных и исчерпавших попытки доставок
+func WithRetention(retention time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		if retention > 0 {
+			d.retention = retention
+		}
+	}
+}
+
+// WithPrivateNetworks разрешает доставку на адреса частных, локальных и зарезервированных сетей.
+// По умолчанию подключение к ним отклоняется (защита от SSRF); опция нужна для локальной разработки.
+func WithPrivateNetworks() DispatcherOption {
+	return func(d *Dispatcher) {
+		d.client.Transport = newTransport(true)
+	}
+}
+
+// WithLogger задает логгер ошибок доставки
+func WithLogger(logger *zap.Logger) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.logger = logger
+	}
+}
+
+// NewDispatcher создает Dispatcher
+func NewDispatcher(store Store, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		store: store,
+		client: &http.Client{
+			Transport: newTransport(false),
+			Timeout:   defaultTimeout,
+			// перенаправление считается неуспешной попыткой
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger:          zap.NewNop(),
+		clickSampleRate: defaultClickSampleRate,
+		maxAttempts:     defaultMaxAttempts,
+		retryDelay:      defaultRetryDelay,
+		maxRetryDelay:   defaultMaxRetryDelay,
+		pollInterval:    defaultPollInterval,
+		retention:       defaultRetention,
+		wake:            make(chan struct{}, 1),
+		done:            make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Publish сохраняет доставки событий на вебхуки владельцев ссылок, подписанные на них
+func (d *Dispatcher) Publish(ctx context.Context, events ...Event) error {
+	now := time.Now().UTC()
+	endpointsByUser := make(map[int][]Endpoint)
+	deliveries := make([]Delivery, 0)
+
+	for _, event := range events {
+		if event.Type == EventLinkClicked && !d.sampleClick() {
+			continue
+		}
+		if event.Time.IsZero() {
+			event.Time = now
+		}
+
+		endpoints, ok := endpointsByUser[event.UserID]
+		if !ok {
+			var err error
+			if endpoints, err = d.store.UserEndpoints(ctx, event.UserID); err != nil {
+				return err
+			}
+			endpointsByUser[event.UserID] = endpoints
+		}
+
+		for _, endpoint := range endpoints {
+			if !endpoint.Subscribed(event.Type) {
+				continue
+			}
+
+			id, err := randomHex(16)
+			if err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, Delivery{
+				ID:            id,
+				EndpointID:    endpoint.ID,
+				UserID:        event.UserID,
+				Event:         event,
+				Status:        DeliveryPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			})
+		}
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := d.store.CreateDeliveries(ctx, deliveries...); err != nil {
+		return err
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// StartWorker запускает доставку событий в отдельной горутине
+func (d *Dispatcher) StartWorker(ctx context.Context) {
+	go func() {
+		defer close(d.done)
+
+		ticker := time.NewTicker(d.pollInterval)
+		defer ticker.Stop()
+
+		var prunedAt time.Time
+		for {
+			select {
+			case <-ticker.C:
+			case <-d.wake:
+			case <-ctx.Done():
+				return
+			}
+
+			if err := d.deliverDue(ctx); err != nil {
+				d.logger.Error("error delivering webhooks", zap.Error(err))
+			}
+
+			if time.Since(prunedAt) >= pruneInterval {
+				prunedAt = time.Now()
+				if err := d.store.PruneDeliveries(ctx, prunedAt.UTC().Add(-d.retention)); err != nil {
+					d.logger.Error("error pruning webhook deliveries", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Wait ожидает завершения обработчика, запущенного StartWorker, после завершения его контекста.
+// Прерванные доставки повторяются после истечения аренды.
+func (d *Dispatcher) Wait() {
+	<-d.done
+}
+
+// deliverDue выполняет попытки доставки, время которых наступило
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	for {
+		deliveries, err := d.store.ClaimDueDeliveries(ctx, time.Now().UTC(), d.lease(), claimBatchSize)
+		if err != nil {
+			return err
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(deliveries))
+		for i, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = d.deliver(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+
+		if err = errors.Join(errs...); err != nil {
+			return err
+		}
+
+		if len(deliveries) < claimBatchSize {
+			return nil
+		}
+	}
+}
+
+// deliver выполняет попытку доставки и сохраняет ее результат
+func (d *Dispatcher) deliver(ctx context.Context, delivery Delivery) error {
+	endpoint, err := d.store.Endpoint(ctx, delivery.EndpointID)
+	if errors.Is(err, ErrNotFound) {
+		delivery.Status = DeliveryDead
+		delivery.LastError = "webhook endpoint deleted"
+		delivery.UpdatedAt = time.Now().UTC()
+
+		return d.store.UpdateDelivery(ctx, delivery)
+	}
+	if err != nil {
+		// доставка будет повторена после истечения аренды
+		return err
+	}
+
+	statusCode, err := d.send(ctx, endpoint, delivery)
+
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		delivery.Status = DeliveryDelivered
+		delivery.LastError = ""
+	case errors.Is(err, ErrForbiddenAddress) || delivery.Attempts >= d.maxAttempts:
+		delivery.Status = DeliveryDead
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+
+	return d.store.UpdateDelivery(ctx, delivery)
+}
+
+// send отправляет подписанное событие на вебхук и возвращает код ответа
+func (d *Dispatcher) send(ctx context.Context, endpoint Endpoint, delivery Delivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := unixTimestamp(time.Now())
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(endpoint.Secret, timestamp, body))
+	request.Header.Set(EventHeader, string(delivery.Event.Type))
+	request.Header.Set(DeliveryHeader, delivery.ID)
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseBodySize))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("%w: %d", ErrUnexpectedStatus, response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+// backoff возвращает задержку перед попыткой после attempts неуспешных:
+// retryDelay, удваивающаяся с каждой попыткой, но не больше maxRetryDelay
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.retryDelay
+	for i := 1; i < attempts && delay < d.maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.maxRetryDelay)
+}
+
+// lease возвращает время, на которое откладываются забранные доставки:
+// за него попытка гарантированно завершается
+func (d *Dispatcher) lease() time.Duration {
+	return d.client.Timeout * 2
+}
+
+// sampleClick решает, публикуется ли событие перехода
+func (d *Dispatcher) sampleClick() bool {
+	return d.clickSampleRate > 0 && rand.Float64() < d.clickSampleRate
+}
@@ -0,0 +1,350 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReceiver получатель вебхуков, отвечающий кодами statusCodes по очереди
+// (последний код повторяется) и запоминающий запросы
+type testReceiver struct {
+	*httptest.Server
+
+	mutex       sync.Mutex
+	statusCodes []int
+	requests    []receivedRequest
+}
+
+// receivedRequest запрос, полученный testReceiver
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newTestReceiver(t *testing.T, statusCodes ...int) *testReceiver {
+	receiver := &testReceiver{statusCodes: statusCodes}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		receiver.mutex.Lock()
+		defer receiver.mutex.Unlock()
+
+		receiver.requests = append(receiver.requests, receivedRequest{header: r.Header.Clone(), body: body})
+		statusCode := receiver.statusCodes[min(len(receiver.requests), len(receiver.statusCodes))-1]
+		w.WriteHeader(statusCode)
+	}))
+	t.Cleanup(receiver.Close)
+
+	return receiver
+}
+
+func (r *testReceiver) received() []receivedRequest {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+// createEndpoint регистрирует вебхук пользователя userID на адрес receiverURL
+func createEndpoint(t *testing.T, store Store, userID int, receiverURL string, events ...EventType) Endpoint {
+	endpoint, err := NewEndpoint(userID, receiverURL, events)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateEndpoint(context.Background(), endpoint))
+
+	return endpoint
+}
+
+// userDelivery возвращает единственную доставку пользователя userID
+func userDelivery(t *testing.T, store Store, userID int) Delivery {
+	deliveries, err := store.UserDeliveries(context.Background(), userID, DefaultUserDeliveriesLimit)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+
+	return deliveries[0]
+}
+
+func TestDispatcherDeliver(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	receiver := newTestReceiver(t, http.StatusOK)
+	endpoint := createEndpoint(t, store, 1, receiver.URL, EventLinkCreated)
+	// приемник теста слушает локальный адрес
+	dispatcher := NewDispatcher(store, WithPrivateNetworks())
+
+	event := Event{Type: EventLinkCreated, UserID: 1, ShortID: "abc", OriginalURL: "http://example.com/"}
+	require.NoError(t, dispatcher.Publish(ctx, event))
+	require.NoError(t, dispatcher.deliverDue(ctx))
+
+	requests := receiver.received()
+	require.Len(t, requests, 1)
+
+	header := requests[0].header
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, string(EventLinkCreated), header.Get(EventHeader))
+	assert.True(t, Verify(endpoint.Secret, header.Get(TimestampHeader), requests[0].body, header.Get(SignatureHeader)))
+	assert.False(t, Verify("other secret", header.Get(TimestampHeader), requests[0].body, header.Get(SignatureHeader)))
+
+	var received Event
+	require.NoError(t, json.Unmarshal(requests[0].body, &received))
+	assert.Equal(t, event.ShortID, received.ShortID)
+	assert.Equal(t, event.OriginalURL, received.OriginalURL)
+	assert.WithinDuration(t, time.Now(), received.Time, time.Minute)
+
+	delivery := userDelivery(t, store, 1)
+	assert.Equal(t, header.Get(DeliveryHeader), delivery.ID)
+	assert.Equal(t, DeliveryDelivered, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusOK, delivery.LastStatusCode)
+	assert.Empty(t, delivery.LastError)
+
+	// доставленное событие больше не отправляется
+	require.NoError(t, dispatcher.deliverDue(ctx))
+	assert.Len(t, receiver.received(), 1)
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	receiver := newTestReceiver(t, http.StatusInternalServerError, http.StatusOK)
+	createEndpoint(t, store, 1, receiver.URL, EventLinkDeleted)
+	dispatcher := NewDispatcher(store, WithRetry(3, time.Millisecond, time.Millisecond), WithPrivateNetworks())
+
+	require.NoError(t, dispatcher.Publish(ctx, Event{Type: EventLinkDeleted, UserID: 1, ShortID: "abc"}))
+	require.NoError(t, dispatcher.deliverDue(ctx))
+
+	delivery := userDelivery(t, store, 1)
+	assert.Equal(t, DeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+	assert.NotEmpty(t, delivery.LastError)
+
+	time.Sleep(time.Millisecond * 10)
+	require.NoError(t, dispatcher.deliverDue(ctx))
+
+	delivery = userDelivery(t, store, 1)
+	assert.Equal(t, DeliveryDelivered, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Empty(t, delivery.LastError)
+
+	// повторная попытка отправляется с тем же ID доставки
+	requests := receiver.received()
+	require.Len(t, requests, 2)
+	assert.Equal(t, requests[0].header.Get(DeliveryHeader), requests[1].header.Get(DeliveryHeader))
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	receiver := newTestReceiver(t, http.StatusServiceUnavailable)
+	createEndpoint(t, store, 1, receiver.URL, EventLinkCreated)
+	dispatcher := NewDispatcher(store, WithRetry(3, time.Millisecond, time.Millisecond), WithPrivateNetworks())
+
+	require.NoError(t, dispatcher.Publish(ctx, Event{Type: EventLinkCreated, UserID: 1, ShortID: "abc"}))
+	for range 5 {
+		require.NoError(t, dispatcher.deliverDue(ctx))
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	delivery := userDelivery(t, store, 1)
+	assert.Equal(t, DeliveryDead, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+	assert.Contains(t, delivery.LastError, ErrUnexpectedStatus.Error())
+	assert.Len(t, receiver.received(), 3)
+}
+
+func TestDispatcherDeletedEndpoint(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	receiver := newTestReceiver(t, http.StatusOK)
+	endpoint := createEndpoint(t, store, 1, receiver.URL, EventLinkCreated)
+	dispatcher := NewDispatcher(store)
+
+	require.NoError(t, dispatcher.Publish(ctx, Event{Type: EventLinkCreated, UserID: 1, ShortID: "abc"}))
+	require.NoError(t, store.DeleteEndpoint(ctx, 1, endpoint.ID))
+	require.NoError(t, dispatcher.deliverDue(ctx))
+
+	delivery := userDelivery(t, store, 1)
+	assert.Equal(t, DeliveryDead, delivery.Status)
+	assert.Zero(t, delivery.Attempts)
+	assert.Empty(t, receiver.received())
+}
+
+func TestDispatcherPublish(t *testing.T) {
+	tests := []struct {
+		name            string
+		clickSampleRate float64
+		event           Event
+		wantDeliveries  int
+	}{
+		{
+			"subscribed endpoints only",
+			0,
+			Event{Type: EventLinkCreated, UserID: 1, ShortID: "abc"},
+			2,
+		},
+		{
+			"no endpoints of owner",
+			0,
+			Event{Type: EventLinkCreated, UserID: 3, ShortID: "abc"},
+			0,
+		},
+		{
+			"click not sampled",
+			0,
+			Event{Type: EventLinkClicked, UserID: 1, ShortID: "abc"},
+			0,
+		},
+		{
+			"click sampled",
+			1,
+			Event{Type: EventLinkClicked, UserID: 1, ShortID: "abc"},
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			createEndpoint(t, store, 1, "http://receiver.example/all", EventTypes...)
+			createEndpoint(t, store, 1, "http://receiver.example/created", EventLinkCreated)
+			createEndpoint(t, store, 1, "http://receiver.example/deleted", EventLinkDeleted)
+			createEndpoint(t, store, 2, "http://receiver.example/other", EventTypes...)
+			dispatcher := NewDispatcher(store, WithClickSampleRate(tt.clickSampleRate))
+
+			require.NoError(t, dispatcher.Publish(ctx, tt.event))
+
+			deliveries, err := store.UserDeliveries(ctx, tt.event.UserID, DefaultUserDeliveriesLimit)
+			require.NoError(t, err)
+			assert.Len(t, deliveries, tt.wantDeliveries)
+			for _, delivery := range deliveries {
+				assert.Equal(t, DeliveryPending, delivery.Status)
+				assert.Equal(t, tt.event.Type, delivery.Event.Type)
+			}
+		})
+	}
+}
+
+func TestDispatcherStartWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewMemoryStore()
+	receiver := newTestReceiver(t, http.StatusNoContent)
+	createEndpoint(t, store, 1, receiver.URL, EventLinkCreated)
+	// период опроса больше времени теста: доставку запускает публикация
+	dispatcher := NewDispatcher(store, WithPollInterval(time.Hour), WithPrivateNetworks())
+	dispatcher.StartWorker(ctx)
+
+	require.NoError(t, dispatcher.Publish(ctx, Event{Type: EventLinkCreated, UserID: 1, ShortID: "abc"}))
+
+	assert.Eventually(t, func() bool {
+		return len(receiver.received()) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	// после завершения контекста обработчик останавливается
+	cancel()
+	dispatcher.Wait()
+}
+
+func TestDispatcherPruneDeliveries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewMemoryStore()
+	old := time.Now().UTC().Add(-time.Hour)
+	require.NoError(t, store.CreateDeliveries(ctx, Delivery{ID: "old", UserID: 1, Status: DeliveryDead, CreatedAt: old, UpdatedAt: old}))
+
+	dispatcher := NewDispatcher(store, WithPollInterval(time.Millisecond*10), WithRetention(time.Minute))
+	dispatcher.StartWorker(ctx)
+
+	assert.Eventually(t, func() bool {
+		deliveries, err := store.UserDeliveries(ctx, 1, 10)
+		return err == nil && len(deliveries) == 0
+	}, time.Second*5, time.Millisecond*10)
+
+	cancel()
+	dispatcher.Wait()
+}
+
+func TestDispatcherForbiddenAddress(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	receiver := newTestReceiver(t, http.StatusOK)
+	createEndpoint(t, store, 1, receiver.URL, EventLinkCreated)
+	dispatcher := NewDispatcher(store)
+
+	require.NoError(t, dispatcher.Publish(ctx, Event{Type: EventLinkCreated, UserID: 1, ShortID: "abc"}))
+	require.NoError(t, dispatcher.deliverDue(ctx))
+
+	// подключение к локальному адресу отклоняется без повторных попыток
+	delivery := userDelivery(t, store, 1)
+	assert.Equal(t, DeliveryDead, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Contains(t, delivery.LastError, ErrForbiddenAddress.Error())
+	assert.Empty(t, receiver.received())
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"198.18.0.1", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"2001:db8::1", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isPublicAddr(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	dispatcher := NewDispatcher(NewMemoryStore(), WithRetry(10, time.Second, time.Second*10))
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, time.Second * 2},
+		{3, time.Second * 4},
+		{4, time.Second * 8},
+		{5, time.Second * 10},
+		{100, time.Second * 10},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, dispatcher.backoff(tt.attempts), "attempts %d", tt.attempts)
+	}
+}
@@ -0,0 +1,365 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// storeCompactSuffix суффикс временного файла при сжатии журнала
+const storeCompactSuffix = ".tmp"
+
+// storeSnapshot вебхуки и доставки хранилища
+type storeSnapshot struct {
+	Endpoints []endpointRecord
+	// Deliveries доставки в порядке создания
+	Deliveries []deliveryRecord
+}
+
+// endpointRecord вебхук в файле хранилища вместе с ID пользователя
+type endpointRecord struct {
+	Endpoint
+	UserID int `json:"user_id"`
+}
+
+// deliveryRecord доставка в файле хранилища вместе с ID пользователя
+type deliveryRecord struct {
+	Delivery
+	UserID int `json:"user_id"`
+}
+
+// storeRecord строка журнала хранилища: созданный или удаленный вебхук, созданные
+// или обновленная доставки либо время, до которого удалены завершенные доставки
+type storeRecord struct {
+	Endpoint        *endpointRecord  `json:"endpoint,omitempty"`
+	DeletedEndpoint string           `json:"deleted_endpoint,omitempty"`
+	Deliveries      []deliveryRecord `json:"deliveries,omitempty"`
+	UpdatedDelivery *deliveryRecord  `json:"updated_delivery,omitempty"`
+	PrunedBefore    *time.Time       `json:"pruned_before,omitempty"`
+}
+
+// FileStore хранилище вебхуков в файле-журнале.
+// Данные хранятся в MemoryStore; каждое изменение дописывается строкой JSON и сбрасывается
+// на диск до изменения памяти, поэтому после неудачной записи хранилище не меняется.
+// При открытии журнал воспроизводится, а при удалении завершенных доставок (PruneDeliveries)
+// он заменяется текущим состоянием хранилища.
+// Аренда доставок в файл не записывается: после перезапуска прерванные доставки повторяются сразу.
+type FileStore struct {
+	mutex sync.Mutex
+	fs    afero.Fs
+	path  string
+	file  afero.File
+	// size размер журнала без недописанной строки
+	size   int64
+	memory *MemoryStore
+}
+
+// NewFileStore открывает или создает файл хранилища и восстанавливает его содержимое.
+// Строка, недописанная при аварийном завершении, отбрасывается.
+func NewFileStore(fs afero.Fs, path string) (*FileStore, error) {
+	file, err := fs.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{
+		fs:     fs,
+		path:   path,
+		file:   file,
+		memory: NewMemoryStore(),
+	}
+
+	if err = s.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// CreateEndpoint сохраняет вебхук, если у пользователя меньше MaxUserEndpoints вебхуков
+func (s *FileStore) CreateEndpoint(ctx context.Context, endpoint Endpoint) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	endpoints, err := s.memory.UserEndpoints(ctx, endpoint.UserID)
+	if err != nil {
+		return err
+	}
+	if len(endpoints) >= MaxUserEndpoints {
+		return ErrTooManyEndpoints
+	}
+
+	if err = s.write(storeRecord{Endpoint: &endpointRecord{Endpoint: endpoint, UserID: endpoint.UserID}}); err != nil {
+		return err
+	}
+
+	return s.memory.CreateEndpoint(ctx, endpoint)
+}
+
+// Endpoint возвращает вебхук по ID
+func (s *FileStore) Endpoint(ctx context.Context, endpointID string) (Endpoint, error) {
+	return s.memory.Endpoint(ctx, endpointID)
+}
+
+// UserEndpoints возвращает вебхуки пользователя в порядке создания
+func (s *FileStore) UserEndpoints(ctx context.Context, userID int) ([]Endpoint, error) {
+	return s.memory.UserEndpoints(ctx, userID)
+}
+
+// DeleteEndpoint удаляет вебхук пользователя
+func (s *FileStore) DeleteEndpoint(ctx context.Context, userID int, endpointID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	endpoint, err := s.memory.Endpoint(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+	if endpoint.UserID != userID {
+		return ErrNotFound
+	}
+
+	if err = s.write(storeRecord{DeletedEndpoint: endpointID}); err != nil {
+		return err
+	}
+
+	return s.memory.DeleteEndpoint(ctx, userID, endpointID)
+}
+
+// CreateDeliveries сохраняет новые доставки
+func (s *FileStore) CreateDeliveries(ctx context.Context, deliveries ...Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	records := make([]deliveryRecord, len(deliveries))
+	for i, delivery := range deliveries {
+		records[i] = deliveryRecord{Delivery: delivery, UserID: delivery.UserID}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.write(storeRecord{Deliveries: records}); err != nil {
+		return err
+	}
+
+	return s.memory.CreateDeliveries(ctx, deliveries...)
+}
+
+// ClaimDueDeliveries возвращает ожидающие доставки, время попытки которых наступило
+func (s *FileStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	return s.memory.ClaimDueDeliveries(ctx, now, lease, limit)
+}
+
+// UpdateDelivery сохраняет результат попытки доставки
+func (s *FileStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.memory.hasDelivery(delivery.ID) {
+		return ErrNotFound
+	}
+
+	if err := s.write(storeRecord{UpdatedDelivery: &deliveryRecord{Delivery: delivery, UserID: delivery.UserID}}); err != nil {
+		return err
+	}
+
+	return s.memory.UpdateDelivery(ctx, delivery)
+}
+
+// UserDeliveries возвращает последние доставки пользователя, начиная с новых
+func (s *FileStore) UserDeliveries(ctx context.Context, userID int, limit int) ([]Delivery, error) {
+	return s.memory.UserDeliveries(ctx, userID, limit)
+}
+
+// PruneDeliveries удаляет доставленные и исчерпавшие попытки доставки, обновленные до before,
+// и заменяет журнал текущим состоянием хранилища
+func (s *FileStore) PruneDeliveries(ctx context.Context, before time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.write(storeRecord{PrunedBefore: &before}); err != nil {
+		return err
+	}
+
+	if err := s.memory.PruneDeliveries(ctx, before); err != nil {
+		return err
+	}
+
+	return s.compact()
+}
+
+// Close закрывает файл хранилища
+func (s *FileStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.file.Close()
+}
+
+// write дописывает записи в журнал и сбрасывает его на диск.
+// Недописанные при ошибке строки обрезаются, чтобы не оказаться перед следующими записями.
+// Вызывается под мьютексом.
+func (s *FileStore) write(records ...storeRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.file.Write(buf.Bytes())
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		_ = s.truncate(s.size)
+		return err
+	}
+
+	s.size += int64(buf.Len())
+
+	return nil
+}
+
+// replay восстанавливает хранилище из журнала и обрезает его после последней целой строки
+func (s *FileStore) replay() error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var offset int64
+
+	reader := bufio.NewReader(s.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// недописанная строка не применяется
+			break
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+
+		var record storeRecord
+		if err = json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+
+		if err = s.apply(ctx, record); err != nil {
+			return err
+		}
+	}
+
+	s.size = offset
+
+	return s.truncate(offset)
+}
+
+// apply применяет запись журнала к хранилищу в памяти
+func (s *FileStore) apply(ctx context.Context, record storeRecord) error {
+	snapshot := storeSnapshot{Deliveries: record.Deliveries}
+	if record.Endpoint != nil {
+		snapshot.Endpoints = []endpointRecord{*record.Endpoint}
+	}
+	s.memory.restore(snapshot)
+
+	if record.DeletedEndpoint != "" {
+		endpoint, err := s.memory.Endpoint(ctx, record.DeletedEndpoint)
+		if err == nil {
+			err = s.memory.DeleteEndpoint(ctx, endpoint.UserID, endpoint.ID)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+
+	if record.UpdatedDelivery != nil {
+		delivery := record.UpdatedDelivery.Delivery
+		delivery.UserID = record.UpdatedDelivery.UserID
+		if err := s.memory.UpdateDelivery(ctx, delivery); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+
+	if record.PrunedBefore != nil {
+		return s.memory.PruneDeliveries(ctx, *record.PrunedBefore)
+	}
+
+	return nil
+}
+
+// compact заменяет журнал записями текущих вебхуков и доставок через временный файл,
+// поэтому при аварийном завершении остается прежний или новый журнал.
+// Вызывается под мьютексом.
+func (s *FileStore) compact() error {
+	snapshot := s.memory.snapshot()
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range snapshot.Endpoints {
+		if err := encoder.Encode(storeRecord{Endpoint: &snapshot.Endpoints[i]}); err != nil {
+			return err
+		}
+	}
+	for i := range snapshot.Deliveries {
+		if err := encoder.Encode(storeRecord{Deliveries: snapshot.Deliveries[i : i+1]}); err != nil {
+			return err
+		}
+	}
+
+	tmpPath := s.path + storeCompactSuffix
+	tmpFile, err := s.fs.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmpFile.Write(buf.Bytes()); err == nil {
+		err = tmpFile.Sync()
+	}
+	if err == nil {
+		err = s.fs.Rename(tmpPath, s.path)
+	}
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	// открытый файл после переименования указывает на новый журнал
+	s.file.Close()
+	s.file = tmpFile
+	s.size = int64(buf.Len())
+
+	return nil
+}
+
+// truncate обрезает журнал до size байт и переносит позицию записи в его конец
+func (s *FileStore) truncate(size int64) error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() != size {
+		if err = s.file.Truncate(size); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.file.Seek(size, io.SeekStart)
+
+	return err
+}
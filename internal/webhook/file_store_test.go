@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorePersistence(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	path := "storage.json" + storeSuffix
+
+	store, err := NewFileStore(fs, path)
+	require.NoError(t, err)
+
+	endpoint := createEndpoint(t, store, 1, "https://receiver.example/hook", EventLinkCreated)
+	deleted := createEndpoint(t, store, 1, "https://receiver.example/deleted", EventLinkDeleted)
+	require.NoError(t, store.DeleteEndpoint(ctx, 1, deleted.ID))
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	require.NoError(t, store.CreateDeliveries(ctx,
+		Delivery{ID: "first", EndpointID: endpoint.ID, UserID: 1, Status: DeliveryPending, NextAttemptAt: now, CreatedAt: now},
+		Delivery{ID: "second", EndpointID: endpoint.ID, UserID: 1, Status: DeliveryPending, NextAttemptAt: now, CreatedAt: now},
+	))
+	require.NoError(t, store.UpdateDelivery(ctx, Delivery{
+		ID: "first", EndpointID: endpoint.ID, UserID: 1, Status: DeliveryDelivered, Attempts: 1,
+		NextAttemptAt: now, LastStatusCode: 200, CreatedAt: now, UpdatedAt: now,
+	}))
+	require.NoError(t, store.Close())
+
+	reopened, err := NewFileStore(fs, path)
+	require.NoError(t, err)
+
+	endpoints, err := reopened.UserEndpoints(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	assert.Equal(t, endpoint, endpoints[0])
+
+	deliveries, err := reopened.UserDeliveries(ctx, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"second", "first"}, deliveryIDs(deliveries))
+	assert.Equal(t, 1, deliveries[0].UserID)
+	assert.Equal(t, DeliveryDelivered, deliveries[1].Status)
+	assert.Equal(t, 200, deliveries[1].LastStatusCode)
+
+	// прерванная доставка повторяется после перезапуска
+	claimed, err := reopened.ClaimDueDeliveries(ctx, now, time.Minute, claimBatchSize)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"second"}, deliveryIDs(claimed))
+}
+
+func TestFileStorePruneDeliveries(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	store, err := NewFileStore(fs, "storage.json"+storeSuffix)
+	require.NoError(t, err)
+
+	requirePruneDeliveries(t, store, 1)
+
+	reopened, err := NewFileStore(fs, "storage.json"+storeSuffix)
+	require.NoError(t, err)
+
+	deliveries, err := reopened.UserDeliveries(context.Background(), 1, 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1-old-pending", "1-recent-delivered"}, deliveryIDs(deliveries))
+}
+
+func TestFileStoreJournal(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	path := "storage.json" + storeSuffix
+
+	store, err := NewFileStore(fs, path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	endpoint := createEndpoint(t, store, 1, "https://receiver.example/hook", EventLinkCreated)
+	before, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+
+	// новая доставка дописывается в конец журнала, не перезаписывая его
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	require.NoError(t, store.CreateDeliveries(ctx, Delivery{ID: "first", EndpointID: endpoint.ID, UserID: 1, Status: DeliveryPending, NextAttemptAt: now, CreatedAt: now}))
+	after, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after[:len(before)])
+	assert.Equal(t, 2, bytes.Count(after, []byte("\n")))
+
+	// после удаления завершенных доставок журнал заменяется текущим состоянием
+	requirePruneDeliveries(t, store, 2)
+	compacted, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+	assert.Equal(t, 4, bytes.Count(compacted, []byte("\n")), "endpoint and three kept deliveries")
+
+	// запись после замены журнала попадает в новый файл
+	require.NoError(t, store.DeleteEndpoint(ctx, 1, endpoint.ID))
+
+	reopened, err := NewFileStore(fs, path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	endpoints, err := reopened.UserEndpoints(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, endpoints)
+
+	deliveries, err := reopened.UserDeliveries(ctx, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first"}, deliveryIDs(deliveries))
+
+	deliveries, err = reopened.UserDeliveries(ctx, 2, 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"2-old-pending", "2-recent-delivered"}, deliveryIDs(deliveries))
+}
+
+var errSyncFailed = errors.New("sync failed")
+
+// failingSyncFs файловая система в памяти, файлы которой не сбрасываются на диск при failSync
+type failingSyncFs struct {
+	afero.Fs
+	failSync bool
+}
+
+func (fs *failingSyncFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	file, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failingSyncFile{File: file, fs: fs}, nil
+}
+
+type failingSyncFile struct {
+	afero.File
+	fs *failingSyncFs
+}
+
+func (f *failingSyncFile) Sync() error {
+	if f.fs.failSync {
+		return errSyncFailed
+	}
+
+	return f.File.Sync()
+}
+
+func TestFileStoreFailedWrite(t *testing.T) {
+	ctx := context.Background()
+	fs := &failingSyncFs{Fs: afero.NewMemMapFs()}
+	path := "storage.json" + storeSuffix
+
+	store, err := NewFileStore(fs, path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	kept := createEndpoint(t, store, 1, "https://receiver.example/kept", EventLinkCreated)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	delivery := Delivery{ID: "first", EndpointID: kept.ID, UserID: 1, Status: DeliveryPending, NextAttemptAt: now, CreatedAt: now}
+	require.NoError(t, store.CreateDeliveries(ctx, delivery))
+
+	// неудачная запись не изменяет хранилище
+	fs.failSync = true
+	endpoint, err := NewEndpoint(1, "https://receiver.example/lost", []EventType{EventLinkCreated})
+	require.NoError(t, err)
+	require.ErrorIs(t, store.CreateEndpoint(ctx, endpoint), errSyncFailed)
+	require.ErrorIs(t, store.DeleteEndpoint(ctx, 1, kept.ID), errSyncFailed)
+	require.ErrorIs(t, store.CreateDeliveries(ctx, Delivery{ID: "lost", EndpointID: kept.ID, UserID: 1, Status: DeliveryPending, NextAttemptAt: now, CreatedAt: now}), errSyncFailed)
+
+	delivered := delivery
+	delivered.Status, delivered.UpdatedAt = DeliveryDelivered, now
+	require.ErrorIs(t, store.UpdateDelivery(ctx, delivered), errSyncFailed)
+	fs.failSync = false
+
+	endpoints, err := store.UserEndpoints(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{kept}, endpoints)
+
+	deliveries, err := store.UserDeliveries(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"first"}, deliveryIDs(deliveries))
+	assert.Equal(t, DeliveryPending, deliveries[0].Status)
+
+	// журнал обрезан до последней удачной записи
+	require.NoError(t, store.UpdateDelivery(ctx, delivered))
+
+	reopened, err := NewFileStore(fs, path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	endpoints, err = reopened.UserEndpoints(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{kept}, endpoints)
+
+	deliveries, err = reopened.UserDeliveries(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"first"}, deliveryIDs(deliveries))
+	assert.Equal(t, DeliveryDelivered, deliveries[0].Status)
+}
@@ -0,0 +1,384 @@
+package webhook
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisStoreKeyPrefix префикс ключей хранилища вебхуков в Redis
+	redisStoreKeyPrefix = "shortener:webhooks:"
+	// redisEndpointsKey хеш ID вебхука -> вебхук в JSON
+	redisEndpointsKey = redisStoreKeyPrefix + "endpoints"
+	// redisUserEndpointsKeyPrefix префикс множеств ID вебхуков пользователя
+	redisUserEndpointsKeyPrefix = redisStoreKeyPrefix + "user_endpoints:"
+	// redisDeliveriesKey хеш ID доставки -> доставка в JSON
+	redisDeliveriesKey = redisStoreKeyPrefix + "deliveries"
+	// redisPendingKey упорядоченное множество ожидающих доставок (score - время попытки в миллисекундах Unix)
+	redisPendingKey = redisStoreKeyPrefix + "pending"
+	// redisFinishedKey упорядоченное множество завершенных доставок (score - время обновления в миллисекундах Unix)
+	redisFinishedKey = redisStoreKeyPrefix + "finished"
+	// redisUserDeliveriesKeyPrefix префикс упорядоченных множеств доставок пользователя (score - время создания)
+	redisUserDeliveriesKeyPrefix = redisStoreKeyPrefix + "user_deliveries:"
+)
+
+// redisPruneBatchSize количество доставок, удаляемых PruneDeliveries за одну транзакцию
+const redisPruneBatchSize = 1000
+
+// redisCreateEndpointScript сохраняет вебхук ARGV[2] с ID ARGV[1], если в множестве KEYS[2]
+// меньше ARGV[3] вебхуков. Возвращает 0, если предел достигнут.
+var redisCreateEndpointScript = redis.NewScript(`
+if redis.call('SCARD', KEYS[2]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('SADD', KEYS[2], ARGV[1])
+return 1
+`)
+
+// redisDeleteEndpointScript удаляет вебхук ARGV[1], если он есть в множестве KEYS[2].
+// Возвращает 0, если вебхук не найден.
+var redisDeleteEndpointScript = redis.NewScript(`
+if redis.call('SREM', KEYS[2], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+return 1
+`)
+
+// redisClaimScript откладывает до ARGV[2] не более ARGV[3] ожидающих доставок со временем попытки
+// не позже ARGV[1] и возвращает их. Скрипт выполняется атомарно, поэтому несколько экземпляров
+// сервиса не забирают одну доставку.
+var redisClaimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[3]))
+if #ids == 0 then
+	return {}
+end
+for _, id in ipairs(ids) do
+	redis.call('ZADD', KEYS[1], ARGV[2], id)
+end
+return redis.call('HMGET', KEYS[2], unpack(ids))
+`)
+
+// redisUpdateDeliveryScript заменяет доставку ARGV[1] ее JSON ARGV[2] и переносит ее в множество
+// ожидающих (ARGV[3] = 1, score ARGV[4] - время попытки) или завершенных доставок (score - время обновления).
+// Возвращает 0, если доставка не найдена.
+var redisUpdateDeliveryScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if ARGV[3] == '1' then
+	redis.call('ZREM', KEYS[3], ARGV[1])
+	redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1])
+else
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	redis.call('ZADD', KEYS[3], ARGV[4], ARGV[1])
+end
+return 1
+`)
+
+// RedisStore хранилище вебхуков в Redis.
+// Используется с хранилищем ссылок в Redis, чтобы вебхуки и доставки были общими
+// для экземпляров сервиса и переживали их перезапуск вместе с хранилищем.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore подключается к Redis по адресу addr (host:port или redis:// URL)
+func NewRedisStore(ctx context.Context, addr string) (*RedisStore, error) {
+	options := &redis.Options{Addr: addr}
+	if strings.Contains(addr, "://") {
+		var err error
+		if options, err = redis.ParseURL(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisStore{client: client}, nil
+}
+
+// CreateEndpoint сохраняет вебхук, если у пользователя меньше MaxUserEndpoints вебхуков
+func (s *RedisStore) CreateEndpoint(ctx context.Context, endpoint Endpoint) error {
+	data, err := json.Marshal(endpointRecord{Endpoint: endpoint, UserID: endpoint.UserID})
+	if err != nil {
+		return err
+	}
+
+	keys := []string{redisEndpointsKey, redisUserEndpointsKey(endpoint.UserID)}
+	created, err := redisCreateEndpointScript.Run(ctx, s.client, keys, endpoint.ID, data, MaxUserEndpoints).Int()
+	if err != nil {
+		return err
+	}
+
+	if created == 0 {
+		return ErrTooManyEndpoints
+	}
+
+	return nil
+}
+
+// Endpoint возвращает вебхук по ID
+func (s *RedisStore) Endpoint(ctx context.Context, endpointID string) (Endpoint, error) {
+	data, err := s.client.HGet(ctx, redisEndpointsKey, endpointID).Result()
+	if errors.Is(err, redis.Nil) {
+		return Endpoint{}, ErrNotFound
+	}
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	return unmarshalEndpoint(data)
+}
+
+// UserEndpoints возвращает вебхуки пользователя в порядке создания
+func (s *RedisStore) UserEndpoints(ctx context.Context, userID int) ([]Endpoint, error) {
+	ids, err := s.client.SMembers(ctx, redisUserEndpointsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]Endpoint, 0, len(ids))
+	if len(ids) == 0 {
+		return endpoints, nil
+	}
+
+	values, err := s.client.HMGet(ctx, redisEndpointsKey, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		endpoint, err := unmarshalEndpoint(data)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	slices.SortFunc(endpoints, func(a, b Endpoint) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return endpoints, nil
+}
+
+// DeleteEndpoint удаляет вебхук пользователя
+func (s *RedisStore) DeleteEndpoint(ctx context.Context, userID int, endpointID string) error {
+	keys := []string{redisEndpointsKey, redisUserEndpointsKey(userID)}
+	deleted, err := redisDeleteEndpointScript.Run(ctx, s.client, keys, endpointID).Int()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateDeliveries сохраняет новые доставки в одной транзакции
+func (s *RedisStore) CreateDeliveries(ctx context.Context, deliveries ...Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	values := make(map[string]any, len(deliveries))
+	for _, delivery := range deliveries {
+		data, err := json.Marshal(deliveryRecord{Delivery: delivery, UserID: delivery.UserID})
+		if err != nil {
+			return err
+		}
+		values[delivery.ID] = data
+	}
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisDeliveriesKey, values)
+		for _, delivery := range deliveries {
+			if delivery.Status == DeliveryPending {
+				pipe.ZAdd(ctx, redisPendingKey, redis.Z{Score: float64(delivery.NextAttemptAt.UnixMilli()), Member: delivery.ID})
+			} else {
+				pipe.ZAdd(ctx, redisFinishedKey, redis.Z{Score: float64(delivery.UpdatedAt.UnixMilli()), Member: delivery.ID})
+			}
+			pipe.ZAdd(ctx, redisUserDeliveriesKey(delivery.UserID), redis.Z{Score: float64(delivery.CreatedAt.UnixMilli()), Member: delivery.ID})
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// ClaimDueDeliveries возвращает ожидающие доставки, время попытки которых наступило
+func (s *RedisStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	keys := []string{redisPendingKey, redisDeliveriesKey}
+	values, err := redisClaimScript.Run(ctx, s.client, keys, now.UnixMilli(), now.Add(lease).UnixMilli(), limit).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalDeliveries(values)
+}
+
+// UpdateDelivery сохраняет результат попытки доставки
+func (s *RedisStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	data, err := json.Marshal(deliveryRecord{Delivery: delivery, UserID: delivery.UserID})
+	if err != nil {
+		return err
+	}
+
+	pending, score := "0", delivery.UpdatedAt.UnixMilli()
+	if delivery.Status == DeliveryPending {
+		pending, score = "1", delivery.NextAttemptAt.UnixMilli()
+	}
+
+	keys := []string{redisDeliveriesKey, redisPendingKey, redisFinishedKey}
+	updated, err := redisUpdateDeliveryScript.Run(ctx, s.client, keys, delivery.ID, data, pending, score).Int()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UserDeliveries возвращает последние доставки пользователя, начиная с новых
+func (s *RedisStore) UserDeliveries(ctx context.Context, userID int, limit int) ([]Delivery, error) {
+	if limit < 1 {
+		return nil, ErrInvalidLimit
+	}
+
+	ids, err := s.client.ZRevRange(ctx, redisUserDeliveriesKey(userID), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return make([]Delivery, 0), nil
+	}
+
+	values, err := s.client.HMGet(ctx, redisDeliveriesKey, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalDeliveries(values)
+}
+
+// PruneDeliveries удаляет доставленные и исчерпавшие попытки доставки, обновленные до before.
+// Завершенная доставка больше не изменяется, поэтому удаляется без блокировки.
+func (s *RedisStore) PruneDeliveries(ctx context.Context, before time.Time) error {
+	maxScore := "(" + strconv.FormatInt(before.UnixMilli(), 10)
+	for {
+		ids, err := s.client.ZRangeByScore(ctx, redisFinishedKey, &redis.ZRangeBy{
+			Min: "-inf", Max: maxScore, Count: redisPruneBatchSize,
+		}).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		values, err := s.client.HMGet(ctx, redisDeliveriesKey, ids...).Result()
+		if err != nil {
+			return err
+		}
+
+		deliveries, err := unmarshalDeliveries(values)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, redisDeliveriesKey, ids...)
+			pipe.ZRem(ctx, redisFinishedKey, ids)
+			for _, delivery := range deliveries {
+				pipe.ZRem(ctx, redisUserDeliveriesKey(delivery.UserID), delivery.ID)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(ids) < redisPruneBatchSize {
+			return nil
+		}
+	}
+}
+
+// Close закрывает подключение к Redis
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// redisUserEndpointsKey возвращает ключ множества ID вебхуков пользователя
+func redisUserEndpointsKey(userID int) string {
+	return redisUserEndpointsKeyPrefix + strconv.Itoa(userID)
+}
+
+// redisUserDeliveriesKey возвращает ключ упорядоченного множества доставок пользователя
+func redisUserDeliveriesKey(userID int) string {
+	return redisUserDeliveriesKeyPrefix + strconv.Itoa(userID)
+}
+
+// unmarshalEndpoint читает вебхук из JSON хранилища
+func unmarshalEndpoint(data string) (Endpoint, error) {
+	var record endpointRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return Endpoint{}, err
+	}
+
+	endpoint := record.Endpoint
+	endpoint.UserID = record.UserID
+
+	return endpoint, nil
+}
+
+// unmarshalDeliveries читает доставки из ответа HMGET, пропуская удаленные
+func unmarshalDeliveries(values []any) ([]Delivery, error) {
+	deliveries := make([]Delivery, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var record deliveryRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, err
+		}
+
+		delivery := record.Delivery
+		delivery.UserID = record.UserID
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/config"
+)
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	store, err := NewRedisStore(ctx, server.Addr())
+	require.NoError(t, err)
+
+	endpoint := createEndpoint(t, store, 1, "https://receiver.example/hook", EventLinkCreated, EventLinkDeleted)
+	got, err := store.Endpoint(ctx, endpoint.ID)
+	require.NoError(t, err)
+	assert.Equal(t, endpoint, got)
+
+	_, err = store.Endpoint(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	require.NoError(t, store.CreateDeliveries(ctx,
+		Delivery{ID: "due-1", EndpointID: endpoint.ID, UserID: 1, Status: DeliveryPending, NextAttemptAt: now.Add(-time.Second), CreatedAt: now.Add(-time.Second)},
+		Delivery{ID: "due-2", EndpointID: endpoint.ID, UserID: 1, Status: DeliveryPending, NextAttemptAt: now, CreatedAt: now},
+		Delivery{ID: "later", EndpointID: endpoint.ID, UserID: 1, Status: DeliveryPending, NextAttemptAt: now.Add(time.Minute), CreatedAt: now.Add(time.Second)},
+	))
+
+	claimed, err := store.ClaimDueDeliveries(ctx, now, time.Minute, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"due-1"}, deliveryIDs(claimed))
+	assert.Equal(t, 1, claimed[0].UserID)
+
+	// забранные доставки скрыты до истечения аренды
+	claimed, err = store.ClaimDueDeliveries(ctx, now, time.Minute, claimBatchSize)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"due-2"}, deliveryIDs(claimed))
+
+	delivered := claimed[0]
+	delivered.Status = DeliveryDelivered
+	delivered.Attempts = 1
+	delivered.UpdatedAt = now
+	require.NoError(t, store.UpdateDelivery(ctx, delivered))
+	assert.ErrorIs(t, store.UpdateDelivery(ctx, Delivery{ID: "missing"}), ErrNotFound)
+	require.NoError(t, store.Close())
+
+	// вебхуки и доставки общие для экземпляров сервиса и переживают их перезапуск
+	store, err = NewRedisStore(ctx, server.Addr())
+	require.NoError(t, err)
+	defer store.Close()
+
+	endpoints, err := store.UserEndpoints(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{endpoint}, endpoints)
+
+	deliveries, err := store.UserDeliveries(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"later", "due-2"}, deliveryIDs(deliveries))
+	assert.Equal(t, DeliveryDelivered, deliveries[1].Status)
+
+	_, err = store.UserDeliveries(ctx, 1, 0)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+
+	// доставленная доставка больше не забирается
+	claimed, err = store.ClaimDueDeliveries(ctx, now.Add(time.Minute), time.Minute, claimBatchSize)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"due-1", "later"}, deliveryIDs(claimed))
+
+	// чужой вебхук не удаляется
+	assert.ErrorIs(t, store.DeleteEndpoint(ctx, 2, endpoint.ID), ErrNotFound)
+	require.NoError(t, store.DeleteEndpoint(ctx, 1, endpoint.ID))
+	_, err = store.Endpoint(ctx, endpoint.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	requireEndpointLimit(t, store, 2)
+	requirePruneDeliveries(t, store, 3)
+}
+
+func TestNewAppStoreRedisStorage(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	store, err := NewAppStore(context.Background(), config.NewConfig(config.WithStorageLocation(config.Redis, server.Addr())), nil)
+	require.NoError(t, err)
+	defer store.Close()
+
+	assert.IsType(t, &RedisStore{}, store)
+}
@@ -0,0 +1,322 @@
+package webhook
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/afero"
+
+	"github.com/rovany706/url-shortener/internal/config"
+)
+
+// Store интерфейс хранилища вебхуков и их доставок
+type Store interface {
+	// CreateEndpoint сохраняет вебхук или возвращает ErrTooManyEndpoints,
+	// если у пользователя уже MaxUserEndpoints вебхуков
+	CreateEndpoint(ctx context.Context, endpoint Endpoint) error
+	// Endpoint возвращает вебхук по ID или ErrNotFound
+	Endpoint(ctx context.Context, endpointID string) (Endpoint, error)
+	// UserEndpoints возвращает вебхуки пользователя в порядке создания
+	UserEndpoints(ctx context.Context, userID int) ([]Endpoint, error)
+	// DeleteEndpoint удаляет вебхук пользователя или возвращает ErrNotFound
+	DeleteEndpoint(ctx context.Context, userID int, endpointID string) error
+	// CreateDeliveries сохраняет новые доставки
+	CreateDeliveries(ctx context.Context, deliveries ...Delivery) error
+	// ClaimDueDeliveries возвращает не более limit ожидающих доставок со временем попытки не позже now
+	// и откладывает их следующую попытку на lease, чтобы их не взял другой обработчик
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	// UpdateDelivery сохраняет результат попытки доставки
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+	// UserDeliveries возвращает не более limit последних доставок пользователя, начиная с новых
+	UserDeliveries(ctx context.Context, userID int, limit int) ([]Delivery, error)
+	// PruneDeliveries удаляет доставленные и исчерпавшие попытки доставки, обновленные до before
+	PruneDeliveries(ctx context.Context, before time.Time) error
+	// Close завершает работу с хранилищем
+	Close() error
+}
+
+// storeSuffix суффикс файла хранилища вебхуков рядом с файлом хранилища ссылок
+const storeSuffix = ".webhooks"
+
+// NewAppStore создает хранилище вебхуков рядом с хранилищем ссылок: в БД пула pool хранилища
+// в Postgres (первого шарда при шардировании), в Redis, в файле рядом с файловым хранилищем, иначе в памяти
+func NewAppStore(ctx context.Context, appConfig *config.AppConfig, pool *pgxpool.Pool) (Store, error) {
+	if pool != nil {
+		return NewDatabaseStore(pool), nil
+	}
+
+	if appConfig.StorageType == config.Redis {
+		return NewRedisStore(ctx, appConfig.RedisAddr)
+	}
+
+	if path := storePath(appConfig); path != "" {
+		return NewFileStore(afero.NewOsFs(), path)
+	}
+
+	return NewMemoryStore(), nil
+}
+
+// storePath возвращает путь файла хранилища вебхуков или пустую строку для хранилищ без файла
+func storePath(appConfig *config.AppConfig) string {
+	switch appConfig.StorageType {
+	case config.File:
+		return appConfig.FileStoragePath + storeSuffix
+	case config.SQLite:
+		return appConfig.SQLitePath + storeSuffix
+	case config.Bolt:
+		return appConfig.BoltPath + storeSuffix
+	default:
+		return ""
+	}
+}
+
+// MemoryStore хранилище вебхуков в памяти.
+// Используется также как индекс FileStore.
+type MemoryStore struct {
+	mutex      sync.Mutex
+	endpoints  map[string]Endpoint
+	deliveries map[string]Delivery
+	// userDeliveries ID доставок пользователя в порядке создания
+	userDeliveries map[int][]string
+}
+
+// NewMemoryStore создает MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		endpoints:      make(map[string]Endpoint),
+		deliveries:     make(map[string]Delivery),
+		userDeliveries: make(map[int][]string),
+	}
+}
+
+// CreateEndpoint сохраняет вебхук, если у пользователя меньше MaxUserEndpoints вебхуков
+func (s *MemoryStore) CreateEndpoint(ctx context.Context, endpoint Endpoint) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	userEndpoints := 0
+	for _, existing := range s.endpoints {
+		if existing.UserID == endpoint.UserID {
+			userEndpoints++
+		}
+	}
+	if userEndpoints >= MaxUserEndpoints {
+		return ErrTooManyEndpoints
+	}
+
+	endpoint.Events = slices.Clone(endpoint.Events)
+	s.endpoints[endpoint.ID] = endpoint
+
+	return nil
+}
+
+// Endpoint возвращает вебхук по ID
+func (s *MemoryStore) Endpoint(ctx context.Context, endpointID string) (Endpoint, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	endpoint, ok := s.endpoints[endpointID]
+	if !ok {
+		return Endpoint{}, ErrNotFound
+	}
+
+	return endpoint, nil
+}
+
+// UserEndpoints возвращает вебхуки пользователя в порядке создания
+func (s *MemoryStore) UserEndpoints(ctx context.Context, userID int) ([]Endpoint, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	endpoints := make([]Endpoint, 0)
+	for _, endpoint := range s.endpoints {
+		if endpoint.UserID == userID {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	slices.SortFunc(endpoints, func(a, b Endpoint) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return endpoints, nil
+}
+
+// DeleteEndpoint удаляет вебхук пользователя
+func (s *MemoryStore) DeleteEndpoint(ctx context.Context, userID int, endpointID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	endpoint, ok := s.endpoints[endpointID]
+	if !ok || endpoint.UserID != userID {
+		return ErrNotFound
+	}
+
+	delete(s.endpoints, endpointID)
+
+	return nil
+}
+
+// CreateDeliveries сохраняет новые доставки
+func (s *MemoryStore) CreateDeliveries(ctx context.Context, deliveries ...Delivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, delivery := range deliveries {
+		s.deliveries[delivery.ID] = delivery
+		s.userDeliveries[delivery.UserID] = append(s.userDeliveries[delivery.UserID], delivery.ID)
+	}
+
+	return nil
+}
+
+// ClaimDueDeliveries возвращает ожидающие доставки, время попытки которых наступило
+func (s *MemoryStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	due := make([]Delivery, 0)
+	for _, delivery := range s.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+
+	slices.SortFunc(due, func(a, b Delivery) int {
+		return cmp.Or(a.NextAttemptAt.Compare(b.NextAttemptAt), cmp.Compare(a.ID, b.ID))
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i := range due {
+		claimed := s.deliveries[due[i].ID]
+		claimed.NextAttemptAt = now.Add(lease)
+		s.deliveries[due[i].ID] = claimed
+	}
+
+	return due, nil
+}
+
+// UpdateDelivery сохраняет результат попытки доставки
+func (s *MemoryStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.deliveries[delivery.ID]; !ok {
+		return ErrNotFound
+	}
+	s.deliveries[delivery.ID] = delivery
+
+	return nil
+}
+
+// UserDeliveries возвращает последние доставки пользователя, начиная с новых
+func (s *MemoryStore) UserDeliveries(ctx context.Context, userID int, limit int) ([]Delivery, error) {
+	if limit < 1 {
+		return nil, ErrInvalidLimit
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ids := s.userDeliveries[userID]
+	deliveries := make([]Delivery, 0, min(limit, len(ids)))
+	for i := len(ids) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		deliveries = append(deliveries, s.deliveries[ids[i]])
+	}
+
+	return deliveries, nil
+}
+
+// PruneDeliveries удаляет доставленные и исчерпавшие попытки доставки, обновленные до before
+func (s *MemoryStore) PruneDeliveries(ctx context.Context, before time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for userID, ids := range s.userDeliveries {
+		ids = slices.DeleteFunc(ids, func(id string) bool {
+			delivery := s.deliveries[id]
+			if delivery.Status == DeliveryPending || !delivery.UpdatedAt.Before(before) {
+				return false
+			}
+
+			delete(s.deliveries, id)
+			return true
+		})
+
+		if len(ids) == 0 {
+			delete(s.userDeliveries, userID)
+		} else {
+			s.userDeliveries[userID] = ids
+		}
+	}
+
+	return nil
+}
+
+// Close ничего не делает
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// hasDelivery проверяет, сохранена ли доставка
+func (s *MemoryStore) hasDelivery(deliveryID string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.deliveries[deliveryID]
+
+	return ok
+}
+
+// snapshot возвращает вебхуки и доставки хранилища
+func (s *MemoryStore) snapshot() storeSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot := storeSnapshot{
+		Endpoints:  make([]endpointRecord, 0, len(s.endpoints)),
+		Deliveries: make([]deliveryRecord, 0, len(s.deliveries)),
+	}
+
+	for _, endpoint := range s.endpoints {
+		snapshot.Endpoints = append(snapshot.Endpoints, endpointRecord{Endpoint: endpoint, UserID: endpoint.UserID})
+	}
+	slices.SortFunc(snapshot.Endpoints, func(a, b endpointRecord) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	for _, userID := range slices.Sorted(maps.Keys(s.userDeliveries)) {
+		for _, id := range s.userDeliveries[userID] {
+			delivery := s.deliveries[id]
+			snapshot.Deliveries = append(snapshot.Deliveries, deliveryRecord{Delivery: delivery, UserID: delivery.UserID})
+		}
+	}
+
+	return snapshot
+}
+
+// restore добавляет в хранилище вебхуки и доставки снимка
+func (s *MemoryStore) restore(snapshot storeSnapshot) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, record := range snapshot.Endpoints {
+		endpoint := record.Endpoint
+		endpoint.UserID = record.UserID
+		s.endpoints[endpoint.ID] = endpoint
+	}
+
+	for _, record := range snapshot.Deliveries {
+		delivery := record.Delivery
+		delivery.UserID = record.UserID
+		s.deliveries[delivery.ID] = delivery
+		s.userDeliveries[delivery.UserID] = append(s.userDeliveries[delivery.UserID], delivery.ID)
+	}
+}
@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rovany706/url-shortener/internal/config"
+)
+
+func TestMemoryStoreClaimDueDeliveries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+
+	require.NoError(t, store.CreateDeliveries(ctx,
+		Delivery{ID: "due-1", UserID: 1, Status: DeliveryPending, NextAttemptAt: now.Add(-time.Second)},
+		Delivery{ID: "due-2", UserID: 1, Status: DeliveryPending, NextAttemptAt: now},
+		Delivery{ID: "later", UserID: 1, Status: DeliveryPending, NextAttemptAt: now.Add(time.Minute)},
+		Delivery{ID: "dead", UserID: 1, Status: DeliveryDead, NextAttemptAt: now.Add(-time.Second)},
+	))
+
+	claimed, err := store.ClaimDueDeliveries(ctx, now, time.Minute, 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "due-1", claimed[0].ID)
+
+	claimed, err = store.ClaimDueDeliveries(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "due-2", claimed[0].ID)
+
+	// забранные доставки снова возвращаются после истечения аренды
+	claimed, err = store.ClaimDueDeliveries(ctx, now.Add(time.Minute), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Len(t, claimed, 3)
+
+	deliveries, err := store.UserDeliveries(ctx, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, "dead", deliveries[0].ID)
+	assert.Equal(t, "later", deliveries[1].ID)
+
+	_, err = store.UserDeliveries(ctx, 1, 0)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+}
+
+func TestMemoryStoreEndpointLimit(t *testing.T) {
+	requireEndpointLimit(t, NewMemoryStore(), 1)
+}
+
+// requireEndpointLimit проверяет, что хранилище не сохраняет больше MaxUserEndpoints вебхуков пользователя userID
+func requireEndpointLimit(t *testing.T, store Store, userID int) {
+	ctx := context.Background()
+	for range MaxUserEndpoints {
+		createEndpoint(t, store, userID, "https://receiver.example/hook", EventLinkCreated)
+	}
+
+	endpoint, err := NewEndpoint(userID, "https://receiver.example/hook", []EventType{EventLinkCreated})
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.CreateEndpoint(ctx, endpoint), ErrTooManyEndpoints)
+
+	// предел действует для каждого пользователя отдельно
+	createEndpoint(t, store, userID+1, "https://receiver.example/hook", EventLinkCreated)
+
+	// после удаления вебхука можно создать новый
+	endpoints, err := store.UserEndpoints(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, endpoints, MaxUserEndpoints)
+	require.NoError(t, store.DeleteEndpoint(ctx, userID, endpoints[0].ID))
+	require.NoError(t, store.CreateEndpoint(ctx, endpoint))
+}
+
+func TestMemoryStoreDeleteEndpoint(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	endpoint := createEndpoint(t, store, 1, "https://receiver.example/hook", EventLinkCreated)
+
+	// чужой вебхук не удаляется
+	assert.ErrorIs(t, store.DeleteEndpoint(ctx, 2, endpoint.ID), ErrNotFound)
+	require.NoError(t, store.DeleteEndpoint(ctx, 1, endpoint.ID))
+	assert.ErrorIs(t, store.DeleteEndpoint(ctx, 1, endpoint.ID), ErrNotFound)
+
+	_, err := store.Endpoint(ctx, endpoint.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStorePruneDeliveries(t *testing.T) {
+	requirePruneDeliveries(t, NewMemoryStore(), 1)
+}
+
+// requirePruneDeliveries проверяет, что хранилище удаляет только завершенные доставки, обновленные до срока
+func requirePruneDeliveries(t *testing.T, store Store, userID int) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	old := now.Add(-time.Hour)
+
+	require.NoError(t, store.CreateDeliveries(ctx,
+		Delivery{ID: fmt.Sprint(userID, "-old-delivered"), UserID: userID, Status: DeliveryPending, NextAttemptAt: old, CreatedAt: old},
+		Delivery{ID: fmt.Sprint(userID, "-old-dead"), UserID: userID, Status: DeliveryPending, NextAttemptAt: old, CreatedAt: old},
+		Delivery{ID: fmt.Sprint(userID, "-old-pending"), UserID: userID, Status: DeliveryPending, NextAttemptAt: old, CreatedAt: old},
+		Delivery{ID: fmt.Sprint(userID, "-recent-delivered"), UserID: userID, Status: DeliveryPending, NextAttemptAt: now, CreatedAt: now},
+	))
+
+	for id, update := range map[string]struct {
+		status    DeliveryStatus
+		updatedAt time.Time
+	}{
+		"-old-delivered":    {DeliveryDelivered, old},
+		"-old-dead":         {DeliveryDead, old},
+		"-old-pending":      {DeliveryPending, old},
+		"-recent-delivered": {DeliveryDelivered, now},
+	} {
+		require.NoError(t, store.UpdateDelivery(ctx, Delivery{
+			ID: fmt.Sprint(userID, id), UserID: userID, Status: update.status, Attempts: 1,
+			NextAttemptAt: update.updatedAt, CreatedAt: update.updatedAt, UpdatedAt: update.updatedAt,
+		}))
+	}
+
+	require.NoError(t, store.PruneDeliveries(ctx, now.Add(-time.Minute)))
+
+	deliveries, err := store.UserDeliveries(ctx, userID, 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{fmt.Sprint(userID, "-old-pending"), fmt.Sprint(userID, "-recent-delivered")}, deliveryIDs(deliveries))
+}
+
+func TestStorePath(t *testing.T) {
+	tests := []struct {
+		name      string
+		appConfig *config.AppConfig
+		want      string
+	}{
+		{"file storage", config.NewConfig(config.WithStorageLocation(config.File, "storage.json")), "storage.json" + storeSuffix},
+		{"sqlite storage", config.NewConfig(config.WithStorageLocation(config.SQLite, "storage.db")), "storage.db" + storeSuffix},
+		{"bolt storage", config.NewConfig(config.WithStorageLocation(config.Bolt, "storage.bolt")), "storage.bolt" + storeSuffix},
+		{"memory storage", config.NewConfig(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, storePath(tt.appConfig))
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// EventType тип события жизненного цикла ссылки
+type EventType string
+
+// Перечисление событий
+const (
+	// EventLinkCreated ссылка сокращена
+	EventLinkCreated EventType = "link.created"
+	// EventLinkDeleted ссылка помечена удаленной
+	EventLinkDeleted EventType = "link.deleted"
+	// EventLinkClicked переход по ссылке (отправляется для доли переходов)
+	EventLinkClicked EventType = "link.clicked"
+)
+
+// EventTypes все типы событий. У ссылок нет срока действия, поэтому подписка
+// на события истечения срока (link.expired) отклоняется как на неизвестный тип.
+var EventTypes = []EventType{EventLinkCreated, EventLinkDeleted, EventLinkClicked}
+
+// Заголовки запроса доставки события
+const (
+	// SignatureHeader подпись запроса: sha256=<hex HMAC-SHA256 секрета от "<timestamp>.<тело>">
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader время отправки запроса в секундах Unix, входит в подпись
+	TimestampHeader = "X-Webhook-Timestamp"
+	// EventHeader тип события
+	EventHeader = "X-Webhook-Event"
+	// DeliveryHeader ID доставки; повторные попытки отправляются с тем же ID
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// DefaultUserDeliveriesLimit количество доставок пользователя, возвращаемых по умолчанию
+const DefaultUserDeliveriesLimit = 100
+
+// MaxUserEndpoints наибольшее количество вебхуков пользователя
+const MaxUserEndpoints = 10
+
+// signaturePrefix префикс значения заголовка подписи
+const signaturePrefix = "sha256="
+
+// Ошибки
+var (
+	// ErrNotFound ошибка отсутствия вебхука
+	ErrNotFound = errors.New("webhook not found")
+	// ErrInvalidEndpoint ошибка адреса вебхука или набора событий
+	ErrInvalidEndpoint = errors.New("invalid webhook endpoint")
+	// ErrInvalidLimit ошибка неположительного количества запрашиваемых доставок
+	ErrInvalidLimit = errors.New("invalid webhook deliveries limit")
+	// ErrTooManyEndpoints ошибка превышения MaxUserEndpoints вебхуков пользователя
+	ErrTooManyEndpoints = errors.New("too many webhook endpoints")
+	// ErrForbiddenAddress ошибка подключения к адресу частной, локальной или зарезервированной сети
+	ErrForbiddenAddress = errors.New("webhook address is not public")
+)
+
+// Endpoint адрес пользователя, на который отправляются события его ссылок
+type Endpoint struct {
+	ID     string `json:"id"`
+	UserID int    `json:"-"`
+	URL    string `json:"url"`
+	// Secret ключ подписи запросов; возвращается пользователю только при создании
+	Secret    string      `json:"secret,omitempty"`
+	Events    []EventType `json:"events"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Subscribed проверяет, подписан ли вебхук на события типа eventType
+func (e Endpoint) Subscribed(eventType EventType) bool {
+	return slices.Contains(e.Events, eventType)
+}
+
+// NewEndpoint создает вебхук пользователя userID с новым ID и секретом.
+// Возвращает ErrInvalidEndpoint, если адрес не является абсолютным http(s) URL
+// или набор событий пуст либо содержит неизвестные типы.
+func NewEndpoint(userID int, endpointURL string, events []EventType) (Endpoint, error) {
+	u, err := url.ParseRequestURI(endpointURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Endpoint{}, ErrInvalidEndpoint
+	}
+
+	if len(events) == 0 {
+		return Endpoint{}, ErrInvalidEndpoint
+	}
+	for _, eventType := range events {
+		if !slices.Contains(EventTypes, eventType) {
+			return Endpoint{}, ErrInvalidEndpoint
+		}
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	return Endpoint{
+		ID:        id,
+		UserID:    userID,
+		URL:       endpointURL,
+		Secret:    secret,
+		Events:    slices.Compact(slices.Sorted(slices.Values(events))),
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// Event событие жизненного цикла ссылки пользователя
+type Event struct {
+	Type EventType `json:"type"`
+	// Time время события (UTC)
+	Time time.Time `json:"time"`
+	// UserID ID владельца ссылки
+	UserID      int    `json:"user_id"`
+	ShortID     string `json:"short_id"`
+	OriginalURL string `json:"original_url"`
+}
+
+// DeliveryStatus состояние доставки события
+type DeliveryStatus string
+
+// Перечисление состояний доставки
+const (
+	// DeliveryPending доставка ожидает очередной попытки
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered получатель ответил кодом 2xx
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead попытки исчерпаны, доставка больше не повторяется
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// Delivery доставка события на вебхук
+type Delivery struct {
+	ID         string         `json:"id"`
+	EndpointID string         `json:"endpoint_id"`
+	UserID     int            `json:"-"`
+	Event      Event          `json:"event"`
+	Status     DeliveryStatus `json:"status"`
+	// Attempts количество выполненных попыток
+	Attempts int `json:"attempts"`
+	// NextAttemptAt время следующей попытки для ожидающей доставки
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// LastStatusCode код ответа последней попытки (0 - ответ не получен)
+	LastStatusCode int `json:"last_status_code,omitempty"`
+	// LastError ошибка последней попытки
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Publisher интерфейс публикации событий ссылок на вебхуки их владельцев
+type Publisher interface {
+	Publish(ctx context.Context, events ...Event) error
+}
+
+// NopPublisher отбрасывает события
+type NopPublisher struct{}
+
+// Publish ничего не делает
+func (NopPublisher) Publish(ctx context.Context, events ...Event) error {
+	return nil
+}
+
+// Sign возвращает значение заголовка SignatureHeader для тела body, отправленного в момент timestamp
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись запроса доставки: значение signature заголовка SignatureHeader
+// для тела body и заголовка TimestampHeader timestamp
+func Verify(secret string, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// unixTimestamp возвращает значение заголовка TimestampHeader
+func unixTimestamp(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// randomHex возвращает n случайных байт в шестнадцатеричном виде
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		events     []EventType
+		wantEvents []EventType
+		wantErr    error
+	}{
+		{
+			"events are sorted and deduplicated",
+			"https://receiver.example/hook",
+			[]EventType{EventLinkDeleted, EventLinkCreated, EventLinkDeleted},
+			[]EventType{EventLinkCreated, EventLinkDeleted},
+			nil,
+		},
+		{
+			"relative url",
+			"/hook",
+			[]EventType{EventLinkCreated},
+			nil,
+			ErrInvalidEndpoint,
+		},
+		{
+			"unsupported scheme",
+			"ftp://receiver.example/hook",
+			[]EventType{EventLinkCreated},
+			nil,
+			ErrInvalidEndpoint,
+		},
+		{
+			"no events",
+			"https://receiver.example/hook",
+			nil,
+			nil,
+			ErrInvalidEndpoint,
+		},
+		{
+			"unknown event",
+			"https://receiver.example/hook",
+			[]EventType{EventLinkCreated, "link.renamed"},
+			nil,
+			ErrInvalidEndpoint,
+		},
+		{
+			"link expiry is not supported",
+			"https://receiver.example/hook",
+			[]EventType{"link.expired"},
+			nil,
+			ErrInvalidEndpoint,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, err := NewEndpoint(1, tt.url, tt.events)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEvents, endpoint.Events)
+			assert.Equal(t, 1, endpoint.UserID)
+			assert.NotEmpty(t, endpoint.ID)
+			assert.Len(t, endpoint.Secret, 64)
+		})
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"type":"link.created"}`)
+	signature := Sign("secret", "1700000000", body)
+
+	assert.True(t, Verify("secret", "1700000000", body, signature))
+	assert.False(t, Verify("secret", "1700000001", body, signature))
+	assert.False(t, Verify("secret", "1700000000", []byte(`{"type":"link.deleted"}`), signature))
+	assert.False(t, Verify("other", "1700000000", body, signature))
+}
@@ -2,4 +2,8 @@
 mockgen -source=internal/repository/repository.go -destination=internal/repository/mock/repository.go -package mock Repository
 mockgen -source=internal/database/database.go -destination=internal/database/mock/database.go -package mock Database
 mockgen -source=internal/auth/jwt.go -destination=internal/auth/mock/jwt.go -package mock TokenManager
-mockgen -source=internal/service/delete_service.go -destination=internal/service/mock/delete_service.go -package mock DeleteService
\ No newline at end of file
+mockgen -source=internal/service/delete_service.go -destination=internal/service/mock/delete_service.go -package mock DeleteService
+mockgen -source=internal/service/click_service.go -destination=internal/service/mock/click_service.go -package mock ClickService
+mockgen -source=internal/audit/audit.go -destination=internal/audit/mock/audit.go -package mock Log
+mockgen -source=internal/webhook/webhook.go -destination=internal/webhook/mock/webhook.go -package mock Publisher
+mockgen -source=internal/webhook/store.go -destination=internal/webhook/mock/store.go -package mock Store
\ No newline at end of file